@@ -0,0 +1,5 @@
+package ledgerv1
+
+// The generated code is committed. Regenerating it takes protoc with
+// protoc-gen-go v1.35.1 and protoc-gen-go-grpc v1.5.1 on the PATH.
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative ledger/v1/ledger.proto
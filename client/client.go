@@ -0,0 +1,361 @@
+// Package client is the Go client of the ledger API v1. Its operations and
+// types are generated from the OpenAPI document of the server into
+// operations.go; this file holds what they share. The client logs in and
+// renews its token by itself, sends mutations with an Idempotency-Key and
+// retries requests that failed in ways a retry can fix, and reports the
+// problems the API answers with as *Error.
+//
+//	c := client.New("https://ledger.example.com", client.Options{Email: email, Password: password})
+//	_, err := c.CreateTransfer(ctx, client.TransferRequest{SenderID: 1, ReceiverID: 2, Amount: 10})
+//	if errors.Is(err, apperr.ErrInsufficientFunds) {
+//		...
+//	}
+package client
+
+import (
+	"Ledger/pkg/apperr"
+	"bytes"
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxAttempts = 4
+	defaultMinBackoff  = 100 * time.Millisecond
+	defaultMaxBackoff  = 5 * time.Second
+
+	// renewBefore is how long before it expires a token is renewed.
+	renewBefore = time.Minute
+
+	// userAgent names the client in the server's logs.
+	userAgent = "ledger-go-client"
+)
+
+// Options configures a Client. The zero value is a client without
+// credentials, for the public operations.
+type Options struct {
+	// HTTPClient sends the requests; http.DefaultClient when nil.
+	HTTPClient *http.Client
+	// Token is a bearer token to send as it is.
+	Token string
+	// Email and Password log in for a token when an operation needs one,
+	// and again before the token expires or when the server rejects it.
+	Email    string
+	Password string
+	Retry    RetryPolicy
+}
+
+// RetryPolicy sets how requests are retried. Only failures a retry may fix
+// are retried: refusals by the rate limiter, and, for reads and mutations
+// sent with an Idempotency-Key, lost connections and 502, 503 and 504
+// responses. Mutations without a key are not retried once they may have
+// reached the server.
+type RetryPolicy struct {
+	// MaxAttempts is how often a request is sent at most; 4 when zero, and
+	// 1 disables retries.
+	MaxAttempts int
+	// The wait before a retry starts at MinBackoff and doubles up to
+	// MaxBackoff, with jitter; 100ms and 5s when zero. A Retry-After the
+	// server asks for replaces it, unless it is longer than MaxBackoff, in
+	// which case the error is returned instead.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Client calls the ledger API. It is safe for concurrent use.
+type Client struct {
+	baseURL string
+	http    *http.Client
+	retry   RetryPolicy
+
+	email    string
+	password string
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// New returns a client of the API at baseURL, such as
+// https://ledger.example.com.
+func New(baseURL string, opts Options) *Client {
+	c := &Client{
+		baseURL:  strings.TrimRight(baseURL, "/"),
+		http:     opts.HTTPClient,
+		retry:    opts.Retry,
+		email:    opts.Email,
+		password: opts.Password,
+		token:    opts.Token,
+		expires:  expiry(opts.Token),
+	}
+	if c.http == nil {
+		c.http = http.DefaultClient
+	}
+	if c.retry.MaxAttempts <= 0 {
+		c.retry.MaxAttempts = defaultMaxAttempts
+	}
+	if c.retry.MinBackoff <= 0 {
+		c.retry.MinBackoff = defaultMinBackoff
+	}
+	if c.retry.MaxBackoff <= 0 {
+		c.retry.MaxBackoff = defaultMaxBackoff
+	}
+	return c
+}
+
+// File is a response in a format other than JSON, such as the PDF of a
+// statement.
+type File struct {
+	ContentType string
+	Body        []byte
+}
+
+type keyContext struct{}
+
+// WithIdempotencyKey makes a mutation called with ctx send key instead of
+// a key of its own. A caller that retries a mutation itself, after a
+// restart for instance, sends the same key each time, so that the server
+// runs the mutation once.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, keyContext{}, key)
+}
+
+// request is a call of an operation.
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   interface{}
+	// auth operations take a bearer token.
+	auth bool
+	// idempotent operations take an Idempotency-Key.
+	idempotent bool
+}
+
+// response is a response read in full.
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// do sends req, retrying it as the policy allows, and decodes the response
+// into out, which is a *File for the operations that do not answer JSON.
+func (c *Client) do(ctx context.Context, req request, out interface{}) error {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return err
+		}
+	}
+	var key string
+	if req.idempotent {
+		key, _ = ctx.Value(keyContext{}).(string)
+		if key == "" {
+			key = newKey()
+		}
+	}
+
+	renewed := false
+	for attempt := 1; ; attempt++ {
+		token, err := c.bearer(ctx, req.auth)
+		if err != nil {
+			return err
+		}
+		resp, err := c.send(ctx, req, body, key, token)
+
+		if err == nil && resp.status == http.StatusUnauthorized && token != "" && c.email != "" && !renewed {
+			// The token was revoked or expired early; log in again, once.
+			c.forget(token)
+			renewed = true
+			attempt--
+			continue
+		}
+
+		wait, retry := c.retryWait(ctx, req, key != "", resp, err, attempt)
+		if !retry {
+			if err != nil {
+				return err
+			}
+			return decode(resp, out)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, req request, body []byte, key, token string) (*response, error) {
+	target := c.baseURL + req.path
+	if len(req.query) > 0 {
+		target += "?" + req.query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	r, err := http.NewRequestWithContext(ctx, req.method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Set("User-Agent", userAgent)
+	if body != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	if key != "" {
+		r.Header.Set("Idempotency-Key", key)
+	}
+
+	resp, err := c.http.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &response{status: resp.StatusCode, header: resp.Header, body: data}, nil
+}
+
+// retryWait reports whether the attempt-th attempt of req may be retried
+// and how long to wait first. A request that may have run is only retried
+// when running it again is harmless: a read, or a mutation the server
+// recognises by its key.
+func (c *Client) retryWait(ctx context.Context, req request, keyed bool, resp *response, err error, attempt int) (time.Duration, bool) {
+	if attempt >= c.retry.MaxAttempts || ctx.Err() != nil {
+		return 0, false
+	}
+	safe := req.method == http.MethodGet || keyed
+	wait := c.backoff(attempt)
+
+	if err != nil {
+		return wait, safe
+	}
+	switch resp.status {
+	case http.StatusTooManyRequests:
+		// Refused before it ran. A long wait, such as that of a locked
+		// login, is the caller's to decide on.
+		if seconds, err := strconv.Atoi(resp.header.Get("Retry-After")); err == nil {
+			wait = time.Duration(seconds) * time.Second
+			if wait > c.retry.MaxBackoff {
+				return 0, false
+			}
+		}
+		return wait, problemCode(resp) == apperr.CodeRateLimited
+	case http.StatusConflict:
+		// The first request with the key is still running.
+		return wait, keyed && problemCode(resp) == apperr.CodeRequestInProgress
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return wait, safe
+	}
+	return 0, false
+}
+
+// backoff returns the wait before the attempt+1-th attempt: exponential,
+// with full jitter so that clients failing together spread their retries.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.retry.MinBackoff << (attempt - 1)
+	if d > c.retry.MaxBackoff || d <= 0 {
+		d = c.retry.MaxBackoff
+	}
+	return rand.N(d) + 1
+}
+
+// bearer returns the token to send with an operation that needs one,
+// logging in when there is none or it is about to expire. Logins are made
+// one at a time, so concurrent calls share one.
+func (c *Client) bearer(ctx context.Context, auth bool) (string, error) {
+	if !auth {
+		return "", nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fresh := c.token != "" && (c.expires.IsZero() || time.Until(c.expires) > renewBefore)
+	if fresh || c.email == "" {
+		return c.token, nil
+	}
+
+	var token Token
+	err := c.do(ctx, request{method: http.MethodPost, path: "/v1/sessions", body: LoginRequest{Email: c.email, Password: c.password}}, &token)
+	if err != nil {
+		return "", err
+	}
+	c.token, c.expires = token.Token, expiry(token.Token)
+	return c.token, nil
+}
+
+// forget drops token once the server rejected it, unless another call has
+// renewed it already.
+func (c *Client) forget(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == token {
+		c.token, c.expires = "", time.Time{}
+	}
+}
+
+// expiry reads the expiry of a JWT without checking its signature, which
+// is the server's to check. It is zero when token carries none.
+func expiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if json.Unmarshal(payload, &claims) != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Exp, 0)
+}
+
+func decode(resp *response, out interface{}) error {
+	if resp.status >= 400 {
+		return newError(resp)
+	}
+	switch out := out.(type) {
+	case nil:
+		return nil
+	case *File:
+		out.ContentType = resp.header.Get("Content-Type")
+		out.Body = resp.body
+		return nil
+	}
+	return json.Unmarshal(resp.body, out)
+}
+
+// newKey returns a random Idempotency-Key.
+func newKey() string {
+	b := make([]byte, 16)
+	if _, err := cryptorand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
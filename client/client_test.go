@@ -0,0 +1,342 @@
+package client_test
+
+import (
+	"Ledger/client"
+	"Ledger/config"
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/auth"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// fast retries without waiting long between attempts.
+var fast = client.RetryPolicy{MinBackoff: time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+
+// stub is an API that logs in with any credentials and answers the other
+// requests through respond, recording what they carried.
+type stub struct {
+	respond func(w http.ResponseWriter, r *http.Request, attempt int)
+
+	mu     sync.Mutex
+	logins int
+	keys   []string
+	tokens []string
+}
+
+func (s *stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	if r.URL.Path == "/v1/sessions" {
+		s.logins++
+		token := fmt.Sprintf("token-%d", s.logins)
+		s.mu.Unlock()
+		fmt.Fprintf(w, `{"token":%q}`, token)
+		return
+	}
+	s.keys = append(s.keys, r.Header.Get("Idempotency-Key"))
+	s.tokens = append(s.tokens, r.Header.Get("Authorization"))
+	attempt := len(s.keys)
+	s.mu.Unlock()
+	s.respond(w, r, attempt)
+}
+
+func newStub(t *testing.T, respond func(w http.ResponseWriter, r *http.Request, attempt int)) (*stub, *httptest.Server) {
+	s := &stub{respond: respond}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	return s, server
+}
+
+func problem(w http.ResponseWriter, status int, code apperr.Code) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `{"title":%q,"status":%d,"code":%q,"detail":"stub","request_id":"req-1"}`, http.StatusText(status), status, code)
+}
+
+func TestRetriesReadsAfterUnavailable(t *testing.T) {
+	s, server := newStub(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		if attempt < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"id":7,"email":"a@example.com"}`)
+	})
+
+	user, err := client.New(server.URL, client.Options{Token: "t", Retry: fast}).GetUser(context.Background(), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != 7 || len(s.keys) != 3 {
+		t.Errorf("got user %d after %d attempts, want 7 after 3", user.ID, len(s.keys))
+	}
+}
+
+func TestRetriesMutationsWithTheSameKey(t *testing.T) {
+	s, server := newStub(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		switch attempt {
+		case 1:
+			w.WriteHeader(http.StatusGatewayTimeout)
+		case 2:
+			problem(w, http.StatusConflict, apperr.CodeRequestInProgress)
+		default:
+			fmt.Fprint(w, `{"message":"ok"}`)
+		}
+	})
+
+	_, err := client.New(server.URL, client.Options{Token: "t", Retry: fast}).
+		CreateTransfer(context.Background(), client.TransferRequest{SenderID: 1, ReceiverID: 2, Amount: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.keys) != 3 || s.keys[0] == "" || s.keys[1] != s.keys[0] || s.keys[2] != s.keys[0] {
+		t.Errorf("sent keys %q, want one key three times", s.keys)
+	}
+}
+
+func TestCallsSendNewKeys(t *testing.T) {
+	s, server := newStub(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		fmt.Fprint(w, `{"message":"ok"}`)
+	})
+
+	c := client.New(server.URL, client.Options{Token: "t"})
+	for i := 0; i < 2; i++ {
+		if _, err := c.CreateTransfer(context.Background(), client.TransferRequest{SenderID: 1, ReceiverID: 2, Amount: 5}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.keys[0] == s.keys[1] {
+		t.Errorf("two calls sent the same key %q", s.keys[0])
+	}
+}
+
+func TestWithIdempotencyKey(t *testing.T) {
+	s, server := newStub(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		fmt.Fprint(w, `{"message":"ok"}`)
+	})
+
+	ctx := client.WithIdempotencyKey(context.Background(), "order-42")
+	if _, err := client.New(server.URL, client.Options{Token: "t"}).CreateTransfer(ctx, client.TransferRequest{SenderID: 1, ReceiverID: 2, Amount: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if s.keys[0] != "order-42" {
+		t.Errorf("sent key %q, want order-42", s.keys[0])
+	}
+}
+
+func TestReadsSendNoKey(t *testing.T) {
+	s, server := newStub(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		fmt.Fprint(w, `[]`)
+	})
+
+	if _, err := client.New(server.URL, client.Options{Token: "t"}).ListUsers(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if s.keys[0] != "" {
+		t.Errorf("a read sent the key %q", s.keys[0])
+	}
+}
+
+func TestDoesNotRetryClientErrors(t *testing.T) {
+	s, server := newStub(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		problem(w, http.StatusUnprocessableEntity, apperr.CodeInsufficientFunds)
+	})
+
+	_, err := client.New(server.URL, client.Options{Token: "t", Retry: fast}).
+		CreateTransfer(context.Background(), client.TransferRequest{SenderID: 1, ReceiverID: 2, Amount: 5})
+	if !errors.Is(err, apperr.ErrInsufficientFunds) {
+		t.Fatalf("got %v, want insufficient_funds", err)
+	}
+	if len(s.keys) != 1 {
+		t.Errorf("sent %d times, want once", len(s.keys))
+	}
+}
+
+func TestRetriesRateLimited(t *testing.T) {
+	s, server := newStub(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		if attempt == 1 {
+			w.Header().Set("Retry-After", "0")
+			problem(w, http.StatusTooManyRequests, apperr.CodeRateLimited)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	})
+
+	if _, err := client.New(server.URL, client.Options{Token: "t", Retry: fast}).ListUsers(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.keys) != 2 {
+		t.Errorf("sent %d times, want twice", len(s.keys))
+	}
+}
+
+func TestReturnsLongRetryAfter(t *testing.T) {
+	s, server := newStub(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		w.Header().Set("Retry-After", "3600")
+		problem(w, http.StatusTooManyRequests, apperr.CodeRateLimited)
+	})
+
+	_, err := client.New(server.URL, client.Options{Token: "t", Retry: fast}).ListUsers(context.Background())
+	if !errors.Is(err, apperr.ErrRateLimited) {
+		t.Fatalf("got %v, want rate_limited", err)
+	}
+	if len(s.keys) != 1 {
+		t.Errorf("sent %d times, want once", len(s.keys))
+	}
+}
+
+func TestLogsInOnDemand(t *testing.T) {
+	s, server := newStub(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		fmt.Fprint(w, `[]`)
+	})
+
+	c := client.New(server.URL, client.Options{Email: "a@example.com", Password: "secret"})
+	for i := 0; i < 2; i++ {
+		if _, err := c.ListUsers(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.logins != 1 || s.tokens[0] != "Bearer token-1" || s.tokens[1] != "Bearer token-1" {
+		t.Errorf("logged in %d times and sent %q, want one login and token-1 twice", s.logins, s.tokens)
+	}
+}
+
+func TestRenewsRejectedToken(t *testing.T) {
+	s, server := newStub(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		if r.Header.Get("Authorization") != "Bearer token-1" {
+			problem(w, http.StatusUnauthorized, apperr.CodeUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	})
+
+	c := client.New(server.URL, client.Options{Token: "revoked", Email: "a@example.com", Password: "secret"})
+	if _, err := c.ListUsers(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if s.logins != 1 {
+		t.Errorf("logged in %d times, want once", s.logins)
+	}
+}
+
+func TestRenewsExpiringToken(t *testing.T) {
+	s, server := newStub(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		fmt.Fprint(w, `[]`)
+	})
+
+	claims := fmt.Sprintf(`{"exp":%d}`, time.Now().Add(10*time.Second).Unix())
+	expiring := "e30." + base64.RawURLEncoding.EncodeToString([]byte(claims)) + ".sig"
+	c := client.New(server.URL, client.Options{Token: expiring, Email: "a@example.com", Password: "secret"})
+	if _, err := c.ListUsers(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if s.logins != 1 || s.tokens[0] != "Bearer token-1" {
+		t.Errorf("logged in %d times and sent %q, want a renewed token", s.logins, s.tokens)
+	}
+}
+
+func TestErrorCarriesTheProblem(t *testing.T) {
+	_, server := newStub(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"title":"Bad Request","status":400,"code":"validation_failed","detail":"invalid","request_id":"req-9","errors":[{"field":"amount","message":"must be positive"}]}`)
+	})
+
+	_, err := client.New(server.URL, client.Options{Token: "t"}).
+		CreateTransfer(context.Background(), client.TransferRequest{SenderID: 1, ReceiverID: 2})
+	if !errors.Is(err, apperr.ErrValidationFailed) {
+		t.Fatalf("got %v, want validation_failed", err)
+	}
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %T, want *client.Error", err)
+	}
+	if apiErr.Status != http.StatusBadRequest || apiErr.RequestID != "req-9" || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "amount" {
+		t.Errorf("got %+v", apiErr)
+	}
+}
+
+func TestErrorWithoutProblem(t *testing.T) {
+	_, server := newStub(t, func(w http.ResponseWriter, r *http.Request, attempt int) {
+		w.Header().Set("X-Request-ID", "req-5")
+		http.Error(w, "teapot", http.StatusTeapot)
+	})
+
+	_, err := client.New(server.URL, client.Options{Token: "t"}).ListUsers(context.Background())
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("got %v, want *client.Error", err)
+	}
+	if apiErr.Code != "" || errors.Unwrap(apiErr) != nil || apiErr.RequestID != "req-5" || string(apiErr.Body) != "teapot\n" {
+		t.Errorf("got %+v", apiErr)
+	}
+}
+
+// TestRouterUnauthenticated runs against the real router, on a database
+// handle that is never connected to, as authentication is refused first.
+func TestRouterUnauthenticated(t *testing.T) {
+	sqlDB, err := sql.Open("postgres", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	handler, _ := newRouter(t, sqlDB, "postgres")
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	_, err = client.New(server.URL, client.Options{}).GetUser(context.Background(), 1)
+	if !errors.Is(err, apperr.ErrUnauthorized) {
+		t.Fatalf("got %v, want unauthorized", err)
+	}
+	var apiErr *client.Error
+	if errors.As(err, &apiErr); apiErr.RequestID == "" {
+		t.Errorf("got no request ID in %+v", apiErr)
+	}
+}
+
+// TestRouterTransfersOnlyFromOwnAccount checks that the deprecated transfer
+// route refuses, as /v1/transfers does, to send from another user's account,
+// before the database is reached.
+func TestRouterTransfersOnlyFromOwnAccount(t *testing.T) {
+	sqlDB, err := sql.Open("postgres", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+	handler, _ := newRouter(t, sqlDB, "postgres")
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	cfg := config.Default()
+	cfg.JWT.SecretKey = "client-test"
+	token, err := auth.NewJWTService(cfg.JWT).GenerateToken(1, "a@example.com", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/users/send-credit", "/users/send-credit?senderId=2&receiverId=1&amount=5", "/v1/transfers"} {
+		req, err := http.NewRequest("POST", server.URL+path, strings.NewReader(`{"sender_id":2,"receiver_id":1,"amount":5}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("POST %s: got %d, want %d", path, resp.StatusCode, http.StatusForbidden)
+		}
+	}
+}
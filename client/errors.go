@@ -0,0 +1,90 @@
+package client
+
+import (
+	"Ledger/pkg/apperr"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Error is an error response of the API. Problems carry the code of the
+// error, and Error unwraps to the apperr error of that code, so callers
+// tell errors apart with errors.Is:
+//
+//	if errors.Is(err, apperr.ErrInsufficientFunds) {
+//
+// A response that is not a problem, such as the report of a broken chain
+// or the error page of a proxy, has no code; its body is kept in Body.
+type Error struct {
+	Status    int
+	Code      apperr.Code
+	Title     string
+	Detail    string
+	RequestID string
+	// Fields lists the invalid fields of a validation_failed error.
+	Fields []apperr.FieldError
+	Body   []byte
+}
+
+// problem is the RFC 7807 body of an error response.
+type problem struct {
+	Title     string              `json:"title"`
+	Detail    string              `json:"detail"`
+	Code      apperr.Code         `json:"code"`
+	RequestID string              `json:"request_id"`
+	Errors    []apperr.FieldError `json:"errors"`
+}
+
+func newError(resp *response) *Error {
+	e := &Error{Status: resp.status, Title: http.StatusText(resp.status), Body: resp.body}
+	var p problem
+	if isProblem(resp) && json.Unmarshal(resp.body, &p) == nil {
+		e.Code, e.Title, e.Detail, e.RequestID, e.Fields = p.Code, p.Title, p.Detail, p.RequestID, p.Errors
+	}
+	if e.RequestID == "" {
+		e.RequestID = resp.header.Get("X-Request-ID")
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("ledger: %d %s", e.Status, e.Title)
+	if e.Code != "" {
+		msg += " (" + string(e.Code) + ")"
+	}
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	for i, f := range e.Fields {
+		sep := "; "
+		if i == 0 {
+			sep = ": "
+		}
+		msg += sep + f.Field + " " + f.Message
+	}
+	return msg
+}
+
+// Unwrap returns the apperr error of the code, nil for responses without
+// one.
+func (e *Error) Unwrap() error {
+	if e.Code == "" {
+		return nil
+	}
+	return apperr.New(e.Code, e.Status, e.Detail).WithFields(e.Fields)
+}
+
+func isProblem(resp *response) bool {
+	return strings.HasPrefix(resp.header.Get("Content-Type"), "application/problem+json")
+}
+
+// problemCode returns the code of an error response, empty when it is not
+// a problem.
+func problemCode(resp *response) apperr.Code {
+	var p problem
+	if !isProblem(resp) || json.Unmarshal(resp.body, &p) != nil {
+		return ""
+	}
+	return p.Code
+}
@@ -0,0 +1,5 @@
+package client
+
+// operations.go is generated from the routes of the server; regenerate it
+// after changing them.
+//go:generate go run ../cmd/clientgen -out operations.go
@@ -0,0 +1,684 @@
+// Code generated by cmd/clientgen from the OpenAPI document; DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// ListAccountsParams holds the query parameters of ListAccounts.
+type ListAccountsParams struct {
+	// Comma separated user IDs; every account when omitted
+	IDs string
+}
+
+// ListAccounts calls GET /v1/accounts to list the balances of the accounts. Admins only.
+func (c *Client) ListAccounts(ctx context.Context, params *ListAccountsParams) ([]User, error) {
+	var out []User
+	req := request{method: "GET", path: "/v1/accounts", auth: true}
+	if params != nil {
+		req.query = url.Values{}
+		if params.IDs != "" {
+			req.query.Set("ids", params.IDs)
+		}
+	}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetBalanceParams holds the query parameters of GetBalance.
+type GetBalanceParams struct {
+	// RFC 3339 timestamp, or a date for the end of that UTC day
+	At string
+}
+
+// GetBalance calls GET /v1/accounts/{id}/balance to get the balance of an account, now or at a time.
+func (c *Client) GetBalance(ctx context.Context, id uint, params *GetBalanceParams) (*BalancePoint, error) {
+	var out BalancePoint
+	req := request{method: "GET", path: fmt.Sprintf("/v1/accounts/%d/balance", id), auth: true}
+	if params != nil {
+		req.query = url.Values{}
+		if params.At != "" {
+			req.query.Set("at", params.At)
+		}
+	}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListBalancesParams holds the query parameters of ListBalances.
+type ListBalancesParams struct {
+	// First day, YYYY-MM-DD
+	From string
+	// Last day, YYYY-MM-DD; today by default
+	To string
+	// Only day is supported
+	Interval string
+}
+
+// ListBalances calls GET /v1/accounts/{id}/balances to get the daily balances of an account.
+func (c *Client) ListBalances(ctx context.Context, id uint, params *ListBalancesParams) (*BalanceSeries, error) {
+	var out BalanceSeries
+	req := request{method: "GET", path: fmt.Sprintf("/v1/accounts/%d/balances", id), auth: true}
+	if params != nil {
+		req.query = url.Values{}
+		if params.From != "" {
+			req.query.Set("from", params.From)
+		}
+		if params.To != "" {
+			req.query.Set("to", params.To)
+		}
+		if params.Interval != "" {
+			req.query.Set("interval", params.Interval)
+		}
+	}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreditAccount calls POST /v1/accounts/{id}/credits to add credit to an account. Admins only.
+func (c *Client) CreditAccount(ctx context.Context, id uint, body CreditRequest) (*Message, error) {
+	var out Message
+	req := request{method: "POST", path: fmt.Sprintf("/v1/accounts/%d/credits", id), body: body, auth: true, idempotent: true}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetExportParams holds the query parameters of GetExport.
+type GetExportParams struct {
+	// Month of the statement, YYYY-MM; the last month by default
+	Month string
+	// First day, YYYY-MM-DD, instead of month
+	From string
+	// Last day, YYYY-MM-DD, instead of month
+	To string
+	// camt053 or ofx; camt053 by default
+	Format string
+}
+
+// GetExport calls GET /v1/accounts/{id}/export to export the statement of an account for a bank.
+func (c *Client) GetExport(ctx context.Context, id uint, params *GetExportParams) (*File, error) {
+	var out File
+	req := request{method: "GET", path: fmt.Sprintf("/v1/accounts/%d/export", id), auth: true}
+	if params != nil {
+		req.query = url.Values{}
+		if params.Month != "" {
+			req.query.Set("month", params.Month)
+		}
+		if params.From != "" {
+			req.query.Set("from", params.From)
+		}
+		if params.To != "" {
+			req.query.Set("to", params.To)
+		}
+		if params.Format != "" {
+			req.query.Set("format", params.Format)
+		}
+	}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetStatementParams holds the query parameters of GetStatement.
+type GetStatementParams struct {
+	// Month of the statement, YYYY-MM; the last month by default
+	Month string
+	// First day, YYYY-MM-DD, instead of month
+	From string
+	// Last day, YYYY-MM-DD, instead of month
+	To string
+	// csv, json or pdf; json by default
+	Format string
+}
+
+// GetStatement calls GET /v1/accounts/{id}/statement to get the statement of an account.
+func (c *Client) GetStatement(ctx context.Context, id uint, params *GetStatementParams) (*Statement, error) {
+	var out Statement
+	req := request{method: "GET", path: fmt.Sprintf("/v1/accounts/%d/statement", id), auth: true}
+	if params != nil {
+		req.query = url.Values{}
+		if params.Month != "" {
+			req.query.Set("month", params.Month)
+		}
+		if params.From != "" {
+			req.query.Set("from", params.From)
+		}
+		if params.To != "" {
+			req.query.Set("to", params.To)
+		}
+		if params.Format != "" {
+			req.query.Set("format", params.Format)
+		}
+	}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetStatementFile is GetStatement for the formats other than JSON: application/pdf, text/csv.
+func (c *Client) GetStatementFile(ctx context.Context, id uint, params *GetStatementParams) (*File, error) {
+	var out File
+	req := request{method: "GET", path: fmt.Sprintf("/v1/accounts/%d/statement", id), auth: true}
+	if params != nil {
+		req.query = url.Values{}
+		if params.Month != "" {
+			req.query.Set("month", params.Month)
+		}
+		if params.From != "" {
+			req.query.Set("from", params.From)
+		}
+		if params.To != "" {
+			req.query.Set("to", params.To)
+		}
+		if params.Format != "" {
+			req.query.Set("format", params.Format)
+		}
+	}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListTransactionsParams holds the query parameters of ListTransactions.
+type ListTransactionsParams struct {
+	// Only the transfers the account sent on this day, YYYY-MM-DD
+	Date string
+}
+
+// ListTransactions calls GET /v1/accounts/{id}/transactions to list the transfers of an account.
+func (c *Client) ListTransactions(ctx context.Context, id uint, params *ListTransactionsParams) ([]TransactionLog, error) {
+	var out []TransactionLog
+	req := request{method: "GET", path: fmt.Sprintf("/v1/accounts/%d/transactions", id), auth: true}
+	if params != nil {
+		req.query = url.Values{}
+		if params.Date != "" {
+			req.query.Set("date", params.Date)
+		}
+	}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ListAuditEventsParams holds the query parameters of ListAuditEvents.
+type ListAuditEventsParams struct {
+	// Email of the actor
+	Actor string
+	// User ID of the actor
+	ActorID string
+	// Action, such as credit.add
+	Action string
+	// Target, such as user:42
+	Target string
+	// Request ID
+	RequestID string
+	// RFC 3339 timestamp
+	From string
+	// RFC 3339 timestamp
+	To string
+	// At most this many events, 100 by default
+	Limit string
+	// Events to skip
+	Offset string
+}
+
+// ListAuditEvents calls GET /v1/admin/audit-events to search the audit trail. Admins only.
+func (c *Client) ListAuditEvents(ctx context.Context, params *ListAuditEventsParams) ([]AuditEvent, error) {
+	var out []AuditEvent
+	req := request{method: "GET", path: "/v1/admin/audit-events", auth: true}
+	if params != nil {
+		req.query = url.Values{}
+		if params.Actor != "" {
+			req.query.Set("actor", params.Actor)
+		}
+		if params.ActorID != "" {
+			req.query.Set("actor_id", params.ActorID)
+		}
+		if params.Action != "" {
+			req.query.Set("action", params.Action)
+		}
+		if params.Target != "" {
+			req.query.Set("target", params.Target)
+		}
+		if params.RequestID != "" {
+			req.query.Set("request_id", params.RequestID)
+		}
+		if params.From != "" {
+			req.query.Set("from", params.From)
+		}
+		if params.To != "" {
+			req.query.Set("to", params.To)
+		}
+		if params.Limit != "" {
+			req.query.Set("limit", params.Limit)
+		}
+		if params.Offset != "" {
+			req.query.Set("offset", params.Offset)
+		}
+	}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ExportAuditEventsParams holds the query parameters of ExportAuditEvents.
+type ExportAuditEventsParams struct {
+	// Email of the actor
+	Actor string
+	// User ID of the actor
+	ActorID string
+	// Action, such as credit.add
+	Action string
+	// Target, such as user:42
+	Target string
+	// Request ID
+	RequestID string
+	// RFC 3339 timestamp
+	From string
+	// RFC 3339 timestamp
+	To string
+	// csv or ndjson; csv by default
+	Format string
+}
+
+// ExportAuditEvents calls GET /v1/admin/audit-events/export to export the audit trail. Admins only.
+func (c *Client) ExportAuditEvents(ctx context.Context, params *ExportAuditEventsParams) (*File, error) {
+	var out File
+	req := request{method: "GET", path: "/v1/admin/audit-events/export", auth: true}
+	if params != nil {
+		req.query = url.Values{}
+		if params.Actor != "" {
+			req.query.Set("actor", params.Actor)
+		}
+		if params.ActorID != "" {
+			req.query.Set("actor_id", params.ActorID)
+		}
+		if params.Action != "" {
+			req.query.Set("action", params.Action)
+		}
+		if params.Target != "" {
+			req.query.Set("target", params.Target)
+		}
+		if params.RequestID != "" {
+			req.query.Set("request_id", params.RequestID)
+		}
+		if params.From != "" {
+			req.query.Set("from", params.From)
+		}
+		if params.To != "" {
+			req.query.Set("to", params.To)
+		}
+		if params.Format != "" {
+			req.query.Set("format", params.Format)
+		}
+	}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListCheckpoints calls GET /v1/admin/ledger/checkpoints to list the chain checkpoints. Admins only.
+func (c *Client) ListCheckpoints(ctx context.Context) ([]ChainCheckpoint, error) {
+	var out []ChainCheckpoint
+	req := request{method: "GET", path: "/v1/admin/ledger/checkpoints", auth: true}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateCheckpoint calls POST /v1/admin/ledger/checkpoints to sign the chain head. Admins only.
+func (c *Client) CreateCheckpoint(ctx context.Context) (*ChainCheckpoint, error) {
+	var out ChainCheckpoint
+	req := request{method: "POST", path: "/v1/admin/ledger/checkpoints", auth: true, idempotent: true}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// VerifyLedger calls GET /v1/admin/ledger/verify to verify the transaction chain; 409 when broken. Admins only.
+func (c *Client) VerifyLedger(ctx context.Context) (*ChainReport, error) {
+	var out ChainReport
+	req := request{method: "GET", path: "/v1/admin/ledger/verify", auth: true}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListLockouts calls GET /v1/admin/lockouts to list the emails locked out of login. Admins only.
+func (c *Client) ListLockouts(ctx context.Context) ([]Lockout, error) {
+	var out []Lockout
+	req := request{method: "GET", path: "/v1/admin/lockouts", auth: true}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CheckReconciliation calls GET /v1/admin/reconciliation to reconcile balances with the log; 409 on discrepancies. Admins only.
+func (c *Client) CheckReconciliation(ctx context.Context) (*ReconcileReport, error) {
+	var out ReconcileReport
+	req := request{method: "GET", path: "/v1/admin/reconciliation", auth: true}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RepairReconciliation calls POST /v1/admin/reconciliation to reconcile and drop cached balances that differ. Admins only.
+func (c *Client) RepairReconciliation(ctx context.Context) (*ReconcileReport, error) {
+	var out ReconcileReport
+	req := request{method: "POST", path: "/v1/admin/reconciliation", auth: true}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UnlockUser calls POST /v1/admin/users/{id}/unlock to clear the failed logins of a user. Admins only.
+func (c *Client) UnlockUser(ctx context.Context, id uint) (*Unlocked, error) {
+	var out Unlocked
+	req := request{method: "POST", path: fmt.Sprintf("/v1/admin/users/%d/unlock", id), auth: true}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateCreditBatch calls POST /v1/credit-batches to add or remove credit of many accounts at once. Admins only.
+func (c *Client) CreateCreditBatch(ctx context.Context, body BatchCreditRequest) ([]BatchTransactionResult, error) {
+	var out []BatchTransactionResult
+	req := request{method: "POST", path: "/v1/credit-batches", body: body, auth: true, idempotent: true}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateSession calls POST /v1/sessions to log in and get a bearer token.
+func (c *Client) CreateSession(ctx context.Context, body LoginRequest) (*Token, error) {
+	var out Token
+	req := request{method: "POST", path: "/v1/sessions", body: body}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateTransfer calls POST /v1/transfers to transfer credit between accounts.
+func (c *Client) CreateTransfer(ctx context.Context, body TransferRequest) (*Message, error) {
+	var out Message
+	req := request{method: "POST", path: "/v1/transfers", body: body, auth: true, idempotent: true}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListUsers calls GET /v1/users to list the users. Admins only.
+func (c *Client) ListUsers(ctx context.Context) ([]User, error) {
+	var out []User
+	req := request{method: "GET", path: "/v1/users", auth: true}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateUser calls POST /v1/users to register a user.
+func (c *Client) CreateUser(ctx context.Context, body RegisterRequest) (*UserCreated, error) {
+	var out UserCreated
+	req := request{method: "POST", path: "/v1/users", body: body, idempotent: true}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetUser calls GET /v1/users/{id} to get a user.
+func (c *Client) GetUser(ctx context.Context, id uint) (*User, error) {
+	var out User
+	req := request{method: "GET", path: fmt.Sprintf("/v1/users/%d", id), auth: true}
+	if err := c.do(ctx, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AuditEvent is the audit.Event schema of the API.
+type AuditEvent struct {
+	Action     string    `json:"action"`
+	Actor      string    `json:"actor"`
+	ActorID    uint      `json:"actor_id,omitempty"`
+	After      string    `json:"after,omitempty"`
+	Before     string    `json:"before,omitempty"`
+	ID         int64     `json:"id"`
+	IP         string    `json:"ip,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Reason     string    `json:"reason,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Target     string    `json:"target"`
+}
+
+// BalanceDay is the balance.Day schema of the API.
+type BalanceDay struct {
+	Balance float64 `json:"balance"`
+	Date    string  `json:"date"`
+}
+
+// BalancePoint is the balance.Point schema of the API.
+type BalancePoint struct {
+	At      time.Time `json:"at"`
+	Balance float64   `json:"balance"`
+	UserID  uint      `json:"user_id"`
+}
+
+// BalanceSeries is the handlers.BalanceSeries schema of the API.
+type BalanceSeries struct {
+	Balances []BalanceDay `json:"balances"`
+	From     string       `json:"from"`
+	Interval string       `json:"interval"`
+	To       string       `json:"to"`
+	UserID   uint         `json:"user_id"`
+}
+
+// BatchCreditRequest is the handlers.BatchCreditRequest schema of the API.
+type BatchCreditRequest struct {
+	Transactions []BatchTransaction `json:"transactions"`
+}
+
+// BatchTransaction is the models.BatchTransaction schema of the API.
+type BatchTransaction struct {
+	Amount float64 `json:"amount,omitempty"`
+	UserID uint    `json:"user_id"`
+}
+
+// BatchTransactionResult is the models.BatchTransactionResult schema of the API.
+type BatchTransactionResult struct {
+	Amount  float64 `json:"amount"`
+	Code    string  `json:"code,omitempty"`
+	Error   string  `json:"error,omitempty"`
+	Success bool    `json:"success"`
+	UserID  uint    `json:"user_id"`
+}
+
+// ChainBreak is the chain.Break schema of the API.
+type ChainBreak struct {
+	CheckpointID uint   `json:"checkpoint_id,omitempty"`
+	LogID        uint   `json:"log_id,omitempty"`
+	Reason       string `json:"reason"`
+}
+
+// ChainCheckpoint is the chain.Checkpoint schema of the API.
+type ChainCheckpoint struct {
+	CreatedAt time.Time `json:"created_at"`
+	Hash      string    `json:"hash"`
+	ID        uint      `json:"id"`
+	Length    uint      `json:"length"`
+	LogID     uint      `json:"log_id"`
+	Signature string    `json:"signature"`
+}
+
+// ChainReport is the chain.Report schema of the API.
+type ChainReport struct {
+	Broken            *ChainBreak `json:"broken,omitempty"`
+	Checkpoints       int64       `json:"checkpoints"`
+	Entries           int64       `json:"entries"`
+	Head              string      `json:"head"`
+	SignaturesChecked bool        `json:"signatures_checked"`
+	Unchained         int64       `json:"unchained"`
+}
+
+// CreditRequest is the handlers.CreditRequest schema of the API.
+type CreditRequest struct {
+	Amount float64 `json:"amount,omitempty"`
+}
+
+// Lockout is the lockout.Lockout schema of the API.
+type Lockout struct {
+	Email         string     `json:"email"`
+	Failures      int64      `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+}
+
+// LoginRequest is the models.LoginRequest schema of the API.
+type LoginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// Message is the handlers.Message schema of the API.
+type Message struct {
+	Message string `json:"message"`
+}
+
+// ReconcileDiscrepancy is the reconcile.Discrepancy schema of the API.
+type ReconcileDiscrepancy struct {
+	Actual   float64 `json:"actual"`
+	Detail   string  `json:"detail"`
+	Expected float64 `json:"expected"`
+	Kind     string  `json:"kind"`
+	LogID    uint    `json:"log_id,omitempty"`
+	Repaired bool    `json:"repaired,omitempty"`
+	UserID   uint    `json:"user_id"`
+}
+
+// ReconcileReport is the reconcile.Report schema of the API.
+type ReconcileReport struct {
+	Accounts      int64                  `json:"accounts"`
+	CacheChecked  bool                   `json:"cache_checked"`
+	CacheError    string                 `json:"cache_error,omitempty"`
+	CacheRepaired int64                  `json:"cache_repaired"`
+	Discrepancies []ReconcileDiscrepancy `json:"discrepancies"`
+	DurationNS    int64                  `json:"duration_ns"`
+	Entries       int64                  `json:"entries"`
+	StartedAt     time.Time              `json:"started_at"`
+}
+
+// RegisterRequest is the models.RegisterRequest schema of the API.
+type RegisterRequest struct {
+	Age      uint   `json:"age,omitempty"`
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Password string `json:"password"`
+	Surname  string `json:"surname"`
+}
+
+// Statement is the statement.Statement schema of the API.
+type Statement struct {
+	ClosingBalance float64         `json:"closing_balance"`
+	Email          string          `json:"email"`
+	From           string          `json:"from"`
+	GeneratedAt    time.Time       `json:"generated_at"`
+	Lines          []StatementLine `json:"lines"`
+	Name           string          `json:"name"`
+	OpeningBalance float64         `json:"opening_balance"`
+	To             string          `json:"to"`
+	TotalCredits   float64         `json:"total_credits"`
+	TotalDebits    float64         `json:"total_debits"`
+	UserID         uint            `json:"user_id"`
+}
+
+// StatementLine is the statement.Line schema of the API.
+type StatementLine struct {
+	Balance        float64   `json:"balance"`
+	Counterparty   string    `json:"counterparty,omitempty"`
+	CounterpartyID uint      `json:"counterparty_id,omitempty"`
+	Credit         float64   `json:"credit"`
+	Date           time.Time `json:"date"`
+	Debit          float64   `json:"debit"`
+	Description    string    `json:"description"`
+	EntryID        uint      `json:"entry_id"`
+}
+
+// Token is the handlers.Token schema of the API.
+type Token struct {
+	Token string `json:"token"`
+}
+
+// TransactionLog is the models.TransactionLog schema of the API.
+type TransactionLog struct {
+	Amount               float64   `json:"amount"`
+	Description          string    `json:"description,omitempty"`
+	Hash                 string    `json:"hash,omitempty"`
+	ID                   uint      `json:"id"`
+	PrevHash             string    `json:"prev_hash,omitempty"`
+	ReceiverCreditAfter  float64   `json:"receiver_credit_after"`
+	ReceiverCreditBefore float64   `json:"receiver_credit_before"`
+	ReceiverID           uint      `json:"receiver_id"`
+	SenderCreditAfter    float64   `json:"sender_credit_after"`
+	SenderCreditBefore   float64   `json:"sender_credit_before"`
+	SenderID             uint      `json:"sender_id"`
+	TransactionDate      time.Time `json:"transaction_date"`
+}
+
+// TransferRequest is the handlers.TransferRequest schema of the API.
+type TransferRequest struct {
+	Amount     float64 `json:"amount,omitempty"`
+	ReceiverID uint    `json:"receiver_id"`
+	SenderID   uint    `json:"sender_id"`
+}
+
+// Unlocked is the handlers.Unlocked schema of the API.
+type Unlocked struct {
+	Unlocked bool `json:"unlocked"`
+	UserID   uint `json:"user_id"`
+}
+
+// User is the models.User schema of the API.
+type User struct {
+	Age     int64   `json:"age"`
+	Credit  float64 `json:"credit"`
+	Email   string  `json:"email"`
+	ID      uint    `json:"id"`
+	Name    string  `json:"name"`
+	Role    string  `json:"role"`
+	Surname string  `json:"surname"`
+}
+
+// UserCreated is the handlers.UserCreated schema of the API.
+type UserCreated struct {
+	Message string `json:"message"`
+	UserID  uint   `json:"user_id"`
+}
@@ -0,0 +1,354 @@
+package client_test
+
+import (
+	"Ledger/client"
+	"Ledger/config"
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/testdb"
+	"Ledger/src/factory"
+	"Ledger/src/models"
+	"Ledger/src/router"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// newRouter returns the router on top of sqlDB. Rate limits are off, as the
+// tests sign up more users than the signup limit allows one address.
+func newRouter(t *testing.T, sqlDB *sql.DB, driver string) (http.Handler, factory.Factory) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.JWT.SecretKey = "client-test"
+	cfg.RateLimit.Enabled = false
+	// The request log of the router would bury the test output.
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	var f factory.Factory
+	switch driver {
+	case "mysql":
+		gormDB, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB}), &gorm.Config{})
+		if err != nil {
+			t.Fatalf("open GORM: %v", err)
+		}
+		f = factory.NewFactory(gormDB, &cfg)
+	case "postgres":
+		f = factory.NewPostgresFactory(sqlDB, &cfg)
+	}
+	return router.New(f), f
+}
+
+// TestRouter runs the client against the router of each storage driver,
+// served by an httptest server behind a proxy that loses responses and
+// fails requests on demand, so the retries of the client meet the failures
+// they are made for. The cases run in order, each building on the ones
+// before.
+func TestRouter(t *testing.T) {
+	for _, driver := range testdb.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			handler, f := newRouter(t, testdb.Open(t, driver), driver)
+			proxy := &faultyProxy{next: handler}
+			server := httptest.NewServer(proxy)
+			defer server.Close()
+
+			s := &suite{
+				url:   server.URL,
+				proxy: proxy,
+				f:     f,
+				// Every run signs up users of its own, so that runs do not collide.
+				suffix: strconv.FormatInt(time.Now().UnixNano(), 36),
+			}
+			for _, c := range s.cases() {
+				t.Run(c.name, func(t *testing.T) {
+					if err := c.run(context.Background()); err != nil {
+						t.Fatal(err)
+					}
+				})
+			}
+		})
+	}
+}
+
+// faultyProxy passes requests to the router, except that it fails the
+// next one without passing it on after failNext, and passes the next one
+// on but answers 504 in place of its response after loseNext.
+type faultyProxy struct {
+	next http.Handler
+
+	mu   sync.Mutex
+	fail bool
+	lose bool
+	// requests counts the requests that reached the router.
+	requests int
+}
+
+func (p *faultyProxy) failNext() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fail = true
+}
+
+func (p *faultyProxy) loseNext() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lose = true
+}
+
+func (p *faultyProxy) served() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.requests
+}
+
+func (p *faultyProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	fail, lose := p.fail, p.lose
+	p.fail, p.lose = false, false
+	if !fail {
+		p.requests++
+	}
+	p.mu.Unlock()
+
+	switch {
+	case fail:
+		http.Error(w, "upstream unavailable", http.StatusServiceUnavailable)
+	case lose:
+		p.next.ServeHTTP(httptest.NewRecorder(), r)
+		http.Error(w, "upstream timed out", http.StatusGatewayTimeout)
+	default:
+		p.next.ServeHTTP(w, r)
+	}
+}
+
+type testCase struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// suite holds the users the cases share; the cases run in order, each
+// building on the ones before.
+type suite struct {
+	url    string
+	proxy  *faultyProxy
+	f      factory.Factory
+	suffix string
+
+	alice, bob, admin uint
+}
+
+const password = "correct horse battery"
+
+func (s *suite) email(name string) string {
+	return name + "." + s.suffix + "@client.test"
+}
+
+// newClient returns a client that logs in as name, or an anonymous one
+// when name is empty. Retries wait little, as the failures are made up.
+func (s *suite) newClient(name string) *client.Client {
+	opts := client.Options{Retry: client.RetryPolicy{MinBackoff: time.Millisecond, MaxBackoff: 50 * time.Millisecond}}
+	if name != "" {
+		opts.Email, opts.Password = s.email(name), password
+	}
+	return client.New(s.url, opts)
+}
+
+func (s *suite) signUp(ctx context.Context, name string) (uint, error) {
+	created, err := s.newClient("").CreateUser(ctx, client.RegisterRequest{Name: name, Surname: "Check", Age: 30, Email: s.email(name), Password: password})
+	if err != nil {
+		return 0, err
+	}
+	return created.UserID, nil
+}
+
+func (s *suite) cases() []testCase {
+	return []testCase{
+		{"sign up", s.signUpUsers},
+		{"email taken", s.emailTaken},
+		{"validation errors carry fields", s.validationFields},
+		{"logs in on demand", s.logsInOnDemand},
+		{"unauthenticated", s.unauthenticated},
+		{"forbidden", s.forbidden},
+		{"admin credits an account", s.adminCredits},
+		{"insufficient funds", s.insufficientFunds},
+		{"lost response retried once", s.lostResponse},
+		{"idempotency key reused", s.keyReused},
+		{"rejected token renewed", s.tokenRenewed},
+		{"read retried", s.readRetried},
+		{"non-JSON response", s.fileResponse},
+	}
+}
+
+func (s *suite) signUpUsers(ctx context.Context) error {
+	var err error
+	if s.alice, err = s.signUp(ctx, "alice"); err != nil {
+		return err
+	}
+	if s.bob, err = s.signUp(ctx, "bob"); err != nil {
+		return err
+	}
+	if s.admin, err = s.signUp(ctx, "admin"); err != nil {
+		return err
+	}
+	return s.f.NewUserService().SetRole(ctx, s.admin, models.RoleAdmin)
+}
+
+func (s *suite) emailTaken(ctx context.Context) error {
+	_, err := s.signUp(ctx, "alice")
+	if !errors.Is(err, apperr.ErrEmailTaken) {
+		return fmt.Errorf("got %v, want email_taken", err)
+	}
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusConflict || apiErr.RequestID == "" {
+		return fmt.Errorf("got %#v, want a 409 *client.Error with a request ID", err)
+	}
+	return nil
+}
+
+func (s *suite) validationFields(ctx context.Context) error {
+	_, err := s.newClient("").CreateUser(ctx, client.RegisterRequest{Name: "Eve", Surname: "Check", Age: 30, Email: "not an email", Password: "short"})
+	if !errors.Is(err, apperr.ErrValidationFailed) {
+		return fmt.Errorf("got %v, want validation_failed", err)
+	}
+	var apiErr *client.Error
+	errors.As(err, &apiErr)
+	fields := map[string]bool{}
+	for _, f := range apiErr.Fields {
+		fields[f.Field] = true
+	}
+	if !fields["email"] || !fields["password"] {
+		return fmt.Errorf("got fields %v, want email and password", apiErr.Fields)
+	}
+	return nil
+}
+
+func (s *suite) logsInOnDemand(ctx context.Context) error {
+	user, err := s.newClient("alice").GetUser(ctx, s.alice)
+	if err != nil {
+		return err
+	}
+	if user.Email != s.email("alice") {
+		return fmt.Errorf("got %s, want %s", user.Email, s.email("alice"))
+	}
+	return nil
+}
+
+func (s *suite) unauthenticated(ctx context.Context) error {
+	if _, err := s.newClient("").GetUser(ctx, s.alice); !errors.Is(err, apperr.ErrUnauthorized) {
+		return fmt.Errorf("got %v, want unauthorized", err)
+	}
+	return nil
+}
+
+func (s *suite) forbidden(ctx context.Context) error {
+	if _, err := s.newClient("alice").GetUser(ctx, s.bob); !errors.Is(err, apperr.ErrForbidden) {
+		return fmt.Errorf("got %v, want forbidden", err)
+	}
+	return nil
+}
+
+func (s *suite) adminCredits(ctx context.Context) error {
+	if _, err := s.newClient("admin").CreditAccount(ctx, s.alice, client.CreditRequest{Amount: 100}); err != nil {
+		return err
+	}
+	return s.wantCredit(ctx, s.alice, 100)
+}
+
+func (s *suite) insufficientFunds(ctx context.Context) error {
+	_, err := s.newClient("bob").CreateTransfer(ctx, client.TransferRequest{SenderID: s.bob, ReceiverID: s.alice, Amount: 50})
+	if !errors.Is(err, apperr.ErrInsufficientFunds) {
+		return fmt.Errorf("got %v, want insufficient_funds", err)
+	}
+	return nil
+}
+
+// lostResponse has the first response to a transfer lost on its way back;
+// the client retries with the same key and the transfer is made once.
+func (s *suite) lostResponse(ctx context.Context) error {
+	alice := s.newClient("alice")
+	// Log in first, so the lost response is that of the transfer.
+	if _, err := alice.GetUser(ctx, s.alice); err != nil {
+		return err
+	}
+	before := s.proxy.served()
+	s.proxy.loseNext()
+	if _, err := alice.CreateTransfer(ctx, client.TransferRequest{SenderID: s.alice, ReceiverID: s.bob, Amount: 30}); err != nil {
+		return err
+	}
+	if n := s.proxy.served() - before; n != 2 {
+		return fmt.Errorf("transfer sent %d times, want 2", n)
+	}
+	if err := s.wantCredit(ctx, s.alice, 70); err != nil {
+		return err
+	}
+	return s.wantCredit(ctx, s.bob, 30)
+}
+
+func (s *suite) keyReused(ctx context.Context) error {
+	alice := s.newClient("alice")
+	keyed := client.WithIdempotencyKey(ctx, "client-test-"+s.suffix)
+	transfer := client.TransferRequest{SenderID: s.alice, ReceiverID: s.bob, Amount: 5}
+	for i := 0; i < 2; i++ {
+		if _, err := alice.CreateTransfer(keyed, transfer); err != nil {
+			return err
+		}
+	}
+	if err := s.wantCredit(ctx, s.alice, 65); err != nil {
+		return fmt.Errorf("repeated key: %w", err)
+	}
+
+	transfer.Amount = 6
+	if _, err := alice.CreateTransfer(keyed, transfer); !errors.Is(err, apperr.ErrIdempotencyKeyReused) {
+		return fmt.Errorf("got %v, want idempotency_key_reused", err)
+	}
+	return nil
+}
+
+func (s *suite) tokenRenewed(ctx context.Context) error {
+	c := client.New(s.url, client.Options{Token: "revoked", Email: s.email("bob"), Password: password})
+	_, err := c.GetUser(ctx, s.bob)
+	return err
+}
+
+func (s *suite) readRetried(ctx context.Context) error {
+	alice := s.newClient("alice")
+	if _, err := alice.GetUser(ctx, s.alice); err != nil {
+		return err
+	}
+	s.proxy.failNext()
+	_, err := alice.GetUser(ctx, s.alice)
+	return err
+}
+
+func (s *suite) fileResponse(ctx context.Context) error {
+	file, err := s.newClient("admin").ExportAuditEvents(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if file.ContentType == "" || len(file.Body) == 0 {
+		return fmt.Errorf("got an empty export of type %q", file.ContentType)
+	}
+	return nil
+}
+
+// wantCredit checks the credit of account id as an admin sees it.
+func (s *suite) wantCredit(ctx context.Context, id uint, want float64) error {
+	user, err := s.newClient("admin").GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if user.Credit != want {
+		return fmt.Errorf("credit of user %d is %v, want %v", id, user.Credit, want)
+	}
+	return nil
+}
@@ -0,0 +1,466 @@
+// Command clientgen generates the operations and types of package client
+// from the OpenAPI document of the v1 API. The document is taken from the
+// router itself, as served at /openapi.json, so the client follows the
+// routes as they are registered. It needs no database.
+package main
+
+import (
+	"Ledger/config"
+	"Ledger/src/factory"
+	"Ledger/src/router"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+
+	_ "github.com/lib/pq"
+)
+
+func main() {
+	out := flag.String("out", "client/operations.go", "file to write the generated code to")
+	flag.Parse()
+
+	doc, err := document()
+	if err != nil {
+		log.Fatalf("Failed to build the OpenAPI document: %v", err)
+	}
+	code, err := generate(doc)
+	if err != nil {
+		log.Fatalf("Failed to generate the client: %v", err)
+	}
+	if err := os.WriteFile(*out, code, 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// document asks the router for its OpenAPI document. The database is never
+// opened, as describing the routes runs no query.
+func document() (*openAPI, error) {
+	cfg := config.Default()
+	db, err := sql.Open("postgres", "")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+	// The request log of the router is noise here.
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	rec := httptest.NewRecorder()
+	router.New(factory.NewPostgresFactory(db, &cfg)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		return nil, fmt.Errorf("/openapi.json answered %d", rec.Code)
+	}
+	var doc openAPI
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// The parts of the document the generator reads.
+
+type openAPI struct {
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Description string                `json:"description"`
+	Security    []map[string][]string `json:"security"`
+	Parameters  []parameter           `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]mediaType `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]struct {
+		Content map[string]mediaType `json:"content"`
+	} `json:"responses"`
+
+	method string
+	path   string
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Schema      *schema `json:"schema"`
+}
+
+type mediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Minimum              *float64           `json:"minimum"`
+	Items                *schema            `json:"items"`
+	AdditionalProperties *schema            `json:"additionalProperties"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+}
+
+const (
+	refPrefix = "#/components/schemas/"
+	jsonType  = "application/json"
+)
+
+// generator renders the operations and the components they use.
+type generator struct {
+	doc *openAPI
+	buf bytes.Buffer
+	// names maps components to the Go names of their types.
+	names map[string]string
+	// used lists the components the operations refer to.
+	used    map[string]bool
+	imports map[string]bool
+}
+
+func generate(doc *openAPI) ([]byte, error) {
+	g := &generator{doc: doc, names: typeNames(doc), used: map[string]bool{}, imports: map[string]bool{"context": true}}
+
+	var ops []*operation
+	for path, methods := range doc.Paths {
+		if !strings.HasPrefix(path, "/v1/") {
+			continue
+		}
+		for method, op := range methods {
+			op.method, op.path = strings.ToUpper(method), path
+			ops = append(ops, op)
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].path != ops[j].path {
+			return ops[i].path < ops[j].path
+		}
+		return ops[i].method < ops[j].method
+	})
+
+	var body bytes.Buffer
+	for _, op := range ops {
+		if err := g.operation(&body, op); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", op.method, op.path, err)
+		}
+	}
+	for _, name := range g.components() {
+		if err := g.component(&body, name); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	fmt.Fprintf(&g.buf, "// Code generated by cmd/clientgen from the OpenAPI document; DO NOT EDIT.\n\npackage client\n\nimport (\n")
+	imports := make([]string, 0, len(g.imports))
+	for imp := range g.imports {
+		imports = append(imports, imp)
+	}
+	sort.Strings(imports)
+	for _, imp := range imports {
+		fmt.Fprintf(&g.buf, "\t%q\n", imp)
+	}
+	g.buf.WriteString(")\n")
+	g.buf.Write(body.Bytes())
+	return format.Source(g.buf.Bytes())
+}
+
+// operation renders the method of op, and for an operation answering in
+// other formats than JSON a File method as well.
+func (g *generator) operation(w *bytes.Buffer, op *operation) error {
+	name := exported(op.OperationID)
+	var args, pathArgs []string
+	var query []parameter
+	idempotent := false
+	for _, p := range op.Parameters {
+		switch p.In {
+		case "path":
+			// The path parameters are the IDs of users.
+			if p.Schema == nil || p.Schema.Type != "integer" {
+				return fmt.Errorf("path parameter %s: only IDs are supported", p.Name)
+			}
+			args = append(args, p.Name+" uint")
+			pathArgs = append(pathArgs, p.Name)
+		case "query":
+			if p.Schema == nil || p.Schema.Type != "string" {
+				return fmt.Errorf("query parameter %s: only strings are supported", p.Name)
+			}
+			query = append(query, p)
+		case "header":
+			if p.Name != "Idempotency-Key" {
+				return fmt.Errorf("header parameter %s is not supported", p.Name)
+			}
+			idempotent = true
+		}
+	}
+	if len(query) > 0 {
+		args = append(args, fmt.Sprintf("params *%sParams", name))
+	}
+	if op.RequestBody != nil {
+		typ, err := g.goType(op.RequestBody.Content[jsonType].Schema, true)
+		if err != nil {
+			return err
+		}
+		args = append(args, "body "+typ)
+	}
+
+	var jsonResult *schema
+	var files []string
+	for status, resp := range op.Responses {
+		if !strings.HasPrefix(status, "2") {
+			continue
+		}
+		for mt, content := range resp.Content {
+			if mt == jsonType {
+				jsonResult = content.Schema
+			} else {
+				files = append(files, mt)
+			}
+		}
+	}
+	sort.Strings(files)
+
+	doc := strings.ToLower(op.Summary[:1]) + op.Summary[1:]
+	call := g.call(op, pathArgs, query, idempotent)
+	signature := strings.Join(append([]string{"ctx context.Context"}, args...), ", ")
+
+	if len(query) > 0 {
+		fmt.Fprintf(w, "\n// %sParams holds the query parameters of %s.\ntype %sParams struct {\n", name, name, name)
+		for _, p := range query {
+			if p.Description != "" {
+				fmt.Fprintf(w, "\t// %s\n", p.Description)
+			}
+			fmt.Fprintf(w, "\t%s string\n", exported(p.Name))
+		}
+		w.WriteString("}\n")
+	}
+
+	fmt.Fprintf(w, "\n// %s calls %s %s to %s.", name, op.method, op.path, doc)
+	if op.Description != "" {
+		fmt.Fprintf(w, " %s", op.Description)
+	}
+	w.WriteString("\n")
+	switch {
+	case jsonResult != nil:
+		typ, err := g.goType(jsonResult, true)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(typ, "[]") {
+			fmt.Fprintf(w, "func (c *Client) %s(%s) (%s, error) {\n\tvar out %s\n\t%s\n\tif err := c.do(ctx, req, &out); err != nil {\n\t\treturn nil, err\n\t}\n\treturn out, nil\n}\n",
+				name, signature, typ, typ, call)
+		} else {
+			fmt.Fprintf(w, "func (c *Client) %s(%s) (*%s, error) {\n\tvar out %s\n\t%s\n\tif err := c.do(ctx, req, &out); err != nil {\n\t\treturn nil, err\n\t}\n\treturn &out, nil\n}\n",
+				name, signature, typ, typ, call)
+		}
+		if len(files) > 0 {
+			fmt.Fprintf(w, "\n// %sFile is %s for the formats other than JSON: %s.\n", name, name, strings.Join(files, ", "))
+			fmt.Fprintf(w, "func (c *Client) %sFile(%s) (*File, error) {\n\tvar out File\n\t%s\n\tif err := c.do(ctx, req, &out); err != nil {\n\t\treturn nil, err\n\t}\n\treturn &out, nil\n}\n",
+				name, signature, call)
+		}
+	case len(files) > 0:
+		fmt.Fprintf(w, "func (c *Client) %s(%s) (*File, error) {\n\tvar out File\n\t%s\n\tif err := c.do(ctx, req, &out); err != nil {\n\t\treturn nil, err\n\t}\n\treturn &out, nil\n}\n",
+			name, signature, call)
+	default:
+		fmt.Fprintf(w, "func (c *Client) %s(%s) error {\n\t%s\n\treturn c.do(ctx, req, nil)\n}\n", name, signature, call)
+	}
+	return nil
+}
+
+// call renders the request of op.
+func (g *generator) call(op *operation, pathArgs []string, query []parameter, idempotent bool) string {
+	path := fmt.Sprintf("%q", op.path)
+	if len(pathArgs) > 0 {
+		format := op.path
+		for _, arg := range pathArgs {
+			format = strings.Replace(format, "{"+arg+"}", "%d", 1)
+		}
+		path = fmt.Sprintf("fmt.Sprintf(%q, %s)", format, strings.Join(pathArgs, ", "))
+		g.imports["fmt"] = true
+	}
+
+	fields := []string{fmt.Sprintf("method: %q", op.method), "path: " + path}
+	if op.RequestBody != nil {
+		fields = append(fields, "body: body")
+	}
+	if len(op.Security) > 0 {
+		fields = append(fields, "auth: true")
+	}
+	if idempotent {
+		fields = append(fields, "idempotent: true")
+	}
+	req := fmt.Sprintf("req := request{%s}", strings.Join(fields, ", "))
+	if len(query) == 0 {
+		return req
+	}
+
+	g.imports["net/url"] = true
+	var b strings.Builder
+	b.WriteString(req + "\n\tif params != nil {\n\t\treq.query = url.Values{}\n")
+	for _, p := range query {
+		field := exported(p.Name)
+		fmt.Fprintf(&b, "\t\tif params.%s != \"\" {\n\t\t\treq.query.Set(%q, params.%s)\n\t\t}\n", field, p.Name, field)
+	}
+	b.WriteString("\t}")
+	return b.String()
+}
+
+// components returns the components the operations use, and those they
+// use in turn, sorted by Go name.
+func (g *generator) components() []string {
+	var names []string
+	seen := map[string]bool{}
+	var visit func(s *schema)
+	visit = func(s *schema) {
+		if s == nil {
+			return
+		}
+		if name, ok := strings.CutPrefix(s.Ref, refPrefix); ok {
+			if seen[name] {
+				return
+			}
+			seen[name] = true
+			names = append(names, name)
+			s = g.doc.Components.Schemas[name]
+		}
+		visit(s.Items)
+		visit(s.AdditionalProperties)
+		for _, p := range s.Properties {
+			visit(p)
+		}
+	}
+	for name := range g.used {
+		visit(&schema{Ref: refPrefix + name})
+	}
+	sort.Slice(names, func(i, j int) bool { return g.names[names[i]] < g.names[names[j]] })
+	return names
+}
+
+func (g *generator) component(w *bytes.Buffer, name string) error {
+	s := g.doc.Components.Schemas[name]
+	if s == nil || s.Type != "object" {
+		return fmt.Errorf("not an object")
+	}
+	required := map[string]bool{}
+	for _, r := range s.Required {
+		required[r] = true
+	}
+	props := make([]string, 0, len(s.Properties))
+	for p := range s.Properties {
+		props = append(props, p)
+	}
+	sort.Strings(props)
+
+	fmt.Fprintf(w, "\n// %s is the %s schema of the API.\ntype %s struct {\n", g.names[name], name, g.names[name])
+	for _, p := range props {
+		typ, err := g.goType(s.Properties[p], required[p])
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+		tag := p
+		if !required[p] {
+			tag += ",omitempty"
+		}
+		fmt.Fprintf(w, "\t%s %s `json:%q`\n", exported(p), typ, tag)
+	}
+	w.WriteString("}\n")
+	return nil
+}
+
+// goType returns the Go type of s. Optional structs and times are pointers,
+// so that omitting them is not taken for a zero value.
+func (g *generator) goType(s *schema, required bool) (string, error) {
+	if s == nil {
+		return "", fmt.Errorf("no schema")
+	}
+	pointer := ""
+	if !required {
+		pointer = "*"
+	}
+	if name, ok := strings.CutPrefix(s.Ref, refPrefix); ok {
+		if _, ok := g.doc.Components.Schemas[name]; !ok {
+			return "", fmt.Errorf("unknown component %s", name)
+		}
+		g.used[name] = true
+		return pointer + g.names[name], nil
+	}
+
+	switch s.Type {
+	case "string":
+		if s.Format == "date-time" {
+			g.imports["time"] = true
+			return pointer + "time.Time", nil
+		}
+		return "string", nil
+	case "integer":
+		if s.Minimum != nil && *s.Minimum >= 0 {
+			return "uint", nil
+		}
+		return "int64", nil
+	case "number":
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		item, err := g.goType(s.Items, true)
+		return "[]" + item, err
+	case "object":
+		if s.AdditionalProperties != nil {
+			value, err := g.goType(s.AdditionalProperties, true)
+			return "map[string]" + value, err
+		}
+	}
+	g.imports["encoding/json"] = true
+	return "json.RawMessage", nil
+}
+
+// unqualified lists the packages whose types keep their names in the
+// client; the types of other packages are prefixed with the package, as in
+// ChainReport, unless their name starts with it already.
+var unqualified = map[string]bool{"models": true, "handlers": true}
+
+func typeNames(doc *openAPI) map[string]string {
+	names := map[string]string{}
+	for component := range doc.Components.Schemas {
+		pkg, name, ok := strings.Cut(component, ".")
+		if !ok {
+			pkg, name = "", component
+		}
+		if pkg != "" && !unqualified[pkg] && !strings.HasPrefix(strings.ToLower(name), pkg) {
+			name = exported(pkg) + name
+		}
+		names[component] = name
+	}
+	return names
+}
+
+// initialisms are the words written in capitals in Go names.
+var initialisms = map[string]string{"id": "ID", "ids": "IDs", "ip": "IP", "url": "URL", "ns": "NS"}
+
+// exported turns a snake_case or camelCase name into an exported Go name.
+func exported(name string) string {
+	var b strings.Builder
+	for _, word := range strings.Split(name, "_") {
+		if word == "" {
+			continue
+		}
+		if initialism, ok := initialisms[strings.ToLower(word)]; ok {
+			b.WriteString(initialism)
+			continue
+		}
+		b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,406 @@
+// Command lambda-local runs the lambda handler behind a local HTTP server.
+// Each request is converted into the API Gateway proxy event the deployed
+// function receives. POST /users/add-user goes through an in-memory queue
+// instead, the way the API Gateway SQS integration delivers it, and a
+// poller feeds that queue to the handler as SQS batches.
+package main
+
+import (
+	"Ledger/config"
+	"Ledger/pkg/logging"
+	"Ledger/pkg/tracing"
+	"Ledger/src/queue"
+	"Ledger/src/serverless"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// queuedPath is the route API Gateway sends to SQS instead of the lambda.
+const queuedPath = "/users/add-user"
+
+func main() {
+	flags := flag.NewFlagSet("lambda-local", flag.ContinueOnError)
+	visibility := flags.Duration("visibility", 5*time.Second, "how long a failed message stays hidden before it is retried")
+	maxReceives := flags.Int("max-receives", 5, "deliveries before the simulated redrive policy moves a message to the dead-letter queue")
+	pollInterval := flags.Duration("poll-interval", 500*time.Millisecond, "how often the queue is polled")
+
+	defaults := serverless.Defaults()
+	defaults.Server.Addr = "127.0.0.1:3000"
+	defaults.DB.SSLMode = "disable"
+	defaults.DB.AutoMigrate = true
+	defaults.Log.Format = "text"
+	// Metrics are scraped from /metrics instead of filling the console.
+	defaults.Metrics.Enabled = true
+	defaults.Metrics.EMF = false
+
+	cfg, err := config.ParseWith(defaults, flags, os.Args[1:])
+	if err != nil {
+		logging.Fatal("Failed to load configuration", err)
+	}
+	// The simulated queue is the one the claim lease must outlive.
+	cfg.Queue.VisibilityTimeout = *visibility
+	if err := cfg.Validate(); err != nil {
+		logging.Fatal("Failed to load configuration", err)
+	}
+	if _, err := logging.Setup(cfg.Log); err != nil {
+		logging.Fatal("Failed to set up logging", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	deadLetter := queue.NewMemoryQueue()
+	handler, err := serverless.Setup(ctx, cfg, deadLetter)
+	if err != nil {
+		logging.Fatal("Failed to set up the handler", err)
+	}
+
+	e := &emulator{
+		handler:     handler,
+		queue:       queue.NewMemoryQueue(),
+		deadLetter:  deadLetter,
+		visibility:  *visibility,
+		maxReceives: *maxReceives,
+		started:     time.Now().UnixNano(),
+	}
+	go e.poll(ctx, *pollInterval)
+
+	server := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           e,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	slog.Info("lambda-local listening", "url", "http://"+cfg.Server.Addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logging.Fatal("Failed to start server", err)
+	}
+}
+
+type emulator struct {
+	handler     *serverless.Handler
+	queue       *queue.MemoryQueue
+	deadLetter  *queue.MemoryQueue
+	visibility  time.Duration
+	maxReceives int
+	started     int64
+	requests    atomic.Int64
+}
+
+func (e *emulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Request IDs key the idempotency store, so they must stay unique across
+	// restarts against the same database.
+	requestID := "local-" + strconv.FormatInt(e.started, 36) + "-" + strconv.FormatInt(e.requests.Add(1), 10)
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case r.URL.Path == "/_local/dlq" && r.Method == http.MethodGet:
+		e.serveDeadLetters(w)
+	case r.URL.Path == "/_local/schedule" && r.Method == http.MethodPost:
+		e.schedule(w, r)
+	case r.URL.Path == queuedPath && r.Method == http.MethodPost:
+		e.enqueue(w, r, requestID, body)
+	default:
+		e.invoke(w, r, requestID, body)
+	}
+}
+
+// invoke runs one HTTP request through the handler as a REST API proxy
+// event.
+func (e *emulator) invoke(w http.ResponseWriter, r *http.Request, requestID string, body []byte) {
+	event := proxyRequest(r, requestID, body)
+	payload, err := json.Marshal(event)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result, err := e.handler.HandleRequest(r.Context(), payload)
+	if err != nil {
+		slog.Error("Handler error", "method", r.Method, "path", r.URL.Path, "error", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	// The handler returns whatever the lambda runtime would serialize.
+	raw, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	var resp events.APIGatewayProxyResponse
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	writeProxyResponse(w, resp)
+	slog.Debug("Proxy response", "method", r.Method, "path", r.URL.Path, "status", resp.StatusCode)
+}
+
+func proxyRequest(r *http.Request, requestID string, body []byte) events.APIGatewayProxyRequest {
+	headers := make(map[string]string, len(r.Header))
+	for k, v := range r.Header {
+		headers[k] = v[len(v)-1]
+	}
+	headers["Host"] = r.Host
+
+	query := r.URL.Query()
+	single := make(map[string]string, len(query))
+	for k, v := range query {
+		single[k] = v[len(v)-1]
+	}
+
+	sourceIP, _, _ := net.SplitHostPort(r.RemoteAddr)
+	event := events.APIGatewayProxyRequest{
+		Resource:                        r.URL.Path,
+		Path:                            r.URL.Path,
+		HTTPMethod:                      r.Method,
+		Headers:                         headers,
+		MultiValueHeaders:               r.Header,
+		QueryStringParameters:           single,
+		MultiValueQueryStringParameters: query,
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID:        requestID,
+			Stage:            "local",
+			HTTPMethod:       r.Method,
+			Path:             r.URL.Path,
+			RequestTimeEpoch: time.Now().UnixMilli(),
+			Identity:         events.APIGatewayRequestIdentity{SourceIP: sourceIP},
+		},
+	}
+	if utf8.Valid(body) {
+		event.Body = string(body)
+	} else {
+		event.Body = base64.StdEncoding.EncodeToString(body)
+		event.IsBase64Encoded = true
+	}
+	return event
+}
+
+func writeProxyResponse(w http.ResponseWriter, resp events.APIGatewayProxyResponse) {
+	for k, v := range resp.Headers {
+		w.Header().Set(k, v)
+	}
+	for k, values := range resp.MultiValueHeaders {
+		w.Header().Del(k)
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+
+	body := []byte(resp.Body)
+	if resp.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(resp.Body)
+		if err != nil {
+			http.Error(w, "handler returned an invalid base64 body", http.StatusBadGateway)
+			return
+		}
+		body = decoded
+	}
+
+	status := resp.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// enqueue does what the API Gateway SQS integration does: wrap the request
+// body in a create_user envelope keyed by the request ID, pass the trace
+// context of the request on in message attributes and answer with the
+// integration response at once.
+func (e *emulator) enqueue(w http.ResponseWriter, r *http.Request, requestID string, body []byte) {
+	if !json.Valid(body) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	env := &queue.Envelope{
+		Version: queue.EnvelopeVersion,
+		ID:      requestID,
+		Command: queue.CreateUser,
+		Payload: body,
+	}
+	msgBody, err := env.Encode()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	attrs := make(map[string]string)
+	tracing.Inject(tracing.Extract(r.Context(), propagation.HeaderCarrier(r.Header)), attrs)
+	e.queue.Send(context.Background(), queue.Message{Body: msgBody, Attributes: attrs})
+	slog.Info("Request queued", "path", queuedPath, "request_id", requestID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "Kullanıcı oluşturma isteği kuyruğa alındı",
+		"status":  "success",
+	})
+}
+
+// poll feeds the queue to the handler in batches of five, like the event
+// source mapping, and applies the redrive policy to messages that keep
+// failing.
+func (e *emulator) poll(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		messages, err := e.queue.Receive(ctx, 5, e.visibility)
+		if err != nil || len(messages) == 0 {
+			continue
+		}
+		e.deliver(ctx, messages)
+	}
+}
+
+func (e *emulator) deliver(ctx context.Context, messages []queue.Message) {
+	event := events.SQSEvent{Records: make([]events.SQSMessage, len(messages))}
+	for i, m := range messages {
+		attrs := make(map[string]events.SQSMessageAttribute, len(m.Attributes))
+		for k, v := range m.Attributes {
+			attrs[k] = events.SQSMessageAttribute{DataType: "String", StringValue: &v}
+		}
+		event.Records[i] = events.SQSMessage{
+			MessageId:         m.ID,
+			ReceiptHandle:     m.ReceiptHandle,
+			Body:              m.Body,
+			Attributes:        map[string]string{"ApproximateReceiveCount": strconv.Itoa(m.ReceiveCount)},
+			MessageAttributes: attrs,
+			EventSource:       "aws:sqs",
+		}
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Encoding the queue batch failed", "error", err)
+		return
+	}
+
+	failed := make(map[string]bool)
+	result, err := e.handler.HandleRequest(ctx, payload)
+	if err != nil {
+		slog.Error("Handler error, the whole batch is retried", "error", err)
+		for _, m := range messages {
+			failed[m.ID] = true
+		}
+	} else if raw, err := json.Marshal(result); err == nil {
+		var resp events.SQSEventResponse
+		json.Unmarshal(raw, &resp)
+		for _, f := range resp.BatchItemFailures {
+			failed[f.ItemIdentifier] = true
+		}
+	}
+
+	for _, m := range messages {
+		switch {
+		case !failed[m.ID]:
+			e.queue.Delete(ctx, m)
+			slog.Info("Queue message processed", "message_id", m.ID)
+		case m.ReceiveCount >= e.maxReceives:
+			e.queue.Delete(ctx, m)
+			e.deadLetter.Send(ctx, m)
+			slog.Warn("Queue message moved to the dead-letter queue", "message_id", m.ID, "receives", m.ReceiveCount)
+		default:
+			slog.Info("Queue message failed, retrying", "message_id", m.ID, "after", e.visibility)
+		}
+	}
+}
+
+// schedule delivers the EventBridge event a schedule sends, with the job
+// and repair_cache=true passed on as the event detail, and answers with
+// the handler's result.
+func (e *emulator) schedule(w http.ResponseWriter, r *http.Request) {
+	detail, _ := json.Marshal(map[string]interface{}{
+		"job":          r.URL.Query().Get("job"),
+		"repair_cache": r.URL.Query().Get("repair_cache") == "true",
+	})
+	payload, err := json.Marshal(events.EventBridgeEvent{
+		Version:    "0",
+		ID:         "local-schedule-" + strconv.FormatInt(time.Now().UnixNano(), 36),
+		DetailType: "Scheduled Event",
+		Source:     "aws.events",
+		Time:       time.Now().UTC(),
+		Detail:     detail,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	result, err := e.handler.HandleRequest(r.Context(), payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// serveDeadLetters lists the dead-letter queue without consuming it.
+func (e *emulator) serveDeadLetters(w http.ResponseWriter) {
+	entries, err := queue.Scan(context.Background(), e.deadLetter, 1000, 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	type deadLetter struct {
+		ID            string            `json:"id"`
+		Command       string            `json:"command"`
+		FailureReason string            `json:"failure_reason"`
+		Attributes    map[string]string `json:"attributes,omitempty"`
+		Body          string            `json:"body"`
+	}
+	out := make([]deadLetter, len(entries))
+	for i, entry := range entries {
+		out[i] = deadLetter{
+			ID:            entry.ID,
+			Command:       entry.Command(),
+			FailureReason: entry.FailureReason(),
+			Attributes:    entry.Attributes,
+			Body:          entry.Body,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
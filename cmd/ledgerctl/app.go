@@ -0,0 +1,189 @@
+package main
+
+import (
+	"Ledger/config"
+	"Ledger/pkg/cache"
+	"Ledger/pkg/db"
+	"Ledger/pkg/logging"
+	"Ledger/src/audit"
+	"Ledger/src/balance"
+	"Ledger/src/chain"
+	"Ledger/src/lockout"
+	"Ledger/src/models"
+	"Ledger/src/repository"
+	"Ledger/src/services"
+	"Ledger/src/statement"
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// command holds the flags every database subcommand shares.
+type command struct {
+	flags *flag.FlagSet
+	out   *output
+	actor *string
+}
+
+func newCommand(name string) *command {
+	flags := flag.NewFlagSet("ledgerctl "+name, flag.ContinueOnError)
+	return &command{
+		flags: flags,
+		out:   newOutput(flags),
+		actor: flags.String("actor", os.Getenv("USER"), "operator recorded in the audit trail"),
+	}
+}
+
+// parse splits args into positional arguments and flags and loads the
+// configuration together with the command's own flags.
+func (c *command) parse(args []string) ([]string, *config.Config, error) {
+	positional, flagArgs := splitArgs(args)
+	defaults := config.Default()
+	// Only problems are logged unless -log-level asks for more.
+	defaults.Log.Level = "warn"
+	cfg, err := config.ParseWith(defaults, c.flags, flagArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return positional, cfg, nil
+}
+
+// requireActor fails for mutating commands run without an operator name to
+// put in the audit trail.
+func (c *command) requireActor() error {
+	if strings.TrimSpace(*c.actor) == "" {
+		return errors.New("an operator name is required (-actor or USER)")
+	}
+	return nil
+}
+
+// app is the service layer wired straight to the database, the way the
+// server wires it, plus the audit trail, the transaction chain, the
+// balance history, the statements and the login lockouts.
+type app struct {
+	db         *sql.DB
+	users      services.UserService
+	audit      *audit.SQLStore
+	chain      *chain.Store
+	balances   *balance.Store
+	statements *statement.Generator
+	lockouts   *lockout.Store
+	cache      *cache.RedisCache
+	actor      string
+}
+
+// openApp connects to the configured database. The repository runs without
+// the credit cache so reads are authoritative; on MySQL, where the server
+// caches credits in Redis, mutations invalidate the cached entries instead.
+func openApp(cfg *config.Config, actor string) (*app, error) {
+	if err := cfg.ValidateDB(); err != nil {
+		return nil, err
+	}
+	// Logs go to stderr, so they never mix with the output.
+	if _, err := logging.Setup(cfg.Log); err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := db.OpenSQL(cfg.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &app{
+		db:       sqlDB,
+		audit:    audit.NewSQLStore(sqlDB, cfg.DB.Driver),
+		chain:    chain.NewStore(sqlDB, cfg.DB.Driver, []byte(cfg.Ledger.CheckpointKey)),
+		balances: balance.NewStore(sqlDB, cfg.DB.Driver),
+		lockouts: lockout.NewStore(sqlDB, cfg.DB.Driver, cfg.Lockout),
+		actor:    "cli:" + actor,
+	}
+
+	var repo repository.UserRepository
+	switch cfg.DB.Driver {
+	case "mysql":
+		// GORM logs to stdout, which would corrupt -json output.
+		gormDB, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB}), &gorm.Config{
+			Logger: logger.Default.LogMode(logger.Silent),
+		})
+		if err != nil {
+			sqlDB.Close()
+			return nil, err
+		}
+		repo = repository.NewUserRepository(gormDB, nil)
+		a.cache = cache.NewRedisCache(cfg.Redis)
+	case "postgres":
+		repo = repository.NewPostgresUserRepository(sqlDB)
+	}
+	a.users = services.NewUserService(repo)
+	a.statements = statement.NewGenerator(sqlDB, cfg.DB.Driver, a.balances)
+
+	return a, nil
+}
+
+func (a *app) Close() error {
+	return a.db.Close()
+}
+
+// resolveUser looks a user up by numeric ID or by email.
+func (a *app) resolveUser(ctx context.Context, ref string) (*models.User, error) {
+	if id, err := strconv.ParseUint(ref, 10, 32); err == nil {
+		return a.users.GetUserByID(ctx, uint(id))
+	}
+	if strings.Contains(ref, "@") {
+		return a.users.GetUserByEmail(ctx, ref)
+	}
+	return nil, fmt.Errorf("%q is neither a user ID nor an email", ref)
+}
+
+// invalidate drops cached credits so the server does not keep serving the
+// balance from before a mutation. A cache failure is only reported; the
+// entries expire on their own.
+func (a *app) invalidate(ctx context.Context, userIDs ...uint) {
+	if a.cache == nil || len(userIDs) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	if err := a.cache.InvalidateMultipleUserCredits(ctx, userIDs); err != nil {
+		fmt.Fprintf(os.Stderr, "ledgerctl: warning: could not invalidate cached credits: %v\n", err)
+	}
+}
+
+// audited returns the service with every change recorded in the audit
+// trail, in the same transaction, as made by the operator for reason.
+func (a *app) audited(reason string) services.UserService {
+	return a.users.WithAudit(audit.Event{Actor: a.actor, Reason: reason})
+}
+
+// readSecret returns value, or the first line of stdin when value is empty,
+// so passwords can be piped in instead of appearing in the shell history.
+func readSecret(value, what string) (string, error) {
+	if value != "" {
+		return value, nil
+	}
+	fmt.Fprintf(os.Stderr, "%s: ", what)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("reading %s from stdin: %w", what, err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("%s is empty", what)
+	}
+	return line, nil
+}
+
+func formatAmount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
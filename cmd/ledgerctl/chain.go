@@ -0,0 +1,115 @@
+package main
+
+import (
+	"Ledger/src/audit"
+	"Ledger/src/chain"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// runChain implements "ledgerctl chain verify|checkpoint|checkpoints".
+func runChain(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	subcommand := args[0]
+	switch subcommand {
+	case "verify", "checkpoint", "checkpoints":
+	default:
+		return errUsage
+	}
+
+	c := newCommand("chain " + subcommand)
+	positional, cfg, err := c.parse(args[1:])
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errUsage
+	}
+	if subcommand == "checkpoint" {
+		if err := c.requireActor(); err != nil {
+			return err
+		}
+	}
+
+	a, err := openApp(cfg, *c.actor)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	switch subcommand {
+	case "verify":
+		return chainVerify(ctx, c.out, a)
+	case "checkpoint":
+		return chainCheckpoint(ctx, c.out, a)
+	default:
+		checkpoints, err := a.chain.Checkpoints(ctx)
+		if err != nil {
+			return err
+		}
+		return printCheckpoints(c.out, checkpoints)
+	}
+}
+
+// chainVerify fails when the chain is broken, so it can run from cron.
+func chainVerify(ctx context.Context, out *output, a *app) error {
+	report, err := a.chain.Verify(ctx)
+	if err != nil {
+		return err
+	}
+
+	if out.json {
+		if err := out.print(report, nil, nil); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(out.w, "%d chained entries, %d recorded before the chain, %d checkpoints\n",
+			report.Entries, report.Unchained, report.Checkpoints)
+		if !report.SignaturesChecked && report.Checkpoints > 0 {
+			fmt.Fprintln(out.w, "checkpoint signatures not checked: no signing key configured")
+		}
+		if report.OK() {
+			fmt.Fprintf(out.w, "chain is intact, head %s\n", report.Head)
+		}
+	}
+
+	if !report.OK() {
+		return errors.New("chain broken at " + report.Broken.String())
+	}
+	return nil
+}
+
+func chainCheckpoint(ctx context.Context, out *output, a *app) error {
+	checkpoint, err := a.chain.Checkpoint(ctx)
+	if err != nil {
+		return err
+	}
+	if err := a.audit.Record(ctx, audit.Event{
+		Actor:  a.actor,
+		Action: chain.ActionCheckpoint,
+		Target: "ledger_checkpoints",
+		After:  audit.State(checkpoint),
+	}); err != nil {
+		return err
+	}
+	return printCheckpoints(out, []chain.Checkpoint{*checkpoint})
+}
+
+func printCheckpoints(out *output, checkpoints []chain.Checkpoint) error {
+	rows := make([][]string, len(checkpoints))
+	for i, c := range checkpoints {
+		rows[i] = []string{
+			strconv.FormatUint(c.ID, 10),
+			c.CreatedAt.UTC().Format(time.RFC3339),
+			strconv.FormatUint(c.LogID, 10),
+			strconv.FormatUint(c.Length, 10),
+			c.Hash,
+		}
+	}
+	return out.print(checkpoints, []string{"ID", "CREATED", "ENTRY", "LENGTH", "HASH"}, rows)
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"Ledger/src/models"
+	"Ledger/src/queue"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// runCredit implements "ledgerctl credit".
+func runCredit(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "adjust" {
+		return errUsage
+	}
+
+	c := newCommand("credit adjust")
+	reason := c.flags.String("reason", "", "why the balance is adjusted (required)")
+	positional, cfg, err := c.parse(args[1:])
+	if err != nil {
+		return err
+	}
+	if len(positional) != 2 {
+		return errors.New("usage: ledgerctl credit adjust <id|email> <amount> -reason text")
+	}
+	amount, err := strconv.ParseFloat(positional[1], 64)
+	if err != nil {
+		return fmt.Errorf("invalid amount %q", positional[1])
+	}
+	if strings.TrimSpace(*reason) == "" {
+		return errors.New("-reason is required for credit adjustments")
+	}
+	if err := c.requireActor(); err != nil {
+		return err
+	}
+
+	a, err := openApp(cfg, *c.actor)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	user, err := a.resolveUser(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	before, after, err := a.audited(*reason).AdjustCredit(ctx, user.ID, amount)
+	if err != nil {
+		return err
+	}
+	a.invalidate(ctx, user.ID)
+
+	result := map[string]interface{}{"id": user.ID, "email": user.Email, "amount": amount, "before": before, "after": after}
+	return c.out.print(result, []string{"ID", "EMAIL", "AMOUNT", "BEFORE", "AFTER"}, [][]string{{
+		strconv.FormatUint(uint64(user.ID), 10), user.Email, formatAmount(amount), formatAmount(before), formatAmount(after),
+	}})
+}
+
+// runBatch implements "ledgerctl batch run". The file is either the JSON
+// body of the batch-update endpoint or a CSV of user_id,amount rows.
+func runBatch(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "run" {
+		return errUsage
+	}
+
+	c := newCommand("batch run")
+	reason := c.flags.String("reason", "", "why the batch is applied (required)")
+	positional, cfg, err := c.parse(args[1:])
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: ledgerctl batch run <file> -reason text")
+	}
+	if strings.TrimSpace(*reason) == "" {
+		return errors.New("-reason is required for batch runs")
+	}
+	if err := c.requireActor(); err != nil {
+		return err
+	}
+
+	raw, err := os.ReadFile(positional[0])
+	if err != nil {
+		return err
+	}
+	transactions, err := parseBatch(raw)
+	if err != nil {
+		return fmt.Errorf("%s: %w", positional[0], err)
+	}
+	if len(transactions) == 0 {
+		return fmt.Errorf("%s: no transactions", positional[0])
+	}
+	if len(transactions) > cfg.Limits.MaxBatchSize {
+		return fmt.Errorf("batch size exceeds the limit of %d", cfg.Limits.MaxBatchSize)
+	}
+
+	a, err := openApp(cfg, *c.actor)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	results := a.audited(*reason).ProcessBatchCreditUpdate(ctx, transactions)
+
+	var applied []uint
+	rows := make([][]string, len(results))
+	for i, r := range results {
+		status := "ok"
+		if r.Success {
+			applied = append(applied, r.UserID)
+		} else {
+			status = r.Error
+		}
+		rows[i] = []string{strconv.FormatUint(uint64(r.UserID), 10), formatAmount(r.Amount), status}
+	}
+	a.invalidate(ctx, applied...)
+
+	if err := c.out.print(results, []string{"USER", "AMOUNT", "STATUS"}, rows); err != nil {
+		return err
+	}
+	if failed := len(results) - len(applied); failed > 0 {
+		return fmt.Errorf("%d of %d entries failed", failed, len(results))
+	}
+	return nil
+}
+
+func parseBatch(raw []byte) ([]models.BatchTransaction, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		var payload queue.BatchUpdatePayload
+		if trimmed[0] == '[' {
+			err := json.Unmarshal(trimmed, &payload.Transactions)
+			return payload.Transactions, err
+		}
+		err := json.Unmarshal(trimmed, &payload)
+		return payload.Transactions, err
+	}
+
+	r := csv.NewReader(bytes.NewReader(trimmed))
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+
+	var transactions []models.BatchTransaction
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			return transactions, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && record[0] == "user_id" {
+			continue
+		}
+
+		userID, err := strconv.ParseUint(record[0], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid user id %q", line, record[0])
+		}
+		amount, err := strconv.ParseFloat(record[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid amount %q", line, record[1])
+		}
+		transactions = append(transactions, models.BatchTransaction{UserID: uint(userID), Amount: amount})
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"Ledger/config"
+	"Ledger/pkg/db"
+	"Ledger/src/audit"
+	"Ledger/src/queue"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dlqMessage is the printed form of a dead-lettered message.
+type dlqMessage struct {
+	ID            string            `json:"id"`
+	Command       string            `json:"command"`
+	FailureReason string            `json:"failure_reason"`
+	ReceiveCount  int               `json:"receive_count"`
+	Attributes    map[string]string `json:"attributes,omitempty"`
+	Body          string            `json:"body"`
+}
+
+func newDLQMessage(e queue.DeadLetterEntry) dlqMessage {
+	return dlqMessage{
+		ID:            e.ID,
+		Command:       e.Command(),
+		FailureReason: e.FailureReason(),
+		ReceiveCount:  e.ReceiveCount,
+		Attributes:    e.Attributes,
+		Body:          e.Body,
+	}
+}
+
+// runDLQ implements "ledgerctl dlq". The dead-letter queue comes from
+// -sqs-dlq-url (SQS_DLQ_URL); set AWS_ENDPOINT_URL_SQS to use a local SQS
+// stand-in.
+func runDLQ(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	command := args[0]
+	positional, flagArgs := splitArgs(args[1:])
+
+	flags := flag.NewFlagSet("ledgerctl dlq "+command, flag.ContinueOnError)
+	out := newOutput(flags)
+	hold := flags.Duration("hold", 30*time.Second, "how long scanned messages stay hidden from other consumers")
+	max := flags.Int("max", 100, "maximum number of messages to list")
+	var edits stringList
+	flags.Var(&edits, "set", "replace a payload field, as path=value (repeatable)")
+	bodyFile := flags.String("body-file", "", "replace the whole message body with this file")
+	reason := flags.String("reason", "", "why the message is replayed, kept in the audit trail")
+	actor := flags.String("actor", os.Getenv("USER"), "operator recorded in the audit trail")
+	dryRun := flags.Bool("dry-run", false, "print the replayed body without sending it")
+
+	cfg, err := config.ParseWith(config.Default(), flags, flagArgs)
+	if err != nil {
+		return err
+	}
+	if cfg.Queue.DeadLetterURL == "" {
+		return errors.New("the dead-letter queue URL is required (-sqs-dlq-url or SQS_DLQ_URL)")
+	}
+	dlq, err := queue.NewSQSQueueFromEnv(ctx, cfg.Queue.DeadLetterURL)
+	if err != nil {
+		return err
+	}
+
+	switch command {
+	case "list":
+		return dlqList(ctx, out, dlq, *max, *hold)
+	case "show":
+		if len(positional) != 1 {
+			return errors.New("usage: ledgerctl dlq show <message-id>")
+		}
+		return dlqShow(ctx, out, dlq, positional[0], *hold)
+	case "replay":
+		if len(positional) != 1 {
+			return errors.New("usage: ledgerctl dlq replay <message-id> [-set path=value] [-body-file file] [-reason text] [-dry-run]")
+		}
+		r := replayRequest{
+			id:       positional[0],
+			edits:    edits,
+			bodyFile: *bodyFile,
+			reason:   *reason,
+			actor:    *actor,
+			dryRun:   *dryRun,
+			hold:     *hold,
+		}
+		return dlqReplay(ctx, out, cfg, dlq, r)
+	default:
+		return errUsage
+	}
+}
+
+func dlqList(ctx context.Context, out *output, dlq queue.Queue, max int, hold time.Duration) error {
+	entries, err := queue.Scan(ctx, dlq, max, hold)
+	if err != nil {
+		return err
+	}
+
+	messages := make([]dlqMessage, len(entries))
+	rows := make([][]string, len(entries))
+	for i, e := range entries {
+		messages[i] = newDLQMessage(e)
+		rows[i] = []string{e.ID, e.Command(), strconv.Itoa(e.ReceiveCount), truncate(e.FailureReason(), 60)}
+	}
+	return out.print(messages, []string{"ID", "COMMAND", "RECEIVES", "REASON"}, rows)
+}
+
+func dlqShow(ctx context.Context, out *output, dlq queue.Queue, id string, hold time.Duration) error {
+	entry, err := queue.Find(ctx, dlq, id, hold)
+	if err != nil {
+		return err
+	}
+
+	m := newDLQMessage(*entry)
+	rows := [][]string{
+		{"id", m.ID},
+		{"command", m.Command},
+		{"receive count", strconv.Itoa(m.ReceiveCount)},
+		{"failure reason", m.FailureReason},
+	}
+	for k, v := range m.Attributes {
+		if k != queue.AttrFailureReason && k != queue.AttrCommand {
+			rows = append(rows, []string{"attribute " + k, v})
+		}
+	}
+	rows = append(rows, []string{"body", m.Body})
+	return out.print(m, []string{"FIELD", "VALUE"}, rows)
+}
+
+type replayRequest struct {
+	id       string
+	edits    []string
+	bodyFile string
+	reason   string
+	actor    string
+	dryRun   bool
+	hold     time.Duration
+}
+
+func dlqReplay(ctx context.Context, out *output, cfg *config.Config, dlq queue.Queue, r replayRequest) error {
+	if cfg.Queue.URL == "" && !r.dryRun {
+		return errors.New("the target queue URL is required (-sqs-queue-url or SQS_QUEUE_URL)")
+	}
+
+	entry, err := queue.Find(ctx, dlq, r.id, r.hold)
+	if err != nil {
+		return err
+	}
+
+	body, err := replayBody(*entry, r)
+	if err != nil {
+		return err
+	}
+
+	result := map[string]string{"id": entry.ID, "body": body}
+	if r.dryRun {
+		result["status"] = "dry run"
+		return out.print(result, []string{"ID", "STATUS", "BODY"}, [][]string{{entry.ID, "dry run", body}})
+	}
+
+	// The audit trail is opened first so a replay is never sent without a
+	// place to record it.
+	if err := cfg.ValidateDB(); err != nil {
+		return fmt.Errorf("replay records an audit entry and needs the database settings: %w", err)
+	}
+	sqlDB, err := db.OpenSQL(cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+	recorder := audit.NewSQLStore(sqlDB, cfg.DB.Driver)
+
+	target, err := queue.NewSQSQueueFromEnv(ctx, cfg.Queue.URL)
+	if err != nil {
+		return err
+	}
+	if err := queue.Replay(ctx, dlq, target, *entry, body); err != nil {
+		return err
+	}
+
+	if err := recorder.Record(ctx, audit.Event{
+		Actor:  "cli:" + r.actor,
+		Action: "dlq.replay",
+		Target: "sqs-message:" + entry.ID,
+		Before: entry.Body,
+		After:  body,
+		Reason: r.reason,
+	}); err != nil {
+		return fmt.Errorf("message %s was replayed but the audit entry failed: %w", entry.ID, err)
+	}
+
+	result["status"] = "replayed"
+	return out.print(result, []string{"ID", "STATUS", "BODY"}, [][]string{{entry.ID, "replayed", body}})
+}
+
+// replayBody builds the body to replay: the original envelope, or the
+// -body-file contents, with every -set edit applied.
+func replayBody(entry queue.DeadLetterEntry, r replayRequest) (string, error) {
+	if r.bodyFile != "" {
+		raw, err := os.ReadFile(r.bodyFile)
+		if err != nil {
+			return "", err
+		}
+		entry = queue.Inspect(queue.Message{ID: entry.ID, Body: string(raw), Attributes: entry.Attributes})
+	}
+
+	env, err := queue.ReplayEnvelope(entry)
+	if err != nil {
+		return "", fmt.Errorf("message cannot be replayed as is, fix it with -body-file: %w", err)
+	}
+
+	for _, edit := range r.edits {
+		path, value, ok := strings.Cut(edit, "=")
+		if !ok || path == "" {
+			return "", fmt.Errorf("invalid -set %q, want path=value", edit)
+		}
+		if err := queue.SetPayloadField(env, path, value); err != nil {
+			return "", err
+		}
+	}
+
+	return env.Encode()
+}
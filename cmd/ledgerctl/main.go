@@ -0,0 +1,105 @@
+// Command ledgerctl is the operator tool for the ledger. Every subcommand
+// takes its positional arguments first, followed by its own flags and the
+// regular config flags (-db-host, -sqs-dlq-url, ...).
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const usage = `usage: ledgerctl <command> [arguments] [flags]
+
+commands:
+  user create           create a user (-email, -name, -surname, -age, -admin)
+  user list             list users
+  user promote <user>   make a user an admin
+  user demote <user>    make an admin a regular user
+  user reset-password <user>
+                        set a new password (-password or stdin)
+  user unlock <user>    clear a user's failed logins and lift their lockout
+  credit adjust <user> <amount>
+                        add or remove credit; -reason is required
+  batch run <file>      apply a JSON or CSV batch of credit updates; -reason is required
+  balance [user]        show the balance of one user or of everyone; with -at,
+                        the balance one user had at that time
+  snapshot              take the end-of-day balance snapshots that are due (-day YYYY-MM-DD)
+  statement <user>      write a statement of last month (-month YYYY-MM or -from/-to,
+                        -format csv|json|pdf|camt053|ofx, -o file)
+  statement generate    pre-generate every user's statement of last month (-month YYYY-MM)
+  statement validate <file>
+                        check a camt.053 or OFX file against its schema
+  history <user>        show a user's transfers (-date YYYY-MM-DD)
+  verify                check the ledger for inconsistencies
+  reconcile             recompute balances from their history and compare them
+                        with the stored and cached balances (-repair-cache)
+  chain verify          walk the transaction hash chain and report the first broken link
+  chain checkpoint      sign the current head of the chain (LEDGER_CHECKPOINT_KEY)
+  chain checkpoints     list the signed checkpoints
+  export users|transactions
+                        export data (-format csv|json, -o file)
+  audit list            list audit events, newest first (-by, -action, -target, -from, -to)
+  audit export          export audit events for compliance (-format csv|ndjson, -o file)
+  dlq list              list dead-lettered queue messages
+  dlq show <id>         show one dead-lettered message and why it failed
+  dlq replay <id>       send a dead-lettered message back to the queue
+
+<user> is a user ID or email. Output is a table, or JSON with -json. Every
+mutation is recorded in the audit trail under -actor (default $USER).`
+
+var errUsage = errors.New(usage)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "user":
+		err = runUser(ctx, os.Args[2:])
+	case "credit":
+		err = runCredit(ctx, os.Args[2:])
+	case "batch":
+		err = runBatch(ctx, os.Args[2:])
+	case "balance":
+		err = runBalance(ctx, os.Args[2:])
+	case "history":
+		err = runHistory(ctx, os.Args[2:])
+	case "verify":
+		err = runVerify(ctx, os.Args[2:])
+	case "reconcile":
+		err = runReconcile(ctx, os.Args[2:])
+	case "snapshot":
+		err = runSnapshot(ctx, os.Args[2:])
+	case "statement":
+		err = runStatement(ctx, os.Args[2:])
+	case "chain":
+		err = runChain(ctx, os.Args[2:])
+	case "export":
+		err = runExport(ctx, os.Args[2:])
+	case "audit":
+		err = runAudit(ctx, os.Args[2:])
+	case "dlq":
+		err = runDLQ(ctx, os.Args[2:])
+	default:
+		err = errUsage
+	}
+
+	if errors.Is(err, errUsage) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ledgerctl: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// output renders command results as an aligned table or, with -json, as
+// indented JSON.
+type output struct {
+	json bool
+	w    io.Writer
+}
+
+func newOutput(flags *flag.FlagSet) *output {
+	o := &output{w: os.Stdout}
+	flags.BoolVar(&o.json, "json", false, "print JSON instead of a table")
+	return o
+}
+
+// print writes v as JSON, or rows under headers as a table.
+func (o *output) print(v interface{}, headers []string, rows [][]string) error {
+	if o.json {
+		enc := json.NewEncoder(o.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+
+	tw := tabwriter.NewWriter(o.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// stringList collects a repeatable flag.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(s string) error { *l = append(*l, s); return nil }
+
+// splitArgs separates leading positional arguments from the flags after
+// them. Negative numbers are positional, so "credit adjust 7 -10" works.
+func splitArgs(args []string) ([]string, []string) {
+	for i, a := range args {
+		if _, err := strconv.ParseFloat(a, 64); err == nil {
+			continue
+		}
+		if strings.HasPrefix(a, "-") {
+			return args[:i], args[i:]
+		}
+	}
+	return args, nil
+}
+
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= n {
+		return s
+	}
+	return s[:n-3] + "..."
+}
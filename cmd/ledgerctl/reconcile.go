@@ -0,0 +1,90 @@
+package main
+
+import (
+	"Ledger/src/audit"
+	"Ledger/src/reconcile"
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// runReconcile implements "ledgerctl reconcile". It fails when anything
+// differs, so it can run from cron like verify.
+func runReconcile(ctx context.Context, args []string) error {
+	c := newCommand("reconcile")
+	repairCache := c.flags.Bool("repair-cache", false, "drop cached balances that differ from the stored ones")
+	positional, cfg, err := c.parse(args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errUsage
+	}
+	if *repairCache {
+		if err := c.requireActor(); err != nil {
+			return err
+		}
+	}
+
+	a, err := openApp(cfg, *c.actor)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	// Only MySQL deployments have a credit cache.
+	var cache reconcile.Cache
+	if a.cache != nil {
+		cache = a.cache
+	}
+	report, err := reconcile.New(a.db, cache).Run(ctx, reconcile.Options{RepairCache: *repairCache})
+	if err != nil {
+		return err
+	}
+	if *repairCache {
+		if err := a.audit.Record(ctx, audit.Event{
+			Actor:  a.actor,
+			Action: reconcile.ActionRun,
+			Target: "users",
+			After:  audit.State(map[string]int{"discrepancies": len(report.Discrepancies), "cache_repaired": report.CacheRepaired}),
+		}); err != nil {
+			return err
+		}
+	}
+
+	if !c.out.json {
+		fmt.Fprintf(c.out.w, "%d accounts, %d entries", report.Accounts, report.Entries)
+		switch {
+		case report.CacheError != "":
+			fmt.Fprintf(c.out.w, ", cache not checked: %s", report.CacheError)
+		case !report.CacheChecked:
+			fmt.Fprint(c.out.w, ", no cache to check")
+		}
+		fmt.Fprintln(c.out.w)
+		if report.OK() {
+			fmt.Fprintln(c.out.w, "every balance matches its history")
+			return nil
+		}
+	}
+
+	rows := make([][]string, len(report.Discrepancies))
+	for i, d := range report.Discrepancies {
+		logID := ""
+		if d.LogID != 0 {
+			logID = strconv.FormatUint(d.LogID, 10)
+		}
+		repaired := ""
+		if d.Repaired {
+			repaired = "yes"
+		}
+		rows[i] = []string{d.Kind, strconv.FormatUint(uint64(d.UserID), 10), logID,
+			formatAmount(d.Expected), formatAmount(d.Actual), repaired, d.Detail}
+	}
+	if err := c.out.print(report, []string{"KIND", "USER", "ENTRY", "EXPECTED", "ACTUAL", "REPAIRED", "DETAIL"}, rows); err != nil {
+		return err
+	}
+	if !report.OK() {
+		return fmt.Errorf("%d discrepancy(ies)", len(report.Discrepancies))
+	}
+	return nil
+}
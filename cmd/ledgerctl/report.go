@@ -0,0 +1,258 @@
+package main
+
+import (
+	"Ledger/src/balance"
+	"Ledger/src/integrity"
+	"Ledger/src/models"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// runBalance implements "ledgerctl balance [user]". With -at it shows the
+// balance one user had at that time instead.
+func runBalance(ctx context.Context, args []string) error {
+	c := newCommand("balance")
+	at := c.flags.String("at", "", "show the balance at this time (RFC 3339, or YYYY-MM-DD for the end of that day)")
+	positional, cfg, err := c.parse(args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 || (*at != "" && len(positional) != 1) {
+		return errors.New("usage: ledgerctl balance [id|email] [-at time]")
+	}
+	var when time.Time
+	if *at != "" {
+		if when, err = balance.ParseTime(*at); err != nil {
+			return err
+		}
+	}
+
+	a, err := openApp(cfg, *c.actor)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	var users []models.User
+	if len(positional) == 1 {
+		user, err := a.resolveUser(ctx, positional[0])
+		if err != nil {
+			return err
+		}
+		if *at != "" {
+			point, err := a.balances.At(ctx, user.ID, when)
+			if err != nil {
+				return err
+			}
+			return c.out.print(point, []string{"ID", "EMAIL", "AT", "BALANCE"}, [][]string{{
+				strconv.FormatUint(uint64(user.ID), 10), user.Email, point.At.Format(time.RFC3339), formatAmount(point.Balance),
+			}})
+		}
+		users = []models.User{*user}
+	} else if users, err = a.users.GetAllUsers(ctx); err != nil {
+		return err
+	}
+	return printUsers(c.out, users)
+}
+
+// runHistory implements "ledgerctl history <user>".
+func runHistory(ctx context.Context, args []string) error {
+	c := newCommand("history")
+	date := c.flags.String("date", "", "only show transfers on this day (YYYY-MM-DD)")
+	positional, cfg, err := c.parse(args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: ledgerctl history <id|email> [-date YYYY-MM-DD]")
+	}
+	if *date != "" {
+		if _, err := time.Parse("2006-01-02", *date); err != nil {
+			return fmt.Errorf("invalid -date %q, want YYYY-MM-DD", *date)
+		}
+	}
+
+	a, err := openApp(cfg, *c.actor)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	user, err := a.resolveUser(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	logs, err := a.users.GetTransactionLogsByUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	shown := make([]models.TransactionLog, 0, len(logs))
+	rows := make([][]string, 0, len(logs))
+	for _, log := range logs {
+		if *date != "" && log.TransactionDate.Format("2006-01-02") != *date {
+			continue
+		}
+		direction, counterparty, balance := "out", log.ReceiverID, log.SenderCreditAfter
+		if log.ReceiverID == user.ID {
+			direction, counterparty, balance = "in", log.SenderID, log.ReceiverCreditAfter
+		}
+		// Credits and debits of the user's own balance have no counterparty.
+		party := "-"
+		if counterparty != 0 {
+			party = strconv.FormatUint(uint64(counterparty), 10)
+		}
+		shown = append(shown, log)
+		rows = append(rows, []string{
+			strconv.FormatUint(uint64(log.ID), 10),
+			log.TransactionDate.Format(time.RFC3339),
+			direction,
+			party,
+			formatAmount(log.Amount),
+			formatAmount(balance),
+		})
+	}
+	return c.out.print(shown, []string{"ID", "DATE", "DIRECTION", "COUNTERPARTY", "AMOUNT", "BALANCE"}, rows)
+}
+
+// runVerify implements "ledgerctl verify". It fails when any check finds
+// something, so it can run from cron or CI.
+func runVerify(ctx context.Context, args []string) error {
+	c := newCommand("verify")
+	positional, cfg, err := c.parse(args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errUsage
+	}
+
+	a, err := openApp(cfg, *c.actor)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	findings, err := integrity.Check(ctx, a.db)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, len(findings))
+	for i, f := range findings {
+		rows[i] = []string{f.Check, f.Table, strconv.FormatUint(f.ID, 10), f.Detail}
+	}
+	result := map[string]interface{}{"checks": integrity.Checks(), "findings": findings}
+	if len(findings) == 0 && !c.out.json {
+		fmt.Fprintf(c.out.w, "ledger is consistent (%d checks)\n", len(integrity.Checks()))
+		return nil
+	}
+	if err := c.out.print(result, []string{"CHECK", "TABLE", "ID", "DETAIL"}, rows); err != nil {
+		return err
+	}
+	if len(findings) > 0 {
+		return fmt.Errorf("%d integrity finding(s)", len(findings))
+	}
+	return nil
+}
+
+// runExport implements "ledgerctl export users|transactions".
+func runExport(ctx context.Context, args []string) error {
+	if len(args) == 0 || (args[0] != "users" && args[0] != "transactions") {
+		return errUsage
+	}
+	what := args[0]
+
+	c := newCommand("export " + what)
+	format := c.flags.String("format", "csv", "export format: csv or json")
+	file := c.flags.String("o", "", "write to this file instead of stdout")
+	positional, cfg, err := c.parse(args[1:])
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errUsage
+	}
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("unknown -format %q, want csv or json", *format)
+	}
+
+	a, err := openApp(cfg, *c.actor)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	var data interface{}
+	var header []string
+	var records [][]string
+	if what == "users" {
+		users, err := a.users.GetAllUsers(ctx)
+		if err != nil {
+			return err
+		}
+		views := make([]userView, len(users))
+		for i, u := range users {
+			views[i] = newUserView(u)
+			records = append(records, []string{
+				strconv.FormatUint(uint64(u.ID), 10), u.Email, u.Name, u.Surname, strconv.Itoa(u.Age), u.Role, formatAmount(u.Credit),
+			})
+		}
+		data = views
+		header = []string{"id", "email", "name", "surname", "age", "role", "credit"}
+	} else {
+		logs, err := a.users.GetAllTransactionLogs(ctx)
+		if err != nil {
+			return err
+		}
+		for _, l := range logs {
+			records = append(records, []string{
+				strconv.FormatUint(uint64(l.ID), 10),
+				l.TransactionDate.UTC().Format(time.RFC3339),
+				strconv.FormatUint(uint64(l.SenderID), 10),
+				strconv.FormatUint(uint64(l.ReceiverID), 10),
+				formatAmount(l.Amount),
+				l.Description,
+				formatAmount(l.SenderCreditBefore),
+				formatAmount(l.SenderCreditAfter),
+				formatAmount(l.ReceiverCreditBefore),
+				formatAmount(l.ReceiverCreditAfter),
+			})
+		}
+		data = logs
+		header = []string{"id", "date", "sender_id", "receiver_id", "amount", "description",
+			"sender_credit_before", "sender_credit_after", "receiver_credit_before", "receiver_credit_after"}
+	}
+
+	if *file == "" {
+		return writeExport(os.Stdout, *format, data, header, records)
+	}
+	f, err := os.Create(*file)
+	if err != nil {
+		return err
+	}
+	if err := writeExport(f, *format, data, header, records); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func writeExport(w io.Writer, format string, data interface{}, header []string, records [][]string) error {
+	if format == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(data)
+	}
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	cw.WriteAll(records)
+	return cw.Error()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"Ledger/src/balance"
+	"context"
+	"fmt"
+	"time"
+)
+
+// runSnapshot implements "ledgerctl snapshot". It takes the end-of-day
+// balance snapshots the server and the lambda schedule take, so days can be
+// backfilled or a missed run made up.
+func runSnapshot(ctx context.Context, args []string) error {
+	c := newCommand("snapshot")
+	day := c.flags.String("day", "", "snapshot this day (YYYY-MM-DD) instead of every day that is due")
+	positional, cfg, err := c.parse(args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errUsage
+	}
+
+	a, err := openApp(cfg, *c.actor)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	if *day == "" {
+		days, err := a.balances.SnapshotDue(ctx, time.Now())
+		if err != nil {
+			return fmt.Errorf("after %d day(s): %w", days, err)
+		}
+		fmt.Fprintf(c.out.w, "snapshots taken for %d day(s)\n", days)
+		return nil
+	}
+
+	t, err := time.Parse("2006-01-02", *day)
+	if err != nil {
+		return fmt.Errorf("invalid -day %q, want YYYY-MM-DD", *day)
+	}
+	if !t.Before(balance.StartOfDay(time.Now())) {
+		return fmt.Errorf("%s has not ended yet", *day)
+	}
+	n, err := a.balances.Snapshot(ctx, t)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		fmt.Fprintf(c.out.w, "%s was snapshotted already\n", *day)
+		return nil
+	}
+	fmt.Fprintf(c.out.w, "%d balance(s) stored for %s\n", n, *day)
+	return nil
+}
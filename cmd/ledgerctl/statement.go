@@ -0,0 +1,172 @@
+package main
+
+import (
+	"Ledger/src/bankexport"
+	"Ledger/src/statement"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// runStatement implements "ledgerctl statement <user>", "ledgerctl
+// statement generate" and "ledgerctl statement validate".
+func runStatement(ctx context.Context, args []string) error {
+	if len(args) > 0 && args[0] == "generate" {
+		return runStatementGenerate(ctx, args[1:])
+	}
+	if len(args) > 0 && args[0] == "validate" {
+		return runStatementValidate(args[1:])
+	}
+
+	c := newCommand("statement")
+	month := c.flags.String("month", "", "the month to cover (YYYY-MM, default last month)")
+	from := c.flags.String("from", "", "first day to cover (YYYY-MM-DD) instead of a month")
+	to := c.flags.String("to", "", "last day to cover (YYYY-MM-DD) instead of a month")
+	format := c.flags.String("format", statement.FormatCSV, "statement format: csv, json, pdf, camt053 or ofx")
+	file := c.flags.String("o", "", "write to this file instead of stdout")
+	positional, cfg, err := c.parse(args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: ledgerctl statement <id|email> [-month YYYY-MM | -from YYYY-MM-DD -to YYYY-MM-DD] [-format csv|json|pdf|camt053|ofx] [-o file]")
+	}
+	if statement.ContentType(*format) == "" && bankexport.ContentType(*format) == "" {
+		return fmt.Errorf("unknown -format %q, want csv, json, pdf, camt053 or ofx", *format)
+	}
+	periodFrom, periodTo, err := statementPeriod(*month, *from, *to)
+	if err != nil {
+		return err
+	}
+
+	a, err := openApp(cfg, *c.actor)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	user, err := a.resolveUser(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	s, err := a.statements.Statement(ctx, user.ID, periodFrom, periodTo)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *file != "" {
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if bankexport.ContentType(*format) != "" {
+		return bankexport.Write(w, s, *format, cfg.Ledger.Currency)
+	}
+	return statement.Write(w, s, *format)
+}
+
+// statementPeriod returns the period -month or -from and -to name, last
+// month when none is given.
+func statementPeriod(month, from, to string) (time.Time, time.Time, error) {
+	switch {
+	case month != "" && (from != "" || to != ""):
+		return time.Time{}, time.Time{}, errors.New("-month cannot be combined with -from and -to")
+	case month != "":
+		t, err := time.Parse("2006-01", month)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid -month %q, want YYYY-MM", month)
+		}
+		first, last := statement.Month(t)
+		return first, last, nil
+	case from != "" || to != "":
+		first, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid -from %q, want YYYY-MM-DD", from)
+		}
+		last, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid -to %q, want YYYY-MM-DD", to)
+		}
+		if first.After(last) {
+			return time.Time{}, time.Time{}, errors.New("-from must not be after -to")
+		}
+		return first, last, nil
+	}
+	first, last := statement.Month(time.Now().UTC().AddDate(0, -1, 0))
+	return first, last, nil
+}
+
+// runStatementGenerate implements "ledgerctl statement generate", the job
+// the server and the lambda schedule, for any closed month.
+func runStatementGenerate(ctx context.Context, args []string) error {
+	c := newCommand("statement generate")
+	month := c.flags.String("month", "", "the month to pre-generate (YYYY-MM, default last month)")
+	positional, cfg, err := c.parse(args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 0 {
+		return errUsage
+	}
+	t := time.Now().UTC().AddDate(0, -1, 0)
+	if *month != "" {
+		if t, err = time.Parse("2006-01", *month); err != nil {
+			return fmt.Errorf("invalid -month %q, want YYYY-MM", *month)
+		}
+	}
+	if _, last := statement.Month(t); !last.Before(time.Now().UTC().Truncate(24 * time.Hour)) {
+		return fmt.Errorf("%s has not ended yet", t.Format("2006-01"))
+	}
+
+	a, err := openApp(cfg, *c.actor)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	stored, err := a.statements.Pregenerate(ctx, t)
+	if err != nil {
+		return fmt.Errorf("after %d statement(s): %w", stored, err)
+	}
+	fmt.Fprintf(c.out.w, "%d statement(s) stored for %s\n", stored, t.Format("2006-01"))
+	return nil
+}
+
+// runStatementValidate implements "ledgerctl statement validate", which
+// checks a camt.053 or OFX file against the schema the exports are held to.
+func runStatementValidate(args []string) error {
+	c := newCommand("statement validate")
+	format := c.flags.String("format", "", "format of the file: camt053 or ofx (default from the extension)")
+	positional, _, err := c.parse(args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return errors.New("usage: ledgerctl statement validate <file> [-format camt053|ofx]")
+	}
+	if *format == "" {
+		*format = bankexport.FormatCAMT053
+		if strings.HasSuffix(strings.ToLower(positional[0]), ".ofx") {
+			*format = bankexport.FormatOFX
+		}
+	}
+
+	f, err := os.Open(positional[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := bankexport.Validate(f, *format); err != nil {
+		return fmt.Errorf("%s: %w", positional[0], err)
+	}
+	fmt.Fprintf(c.out.w, "%s is a valid %s file\n", positional[0], *format)
+	return nil
+}
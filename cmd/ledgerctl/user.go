@@ -0,0 +1,209 @@
+package main
+
+import (
+	"Ledger/src/audit"
+	"Ledger/src/lockout"
+	"Ledger/src/models"
+	"Ledger/src/validation"
+	"context"
+	"errors"
+	"strconv"
+)
+
+// userView is the printed form of a user; it never includes the password
+// hash.
+type userView struct {
+	ID      uint    `json:"id"`
+	Email   string  `json:"email"`
+	Name    string  `json:"name"`
+	Surname string  `json:"surname"`
+	Age     int     `json:"age"`
+	Role    string  `json:"role"`
+	Credit  float64 `json:"credit"`
+}
+
+func newUserView(u models.User) userView {
+	return userView{
+		ID:      u.ID,
+		Email:   u.Email,
+		Name:    u.Name,
+		Surname: u.Surname,
+		Age:     u.Age,
+		Role:    u.Role,
+		Credit:  u.Credit,
+	}
+}
+
+var userHeaders = []string{"ID", "EMAIL", "NAME", "ROLE", "CREDIT"}
+
+func (v userView) row() []string {
+	return []string{strconv.FormatUint(uint64(v.ID), 10), v.Email, v.Name + " " + v.Surname, v.Role, formatAmount(v.Credit)}
+}
+
+func printUsers(out *output, users []models.User) error {
+	views := make([]userView, len(users))
+	rows := make([][]string, len(users))
+	for i, u := range users {
+		views[i] = newUserView(u)
+		rows[i] = views[i].row()
+	}
+	return out.print(views, userHeaders, rows)
+}
+
+// runUser implements "ledgerctl user".
+func runUser(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	subcommand := args[0]
+
+	c := newCommand("user " + subcommand)
+	email := c.flags.String("email", "", "email of the new user")
+	name := c.flags.String("name", "", "first name of the new user")
+	surname := c.flags.String("surname", "", "last name of the new user")
+	age := c.flags.Int("age", 0, "age of the new user")
+	admin := c.flags.Bool("admin", false, "create the user as an admin")
+	password := c.flags.String("password", "", "password; read from stdin when omitted")
+	reason := c.flags.String("reason", "", "why the change is made, kept in the audit trail")
+
+	positional, cfg, err := c.parse(args[1:])
+	if err != nil {
+		return err
+	}
+
+	switch subcommand {
+	case "create", "list":
+		if len(positional) != 0 {
+			return errUsage
+		}
+	case "promote", "demote", "reset-password", "unlock":
+		if len(positional) != 1 {
+			return errors.New("usage: ledgerctl user " + subcommand + " <id|email> [flags]")
+		}
+	default:
+		return errUsage
+	}
+	if subcommand != "list" {
+		if err := c.requireActor(); err != nil {
+			return err
+		}
+	}
+
+	a, err := openApp(cfg, *c.actor)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	switch subcommand {
+	case "create":
+		secret, err := readSecret(*password, "password")
+		if err != nil {
+			return err
+		}
+		req := models.RegisterRequest{Name: *name, Surname: *surname, Age: *age, Email: *email, Password: secret}
+		if err := validation.New(cfg.Limits).Struct(&req); err != nil {
+			return err
+		}
+		user := &models.User{
+			Name:     req.Name,
+			Surname:  req.Surname,
+			Age:      req.Age,
+			Email:    req.Email,
+			Password: req.Password,
+		}
+		return userCreate(ctx, c.out, a, user, *admin, *reason)
+	case "list":
+		users, err := a.users.GetAllUsers(ctx)
+		if err != nil {
+			return err
+		}
+		return printUsers(c.out, users)
+	case "promote":
+		return userSetRole(ctx, c.out, a, positional[0], models.RoleAdmin, *reason)
+	case "demote":
+		return userSetRole(ctx, c.out, a, positional[0], models.RoleUser, *reason)
+	case "unlock":
+		return userUnlock(ctx, c.out, a, positional[0], *reason)
+	default:
+		secret, err := readSecret(*password, "new password")
+		if err != nil {
+			return err
+		}
+		return userResetPassword(ctx, c.out, a, positional[0], secret, *reason)
+	}
+}
+
+func userCreate(ctx context.Context, out *output, a *app, user *models.User, admin bool, reason string) error {
+	user.Role = models.RoleUser
+	if admin {
+		user.Role = models.RoleAdmin
+	}
+	if err := a.audited(reason).CreateUser(ctx, user); err != nil {
+		return err
+	}
+
+	view := newUserView(*user)
+	return out.print(view, userHeaders, [][]string{view.row()})
+}
+
+func userSetRole(ctx context.Context, out *output, a *app, ref, role, reason string) error {
+	user, err := a.resolveUser(ctx, ref)
+	if err != nil {
+		return err
+	}
+	if err := a.audited(reason).SetRole(ctx, user.ID, role); err != nil {
+		return err
+	}
+
+	user.Role = role
+	view := newUserView(*user)
+	return out.print(view, userHeaders, [][]string{view.row()})
+}
+
+func userResetPassword(ctx context.Context, out *output, a *app, ref, password, reason string) error {
+	user, err := a.resolveUser(ctx, ref)
+	if err != nil {
+		return err
+	}
+	if err := a.audited(reason).ResetPassword(ctx, user.ID, password); err != nil {
+		return err
+	}
+
+	result := map[string]interface{}{"id": user.ID, "email": user.Email, "status": "password reset"}
+	return out.print(result, []string{"ID", "EMAIL", "STATUS"},
+		[][]string{{strconv.FormatUint(uint64(user.ID), 10), user.Email, "password reset"}})
+}
+
+// userUnlock clears the failed logins of a user, which is how an admin
+// locked out of the API gets back in.
+func userUnlock(ctx context.Context, out *output, a *app, ref, reason string) error {
+	user, err := a.resolveUser(ctx, ref)
+	if err != nil {
+		return err
+	}
+	cleared, err := a.lockouts.Unlock(ctx, user.Email)
+	if err != nil {
+		return err
+	}
+	e := audit.Event{
+		Actor:  a.actor,
+		Action: lockout.ActionUnlock,
+		Target: "user:" + strconv.FormatUint(uint64(user.ID), 10),
+		Reason: reason,
+	}
+	if cleared != nil {
+		e.Before = audit.State(cleared)
+	}
+	if err := a.audit.Record(ctx, e); err != nil {
+		return err
+	}
+
+	status := "not locked"
+	if cleared != nil {
+		status = "unlocked"
+	}
+	result := map[string]interface{}{"id": user.ID, "email": user.Email, "status": status}
+	return out.print(result, []string{"ID", "EMAIL", "STATUS"},
+		[][]string{{strconv.FormatUint(uint64(user.ID), 10), user.Email, status}})
+}
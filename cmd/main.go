@@ -1,41 +1,191 @@
 package main
 
 import (
+	"Ledger/config"
 	"Ledger/pkg/db"
+	"Ledger/pkg/logging"
+	"Ledger/pkg/migrate"
+	"Ledger/pkg/tracing"
 	"Ledger/src/factory"
-	"log"
+	"Ledger/src/reconcile"
+	"Ledger/src/repository"
+	"Ledger/src/router"
+	"context"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 
-	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
 )
 
 func main() {
-	database := db.ConnectDB()
-	appFactory := factory.NewFactory(database)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			logging.Fatal("Migration failed", err)
+		}
+		return
+	}
+
+	cfg, err := config.Load(config.Default(), os.Args[1:])
+	if err != nil {
+		logging.Fatal("Failed to load configuration", err)
+	}
+	if _, err := logging.Setup(cfg.Log); err != nil {
+		logging.Fatal("Failed to set up logging", err)
+	}
+
+	// SIGTERM stops the server taking requests and the workers; a second
+	// signal kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	traces, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		logging.Fatal("Failed to set up tracing", err)
+	}
+
+	database, err := db.ConnectDB(cfg.DB)
+	if err != nil {
+		logging.Fatal("Failed to connect to database", err)
+	}
+
+	if cfg.DB.AutoMigrate {
+		sqlDB, err := database.DB()
+		if err != nil {
+			logging.Fatal("Failed to access database handle", err)
+		}
+		migrator, err := migrate.ForDriver(sqlDB, cfg.DB.Driver)
+		if err != nil {
+			logging.Fatal("Failed to load migrations", err)
+		}
+		if err := migrator.Up(ctx); err != nil {
+			logging.Fatal("Failed to apply migrations", err)
+		}
+	}
+	appFactory := factory.NewFactory(database, cfg)
+
+	// Workers stop with ctx. A run cut short loses nothing: snapshots and
+	// statements catch up on what is due at the next start, and a
+	// reconciliation simply runs again.
+	var workers sync.WaitGroup
+	work := func(fn func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			fn(ctx)
+		}()
+	}
+	if interval := cfg.Ledger.ReconcileInterval; interval > 0 {
+		if reconciler := appFactory.NewReconciler(); reconciler != nil {
+			work(func(ctx context.Context) {
+				reconciler.Every(ctx, interval, reconcile.Options{RepairCache: cfg.Ledger.ReconcileRepairCache})
+			})
+		}
+	}
+	if interval := cfg.Ledger.SnapshotInterval; interval > 0 {
+		if snapshots := appFactory.NewBalanceStore(); snapshots != nil {
+			work(func(ctx context.Context) { snapshots.Every(ctx, interval) })
+		}
+	}
+	if interval := cfg.Ledger.StatementInterval; interval > 0 {
+		if statements := appFactory.NewStatementGenerator(); statements != nil {
+			work(func(ctx context.Context) { statements.Every(ctx, interval) })
+		}
+	}
+
+	server := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           router.New(appFactory),
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+	served := make(chan error, 2)
+	go func() {
+		served <- server.ListenAndServe()
+	}()
+	slog.Info("Server listening", "addr", cfg.Server.Addr)
 
-	userHandler := appFactory.NewUserHandler()
-	authMiddleware := appFactory.NewAuthMiddleware()
+	var grpcServer *grpc.Server
+	if cfg.GRPC.Addr != "" {
+		listener, err := net.Listen("tcp", cfg.GRPC.Addr)
+		if err != nil {
+			logging.Fatal("Failed to start gRPC server", err)
+		}
+		grpcServer = appFactory.NewGRPCServer()
+		go func() {
+			served <- grpcServer.Serve(listener)
+		}()
+		slog.Info("gRPC server listening", "addr", cfg.GRPC.Addr)
+	}
 
-	router := mux.NewRouter()
+	select {
+	case err := <-served:
+		logging.Fatal("Failed to start server", err)
+	case <-ctx.Done():
+	}
+	stop()
 
-	// Public endpoints
-	router.HandleFunc("/users/add-user", userHandler.CreateUser).Methods("POST")
-	router.HandleFunc("/login", userHandler.Login).Methods("POST")
+	slog.Info("Shutting down", "timeout", cfg.Server.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
 
-	// Protected endpoints
-	router.HandleFunc("/users/get-credit", authMiddleware.Authenticate(userHandler.GetCredit)).Methods("GET")
-	router.HandleFunc("/users/send-credit", authMiddleware.Authenticate(userHandler.SendCredit)).Methods("POST")
-	router.HandleFunc("/users/transaction-logs/sender", authMiddleware.Authenticate(userHandler.GetTransactionLogsBySenderAndDate)).Methods("GET")
+	// Requests finish first, as they start cache invalidations.
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("In-flight requests did not finish", "error", err)
+	}
+	if grpcServer != nil {
+		if err := stopGRPC(shutdownCtx, grpcServer); err != nil {
+			slog.Warn("In-flight gRPC calls did not finish", "error", err)
+		}
+	}
+	if err := repository.Drain(shutdownCtx); err != nil {
+		slog.Warn("Cache invalidations did not finish", "error", err)
+	}
+	if err := wait(shutdownCtx, &workers); err != nil {
+		slog.Warn("Workers did not stop", "error", err)
+	}
+	if err := traces.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Traces could not be exported", "error", err)
+	}
+	slog.Info("Server stopped")
+}
+
+// stopGRPC lets the calls of s finish until ctx is done, then cancels the
+// rest, such as balance watches, which only end with their clients.
+func stopGRPC(ctx context.Context, s *grpc.Server) error {
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.Stop()
+		return ctx.Err()
+	}
+}
 
-	// Admin only endpoints
-	router.HandleFunc("/users", authMiddleware.Authenticate(authMiddleware.AdminOnly(userHandler.GetAllUsers))).Methods("GET")
-	router.HandleFunc("/users/get-user", authMiddleware.Authenticate(authMiddleware.AdminOnly(userHandler.GetUserByID))).Methods("GET")
-	router.HandleFunc("/users/add-credit", authMiddleware.Authenticate(authMiddleware.AdminOnly(userHandler.AddCredit))).Methods("POST")
-	router.HandleFunc("/users/credits", authMiddleware.Authenticate(authMiddleware.AdminOnly(userHandler.GetAllCredits))).Methods("GET")
-	router.HandleFunc("/users/batch/credits", authMiddleware.Authenticate(authMiddleware.AdminOnly(userHandler.GetMultipleUserCredits))).Methods("POST")
-	router.HandleFunc("/users/batch/update-credits", authMiddleware.Authenticate(authMiddleware.AdminOnly(userHandler.ProcessBatchCreditUpdate))).Methods("POST")
+// wait waits for wg until ctx is done.
+func wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
 
-	if err := http.ListenAndServe(":8080", router); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
 }
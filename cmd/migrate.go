@@ -0,0 +1,140 @@
+package main
+
+import (
+	"Ledger/config"
+	"Ledger/pkg/db"
+	"Ledger/pkg/migrate"
+	"Ledger/src/audit"
+	"Ledger/src/balance"
+	"Ledger/src/chain"
+	"Ledger/src/idempotency"
+	"Ledger/src/lockout"
+	"Ledger/src/models"
+	"Ledger/src/queue"
+	"Ledger/src/statement"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = `usage: main migrate <command> [flags]
+
+commands:
+  up              apply every pending migration
+  down [steps]    revert the last applied migration(s), default 1
+  status          list migrations and whether they are applied
+  to <version>    migrate up or down to exactly version (0 reverts all)
+  verify          check that every migrated table has its columns`
+
+// runMigrate implements the migrate subcommand. Positional arguments come
+// first; everything after them is parsed as regular config flags.
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	command, args := args[0], args[1:]
+	var operand string
+	if (command == "down" || command == "to") && len(args) > 0 && args[0] != "" && args[0][0] != '-' {
+		operand, args = args[0], args[1:]
+	}
+
+	cfg, err := config.Parse(config.Default(), args)
+	if err != nil {
+		return err
+	}
+	if err := cfg.ValidateDB(); err != nil {
+		return err
+	}
+
+	sqlDB, err := db.OpenSQL(cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	migrator, err := migrate.ForDriver(sqlDB, cfg.DB.Driver)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch command {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if operand != "" {
+			if steps, err = strconv.Atoi(operand); err != nil || steps <= 0 {
+				return fmt.Errorf("invalid step count %q", operand)
+			}
+		}
+		return migrator.Down(ctx, steps)
+	case "to":
+		if operand == "" {
+			return errors.New("migrate to requires a version")
+		}
+		version, err := strconv.ParseInt(operand, 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", operand)
+		}
+		return migrator.To(ctx, version)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	case "verify":
+		if pending, err := migrator.Pending(ctx); err != nil {
+			return err
+		} else if pending > 0 {
+			return fmt.Errorf("%d migration(s) pending", pending)
+		}
+		tables, err := migratedTables()
+		if err != nil {
+			return err
+		}
+		return migrator.Verify(ctx, tables)
+	default:
+		return errors.New(migrateUsage)
+	}
+}
+
+// migratedTables lists every table the migrations create: the GORM models
+// and the tables the packages that write them with SQL declare.
+func migratedTables() ([]migrate.Table, error) {
+	tables, err := migrate.TablesFromModels(&models.User{}, &models.TransactionLog{})
+	if err != nil {
+		return nil, err
+	}
+	for _, declared := range [][]migrate.Table{
+		audit.Tables,
+		balance.Tables,
+		chain.Tables,
+		idempotency.Tables,
+		lockout.Tables,
+		queue.Tables,
+		statement.Tables,
+	} {
+		tables = append(tables, declared...)
+	}
+	return tables, nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"Ledger/pkg/migrate"
+	"Ledger/pkg/testdb"
+	"context"
+	"testing"
+)
+
+// TestVerify checks that the migrations create every table and column the
+// code expects.
+func TestVerify(t *testing.T) {
+	tables, err := migratedTables()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, driver := range testdb.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			db := testdb.Open(t, driver)
+			migrator, err := migrate.ForDriver(db, driver)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := migrator.Verify(context.Background(), tables); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
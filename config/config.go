@@ -1 +1,280 @@
 package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Config is the complete runtime configuration shared by the HTTP server and
+// the lambda. Values are resolved in order: defaults, optional config file,
+// environment, command line flags.
+type Config struct {
+	Server      ServerConfig      `yaml:"server" toml:"server"`
+	GRPC        GRPCConfig        `yaml:"grpc" toml:"grpc"`
+	DB          DBConfig          `yaml:"db" toml:"db"`
+	Redis       RedisConfig       `yaml:"redis" toml:"redis"`
+	JWT         JWTConfig         `yaml:"jwt" toml:"jwt"`
+	Limits      LimitsConfig      `yaml:"limits" toml:"limits"`
+	Queue       QueueConfig       `yaml:"queue" toml:"queue"`
+	Ledger      LedgerConfig      `yaml:"ledger" toml:"ledger"`
+	Log         LogConfig         `yaml:"log" toml:"log"`
+	Metrics     MetricsConfig     `yaml:"metrics" toml:"metrics"`
+	Tracing     TracingConfig     `yaml:"tracing" toml:"tracing"`
+	Health      HealthConfig      `yaml:"health" toml:"health"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit" toml:"rate_limit"`
+	Lockout     LockoutConfig     `yaml:"lockout" toml:"lockout"`
+	Idempotency IdempotencyConfig `yaml:"idempotency" toml:"idempotency"`
+}
+
+type ServerConfig struct {
+	Addr              string        `yaml:"addr" toml:"addr" env:"SERVER_ADDR" flag:"addr" usage:"HTTP listen address"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" toml:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT" flag:"server-read-header-timeout" usage:"how long a client may take to send the request headers"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"SERVER_READ_TIMEOUT" flag:"server-read-timeout" usage:"how long a client may take to send a whole request (0 for no limit)"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"SERVER_WRITE_TIMEOUT" flag:"server-write-timeout" usage:"how long a request may take from the end of its headers to the end of the response (0 for no limit)"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" flag:"server-idle-timeout" usage:"how long an idle keep-alive connection is kept open (0 for no limit)"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" flag:"server-shutdown-timeout" usage:"how long to drain in-flight requests and background work after SIGTERM before exiting"`
+}
+
+type GRPCConfig struct {
+	Addr          string        `yaml:"addr" toml:"addr" env:"GRPC_ADDR" flag:"grpc-addr" usage:"gRPC listen address (empty to serve HTTP only)"`
+	WatchInterval time.Duration `yaml:"watch_interval" toml:"watch_interval" env:"GRPC_WATCH_INTERVAL" flag:"grpc-watch-interval" usage:"how often WatchBalance checks a watched balance for changes"`
+}
+
+type DBConfig struct {
+	Driver          string        `yaml:"driver" toml:"driver" env:"DB_DRIVER" flag:"db-driver" usage:"database driver (mysql or postgres)"`
+	Host            string        `yaml:"host" toml:"host" env:"DB_HOST" flag:"db-host" usage:"database host"`
+	Port            int           `yaml:"port" toml:"port" env:"DB_PORT" flag:"db-port" usage:"database port (0 uses the driver default)"`
+	User            string        `yaml:"user" toml:"user" env:"DB_USER" flag:"db-user" usage:"database user"`
+	Password        string        `yaml:"password" toml:"password" env:"DB_PASSWORD" flag:"db-password" usage:"database password"`
+	Name            string        `yaml:"name" toml:"name" env:"DB_NAME" flag:"db-name" usage:"database name"`
+	SSLMode         string        `yaml:"ssl_mode" toml:"ssl_mode" env:"DB_SSL_MODE" flag:"db-ssl-mode" usage:"postgres sslmode"`
+	MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" flag:"db-max-open-conns" usage:"maximum open connections (0 is unlimited)"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" flag:"db-max-idle-conns" usage:"maximum idle connections"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" flag:"db-conn-max-lifetime" usage:"maximum connection lifetime"`
+	AutoMigrate     bool          `yaml:"auto_migrate" toml:"auto_migrate" env:"DB_AUTO_MIGRATE" flag:"db-auto-migrate" usage:"apply pending schema migrations at startup"`
+}
+
+// PortOrDefault returns the configured port, falling back to the standard
+// port of the configured driver.
+func (c DBConfig) PortOrDefault() int {
+	if c.Port != 0 {
+		return c.Port
+	}
+	if c.Driver == "postgres" {
+		return 5432
+	}
+	return 3306
+}
+
+type RedisConfig struct {
+	Host     string        `yaml:"host" toml:"host" env:"REDIS_HOST" flag:"redis-host" usage:"redis host"`
+	Port     int           `yaml:"port" toml:"port" env:"REDIS_PORT" flag:"redis-port" usage:"redis port"`
+	Password string        `yaml:"password" toml:"password" env:"REDIS_PASSWORD" flag:"redis-password" usage:"redis password"`
+	DB       int           `yaml:"db" toml:"db" env:"REDIS_DB" flag:"redis-db" usage:"redis database index"`
+	TTL      time.Duration `yaml:"ttl" toml:"ttl" env:"REDIS_TTL" flag:"redis-ttl" usage:"credit cache entry lifetime"`
+	Timeout  time.Duration `yaml:"timeout" toml:"timeout" env:"REDIS_TIMEOUT" flag:"redis-timeout" usage:"how long to wait for redis to connect, answer or accept a command before reading from the database instead"`
+	Critical bool          `yaml:"critical" toml:"critical" env:"REDIS_CRITICAL" flag:"redis-critical" usage:"report the server unready when redis is down (otherwise it is only degraded)"`
+}
+
+func (c RedisConfig) Addr() string {
+	return c.Host + ":" + strconv.Itoa(c.Port)
+}
+
+type JWTConfig struct {
+	SecretKey       string `yaml:"secret_key" toml:"secret_key" env:"JWT_SECRET_KEY" flag:"jwt-secret-key" usage:"HMAC key used to sign tokens"`
+	Issuer          string `yaml:"issuer" toml:"issuer" env:"JWT_ISSUER" flag:"jwt-issuer" usage:"token issuer"`
+	ExpirationHours int    `yaml:"expiration_hours" toml:"expiration_hours" env:"JWT_EXPIRATION_HOURS" flag:"jwt-expiration-hours" usage:"token lifetime in hours"`
+}
+
+func (c JWTConfig) Expiration() time.Duration {
+	return time.Duration(c.ExpirationHours) * time.Hour
+}
+
+type LimitsConfig struct {
+	MaxBatchSize        int     `yaml:"max_batch_size" toml:"max_batch_size" env:"LIMITS_MAX_BATCH_SIZE" flag:"max-batch-size" usage:"maximum entries in a batch request"`
+	MaxRequestBodyBytes int64   `yaml:"max_request_body_bytes" toml:"max_request_body_bytes" env:"LIMITS_MAX_REQUEST_BODY_BYTES" flag:"max-request-body-bytes" usage:"maximum request body size in bytes"`
+	MaxAmount           float64 `yaml:"max_amount" toml:"max_amount" env:"LIMITS_MAX_AMOUNT" flag:"max-amount" usage:"largest amount a single transfer or credit may move"`
+}
+
+type QueueConfig struct {
+	URL           string `yaml:"url" toml:"url" env:"SQS_QUEUE_URL" flag:"sqs-queue-url" usage:"queue that receives commands"`
+	DeadLetterURL string `yaml:"dead_letter_url" toml:"dead_letter_url" env:"SQS_DLQ_URL" flag:"sqs-dlq-url" usage:"dead-letter queue that receives poison messages"`
+	MaxAttempts   int    `yaml:"max_attempts" toml:"max_attempts" env:"QUEUE_MAX_ATTEMPTS" flag:"queue-max-attempts" usage:"deliveries before a failing message is dead-lettered"`
+	// A claim on a message outlives its delivery, so a redelivery waits for
+	// it to finish instead of running it next to it.
+	ClaimLease        time.Duration `yaml:"claim_lease" toml:"claim_lease" env:"QUEUE_CLAIM_LEASE" flag:"queue-claim-lease" usage:"how long a delivery holds a message before another may take it over"`
+	VisibilityTimeout time.Duration `yaml:"visibility_timeout" toml:"visibility_timeout" env:"SQS_VISIBILITY_TIMEOUT" flag:"sqs-visibility-timeout" usage:"visibility timeout of the queue, as configured in SQS"`
+	FunctionTimeout   time.Duration `yaml:"function_timeout" toml:"function_timeout" env:"LAMBDA_TIMEOUT" flag:"lambda-timeout" usage:"timeout of the lambda that processes the queue, as configured in AWS"`
+}
+
+type LedgerConfig struct {
+	CheckpointKey        string        `yaml:"checkpoint_key" toml:"checkpoint_key" env:"LEDGER_CHECKPOINT_KEY" flag:"ledger-checkpoint-key" usage:"HMAC key that signs transaction chain checkpoints"`
+	ReconcileInterval    time.Duration `yaml:"reconcile_interval" toml:"reconcile_interval" env:"LEDGER_RECONCILE_INTERVAL" flag:"ledger-reconcile-interval" usage:"how often the server reconciles balances against their history (0 disables)"`
+	ReconcileRepairCache bool          `yaml:"reconcile_repair_cache" toml:"reconcile_repair_cache" env:"LEDGER_RECONCILE_REPAIR_CACHE" flag:"ledger-reconcile-repair-cache" usage:"drop stale cached balances found by scheduled reconciliations"`
+	SnapshotInterval     time.Duration `yaml:"snapshot_interval" toml:"snapshot_interval" env:"LEDGER_SNAPSHOT_INTERVAL" flag:"ledger-snapshot-interval" usage:"how often the server takes the end-of-day balance snapshots that are due (0 disables)"`
+	StatementInterval    time.Duration `yaml:"statement_interval" toml:"statement_interval" env:"LEDGER_STATEMENT_INTERVAL" flag:"ledger-statement-interval" usage:"how often the server pre-generates last month's statements that are missing (0 disables)"`
+	Currency             string        `yaml:"currency" toml:"currency" env:"LEDGER_CURRENCY" flag:"ledger-currency" usage:"ISO 4217 code of the currency balances are kept in, used by bank exports"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" toml:"level" env:"LOG_LEVEL" flag:"log-level" usage:"lowest level logged: debug, info, warn or error"`
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT" flag:"log-format" usage:"log format: text or json"`
+}
+
+type MetricsConfig struct {
+	Enabled   bool   `yaml:"enabled" toml:"enabled" env:"METRICS_ENABLED" flag:"metrics-enabled" usage:"serve Prometheus metrics at /metrics"`
+	EMF       bool   `yaml:"emf" toml:"emf" env:"METRICS_EMF" flag:"metrics-emf" usage:"write metrics to stdout as CloudWatch embedded metric format lines"`
+	Namespace string `yaml:"namespace" toml:"namespace" env:"METRICS_NAMESPACE" flag:"metrics-namespace" usage:"CloudWatch namespace of EMF metrics"`
+}
+
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" toml:"exporter" env:"TRACING_EXPORTER" flag:"tracing-exporter" usage:"where spans are sent: none, otlp or stdout"`
+	Endpoint    string  `yaml:"endpoint" toml:"endpoint" env:"TRACING_OTLP_ENDPOINT" flag:"tracing-otlp-endpoint" usage:"host:port of the OTLP/HTTP collector (empty uses OTEL_EXPORTER_OTLP_ENDPOINT or localhost:4318)"`
+	Insecure    bool    `yaml:"insecure" toml:"insecure" env:"TRACING_OTLP_INSECURE" flag:"tracing-otlp-insecure" usage:"send spans to the OTLP collector over plain HTTP"`
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" flag:"tracing-sample-ratio" usage:"share of traces started here that are recorded, from 0 to 1"`
+	ServiceName string  `yaml:"service_name" toml:"service_name" env:"TRACING_SERVICE_NAME" flag:"tracing-service-name" usage:"service name spans are reported under"`
+}
+
+type HealthConfig struct {
+	DBTimeout         time.Duration `yaml:"db_timeout" toml:"db_timeout" env:"HEALTH_DB_TIMEOUT" flag:"health-db-timeout" usage:"how long the readiness check waits for the database to answer a ping"`
+	RedisTimeout      time.Duration `yaml:"redis_timeout" toml:"redis_timeout" env:"HEALTH_REDIS_TIMEOUT" flag:"health-redis-timeout" usage:"how long the readiness check waits for redis to answer a ping"`
+	MigrationsTimeout time.Duration `yaml:"migrations_timeout" toml:"migrations_timeout" env:"HEALTH_MIGRATIONS_TIMEOUT" flag:"health-migrations-timeout" usage:"how long the readiness check waits to learn whether migrations are pending"`
+}
+
+// RateLimitConfig sets the token buckets of the throttled endpoints: each
+// holds Requests tokens and refills them over Window.
+type RateLimitConfig struct {
+	Enabled           bool          `yaml:"enabled" toml:"enabled" env:"RATE_LIMIT_ENABLED" flag:"rate-limit-enabled" usage:"throttle logins, signups and transfers"`
+	LoginRequests     int           `yaml:"login_requests" toml:"login_requests" env:"RATE_LIMIT_LOGIN_REQUESTS" flag:"rate-limit-login-requests" usage:"login attempts a client address may make per login window"`
+	LoginWindow       time.Duration `yaml:"login_window" toml:"login_window" env:"RATE_LIMIT_LOGIN_WINDOW" flag:"rate-limit-login-window" usage:"window of the login limit"`
+	SignupRequests    int           `yaml:"signup_requests" toml:"signup_requests" env:"RATE_LIMIT_SIGNUP_REQUESTS" flag:"rate-limit-signup-requests" usage:"signups a client address may make per signup window"`
+	SignupWindow      time.Duration `yaml:"signup_window" toml:"signup_window" env:"RATE_LIMIT_SIGNUP_WINDOW" flag:"rate-limit-signup-window" usage:"window of the signup limit"`
+	TransferRequests  int           `yaml:"transfer_requests" toml:"transfer_requests" env:"RATE_LIMIT_TRANSFER_REQUESTS" flag:"rate-limit-transfer-requests" usage:"transfers a user may send per transfer window"`
+	TransferWindow    time.Duration `yaml:"transfer_window" toml:"transfer_window" env:"RATE_LIMIT_TRANSFER_WINDOW" flag:"rate-limit-transfer-window" usage:"window of the transfer limit"`
+	TrustForwardedFor bool          `yaml:"trust_forwarded_for" toml:"trust_forwarded_for" env:"RATE_LIMIT_TRUST_FORWARDED_FOR" flag:"rate-limit-trust-forwarded-for" usage:"count and audit clients by the address the nearest proxy appended to X-Forwarded-For instead of the connection's"`
+}
+
+// LockoutConfig sets how failed logins lock an account. Every failure past
+// Threshold doubles the lockout, up to MaxDuration.
+type LockoutConfig struct {
+	Threshold   int           `yaml:"threshold" toml:"threshold" env:"LOCKOUT_THRESHOLD" flag:"lockout-threshold" usage:"failed logins in a row that lock an account (0 disables lockouts)"`
+	Duration    time.Duration `yaml:"duration" toml:"duration" env:"LOCKOUT_DURATION" flag:"lockout-duration" usage:"how long the first lockout lasts"`
+	MaxDuration time.Duration `yaml:"max_duration" toml:"max_duration" env:"LOCKOUT_MAX_DURATION" flag:"lockout-max-duration" usage:"longest lockout"`
+	Window      time.Duration `yaml:"window" toml:"window" env:"LOCKOUT_WINDOW" flag:"lockout-window" usage:"how long failed logins are remembered after the last one"`
+}
+
+// IdempotencyConfig sets how requests sent with an Idempotency-Key are
+// deduplicated.
+type IdempotencyConfig struct {
+	TTL   time.Duration `yaml:"ttl" toml:"ttl" env:"IDEMPOTENCY_TTL" flag:"idempotency-ttl" usage:"how long the response to a request with an Idempotency-Key is replayed to its retries"`
+	Lease time.Duration `yaml:"lease" toml:"lease" env:"IDEMPOTENCY_LEASE" flag:"idempotency-lease" usage:"how long an unfinished request with an Idempotency-Key holds its key before a retry may run it again"`
+}
+
+// Default returns the configuration used before any file, environment
+// variable or flag is applied.
+func Default() Config {
+	return Config{
+		Server: ServerConfig{
+			Addr:              ":8080",
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      60 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   25 * time.Second,
+		},
+		GRPC: GRPCConfig{
+			Addr:          ":9090",
+			WatchInterval: time.Second,
+		},
+		DB: DBConfig{
+			Driver:          "mysql",
+			Host:            "localhost",
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		Redis: RedisConfig{
+			Host:     "localhost",
+			Port:     6379,
+			TTL:      30 * time.Minute,
+			Timeout:  500 * time.Millisecond,
+			Critical: true,
+		},
+		JWT: JWTConfig{
+			Issuer:          "ledger.app",
+			ExpirationHours: 24,
+		},
+		Limits: LimitsConfig{
+			MaxBatchSize:        1000,
+			MaxRequestBodyBytes: 1 << 20,
+			MaxAmount:           1_000_000,
+		},
+		Queue: QueueConfig{
+			MaxAttempts:       3,
+			ClaimLease:        2 * time.Minute,
+			VisibilityTimeout: time.Minute,
+			FunctionTimeout:   30 * time.Second,
+		},
+		Ledger: LedgerConfig{
+			SnapshotInterval:  time.Hour,
+			StatementInterval: time.Hour,
+			Currency:          "TRY",
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+		Metrics: MetricsConfig{
+			Enabled:   true,
+			Namespace: "Ledger",
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRatio: 1,
+			ServiceName: "ledger",
+		},
+		Health: HealthConfig{
+			DBTimeout:         2 * time.Second,
+			RedisTimeout:      500 * time.Millisecond,
+			MigrationsTimeout: 2 * time.Second,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:          true,
+			LoginRequests:    10,
+			LoginWindow:      time.Minute,
+			SignupRequests:   5,
+			SignupWindow:     time.Hour,
+			TransferRequests: 30,
+			TransferWindow:   time.Minute,
+		},
+		Lockout: LockoutConfig{
+			Threshold:   5,
+			Duration:    time.Minute,
+			MaxDuration: time.Hour,
+			Window:      24 * time.Hour,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:   24 * time.Hour,
+			Lease: time.Minute,
+		},
+	}
+}
+
+func (c *Config) String() string {
+	redacted := *c
+	if redacted.DB.Password != "" {
+		redacted.DB.Password = "***"
+	}
+	if redacted.Redis.Password != "" {
+		redacted.Redis.Password = "***"
+	}
+	if redacted.JWT.SecretKey != "" {
+		redacted.JWT.SecretKey = "***"
+	}
+	if redacted.Ledger.CheckpointKey != "" {
+		redacted.Ledger.CheckpointKey = "***"
+	}
+	return fmt.Sprintf("%+v", redacted)
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// unsetenv clears keys for the test, so settings of the environment the
+// tests run in cannot leak into them.
+func unsetenv(t *testing.T, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseLayers(t *testing.T) {
+	unsetenv(t, FileEnv, "SERVER_ADDR", "DB_USER", "DB_NAME", "REDIS_HOST", "REDIS_PORT")
+	path := writeFile(t, "ledger.yaml", `
+server:
+  addr: ":7000"
+db:
+  user: file-user
+  name: file-db
+redis:
+  host: file-redis
+`)
+	t.Setenv("DB_NAME", "env-db")
+	t.Setenv("REDIS_HOST", "env-redis")
+
+	cfg, err := Parse(Default(), []string{"-config", path, "-db-name", "flag-db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for setting, got := range map[string][2]interface{}{
+		"redis.port (default)": {cfg.Redis.Port, 6379},
+		"server.addr (file)":   {cfg.Server.Addr, ":7000"},
+		"db.user (file)":       {cfg.DB.User, "file-user"},
+		"redis.host (env)":     {cfg.Redis.Host, "env-redis"},
+		"db.name (flag)":       {cfg.DB.Name, "flag-db"},
+	} {
+		if got[0] != got[1] {
+			t.Errorf("%s: got %v, want %v", setting, got[0], got[1])
+		}
+	}
+}
+
+func TestParseFileFromEnv(t *testing.T) {
+	unsetenv(t, "SERVER_ADDR")
+	t.Setenv(FileEnv, writeFile(t, "env.toml", "[server]\naddr = \":7001\"\n"))
+
+	cfg, err := Parse(Default(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Addr != ":7001" {
+		t.Errorf("got server.addr %q from %s, want :7001", cfg.Server.Addr, FileEnv)
+	}
+
+	// -config takes precedence over the variable.
+	cfg, err = Parse(Default(), []string{"-config", writeFile(t, "flag.yaml", "server:\n  addr: \":7002\"\n")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Addr != ":7002" {
+		t.Errorf("got server.addr %q, want :7002 from -config", cfg.Server.Addr)
+	}
+}
+
+func TestParseRejectsUnknownKeys(t *testing.T) {
+	unsetenv(t, FileEnv)
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{"yaml key", "ledger.yaml", "server:\n  adress: \":7000\"\n", "adress"},
+		{"yaml section", "ledger.yml", "sever:\n  addr: \":7000\"\n", "sever"},
+		{"toml key", "ledger.toml", "[server]\nadress = \":7000\"\n", "adress"},
+		{"toml section", "ledger.toml", "[sever]\naddr = \":7000\"\n", "sever"},
+		{"wrong type", "ledger.yaml", "redis:\n  port: many\n", "many"},
+		{"unsupported extension", "ledger.json", "{}", "unsupported config file extension"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(Default(), []string{"-config", writeFile(t, tt.file, tt.content)})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseReportsEveryBadValue(t *testing.T) {
+	unsetenv(t, FileEnv)
+	t.Setenv("REDIS_PORT", "many")
+	t.Setenv("SERVER_SHUTDOWN_TIMEOUT", "soon")
+
+	_, err := Parse(Default(), []string{"-db-port", "none"})
+	if err == nil {
+		t.Fatal("got no error")
+	}
+	for _, want := range []string{"REDIS_PORT", "SERVER_SHUTDOWN_TIMEOUT", "-db-port"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("got %v, want it to mention %s", err, want)
+		}
+	}
+}
+
+// valid returns the defaults with the settings that have none filled in.
+func valid() Config {
+	cfg := Default()
+	cfg.DB.User = "ledger"
+	cfg.DB.Name = "ledger"
+	cfg.JWT.SecretKey = "jwt-secret"
+	cfg.Ledger.CheckpointKey = "checkpoint-secret"
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(c *Config)
+		// want lists the invalid fields in the order they are reported.
+		want []string
+	}{
+		{"valid", func(c *Config) {}, nil},
+		{"defaults", func(c *Config) { *c = Default() }, []string{"db.user", "db.name", "jwt.secret_key", "ledger.checkpoint_key"}},
+		{"no checkpoint key", func(c *Config) { c.Ledger.CheckpointKey = "" }, []string{"ledger.checkpoint_key"}},
+		{"every bad field", func(c *Config) {
+			c.Server.Addr = ""
+			c.DB.Driver = "sqlite"
+			c.Redis.Port = 0
+			c.JWT.SecretKey = ""
+			c.Ledger.CheckpointKey = ""
+			c.Ledger.Currency = "lira"
+			c.Log.Level = "loud"
+			c.Idempotency.Lease = 2 * c.Idempotency.TTL
+		}, []string{"server.addr", "db.driver", "redis.port", "jwt.secret_key", "ledger.checkpoint_key", "ledger.currency", "log.level", "idempotency.lease"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("got %v, want valid", err)
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("got %v, want a *ValidationError", err)
+			}
+			var got []string
+			for _, f := range verr.Fields {
+				got = append(got, f.Field)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got fields %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable that points at an optional config
+// file. The -config flag takes precedence over it.
+const FileEnv = "LEDGER_CONFIG"
+
+// Load resolves the configuration on top of base and validates the result.
+// A missing .env file is not an error; variables already present in the
+// environment always win over it.
+func Load(base Config, args []string) (*Config, error) {
+	cfg, err := Parse(base, args)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Parse resolves the configuration like Load but leaves validation to the
+// caller, for tools that only need part of the settings.
+func Parse(base Config, args []string) (*Config, error) {
+	return ParseWith(base, flag.NewFlagSet(filepath.Base(os.Args[0]), flag.ContinueOnError), args)
+}
+
+// ParseWith is Parse for commands with flags of their own: they are
+// registered on flags by the caller and parsed together with the config
+// flags.
+func ParseWith(base Config, flags *flag.FlagSet, args []string) (*Config, error) {
+	cfg := base
+
+	flagValues, configPath := registerFlags(flags, &cfg)
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if err := godotenv.Load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("loading .env: %w", err)
+	}
+
+	path := *configPath
+	if path == "" {
+		path = os.Getenv(FileEnv)
+	}
+	if path != "" {
+		if err := loadFile(&cfg, path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := errors.Join(applyEnv(&cfg), applyFlags(flags, flagValues)); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("parsing %s: unknown keys %v", path, undecoded)
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension %q (use .yaml, .yml or .toml)", filepath.Ext(path))
+	}
+
+	return nil
+}
+
+// field is a leaf setting reachable through the env and flag struct tags.
+type field struct {
+	value reflect.Value
+	env   string
+	flag  string
+	usage string
+}
+
+func fields(cfg *Config) []field {
+	var out []field
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			fv := v.Field(i)
+			if sf.Type.Kind() == reflect.Struct && sf.Type != reflect.TypeOf(time.Duration(0)) {
+				walk(fv)
+				continue
+			}
+			out = append(out, field{
+				value: fv,
+				env:   sf.Tag.Get("env"),
+				flag:  sf.Tag.Get("flag"),
+				usage: sf.Tag.Get("usage"),
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem())
+	return out
+}
+
+func applyEnv(cfg *Config) error {
+	var errs []error
+	for _, f := range fields(cfg) {
+		if f.env == "" {
+			continue
+		}
+		raw, ok := os.LookupEnv(f.env)
+		if !ok {
+			continue
+		}
+		if err := setValue(f.value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.env, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// registerFlags registers one flag per tagged field. Flag values are
+// collected as strings and only applied after the file and environment
+// layers.
+func registerFlags(flags *flag.FlagSet, cfg *Config) (map[string]reflect.Value, *string) {
+	configPath := flags.String("config", "", "path to a YAML or TOML config file")
+
+	values := make(map[string]reflect.Value)
+	for _, f := range fields(cfg) {
+		if f.flag == "" {
+			continue
+		}
+		values[f.flag] = f.value
+		flags.Var(&flagValue{isBool: f.value.Kind() == reflect.Bool}, f.flag, f.usage)
+	}
+
+	return values, configPath
+}
+
+// flagValue holds the raw text of a flag until it is applied to the config.
+type flagValue struct {
+	raw    string
+	isBool bool
+}
+
+func (v *flagValue) String() string     { return v.raw }
+func (v *flagValue) Set(s string) error { v.raw = s; return nil }
+func (v *flagValue) IsBoolFlag() bool   { return v.isBool }
+
+func applyFlags(flags *flag.FlagSet, values map[string]reflect.Value) error {
+	var errs []error
+	flags.Visit(func(fl *flag.Flag) {
+		v, ok := values[fl.Name]
+		if !ok {
+			return
+		}
+		if err := setValue(v, fl.Value.String()); err != nil {
+			errs = append(errs, fmt.Errorf("-%s: %w", fl.Name, err))
+		}
+	})
+	return errors.Join(errs...)
+}
+
+func setValue(v reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	if v.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// FieldError describes a single invalid setting.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationError collects every invalid setting found by Validate.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		msgs[i] = f.Error()
+	}
+	return "invalid configuration:\n  " + strings.Join(msgs, "\n  ")
+}
+
+func (e *ValidationError) add(field, format string, args ...interface{}) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate checks the whole configuration and returns a *ValidationError
+// listing every bad field, or nil.
+func (c *Config) Validate() error {
+	v := &ValidationError{}
+
+	if c.Server.Addr == "" {
+		v.add("server.addr", "is required")
+	}
+	if c.Server.ReadHeaderTimeout <= 0 {
+		v.add("server.read_header_timeout", "must be positive")
+	}
+	if c.Server.ReadTimeout < 0 {
+		v.add("server.read_timeout", "must not be negative")
+	}
+	if c.Server.WriteTimeout < 0 {
+		v.add("server.write_timeout", "must not be negative")
+	}
+	if c.Server.IdleTimeout < 0 {
+		v.add("server.idle_timeout", "must not be negative")
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		v.add("server.shutdown_timeout", "must be positive")
+	}
+	if c.GRPC.Addr != "" && c.GRPC.Addr == c.Server.Addr {
+		v.add("grpc.addr", "must differ from server.addr")
+	}
+	if c.GRPC.WatchInterval <= 0 {
+		v.add("grpc.watch_interval", "must be positive")
+	}
+
+	c.validateDB(v)
+
+	if c.Redis.Host == "" {
+		v.add("redis.host", "is required")
+	}
+	if c.Redis.Port <= 0 || c.Redis.Port > 65535 {
+		v.add("redis.port", "must be between 1 and 65535")
+	}
+	if c.Redis.DB < 0 {
+		v.add("redis.db", "must not be negative")
+	}
+	if c.Redis.TTL <= 0 {
+		v.add("redis.ttl", "must be positive")
+	}
+	if c.Redis.Timeout <= 0 {
+		v.add("redis.timeout", "must be positive")
+	}
+
+	if c.JWT.SecretKey == "" {
+		v.add("jwt.secret_key", "is required")
+	}
+	if c.JWT.Issuer == "" {
+		v.add("jwt.issuer", "is required")
+	}
+	if c.JWT.ExpirationHours <= 0 {
+		v.add("jwt.expiration_hours", "must be positive")
+	}
+
+	if c.Limits.MaxBatchSize <= 0 {
+		v.add("limits.max_batch_size", "must be positive")
+	}
+	if c.Limits.MaxRequestBodyBytes <= 0 {
+		v.add("limits.max_request_body_bytes", "must be positive")
+	}
+	if c.Limits.MaxAmount <= 0 {
+		v.add("limits.max_amount", "must be positive")
+	}
+
+	if c.Queue.MaxAttempts <= 0 {
+		v.add("queue.max_attempts", "must be positive")
+	}
+	if c.Queue.ClaimLease <= c.Queue.VisibilityTimeout || c.Queue.ClaimLease <= c.Queue.FunctionTimeout {
+		v.add("queue.claim_lease", "must be longer than queue.visibility_timeout and queue.function_timeout")
+	}
+
+	if c.Ledger.CheckpointKey == "" {
+		v.add("ledger.checkpoint_key", "is required")
+	}
+	if c.Ledger.ReconcileInterval < 0 {
+		v.add("ledger.reconcile_interval", "must not be negative")
+	}
+	if c.Ledger.SnapshotInterval < 0 {
+		v.add("ledger.snapshot_interval", "must not be negative")
+	}
+	if c.Ledger.StatementInterval < 0 {
+		v.add("ledger.statement_interval", "must not be negative")
+	}
+	if !currencyCode.MatchString(c.Ledger.Currency) {
+		v.add("ledger.currency", "must be an ISO 4217 code such as TRY")
+	}
+
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		v.add("log.level", "must be debug, info, warn or error")
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "text", "json":
+	default:
+		v.add("log.format", "must be text or json")
+	}
+	if c.Metrics.EMF && c.Metrics.Namespace == "" {
+		v.add("metrics.namespace", "is required when metrics.emf is set")
+	}
+	switch strings.ToLower(c.Tracing.Exporter) {
+	case "none", "otlp", "stdout":
+	default:
+		v.add("tracing.exporter", "must be none, otlp or stdout")
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		v.add("tracing.sample_ratio", "must be between 0 and 1")
+	}
+	if c.Tracing.ServiceName == "" {
+		v.add("tracing.service_name", "is required")
+	}
+	if c.Health.DBTimeout <= 0 {
+		v.add("health.db_timeout", "must be positive")
+	}
+	if c.Health.RedisTimeout <= 0 {
+		v.add("health.redis_timeout", "must be positive")
+	}
+	if c.Health.MigrationsTimeout <= 0 {
+		v.add("health.migrations_timeout", "must be positive")
+	}
+	if c.RateLimit.Enabled {
+		if c.RateLimit.LoginRequests <= 0 {
+			v.add("rate_limit.login_requests", "must be positive")
+		}
+		if c.RateLimit.LoginWindow <= 0 {
+			v.add("rate_limit.login_window", "must be positive")
+		}
+		if c.RateLimit.SignupRequests <= 0 {
+			v.add("rate_limit.signup_requests", "must be positive")
+		}
+		if c.RateLimit.SignupWindow <= 0 {
+			v.add("rate_limit.signup_window", "must be positive")
+		}
+		if c.RateLimit.TransferRequests <= 0 {
+			v.add("rate_limit.transfer_requests", "must be positive")
+		}
+		if c.RateLimit.TransferWindow <= 0 {
+			v.add("rate_limit.transfer_window", "must be positive")
+		}
+	}
+	if c.Lockout.Threshold < 0 {
+		v.add("lockout.threshold", "must not be negative")
+	}
+	if c.Lockout.Threshold > 0 {
+		if c.Lockout.Duration <= 0 {
+			v.add("lockout.duration", "must be positive")
+		}
+		if c.Lockout.MaxDuration < c.Lockout.Duration {
+			v.add("lockout.max_duration", "must not be shorter than lockout.duration")
+		}
+		if c.Lockout.Window <= 0 {
+			v.add("lockout.window", "must be positive")
+		}
+	}
+	if c.Idempotency.TTL <= 0 {
+		v.add("idempotency.ttl", "must be positive")
+	}
+	if c.Idempotency.Lease <= 0 || c.Idempotency.Lease > c.Idempotency.TTL {
+		v.add("idempotency.lease", "must be positive and not longer than idempotency.ttl")
+	}
+
+	if len(v.Fields) > 0 {
+		return v
+	}
+	return nil
+}
+
+// ValidateDB checks only the database settings, for tools such as the
+// migrate subcommand that never touch Redis or JWT.
+func (c *Config) ValidateDB() error {
+	v := &ValidationError{}
+	c.validateDB(v)
+	if len(v.Fields) > 0 {
+		return v
+	}
+	return nil
+}
+
+func (c *Config) validateDB(v *ValidationError) {
+	switch c.DB.Driver {
+	case "mysql", "postgres":
+	default:
+		v.add("db.driver", "must be mysql or postgres, got %q", c.DB.Driver)
+	}
+	if c.DB.Host == "" {
+		v.add("db.host", "is required")
+	}
+	if c.DB.Port < 0 || c.DB.Port > 65535 {
+		v.add("db.port", "must be between 0 and 65535")
+	}
+	if c.DB.User == "" {
+		v.add("db.user", "is required")
+	}
+	if c.DB.Name == "" {
+		v.add("db.name", "is required")
+	}
+	if c.DB.MaxOpenConns < 0 {
+		v.add("db.max_open_conns", "must not be negative")
+	}
+	if c.DB.MaxIdleConns < 0 {
+		v.add("db.max_idle_conns", "must not be negative")
+	} else if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		v.add("db.max_idle_conns", "must not exceed db.max_open_conns")
+	}
+	if c.DB.ConnMaxLifetime < 0 {
+		v.add("db.conn_max_lifetime", "must not be negative")
+	}
+}
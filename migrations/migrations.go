@@ -0,0 +1,16 @@
+// Package migrations embeds the versioned SQL schema for every supported
+// database driver. Files are named <version>_<name>.<up|down>.sql.
+package migrations
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed mysql/*.sql postgres/*.sql
+var files embed.FS
+
+// For returns the migration files for the given driver.
+func For(driver string) (fs.FS, error) {
+	return fs.Sub(files, driver)
+}
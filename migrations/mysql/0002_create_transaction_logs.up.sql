@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS transaction_logs (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    sender_id BIGINT UNSIGNED NOT NULL,
+    receiver_id BIGINT UNSIGNED NOT NULL,
+    amount DECIMAL(10, 2) NOT NULL,
+    sender_credit_before DECIMAL(10, 2) NOT NULL,
+    receiver_credit_before DECIMAL(10, 2) NOT NULL,
+    transaction_date DATETIME(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+    KEY idx_transaction_logs_sender_date (sender_id, transaction_date),
+    CONSTRAINT fk_transaction_logs_sender FOREIGN KEY (sender_id) REFERENCES users (id),
+    CONSTRAINT fk_transaction_logs_receiver FOREIGN KEY (receiver_id) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
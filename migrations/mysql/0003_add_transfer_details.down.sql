@@ -0,0 +1,10 @@
+-- The columns are dropped only while they exist, as MySQL has no DROP
+-- COLUMN IF EXISTS.
+SET @ddl = IF(
+    (SELECT COUNT(*) FROM information_schema.columns
+     WHERE table_schema = DATABASE() AND table_name = 'transaction_logs' AND column_name = 'description') > 0,
+    'ALTER TABLE transaction_logs DROP COLUMN receiver_credit_after, DROP COLUMN sender_credit_after, DROP COLUMN description',
+    'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
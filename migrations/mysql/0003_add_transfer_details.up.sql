@@ -0,0 +1,10 @@
+-- MySQL has no ADD COLUMN IF NOT EXISTS, so the change runs only while
+-- the column is missing. One ALTER TABLE applies whole or not at all.
+SET @ddl = IF(
+    (SELECT COUNT(*) FROM information_schema.columns
+     WHERE table_schema = DATABASE() AND table_name = 'transaction_logs' AND column_name = 'description') = 0,
+    'ALTER TABLE transaction_logs ADD COLUMN description VARCHAR(255) NOT NULL DEFAULT '''' AFTER amount, ADD COLUMN sender_credit_after DECIMAL(10, 2) NULL AFTER receiver_credit_before, ADD COLUMN receiver_credit_after DECIMAL(10, 2) NULL AFTER sender_credit_after',
+    'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
@@ -0,0 +1,9 @@
+-- Idempotency keys of queued commands. A row is claimed before the command
+-- runs and marked done once it succeeded.
+CREATE TABLE IF NOT EXISTS processed_messages (
+    message_key VARCHAR(255) NOT NULL PRIMARY KEY,
+    command VARCHAR(64) NOT NULL,
+    status VARCHAR(16) NOT NULL,
+    claimed_at DATETIME(3) NOT NULL,
+    completed_at DATETIME(3) NULL
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    occurred_at DATETIME(3) NOT NULL,
+    actor_id BIGINT UNSIGNED NULL,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    target VARCHAR(255) NOT NULL,
+    request_id VARCHAR(128) NOT NULL DEFAULT '',
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    before_value TEXT NULL,
+    after_value TEXT NULL,
+    reason VARCHAR(1024) NOT NULL DEFAULT '',
+    KEY idx_audit_events_occurred_at (occurred_at),
+    KEY idx_audit_events_target (target)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
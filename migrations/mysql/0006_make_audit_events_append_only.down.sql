@@ -0,0 +1,22 @@
+-- Each index is dropped only while it exists, as MySQL has no DROP KEY IF
+-- EXISTS.
+SET @ddl = IF(
+    (SELECT COUNT(*) FROM information_schema.statistics
+     WHERE table_schema = DATABASE() AND table_name = 'audit_events' AND index_name = 'idx_audit_events_actor_id') > 0,
+    'ALTER TABLE audit_events DROP KEY idx_audit_events_actor_id',
+    'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+SET @ddl = IF(
+    (SELECT COUNT(*) FROM information_schema.statistics
+     WHERE table_schema = DATABASE() AND table_name = 'audit_events' AND index_name = 'idx_audit_events_request_id') > 0,
+    'ALTER TABLE audit_events DROP KEY idx_audit_events_request_id',
+    'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+DROP TRIGGER IF EXISTS audit_events_no_delete;
+DROP TRIGGER IF EXISTS audit_events_no_update;
@@ -0,0 +1,34 @@
+-- Audit events are evidence: once written they can only be read. The
+-- triggers stop accidental or malicious edits through the application
+-- account; the indexes back the admin query filters.
+DROP TRIGGER IF EXISTS audit_events_no_update;
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events FOR EACH ROW
+BEGIN
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_events is append-only';
+END;
+
+DROP TRIGGER IF EXISTS audit_events_no_delete;
+CREATE TRIGGER audit_events_no_delete BEFORE DELETE ON audit_events FOR EACH ROW
+BEGIN
+    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'audit_events is append-only';
+END;
+
+-- MySQL has no ADD KEY IF NOT EXISTS, so each index is added only while
+-- it is missing.
+SET @ddl = IF(
+    (SELECT COUNT(*) FROM information_schema.statistics
+     WHERE table_schema = DATABASE() AND table_name = 'audit_events' AND index_name = 'idx_audit_events_actor_id') = 0,
+    'ALTER TABLE audit_events ADD KEY idx_audit_events_actor_id (actor_id)',
+    'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+SET @ddl = IF(
+    (SELECT COUNT(*) FROM information_schema.statistics
+     WHERE table_schema = DATABASE() AND table_name = 'audit_events' AND index_name = 'idx_audit_events_request_id') = 0,
+    'ALTER TABLE audit_events ADD KEY idx_audit_events_request_id (request_id)',
+    'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
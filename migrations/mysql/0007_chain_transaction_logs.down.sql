@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS ledger_checkpoints;
+DROP TABLE IF EXISTS ledger_chain_head;
+
+-- The columns are dropped only while they exist, as MySQL has no DROP
+-- COLUMN IF EXISTS.
+SET @ddl = IF(
+    (SELECT COUNT(*) FROM information_schema.columns
+     WHERE table_schema = DATABASE() AND table_name = 'transaction_logs' AND column_name = 'hash') > 0,
+    'ALTER TABLE transaction_logs DROP COLUMN hash, DROP COLUMN prev_hash',
+    'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
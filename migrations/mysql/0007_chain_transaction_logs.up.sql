@@ -0,0 +1,34 @@
+-- Every transfer recorded from now on carries the hash of its contents and
+-- of the entry before it. Rows written before this migration stay unhashed
+-- and sit outside the chain.
+-- MySQL has no ADD COLUMN IF NOT EXISTS, so the change runs only while
+-- the column is missing.
+SET @ddl = IF(
+    (SELECT COUNT(*) FROM information_schema.columns
+     WHERE table_schema = DATABASE() AND table_name = 'transaction_logs' AND column_name = 'hash') = 0,
+    'ALTER TABLE transaction_logs ADD COLUMN prev_hash VARCHAR(64) NULL, ADD COLUMN hash VARCHAR(64) NULL',
+    'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+-- The single head row is locked by every append, which serialises
+-- concurrent transfers into one chain.
+CREATE TABLE IF NOT EXISTS ledger_chain_head (
+    id INT NOT NULL PRIMARY KEY,
+    log_id BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    length BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    hash VARCHAR(64) NOT NULL DEFAULT ''
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+INSERT IGNORE INTO ledger_chain_head (id) VALUES (1);
+
+CREATE TABLE IF NOT EXISTS ledger_checkpoints (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    log_id BIGINT UNSIGNED NOT NULL,
+    length BIGINT UNSIGNED NOT NULL,
+    hash VARCHAR(64) NOT NULL,
+    signature VARCHAR(64) NOT NULL,
+    created_at DATETIME(3) NOT NULL,
+    KEY idx_ledger_checkpoints_log_id (log_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,6 @@
+-- Fails while single-party entries exist; the old schema cannot hold them.
+ALTER TABLE transaction_logs
+    MODIFY sender_id BIGINT UNSIGNED NOT NULL,
+    MODIFY receiver_id BIGINT UNSIGNED NOT NULL,
+    MODIFY sender_credit_before DECIMAL(10, 2) NOT NULL,
+    MODIFY receiver_credit_before DECIMAL(10, 2) NOT NULL;
@@ -0,0 +1,8 @@
+-- Credits and debits that are not transfers are logged with a single party:
+-- a credit has no sender and a debit no receiver. The missing side's
+-- columns are NULL.
+ALTER TABLE transaction_logs
+    MODIFY sender_id BIGINT UNSIGNED NULL,
+    MODIFY receiver_id BIGINT UNSIGNED NULL,
+    MODIFY sender_credit_before DECIMAL(10, 2) NULL,
+    MODIFY receiver_credit_before DECIMAL(10, 2) NULL;
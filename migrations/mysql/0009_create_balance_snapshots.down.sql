@@ -0,0 +1,12 @@
+-- The index is dropped only while it exists, as MySQL has no DROP KEY IF
+-- EXISTS.
+SET @ddl = IF(
+    (SELECT COUNT(*) FROM information_schema.statistics
+     WHERE table_schema = DATABASE() AND table_name = 'transaction_logs' AND index_name = 'idx_transaction_logs_date') > 0,
+    'ALTER TABLE transaction_logs DROP KEY idx_transaction_logs_date',
+    'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
+
+DROP TABLE IF EXISTS balance_snapshots;
@@ -0,0 +1,22 @@
+-- The balance of every user at the end of each UTC day. Point-in-time
+-- queries start from the latest snapshot and replay only the entries after
+-- it.
+CREATE TABLE IF NOT EXISTS balance_snapshots (
+    user_id BIGINT UNSIGNED NOT NULL,
+    day DATE NOT NULL,
+    balance DECIMAL(10, 2) NOT NULL,
+    created_at DATETIME(3) NOT NULL,
+    PRIMARY KEY (user_id, day),
+    KEY idx_balance_snapshots_day (day)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Snapshots replay the entries of a day across every account. MySQL has
+-- no ADD KEY IF NOT EXISTS, so the index is added only while it is missing.
+SET @ddl = IF(
+    (SELECT COUNT(*) FROM information_schema.statistics
+     WHERE table_schema = DATABASE() AND table_name = 'transaction_logs' AND index_name = 'idx_transaction_logs_date') = 0,
+    'ALTER TABLE transaction_logs ADD KEY idx_transaction_logs_date (transaction_date)',
+    'DO 0');
+PREPARE ddl FROM @ddl;
+EXECUTE ddl;
+DEALLOCATE PREPARE ddl;
@@ -0,0 +1,10 @@
+-- Pre-generated statements of closed periods, as JSON. The history they are
+-- built from is append-only, so they never go stale.
+CREATE TABLE IF NOT EXISTS account_statements (
+    user_id BIGINT UNSIGNED NOT NULL,
+    period_start DATE NOT NULL,
+    period_end DATE NOT NULL,
+    data MEDIUMTEXT NOT NULL,
+    created_at DATETIME(3) NOT NULL,
+    PRIMARY KEY (user_id, period_start, period_end)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,10 @@
+-- Failed logins by normalised email, including emails of no account, so a
+-- lockout does not tell whether an account exists. A successful login or an
+-- unlock deletes the row.
+CREATE TABLE IF NOT EXISTS login_failures (
+    email VARCHAR(255) NOT NULL PRIMARY KEY,
+    failures INT NOT NULL,
+    last_failure_at DATETIME(3) NOT NULL,
+    locked_until DATETIME(3) NULL,
+    KEY idx_login_failures_last_failure (last_failure_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,2 @@
+ALTER TABLE users
+    MODIFY credit DOUBLE NOT NULL DEFAULT 0;
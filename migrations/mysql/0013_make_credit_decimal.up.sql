@@ -0,0 +1,4 @@
+-- Balances are kept to the cent like every amount logged against them, as
+-- they are on Postgres. Existing balances are rounded to the cent.
+ALTER TABLE users
+    MODIFY credit DECIMAL(10, 2) NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS transaction_logs;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_transaction_logs_sender_date;
+
+ALTER TABLE transaction_logs
+    DROP COLUMN IF EXISTS receiver_credit_after,
+    DROP COLUMN IF EXISTS sender_credit_after,
+    DROP COLUMN IF EXISTS receiver_credit_before,
+    DROP COLUMN IF EXISTS sender_credit_before,
+    DROP COLUMN IF EXISTS receiver_id,
+    DROP COLUMN IF EXISTS sender_id;
+
+ALTER TABLE users DROP COLUMN IF EXISTS role;
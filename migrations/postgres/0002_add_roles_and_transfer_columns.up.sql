@@ -0,0 +1,13 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(10) NOT NULL DEFAULT 'user';
+
+UPDATE users SET role = 'admin' WHERE email = 'admin@ledger.com';
+
+ALTER TABLE transaction_logs
+    ADD COLUMN IF NOT EXISTS sender_id INTEGER REFERENCES users(id),
+    ADD COLUMN IF NOT EXISTS receiver_id INTEGER REFERENCES users(id),
+    ADD COLUMN IF NOT EXISTS sender_credit_before DECIMAL(10, 2),
+    ADD COLUMN IF NOT EXISTS receiver_credit_before DECIMAL(10, 2),
+    ADD COLUMN IF NOT EXISTS sender_credit_after DECIMAL(10, 2),
+    ADD COLUMN IF NOT EXISTS receiver_credit_after DECIMAL(10, 2);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_logs_sender_date ON transaction_logs (sender_id, created_at);
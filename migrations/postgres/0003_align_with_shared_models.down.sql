@@ -0,0 +1,3 @@
+ALTER TABLE transaction_logs RENAME COLUMN transaction_date TO created_at;
+
+ALTER TABLE users RENAME COLUMN password_hash TO password;
@@ -0,0 +1,5 @@
+-- The lambda and the server share one set of models; use the column names
+-- the MySQL schema already has.
+ALTER TABLE users RENAME COLUMN password TO password_hash;
+
+ALTER TABLE transaction_logs RENAME COLUMN created_at TO transaction_date;
@@ -0,0 +1,28 @@
+-- Every transfer recorded from now on carries the hash of its contents and
+-- of the entry before it. Rows written before this migration stay unhashed
+-- and sit outside the chain.
+ALTER TABLE transaction_logs
+    ADD COLUMN IF NOT EXISTS prev_hash VARCHAR(64) NULL,
+    ADD COLUMN IF NOT EXISTS hash VARCHAR(64) NULL;
+
+-- The single head row is locked by every append, which serialises
+-- concurrent transfers into one chain.
+CREATE TABLE IF NOT EXISTS ledger_chain_head (
+    id INTEGER PRIMARY KEY,
+    log_id BIGINT NOT NULL DEFAULT 0,
+    length BIGINT NOT NULL DEFAULT 0,
+    hash VARCHAR(64) NOT NULL DEFAULT ''
+);
+
+INSERT INTO ledger_chain_head (id) VALUES (1) ON CONFLICT (id) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS ledger_checkpoints (
+    id BIGSERIAL PRIMARY KEY,
+    log_id BIGINT NOT NULL,
+    length BIGINT NOT NULL,
+    hash VARCHAR(64) NOT NULL,
+    signature VARCHAR(64) NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_ledger_checkpoints_log_id ON ledger_checkpoints (log_id);
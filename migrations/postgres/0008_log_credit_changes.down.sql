@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_transaction_logs_receiver_id;
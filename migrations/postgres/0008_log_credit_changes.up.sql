@@ -0,0 +1,5 @@
+-- Credits and debits that are not transfers are logged with a single party:
+-- a credit has no sender and a debit no receiver. The columns are already
+-- nullable here; reconciliation and account history look entries up by
+-- receiver as well as by sender.
+CREATE INDEX IF NOT EXISTS idx_transaction_logs_receiver_id ON transaction_logs (receiver_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_transaction_logs_date;
+DROP TABLE IF EXISTS balance_snapshots;
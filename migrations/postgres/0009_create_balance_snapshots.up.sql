@@ -0,0 +1,15 @@
+-- The balance of every user at the end of each UTC day. Point-in-time
+-- queries start from the latest snapshot and replay only the entries after
+-- it.
+CREATE TABLE IF NOT EXISTS balance_snapshots (
+    user_id BIGINT NOT NULL,
+    day DATE NOT NULL,
+    balance DECIMAL(10, 2) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_balance_snapshots_day ON balance_snapshots (day);
+
+-- Snapshots replay the entries of a day across every account.
+CREATE INDEX IF NOT EXISTS idx_transaction_logs_date ON transaction_logs (transaction_date);
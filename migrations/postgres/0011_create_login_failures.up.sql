@@ -0,0 +1,11 @@
+-- Failed logins by normalised email, including emails of no account, so a
+-- lockout does not tell whether an account exists. A successful login or an
+-- unlock deletes the row.
+CREATE TABLE IF NOT EXISTS login_failures (
+    email VARCHAR(255) NOT NULL PRIMARY KEY,
+    failures INTEGER NOT NULL,
+    last_failure_at TIMESTAMP NOT NULL,
+    locked_until TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_login_failures_last_failure ON login_failures (last_failure_at);
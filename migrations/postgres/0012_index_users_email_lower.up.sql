@@ -0,0 +1,4 @@
+-- Logins look users up by lowercased email. Rows written before emails were
+-- normalised may hold capitals, so the lookup lowers both sides; MySQL
+-- compares emails case-insensitively already.
+CREATE INDEX IF NOT EXISTS idx_users_email_lower ON users (lower(email));
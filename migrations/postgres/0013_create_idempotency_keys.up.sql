@@ -0,0 +1,13 @@
+-- Requests sent with an Idempotency-Key, by a hash of the caller and the key.
+-- A row is claimed before the request runs and holds its response once it
+-- finished, which retries with the same key get instead of running it again.
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    key_hash CHAR(64) NOT NULL PRIMARY KEY,
+    fingerprint CHAR(64) NOT NULL,
+    status_code INTEGER NULL,
+    content_type VARCHAR(255) NULL,
+    body BYTEA NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created ON idempotency_keys (created_at);
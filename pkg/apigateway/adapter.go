@@ -0,0 +1,162 @@
+// Package apigateway serves lambda HTTP events through a regular
+// http.Handler, so the lambda runs the same router and middleware as the
+// server.
+package apigateway
+
+import (
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/response"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// EventKind identifies which integration produced an HTTP event.
+type EventKind int
+
+const (
+	UnknownEvent EventKind = iota
+	RESTEvent              // API Gateway REST API, payload format 1.0
+	HTTPEvent              // API Gateway HTTP API, payload format 2.0
+	ALBEvent               // Application Load Balancer target group
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case RESTEvent:
+		return "rest"
+	case HTTPEvent:
+		return "http"
+	case ALBEvent:
+		return "alb"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrUnknownEvent is returned for payloads that are not HTTP events.
+var ErrUnknownEvent = errors.New("unrecognised HTTP event")
+
+// probe holds the fields that tell the payload formats apart.
+type probe struct {
+	Version        string `json:"version"`
+	RawPath        string `json:"rawPath"`
+	HTTPMethod     string `json:"httpMethod"`
+	RequestContext struct {
+		ELB *json.RawMessage `json:"elb"`
+	} `json:"requestContext"`
+}
+
+// Detect reports which kind of HTTP event payload holds.
+func Detect(payload json.RawMessage) EventKind {
+	var p probe
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return UnknownEvent
+	}
+	switch {
+	case p.RequestContext.ELB != nil:
+		return ALBEvent
+	case p.Version == "2.0" && p.RawPath != "":
+		return HTTPEvent
+	case p.HTTPMethod != "":
+		return RESTEvent
+	}
+	return UnknownEvent
+}
+
+type Adapter struct {
+	handler http.Handler
+}
+
+func New(handler http.Handler) *Adapter {
+	return &Adapter{handler: handler}
+}
+
+// Proxy decodes payload as whichever HTTP event it is and returns the
+// matching response type.
+func (a *Adapter) Proxy(ctx context.Context, payload json.RawMessage) (interface{}, error) {
+	switch Detect(payload) {
+	case RESTEvent:
+		var e events.APIGatewayProxyRequest
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+		return a.ProxyREST(ctx, e), nil
+	case HTTPEvent:
+		var e events.APIGatewayV2HTTPRequest
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+		return a.ProxyHTTP(ctx, e), nil
+	case ALBEvent:
+		var e events.ALBTargetGroupRequest
+		if err := json.Unmarshal(payload, &e); err != nil {
+			return nil, err
+		}
+		return a.ProxyALB(ctx, e), nil
+	}
+	return nil, ErrUnknownEvent
+}
+
+// ProxyREST serves an API Gateway REST API proxy event.
+func (a *Adapter) ProxyREST(ctx context.Context, e events.APIGatewayProxyRequest) events.APIGatewayProxyResponse {
+	rec := a.serve(RESTRequest(ctx, e))
+	body, isBase64 := rec.encodedBody()
+	return events.APIGatewayProxyResponse{
+		StatusCode:        rec.status,
+		MultiValueHeaders: rec.header,
+		Body:              body,
+		IsBase64Encoded:   isBase64,
+	}
+}
+
+// ProxyHTTP serves an API Gateway HTTP API event. Set-Cookie headers are
+// returned through the cookies field as payload format 2.0 requires.
+func (a *Adapter) ProxyHTTP(ctx context.Context, e events.APIGatewayV2HTTPRequest) events.APIGatewayV2HTTPResponse {
+	rec := a.serve(HTTPRequest(ctx, e))
+	cookies := rec.header.Values("Set-Cookie")
+	rec.header.Del("Set-Cookie")
+
+	body, isBase64 := rec.encodedBody()
+	return events.APIGatewayV2HTTPResponse{
+		StatusCode:      rec.status,
+		Headers:         rec.singleHeaders(),
+		Cookies:         cookies,
+		Body:            body,
+		IsBase64Encoded: isBase64,
+	}
+}
+
+// ProxyALB serves an ALB target group event, answering with multi value
+// headers only when the target group has them enabled.
+func (a *Adapter) ProxyALB(ctx context.Context, e events.ALBTargetGroupRequest) events.ALBTargetGroupResponse {
+	rec := a.serve(ALBRequest(ctx, e))
+	body, isBase64 := rec.encodedBody()
+
+	resp := events.ALBTargetGroupResponse{
+		StatusCode:        rec.status,
+		StatusDescription: fmt.Sprintf("%d %s", rec.status, http.StatusText(rec.status)),
+		Body:              body,
+		IsBase64Encoded:   isBase64,
+	}
+	if e.MultiValueHeaders != nil {
+		resp.MultiValueHeaders = rec.header
+	} else {
+		resp.Headers = rec.singleHeaders()
+	}
+	return resp
+}
+
+func (a *Adapter) serve(req *http.Request, err error) *responseRecorder {
+	rec := newResponseRecorder()
+	if err != nil {
+		response.Error(rec, nil, apperr.ErrInvalidRequest.WithMessage("%v", err))
+		return rec
+	}
+	a.handler.ServeHTTP(rec, req)
+	return rec
+}
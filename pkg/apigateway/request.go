@@ -0,0 +1,158 @@
+package apigateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// RESTRequest converts an API Gateway REST API (payload v1) proxy event.
+func RESTRequest(ctx context.Context, e events.APIGatewayProxyRequest) (*http.Request, error) {
+	header := mergeValues(e.Headers, e.MultiValueHeaders)
+	query := mergeValues(e.QueryStringParameters, e.MultiValueQueryStringParameters)
+
+	req, err := newRequest(ctx, e.HTTPMethod, e.Path, "", url.Values(query).Encode(), e.Body, e.IsBase64Encoded, header)
+	if err != nil {
+		return nil, err
+	}
+	req.RemoteAddr = e.RequestContext.Identity.SourceIP
+	setRequestID(req, e.RequestContext.RequestID)
+	return req, nil
+}
+
+// HTTPRequest converts an API Gateway HTTP API (payload v2) event. The stage
+// prefix is stripped from the raw path unless the $default stage is used.
+// The raw path arrives percent-encoded; the URL carries it unescaped in Path
+// and as sent in RawPath, so an escaped slash stays part of its segment.
+func HTTPRequest(ctx context.Context, e events.APIGatewayV2HTTPRequest) (*http.Request, error) {
+	header := http.Header{}
+	for k, v := range e.Headers {
+		// v2 joins repeated headers with commas, which for a list header
+		// means the same as repeating it. Other values, such as dates and
+		// user agents, may hold commas of their own, so none is split.
+		header.Set(k, v)
+	}
+	if len(e.Cookies) > 0 {
+		header.Set("Cookie", strings.Join(e.Cookies, "; "))
+	}
+
+	rawPath := e.RawPath
+	if stage := e.RequestContext.Stage; stage != "" && stage != "$default" {
+		rawPath = strings.TrimPrefix(rawPath, "/"+stage)
+	}
+	path, err := url.PathUnescape(rawPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid path: %w", err)
+	}
+
+	req, err := newRequest(ctx, e.RequestContext.HTTP.Method, path, rawPath, e.RawQueryString, e.Body, e.IsBase64Encoded, header)
+	if err != nil {
+		return nil, err
+	}
+	req.RemoteAddr = e.RequestContext.HTTP.SourceIP
+	setRequestID(req, e.RequestContext.RequestID)
+	return req, nil
+}
+
+// ALBRequest converts an Application Load Balancer target group event. ALB
+// passes query values still URL-encoded.
+func ALBRequest(ctx context.Context, e events.ALBTargetGroupRequest) (*http.Request, error) {
+	header := mergeValues(e.Headers, e.MultiValueHeaders)
+
+	query := url.Values{}
+	for k, values := range mergeValues(e.QueryStringParameters, e.MultiValueQueryStringParameters) {
+		key, err := url.QueryUnescape(k)
+		if err != nil {
+			key = k
+		}
+		for _, v := range values {
+			value, err := url.QueryUnescape(v)
+			if err != nil {
+				value = v
+			}
+			query.Add(key, value)
+		}
+	}
+
+	req, err := newRequest(ctx, e.HTTPMethod, e.Path, "", query.Encode(), e.Body, e.IsBase64Encoded, header)
+	if err != nil {
+		return nil, err
+	}
+	req.RemoteAddr = lastHop(req.Header.Values("X-Forwarded-For"))
+	return req, nil
+}
+
+// lastHop returns the address the load balancer appended to forwarded, the
+// values of X-Forwarded-For; the entries before it are the client's to forge.
+func lastHop(forwarded []string) string {
+	if len(forwarded) == 0 {
+		return ""
+	}
+	hops := strings.Split(forwarded[len(forwarded)-1], ",")
+	return strings.TrimSpace(hops[len(hops)-1])
+}
+
+// setRequestID passes the API Gateway request ID on as X-Request-ID unless
+// the client sent one.
+func setRequestID(req *http.Request, id string) {
+	if id != "" && req.Header.Get("X-Request-ID") == "" {
+		req.Header.Set("X-Request-ID", id)
+	}
+}
+
+// mergeValues combines the single and multi value maps of an event; the
+// multi value map wins when both carry the same key.
+func mergeValues(single map[string]string, multi map[string][]string) http.Header {
+	out := http.Header{}
+	for k, v := range single {
+		out[k] = []string{v}
+	}
+	for k, v := range multi {
+		out[k] = append([]string(nil), v...)
+	}
+	return out
+}
+
+// newRequest builds the request for an event. path is unescaped; rawPath,
+// when the event has one, is its escaped form.
+func newRequest(ctx context.Context, method, path, rawPath, rawQuery, body string, isBase64 bool, header http.Header) (*http.Request, error) {
+	if method == "" {
+		return nil, fmt.Errorf("event has no HTTP method")
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if rawPath != "" && !strings.HasPrefix(rawPath, "/") {
+		rawPath = "/" + rawPath
+	}
+
+	payload := []byte(body)
+	if isBase64 {
+		decoded, err := base64.StdEncoding.DecodeString(body)
+		if err != nil {
+			return nil, fmt.Errorf("decoding base64 body: %w", err)
+		}
+		payload = decoded
+	}
+
+	u := &url.URL{Path: path, RawPath: rawPath, RawQuery: rawQuery}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	for k, values := range header {
+		for _, v := range values {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Host = req.Header.Get("Host")
+	req.RequestURI = u.RequestURI()
+	return req, nil
+}
@@ -0,0 +1,84 @@
+package apigateway
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestHTTPRequestKeepsCommasInHeaders(t *testing.T) {
+	e := events.APIGatewayV2HTTPRequest{
+		RawPath: "/v1/users",
+		Headers: map[string]string{
+			"if-modified-since": "Wed, 21 Oct 2015 07:28:00 GMT",
+			"x-forwarded-for":   "6.6.6.6, 203.0.113.9",
+		},
+	}
+	e.RequestContext.HTTP.Method = "GET"
+
+	req, err := HTTPRequest(context.Background(), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := req.Header.Values("If-Modified-Since"); len(got) != 1 || got[0] != "Wed, 21 Oct 2015 07:28:00 GMT" {
+		t.Errorf("got If-Modified-Since %q", got)
+	}
+	if got := req.Header.Get("X-Forwarded-For"); got != "6.6.6.6, 203.0.113.9" {
+		t.Errorf("got X-Forwarded-For %q", got)
+	}
+}
+
+func TestHTTPRequestUnescapesThePathOnce(t *testing.T) {
+	e := events.APIGatewayV2HTTPRequest{
+		RawPath:        "/prod/v1/users/a%2Fb/c%20d%25",
+		RawQueryString: "q=x%20y",
+	}
+	e.RequestContext.HTTP.Method = "GET"
+	e.RequestContext.Stage = "prod"
+
+	req, err := HTTPRequest(context.Background(), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.URL.Path != "/v1/users/a/b/c d%" {
+		t.Errorf("got path %q", req.URL.Path)
+	}
+	if got := req.URL.EscapedPath(); got != "/v1/users/a%2Fb/c%20d%25" {
+		t.Errorf("got escaped path %q", got)
+	}
+	if req.RequestURI != "/v1/users/a%2Fb/c%20d%25?q=x%20y" {
+		t.Errorf("got request URI %q", req.RequestURI)
+	}
+
+	e.RawPath = "/prod/v1/users/%zz"
+	if _, err := HTTPRequest(context.Background(), e); err == nil {
+		t.Error("got no error for a malformed escape")
+	}
+}
+
+func TestALBRequestTakesTheLastHop(t *testing.T) {
+	tests := []struct {
+		name   string
+		single map[string]string
+		multi  map[string][]string
+		want   string
+	}{
+		{"single hop", map[string]string{"x-forwarded-for": "203.0.113.9"}, nil, "203.0.113.9"},
+		{"forged hops", map[string]string{"x-forwarded-for": "6.6.6.6, 203.0.113.9"}, nil, "203.0.113.9"},
+		{"multi value", nil, map[string][]string{"x-forwarded-for": {"6.6.6.6", "203.0.113.9"}}, "203.0.113.9"},
+		{"none", nil, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := events.ALBTargetGroupRequest{HTTPMethod: "GET", Path: "/", Headers: tt.single, MultiValueHeaders: tt.multi}
+			req, err := ALBRequest(context.Background(), e)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if req.RemoteAddr != tt.want {
+				t.Errorf("got %q, want %q", req.RemoteAddr, tt.want)
+			}
+		})
+	}
+}
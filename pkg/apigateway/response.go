@@ -0,0 +1,86 @@
+package apigateway
+
+import (
+	"bytes"
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// responseRecorder collects everything a handler writes. Unlike a plain
+// buffer swap it appends on every Write, so streamed responses survive.
+type responseRecorder struct {
+	header      http.Header
+	body        bytes.Buffer
+	status      int
+	wroteHeader bool
+}
+
+func newResponseRecorder() *responseRecorder {
+	return &responseRecorder{header: make(http.Header), status: http.StatusOK}
+}
+
+func (r *responseRecorder) Header() http.Header {
+	return r.header
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.wroteHeader {
+		return
+	}
+	r.status = status
+	r.wroteHeader = true
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	if r.header.Get("Content-Type") == "" {
+		r.header.Set("Content-Type", http.DetectContentType(p))
+	}
+	return r.body.Write(p)
+}
+
+// encodedBody returns the body as the proxy integrations expect it: text as
+// is, anything else base64 encoded.
+func (r *responseRecorder) encodedBody() (string, bool) {
+	body := r.body.Bytes()
+	if isText(r.header.Get("Content-Type")) && utf8.Valid(body) {
+		return string(body), false
+	}
+	return base64.StdEncoding.EncodeToString(body), true
+}
+
+// singleHeaders flattens the headers for integrations that only accept one
+// value per name.
+func (r *responseRecorder) singleHeaders() map[string]string {
+	out := make(map[string]string, len(r.header))
+	for k, v := range r.header {
+		out[k] = strings.Join(v, ",")
+	}
+	return out
+}
+
+func isText(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/json",
+		strings.HasSuffix(mediaType, "+json"),
+		mediaType == "application/xml",
+		strings.HasSuffix(mediaType, "+xml"),
+		mediaType == "application/javascript",
+		mediaType == "application/x-www-form-urlencoded":
+		return true
+	}
+	return false
+}
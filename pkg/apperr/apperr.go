@@ -0,0 +1,139 @@
+// Package apperr holds the errors the API reports to its clients. Each one
+// carries a stable code, which clients may rely on, and the HTTP status it
+// is answered with; the message is for people and may change. Layers below
+// the handlers return these errors, or wrap them, and pkg/response turns
+// them into problem details.
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Code identifies a kind of error. Codes never change once published.
+type Code string
+
+const (
+	CodeInvalidRequest       Code = "invalid_request"
+	CodeValidationFailed     Code = "validation_failed"
+	CodeInvalidAmount        Code = "invalid_amount"
+	CodeRequestTooLarge      Code = "request_too_large"
+	CodeUnauthorized         Code = "unauthorized"
+	CodeInvalidCredentials   Code = "invalid_credentials"
+	CodeForbidden            Code = "forbidden"
+	CodeNotFound             Code = "not_found"
+	CodeUserNotFound         Code = "user_not_found"
+	CodeMethodNotAllowed     Code = "method_not_allowed"
+	CodeEmailTaken           Code = "email_taken"
+	CodeConflict             Code = "conflict"
+	CodeInsufficientFunds    Code = "insufficient_funds"
+	CodeIdempotencyKeyReused Code = "idempotency_key_reused"
+	CodeRequestInProgress    Code = "request_in_progress"
+	CodeRateLimited          Code = "rate_limited"
+	CodeLoginLocked          Code = "login_locked"
+	CodeInternal             Code = "internal"
+	CodeUnavailable          Code = "unavailable"
+)
+
+// The errors below are compared with errors.Is, which matches any error of
+// the same code whatever its message, so WithMessage and Wrap keep them
+// recognisable.
+var (
+	ErrInvalidRequest       = New(CodeInvalidRequest, http.StatusBadRequest, "invalid request")
+	ErrValidationFailed     = New(CodeValidationFailed, http.StatusBadRequest, "request validation failed")
+	ErrInvalidAmount        = New(CodeInvalidAmount, http.StatusBadRequest, "amount must be positive")
+	ErrRequestTooLarge      = New(CodeRequestTooLarge, http.StatusRequestEntityTooLarge, "request body too large")
+	ErrUnauthorized         = New(CodeUnauthorized, http.StatusUnauthorized, "authentication required")
+	ErrInvalidCredentials   = New(CodeInvalidCredentials, http.StatusUnauthorized, "invalid credentials")
+	ErrForbidden            = New(CodeForbidden, http.StatusForbidden, "forbidden")
+	ErrNotFound             = New(CodeNotFound, http.StatusNotFound, "not found")
+	ErrUserNotFound         = New(CodeUserNotFound, http.StatusNotFound, "user not found")
+	ErrMethodNotAllowed     = New(CodeMethodNotAllowed, http.StatusMethodNotAllowed, "method not allowed")
+	ErrEmailTaken           = New(CodeEmailTaken, http.StatusConflict, "email already registered")
+	ErrConflict             = New(CodeConflict, http.StatusConflict, "conflict")
+	ErrInsufficientFunds    = New(CodeInsufficientFunds, http.StatusUnprocessableEntity, "insufficient balance")
+	ErrIdempotencyKeyReused = New(CodeIdempotencyKeyReused, http.StatusUnprocessableEntity, "idempotency key used for another request")
+	ErrRequestInProgress    = New(CodeRequestInProgress, http.StatusConflict, "a request with this idempotency key is in progress")
+	ErrRateLimited          = New(CodeRateLimited, http.StatusTooManyRequests, "too many requests")
+	ErrLoginLocked          = New(CodeLoginLocked, http.StatusTooManyRequests, "too many failed logins")
+	ErrInternal             = New(CodeInternal, http.StatusInternalServerError, "internal server error")
+	ErrUnavailable          = New(CodeUnavailable, http.StatusServiceUnavailable, "service unavailable")
+)
+
+// Error is an error a client can act on. Fields lists the invalid fields of
+// a request that failed validation.
+type Error struct {
+	Code    Code
+	Status  int
+	Message string
+	Fields  []FieldError
+	cause   error
+}
+
+// FieldError is one invalid field of a request. Field is the JSON path of
+// the field, such as transactions[2].amount.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func New(code Code, status int, message string) *Error {
+	return &Error{Code: code, Status: status, Message: message}
+}
+
+// Error includes the invalid fields and the cause, for logs; clients only
+// see Message and Fields.
+func (e *Error) Error() string {
+	msg := e.Message
+	for i, f := range e.Fields {
+		sep := "; "
+		if i == 0 {
+			sep = ": "
+		}
+		msg += sep + f.Field + " " + f.Message
+	}
+	if e.cause != nil {
+		msg += ": " + e.cause.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error { return e.cause }
+
+// Is matches errors of the same code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithMessage returns an error of the same code with another message.
+func (e *Error) WithMessage(format string, args ...interface{}) *Error {
+	c := *e
+	c.Message = fmt.Sprintf(format, args...)
+	return &c
+}
+
+// WithFields returns the error listing fields as invalid.
+func (e *Error) WithFields(fields []FieldError) *Error {
+	c := *e
+	c.Fields = fields
+	return &c
+}
+
+// Wrap returns the error with cause attached, for the logs.
+func (e *Error) Wrap(cause error) *Error {
+	c := *e
+	c.cause = cause
+	return &c
+}
+
+// From returns the *Error in err's chain. Any other error is an internal
+// one, whose message is not shown to clients.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return ErrInternal.Wrap(err)
+}
@@ -1,9 +1,8 @@
 package auth
 
 import (
+	"Ledger/config"
 	"fmt"
-	"os"
-	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -16,8 +15,9 @@ type JWTService interface {
 }
 
 type jwtService struct {
-	secretKey string
-	issuer    string
+	secretKey  string
+	issuer     string
+	expiration time.Duration
 }
 
 type JWTClaim struct {
@@ -27,21 +27,21 @@ type JWTClaim struct {
 	jwt.RegisteredClaims
 }
 
-func NewJWTService() JWTService {
+func NewJWTService(cfg config.JWTConfig) JWTService {
 	return &jwtService{
-		secretKey: os.Getenv("JWT_SECRET_KEY"),
-		issuer:    "ledger.app",
+		secretKey:  cfg.SecretKey,
+		issuer:     cfg.Issuer,
+		expiration: cfg.Expiration(),
 	}
 }
 
 func (j *jwtService) GenerateToken(userID uint, email string, isAdmin bool) (string, error) {
-	expirationHours, _ := strconv.Atoi(os.Getenv("JWT_EXPIRATION_HOURS"))
 	claims := &JWTClaim{
 		UserID:  userID,
 		Email:   email,
 		IsAdmin: isAdmin,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * time.Duration(expirationHours))),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.expiration)),
 			Issuer:    j.issuer,
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
@@ -0,0 +1,41 @@
+// Package buildinfo describes the running binary.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Version is the release of the binary, set at build time with
+// -ldflags "-X Ledger/pkg/buildinfo.Version=v1.2.3".
+var Version = "dev"
+
+// Info is what is known about the build of the running binary.
+type Info struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// Read returns Version with the VCS details the Go toolchain stamped into
+// the binary, which go run and test binaries lack.
+func Read() Info {
+	info := Info{Version: Version, GoVersion: runtime.Version()}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, s := range build.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.time":
+			info.Time = s.Value
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
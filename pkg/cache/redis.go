@@ -1,6 +1,8 @@
 package cache
 
 import (
+    "Ledger/config"
+    "Ledger/pkg/metrics"
     "context"
     "fmt"
     "github.com/redis/go-redis/v9"
@@ -10,40 +12,67 @@ import (
 
 type RedisCache struct {
     client *redis.Client
+    ttl    time.Duration
 }
 
-func NewRedisCache(addr string, password string, db int) *RedisCache {
+// NewRedisCache connects lazily, so the server starts while Redis is down.
+// Every command gives up after cfg.Timeout, and callers then read from the
+// database instead.
+func NewRedisCache(cfg config.RedisConfig) *RedisCache {
     client := redis.NewClient(&redis.Options{
-        Addr:     addr,
-        Password: password,
-        DB:       db,
+        Addr:         cfg.Addr(),
+        Password:     cfg.Password,
+        DB:           cfg.DB,
+        DialTimeout:  cfg.Timeout,
+        ReadTimeout:  cfg.Timeout,
+        WriteTimeout: cfg.Timeout,
     })
+    client.AddHook(tracingHook{})
 
     return &RedisCache{
         client: client,
+        ttl:    cfg.TTL,
     }
 }
 
+// Client returns the connection pool of the cache, for the rate limiter to
+// share.
+func (c *RedisCache) Client() *redis.Client {
+    return c.client
+}
+
+// Ping checks that Redis answers, for the readiness check.
+func (c *RedisCache) Ping(ctx context.Context) error {
+    return c.client.Ping(ctx).Err()
+}
+
 func (c *RedisCache) GetUserCredit(ctx context.Context, userID uint) (float64, error) {
     key := fmt.Sprintf("user_credit:%d", userID)
     val, err := c.client.Get(ctx, key).Result()
     if err == redis.Nil {
+        metrics.ObserveCache("get", metrics.CacheMiss, 1)
         return 0, fmt.Errorf("cache miss")
     } else if err != nil {
+        metrics.ObserveCache("get", metrics.CacheError, 1)
         return 0, err
     }
 
+    metrics.ObserveCache("get", metrics.CacheHit, 1)
     return strconv.ParseFloat(val, 64)
 }
 
 func (c *RedisCache) SetUserCredit(ctx context.Context, userID uint, credit float64) error {
     key := fmt.Sprintf("user_credit:%d", userID)
-    return c.client.Set(ctx, key, credit, 30*time.Minute).Err()
+    err := c.client.Set(ctx, key, credit, c.ttl).Err()
+    observeWrite("set", err, 1)
+    return err
 }
 
 func (c *RedisCache) InvalidateUserCredit(ctx context.Context, userID uint) error {
     key := fmt.Sprintf("user_credit:%d", userID)
-    return c.client.Del(ctx, key).Err()
+    err := c.client.Del(ctx, key).Err()
+    observeWrite("invalidate", err, 1)
+    return err
 }
 
 func (c *RedisCache) GetMultipleUserCredits(ctx context.Context, userIDs []uint) (map[uint]float64, error) {
@@ -57,6 +86,7 @@ func (c *RedisCache) GetMultipleUserCredits(ctx context.Context, userIDs []uint)
 
     _, err := pipe.Exec(ctx)
     if err != nil && err != redis.Nil {
+        metrics.ObserveCache("get", metrics.CacheError, len(cmds))
         return nil, err
     }
 
@@ -70,6 +100,8 @@ func (c *RedisCache) GetMultipleUserCredits(ctx context.Context, userIDs []uint)
         }
     }
 
+    metrics.ObserveCache("get", metrics.CacheHit, len(results))
+    metrics.ObserveCache("get", metrics.CacheMiss, len(cmds)-len(results))
     return results, nil
 }
 
@@ -78,10 +110,11 @@ func (c *RedisCache) SetMultipleUserCredits(ctx context.Context, credits map[uin
 
     for userID, credit := range credits {
         key := fmt.Sprintf("user_credit:%d", userID)
-        pipe.Set(ctx, key, credit, 30*time.Minute)
+        pipe.Set(ctx, key, credit, c.ttl)
     }
 
     _, err := pipe.Exec(ctx)
+    observeWrite("set", err, len(credits))
     return err
 }
 
@@ -94,5 +127,16 @@ func (c *RedisCache) InvalidateMultipleUserCredits(ctx context.Context, userIDs
     }
 
     _, err := pipe.Exec(ctx)
+    observeWrite("invalidate", err, len(userIDs))
     return err
-} 
\ No newline at end of file
+}
+
+// observeWrite records n cache writes of operation that failed with err,
+// if not nil.
+func observeWrite(operation string, err error, n int) {
+    if err != nil {
+        metrics.ObserveCache(operation, metrics.CacheError, n)
+        return
+    }
+    metrics.ObserveCache(operation, metrics.CacheOK, n)
+}
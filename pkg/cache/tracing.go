@@ -0,0 +1,73 @@
+package cache
+
+import (
+	"Ledger/pkg/tracing"
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("redis")
+
+// tracingHook traces every Redis command, and every pipeline as one span,
+// as a child of the span in the command's context. Keys are recorded but
+// not values.
+type tracingHook struct{}
+
+func (tracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (tracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := tracer.Start(ctx, "redis."+cmd.Name(),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemRedis, semconv.DBOperationName(cmd.Name())),
+		)
+		if key := commandKey(cmd); key != "" {
+			span.SetAttributes(attribute.String("db.redis.key", key))
+		}
+		err := next(ctx, cmd)
+		tracing.End(span, commandError(err))
+		return err
+	}
+}
+
+func (tracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := tracer.Start(ctx, "redis.pipeline",
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				semconv.DBSystemRedis,
+				semconv.DBOperationName("pipeline"),
+				attribute.Int("db.redis.commands", len(cmds)),
+			),
+		)
+		err := next(ctx, cmds)
+		tracing.End(span, commandError(err))
+		return err
+	}
+}
+
+// commandKey returns the key cmd works on, the argument after its name.
+func commandKey(cmd redis.Cmder) string {
+	args := cmd.Args()
+	if len(args) < 2 {
+		return ""
+	}
+	key, _ := args[1].(string)
+	return key
+}
+
+// commandError drops redis.Nil, which is a cache miss rather than a
+// failure.
+func commandError(err error) error {
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	return err
+}
@@ -1,35 +1,86 @@
 package db
 
 import (
+	"Ledger/config"
+	"database/sql"
 	"fmt"
-	"log"
-	"os"
 
-	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
-func ConnectDB() *gorm.DB {
-	err := godotenv.Load()
+func ConnectDB(cfg config.DBConfig) (*gorm.DB, error) {
+	if cfg.Driver != "mysql" {
+		return nil, fmt.Errorf("unsupported database driver for the server: %s", cfg.Driver)
+	}
 
+	db, err := gorm.Open(mysql.Open(mysqlDSN(cfg)), &gorm.Config{Logger: gormLogger{}})
 	if err != nil {
-		log.Fatalf("Error loading .env file")
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if err := db.Use(instrumentation{}); err != nil {
+		return nil, err
 	}
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"),
-		os.Getenv("DB_HOST"),
-		os.Getenv("DB_PORT"),
-		os.Getenv("DB_NAME"),
-	)
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	configurePool(sqlDB, cfg)
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	return db, nil
+}
+
+// OpenSQL opens a plain database/sql handle for either supported driver.
+func OpenSQL(cfg config.DBConfig) (*sql.DB, error) {
+	var dsn string
+	switch cfg.Driver {
+	case "mysql":
+		dsn = mysqlDSN(cfg)
+	case "postgres":
+		dsn = postgresDSN(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", cfg.Driver)
+	}
 
+	sqlDB, err := sql.Open(cfg.Driver, dsn)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	configurePool(sqlDB, cfg)
+
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
 
-	return db
+	return sqlDB, nil
+}
+
+func mysqlDSN(cfg config.DBConfig) string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		cfg.User,
+		cfg.Password,
+		cfg.Host,
+		cfg.PortOrDefault(),
+		cfg.Name,
+	)
+}
+
+func postgresDSN(cfg config.DBConfig) string {
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		cfg.Host,
+		cfg.PortOrDefault(),
+		cfg.User,
+		cfg.Password,
+		cfg.Name,
+		cfg.SSLMode,
+	)
+}
+
+func configurePool(sqlDB *sql.DB, cfg config.DBConfig) {
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 }
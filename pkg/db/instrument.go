@@ -0,0 +1,93 @@
+package db
+
+import (
+	"Ledger/pkg/metrics"
+	"Ledger/pkg/tracing"
+	"errors"
+	"time"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// Keys under which a statement carries its start and span.
+const (
+	startedKey = "ledger:started"
+	spanKey    = "ledger:span"
+)
+
+var tracer = tracing.Tracer("gorm")
+
+// instrumentation is a GORM plugin that records how long every statement
+// takes, by operation, and traces it as a child of the span in the
+// statement's context. Spans carry the SQL without its values.
+type instrumentation struct{}
+
+func (instrumentation) Name() string {
+	return "ledger:instrumentation"
+}
+
+func (instrumentation) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	registrations := []error{
+		cb.Create().Before("gorm:create").Register("ledger:before_create", started("create")),
+		cb.Create().After("gorm:create").Register("ledger:after_create", finished("create")),
+		cb.Query().Before("gorm:query").Register("ledger:before_query", started("query")),
+		cb.Query().After("gorm:query").Register("ledger:after_query", finished("query")),
+		cb.Update().Before("gorm:update").Register("ledger:before_update", started("update")),
+		cb.Update().After("gorm:update").Register("ledger:after_update", finished("update")),
+		cb.Delete().Before("gorm:delete").Register("ledger:before_delete", started("delete")),
+		cb.Delete().After("gorm:delete").Register("ledger:after_delete", finished("delete")),
+		cb.Row().Before("gorm:row").Register("ledger:before_row", started("row")),
+		cb.Row().After("gorm:row").Register("ledger:after_row", finished("row")),
+		cb.Raw().Before("gorm:raw").Register("ledger:before_raw", started("raw")),
+		cb.Raw().After("gorm:raw").Register("ledger:after_raw", finished("raw")),
+	}
+	for _, err := range registrations {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func started(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		db.InstanceSet(startedKey, time.Now())
+		if ctx := db.Statement.Context; ctx != nil {
+			_, span := tracer.Start(ctx, "gorm."+operation,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(
+					semconv.DBSystemMySQL,
+					semconv.DBOperationName(operation),
+					semconv.DBCollectionName(db.Statement.Table),
+				),
+			)
+			db.InstanceSet(spanKey, span)
+		}
+	}
+}
+
+func finished(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if v, ok := db.InstanceGet(startedKey); ok {
+			if start, ok := v.(time.Time); ok {
+				metrics.ObserveQuery(operation, time.Since(start))
+			}
+		}
+		if v, ok := db.InstanceGet(spanKey); ok {
+			if span, ok := v.(trace.Span); ok {
+				span.SetAttributes(
+					semconv.DBQueryText(db.Statement.SQL.String()),
+					semconv.DBCollectionName(db.Statement.Table),
+				)
+				err := db.Error
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					err = nil
+				}
+				tracing.End(span, err)
+			}
+		}
+	}
+}
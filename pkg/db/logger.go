@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// slowQuery is how long a query may take before it is logged as slow.
+const slowQuery = 200 * time.Millisecond
+
+// gormLogger sends GORM's logs to the default slog logger instead of
+// stdout. Statements are logged at debug level only, since their values
+// include personal data and password hashes.
+type gormLogger struct{}
+
+func (gormLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return gormLogger{}
+}
+
+func (gormLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	slog.InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (gormLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	slog.WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (gormLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	slog.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (gormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		_, rows := fc()
+		slog.ErrorContext(ctx, "Query failed", "duration", elapsed, "rows", rows, "error", err)
+	case elapsed > slowQuery:
+		_, rows := fc()
+		slog.WarnContext(ctx, "Slow query", "duration", elapsed, "rows", rows)
+	}
+	if slog.Default().Enabled(ctx, slog.LevelDebug) {
+		query, rows := fc()
+		slog.DebugContext(ctx, "Query", "sql", query, "duration", elapsed, "rows", rows)
+	}
+}
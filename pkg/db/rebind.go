@@ -0,0 +1,26 @@
+package db
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Rebind rewrites the ? placeholders of query into $n when driver is
+// postgres, so database/sql code can be written once for both drivers.
+func Rebind(driver, query string) string {
+	if driver != "postgres" {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,181 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// emfFamilies are the prefixes of the metrics written as EMF; the Go
+// runtime and process metrics would only add to the CloudWatch bill.
+var emfFamilies = []string{"ledger_", "go_sql_"}
+
+// EMF writes the metrics of Registry as CloudWatch embedded metric format
+// log lines, one per series. Counters and histograms are written as the
+// change since the previous Flush, so CloudWatch can sum them across
+// instances; gauges as their current value.
+type EMF struct {
+	w         io.Writer
+	namespace string
+
+	mu   sync.Mutex
+	last map[string]float64
+}
+
+// NewEMF returns an EMF writing to w under the CloudWatch namespace
+// namespace.
+func NewEMF(w io.Writer, namespace string) *EMF {
+	return &EMF{w: w, namespace: namespace, last: make(map[string]float64)}
+}
+
+// emfMetric is a metric definition in the _aws metadata of a line.
+type emfMetric struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit,omitempty"`
+}
+
+// Flush writes a line for every series that changed since the last Flush
+// and for every gauge.
+func (e *EMF) Flush() error {
+	families, err := Registry.Gather()
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	timestamp := time.Now().UnixMilli()
+	var buf bytes.Buffer
+	for _, family := range families {
+		if !emitted(family.GetName()) {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			values, units := e.values(family, m)
+			if len(values) == 0 {
+				continue
+			}
+			line, err := e.line(timestamp, m.GetLabel(), values, units)
+			if err != nil {
+				return err
+			}
+			buf.Write(line)
+			buf.WriteByte('\n')
+		}
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
+	_, err = e.w.Write(buf.Bytes())
+	return err
+}
+
+// values returns what m reports: the current value of a gauge, the
+// changes of a counter or of the count and sum of a histogram.
+func (e *EMF) values(family *dto.MetricFamily, m *dto.Metric) (map[string]float64, map[string]string) {
+	name := family.GetName()
+	unit := unitOf(name)
+	key := seriesKey(name, m.GetLabel())
+
+	values := make(map[string]float64)
+	units := make(map[string]string)
+	switch family.GetType() {
+	case dto.MetricType_GAUGE:
+		values[name], units[name] = m.GetGauge().GetValue(), unit
+	case dto.MetricType_COUNTER:
+		if d := e.delta(key, m.GetCounter().GetValue()); d != 0 {
+			values[name], units[name] = d, unit
+		}
+	case dto.MetricType_HISTOGRAM:
+		h := m.GetHistogram()
+		if d := e.delta(key+"#count", float64(h.GetSampleCount())); d != 0 {
+			values[name+"_count"], units[name+"_count"] = d, "Count"
+			values[name+"_sum"], units[name+"_sum"] = e.delta(key+"#sum", h.GetSampleSum()), unit
+		}
+	}
+	return values, units
+}
+
+// delta returns how much the series key grew since the last call.
+func (e *EMF) delta(key string, value float64) float64 {
+	d := value - e.last[key]
+	e.last[key] = value
+	return d
+}
+
+func (e *EMF) line(timestamp int64, labels []*dto.LabelPair, values map[string]float64, units map[string]string) ([]byte, error) {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	defs := make([]emfMetric, 0, len(names))
+	for _, name := range names {
+		defs = append(defs, emfMetric{Name: name, Unit: units[name]})
+	}
+	dimensions := make([]string, 0, len(labels))
+	for _, l := range labels {
+		dimensions = append(dimensions, l.GetName())
+	}
+
+	record := map[string]interface{}{
+		"_aws": map[string]interface{}{
+			"Timestamp": timestamp,
+			"CloudWatchMetrics": []map[string]interface{}{{
+				"Namespace":  e.namespace,
+				"Dimensions": [][]string{dimensions},
+				"Metrics":    defs,
+			}},
+		},
+	}
+	for _, l := range labels {
+		record[l.GetName()] = l.GetValue()
+	}
+	for name, v := range values {
+		record[name] = v
+	}
+	return json.Marshal(record)
+}
+
+func emitted(name string) bool {
+	for _, prefix := range emfFamilies {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// unitOf derives the CloudWatch unit from the Prometheus naming
+// conventions.
+func unitOf(name string) string {
+	switch {
+	case strings.HasSuffix(name, "_seconds"), strings.HasSuffix(name, "_seconds_total"):
+		return "Seconds"
+	case strings.HasSuffix(name, "_bytes"):
+		return "Bytes"
+	case strings.Contains(name, "amount"):
+		return "None"
+	}
+	return "Count"
+}
+
+func seriesKey(name string, labels []*dto.LabelPair) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, l := range labels {
+		b.WriteByte('|')
+		b.WriteString(l.GetName())
+		b.WriteByte('=')
+		b.WriteString(l.GetValue())
+	}
+	return b.String()
+}
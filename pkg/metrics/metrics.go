@@ -0,0 +1,176 @@
+// Package metrics holds the Prometheus metrics of the ledger: HTTP
+// requests, transfers, batches, database queries and the connection pool,
+// the credit cache, rate limiting and login lockouts; other packages, such
+// as reconcile, register theirs in Registry. The server serves them at /metrics; the lambda,
+// which nothing can scrape, writes them as CloudWatch embedded metric
+// format log lines instead.
+package metrics
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Outcomes of a transfer.
+const (
+	OutcomeSuccess             = "success"
+	OutcomeInsufficientBalance = "insufficient_balance"
+	OutcomeNotFound            = "not_found"
+	OutcomeError               = "error"
+)
+
+// Results of a cache operation. Only reads hit or miss.
+const (
+	CacheHit   = "hit"
+	CacheMiss  = "miss"
+	CacheOK    = "ok"
+	CacheError = "error"
+)
+
+// UnmatchedRoute labels requests that matched no route, so that scanners
+// probing random paths cannot blow up the number of series.
+const UnmatchedRoute = "unmatched"
+
+// Registry holds every metric of the ledger along with the Go runtime and
+// process metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ledger_http_requests_total",
+		Help: "HTTP requests by method, route template and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ledger_http_request_duration_seconds",
+		Help:    "Time taken to answer HTTP requests by method, route template and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	httpInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ledger_http_requests_in_flight",
+		Help: "HTTP requests being answered.",
+	})
+
+	transfers = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ledger_transfers_total",
+		Help: "Credit transfers by outcome.",
+	}, []string{"outcome"})
+
+	transferAmount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ledger_transfer_amount_total",
+		Help: "Credit moved, or attempted to be moved, by transfers by outcome.",
+	}, []string{"outcome"})
+
+	batchSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "ledger_batch_size",
+		Help:    "Entries in batch credit updates.",
+		Buckets: prometheus.ExponentialBuckets(1, 4, 6),
+	})
+
+	queryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ledger_db_query_duration_seconds",
+		Help:    "Time taken by GORM statements by operation.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation"})
+
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ledger_cache_requests_total",
+		Help: "Credit cache operations by operation and result.",
+	}, []string{"operation", "result"})
+
+	rateLimited = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "ledger_rate_limited_total",
+		Help: "Requests refused by a rate limit, by policy.",
+	}, []string{"policy"})
+
+	lockouts = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ledger_login_lockouts_total",
+		Help: "Failed logins that locked an account or extended its lockout.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, httpInFlight,
+		transfers, transferAmount, batchSize,
+		queryDuration, cacheRequests,
+		rateLimited, lockouts,
+	)
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// RegisterDB adds the connection pool statistics of db, as reported by
+// sql.DB.Stats, under the database name name. Registering the same name
+// again is a no-op.
+func RegisterDB(db *sql.DB, name string) {
+	err := Registry.Register(collectors.NewDBStatsCollector(db, name))
+	var registered prometheus.AlreadyRegisteredError
+	if err != nil && !errors.As(err, &registered) {
+		panic(err)
+	}
+}
+
+// RequestStarted counts a request in flight until the returned function is
+// called.
+func RequestStarted() (done func()) {
+	httpInFlight.Inc()
+	return httpInFlight.Dec
+}
+
+// ObserveRequest records an answered HTTP request.
+func ObserveRequest(method, route string, status int, elapsed time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route, code).Observe(elapsed.Seconds())
+}
+
+// ObserveTransfer records a transfer of amount that ended with outcome.
+func ObserveTransfer(outcome string, amount float64) {
+	transfers.WithLabelValues(outcome).Inc()
+	if amount > 0 {
+		transferAmount.WithLabelValues(outcome).Add(amount)
+	}
+}
+
+// ObserveBatch records a batch credit update of size entries.
+func ObserveBatch(size int) {
+	batchSize.Observe(float64(size))
+}
+
+// ObserveQuery records a database statement of operation, such as query
+// or update.
+func ObserveQuery(operation string, elapsed time.Duration) {
+	queryDuration.WithLabelValues(operation).Observe(elapsed.Seconds())
+}
+
+// ObserveCache records n credit cache lookups or writes of operation that
+// ended with result.
+func ObserveCache(operation, result string, n int) {
+	if n > 0 {
+		cacheRequests.WithLabelValues(operation, result).Add(float64(n))
+	}
+}
+
+// ObserveRateLimited records a request refused by the rate limit policy.
+func ObserveRateLimited(policy string) {
+	rateLimited.WithLabelValues(policy).Inc()
+}
+
+// ObserveLockout records a failed login that locked an account.
+func ObserveLockout() {
+	lockouts.Inc()
+}
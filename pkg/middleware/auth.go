@@ -1,9 +1,10 @@
 package middleware
 
 import (
+	"Ledger/pkg/apperr"
 	"Ledger/pkg/auth"
 	"Ledger/pkg/response"
-	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 )
@@ -27,27 +28,23 @@ func (m *authMiddleware) Authenticate(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			response.WriteError(w, http.StatusUnauthorized, "Authorization header is required")
+			response.Error(w, r, apperr.ErrUnauthorized.WithMessage("Authorization header is required"))
 			return
 		}
 
-		fmt.Printf("Auth header: %s\n", authHeader)
-
 		bearerToken := strings.Split(authHeader, " ")
 		if len(bearerToken) != 2 || bearerToken[0] != "Bearer" {
-			response.WriteError(w, http.StatusUnauthorized, "Invalid token format. Must be 'Bearer <token>'")
+			response.Error(w, r, apperr.ErrUnauthorized.WithMessage("Invalid token format. Must be 'Bearer <token>'"))
 			return
 		}
 
-		fmt.Printf("Token: %s\n", bearerToken[1])
-
 		claims, err := m.jwtService.ValidateToken(bearerToken[1])
 		if err != nil {
-			response.WriteError(w, http.StatusUnauthorized, fmt.Sprintf("Invalid token: %v", err))
+			slog.InfoContext(r.Context(), "Token rejected", "error", err)
+			response.Error(w, r, apperr.ErrUnauthorized.WithMessage("Invalid token: %v", err))
 			return
 		}
-
-		fmt.Printf("Claims: %+v\n", claims)
+		slog.DebugContext(r.Context(), "Request authenticated", "user_id", claims.UserID, "admin", claims.IsAdmin)
 
 		r = r.WithContext(SetUserInContext(r.Context(), claims))
 		next.ServeHTTP(w, r)
@@ -58,7 +55,7 @@ func (m *authMiddleware) AdminOnly(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		claims := GetUserFromContext(r.Context())
 		if claims == nil || !m.jwtService.IsAdmin(claims) {
-			response.WriteError(w, http.StatusForbidden, "Admin privileges required")
+			response.Error(w, r, apperr.ErrForbidden.WithMessage("Admin privileges required"))
 			return
 		}
 		next.ServeHTTP(w, r)
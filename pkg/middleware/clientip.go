@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// ClientIP returns the address of the client behind r. Behind a proxy,
+// which connects on behalf of every client, it is the address the proxy
+// appended to X-Forwarded-For; the entries before it are the client's to
+// forge. Without trustForwardedFor it is the address of the connection,
+// which the lambda sets to the bare source IP of the event.
+func ClientIP(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			hops := strings.Split(forwarded[len(forwarded)-1], ",")
+			if addr := strings.TrimSpace(hops[len(hops)-1]); addr != "" {
+				return addr
+			}
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// ClientAddress resolves the address of the client once per request, by
+// the rule of ClientIP, for handlers that record it.
+func ClientAddress(trustForwardedFor bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), clientIPKey{}, ClientIP(r, trustForwardedFor))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ClientIPFromContext returns the address ClientAddress resolved, or the
+// address of the connection when it did not run.
+func ClientIPFromContext(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return ClientIP(r, false)
+}
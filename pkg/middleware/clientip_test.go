@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		remote    string
+		forwarded []string
+		trust     bool
+		want      string
+	}{
+		{"connection", "10.0.0.1:4321", nil, false, "10.0.0.1"},
+		{"bare address", "10.0.0.1", nil, false, "10.0.0.1"},
+		{"untrusted header", "10.0.0.1:4321", []string{"203.0.113.9"}, false, "10.0.0.1"},
+		{"proxy hop", "10.0.0.1:4321", []string{"6.6.6.6, 203.0.113.9"}, true, "203.0.113.9"},
+		{"last header", "10.0.0.1:4321", []string{"6.6.6.6", "203.0.113.9"}, true, "203.0.113.9"},
+		{"empty hop", "10.0.0.1:4321", []string{"6.6.6.6, "}, true, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if got := ClientIP(r, tt.trust); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
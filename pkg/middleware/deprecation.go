@@ -0,0 +1,13 @@
+package middleware
+
+import "net/http"
+
+// Deprecated marks the responses of a route kept for old clients with a
+// Deprecation header and a Link to the route that replaces it.
+func Deprecated(successor string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		next(w, r)
+	}
+}
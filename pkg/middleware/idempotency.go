@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/response"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// IdempotencyKeyHeader carries the key a client picked for a request it
+	// may retry. Retries send the same key.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// ReplayedHeader marks a response replayed from an earlier request.
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// StoredResponse is the response kept for the retries of a request.
+type StoredResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// IdempotencyStore runs each key once. Begin claims key for the request
+// with fingerprint and returns nil, or returns the response of the request
+// that claimed it before. It fails with apperr.ErrRequestInProgress while
+// that request runs and with apperr.ErrIdempotencyKeyReused when its
+// fingerprint differs. Finish stores the response of a claimed key and
+// Release gives the key up, so a retry runs the request again.
+type IdempotencyStore interface {
+	Begin(ctx context.Context, key, fingerprint string) (*StoredResponse, error)
+	Finish(ctx context.Context, key string, resp StoredResponse) error
+	Release(ctx context.Context, key string) error
+}
+
+// IdempotencyMiddleware lets clients retry mutations safely.
+type IdempotencyMiddleware interface {
+	Idempotent(next http.HandlerFunc) http.HandlerFunc
+}
+
+type idempotencyMiddleware struct {
+	store   IdempotencyStore
+	maxBody int64
+}
+
+func NewIdempotencyMiddleware(store IdempotencyStore, maxBody int64) IdempotencyMiddleware {
+	return &idempotencyMiddleware{store: store, maxBody: maxBody}
+}
+
+// Idempotent runs a request with an Idempotency-Key once per key and
+// caller; retries get the response of the first run. Requests without a
+// key run as usual. Keys are scoped to the authenticated user, so it must
+// run after Authenticate on authenticated routes. Server errors and rate
+// limited responses are not kept, as running the request again may succeed.
+func (m *idempotencyMiddleware) Idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !ValidRequestID(key) {
+			response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("%s must be 1 to 128 letters, digits or ._:-", IdempotencyKeyHeader))
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, m.maxBody))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				response.Error(w, r, apperr.ErrRequestTooLarge.WithMessage("Request body exceeds %d bytes", tooLarge.Limit))
+				return
+			}
+			response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid request body"))
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		scope := "anonymous"
+		if claims := GetUserFromContext(r.Context()); claims != nil {
+			scope = "user:" + strconv.FormatUint(uint64(claims.UserID), 10)
+		}
+		key = digest(scope, key)
+		stored, err := m.store.Begin(r.Context(), key, digest(r.Method, r.URL.Path, string(body)))
+		if err != nil {
+			response.Error(w, r, err)
+			return
+		}
+		if stored != nil {
+			if stored.ContentType != "" {
+				w.Header().Set("Content-Type", stored.ContentType)
+			}
+			w.Header().Set(ReplayedHeader, "true")
+			w.WriteHeader(stored.Status)
+			w.Write(stored.Body)
+			return
+		}
+
+		// The outcome is kept even when the client is gone, as it is the
+		// one most likely to retry.
+		ctx := context.WithoutCancel(r.Context())
+		rec := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+		finished := false
+		defer func() {
+			if !finished {
+				m.release(ctx, key)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+
+		if rec.status >= http.StatusInternalServerError || rec.status == http.StatusTooManyRequests {
+			return
+		}
+		finished = true
+		resp := StoredResponse{Status: rec.status, ContentType: w.Header().Get("Content-Type"), Body: rec.body.Bytes()}
+		if err := m.store.Finish(ctx, key, resp); err != nil {
+			slog.WarnContext(ctx, "Idempotent response not stored", "error", err)
+			m.release(ctx, key)
+		}
+	}
+}
+
+func (m *idempotencyMiddleware) release(ctx context.Context, key string) {
+	if err := m.store.Release(ctx, key); err != nil {
+		slog.WarnContext(ctx, "Idempotency key not released", "error", err)
+	}
+}
+
+// digest hashes parts, separated so that they cannot run into each other.
+func digest(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		io.WriteString(h, p)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter copies the status and body of a response.
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.body.Write(b[:n])
+	return n, err
+}
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"Ledger/pkg/metrics"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// knownMethods keeps the method label bounded when requests that match no
+// route use made-up methods.
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// Metrics counts requests in flight and records every answered request by
+// method, route template (such as /accounts/{id}/balance) and status.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		done := metrics.RequestStarted()
+		defer done()
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+
+		method := r.Method
+		if !knownMethods[method] {
+			method = "OTHER"
+		}
+		metrics.ObserveRequest(method, routeTemplate(r), rec.status, time.Since(start))
+	})
+}
+
+// routeTemplate returns the template of the route r matched, or
+// metrics.UnmatchedRoute.
+func routeTemplate(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if template, err := current.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return metrics.UnmatchedRoute
+}
@@ -0,0 +1,49 @@
+package migrate
+
+import "fmt"
+
+const (
+	versionTable = "schema_migrations"
+	lockTable    = "schema_migrations_lock"
+)
+
+type dialect struct {
+	name               string
+	createVersionTable string
+	createLockTable    string
+}
+
+func dialectFor(driver string) (dialect, error) {
+	switch driver {
+	case "mysql":
+		return dialect{
+			name: driver,
+			createVersionTable: `CREATE TABLE IF NOT EXISTS ` + versionTable + ` (
+				version BIGINT NOT NULL PRIMARY KEY,
+				name VARCHAR(255) NOT NULL,
+				applied_at DATETIME(6) NOT NULL
+			)`,
+			createLockTable: `CREATE TABLE IF NOT EXISTS ` + lockTable + ` (
+				id INT NOT NULL PRIMARY KEY,
+				owner VARCHAR(255) NOT NULL,
+				locked_at DATETIME(6) NOT NULL
+			)`,
+		}, nil
+	case "postgres":
+		return dialect{
+			name: driver,
+			createVersionTable: `CREATE TABLE IF NOT EXISTS ` + versionTable + ` (
+				version BIGINT NOT NULL PRIMARY KEY,
+				name VARCHAR(255) NOT NULL,
+				applied_at TIMESTAMP NOT NULL
+			)`,
+			createLockTable: `CREATE TABLE IF NOT EXISTS ` + lockTable + ` (
+				id INT NOT NULL PRIMARY KEY,
+				owner VARCHAR(255) NOT NULL,
+				locked_at TIMESTAMP NOT NULL
+			)`,
+		}, nil
+	default:
+		return dialect{}, fmt.Errorf("unsupported migration driver: %s", driver)
+	}
+}
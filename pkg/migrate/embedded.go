@@ -0,0 +1,15 @@
+package migrate
+
+import (
+	"Ledger/migrations"
+	"database/sql"
+)
+
+// ForDriver returns a Migrator over the migrations embedded for driver.
+func ForDriver(db *sql.DB, driver string) (*Migrator, error) {
+	files, err := migrations.For(driver)
+	if err != nil {
+		return nil, err
+	}
+	return New(db, driver, files)
+}
@@ -0,0 +1,104 @@
+package migrate
+
+import (
+	"Ledger/pkg/db"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	lockTimeout       = 2 * time.Minute
+	lockRetryInterval = 500 * time.Millisecond
+	// lockStaleAfter bounds how long a crashed instance can block others.
+	lockStaleAfter = 10 * time.Minute
+	// lockRefreshInterval keeps a held lock well clear of lockStaleAfter,
+	// however long the migrations run.
+	lockRefreshInterval = time.Minute
+)
+
+// errLockLost reports that the lock was cleared as stale while held.
+var errLockLost = errors.New("migration lock lost")
+
+// tableLock serialises migration runs across instances by inserting a single
+// well-known row into the lock table; the primary key makes the insert fail
+// for everyone but the holder. The holder refreshes the row while it runs,
+// so only the lock of an instance that stopped goes stale.
+type tableLock struct {
+	db      *sql.DB
+	dialect dialect
+	owner   string
+}
+
+func newTableLock(db *sql.DB, d dialect) *tableLock {
+	host, _ := os.Hostname()
+	return &tableLock{
+		db:      db,
+		dialect: d,
+		owner:   fmt.Sprintf("%s:%d", host, os.Getpid()),
+	}
+}
+
+func (l *tableLock) acquire(ctx context.Context) error {
+	for {
+		_, err := l.db.ExecContext(ctx, db.Rebind(l.dialect.name, "INSERT INTO "+lockTable+" (id, owner, locked_at) VALUES (1, ?, ?)"),
+			l.owner, time.Now().UTC())
+		if err == nil {
+			return nil
+		}
+
+		// Clear a lock left behind by an instance that died mid-migration.
+		if _, err := l.db.ExecContext(ctx, db.Rebind(l.dialect.name, "DELETE FROM "+lockTable+" WHERE id = 1 AND locked_at < ?"),
+			time.Now().UTC().Add(-lockStaleAfter)); err != nil {
+			return fmt.Errorf("checking migration lock: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("waiting for migration lock: %w", ctx.Err())
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+func (l *tableLock) release(ctx context.Context) error {
+	_, err := l.db.ExecContext(ctx, db.Rebind(l.dialect.name, "DELETE FROM "+lockTable+" WHERE id = 1 AND owner = ?"), l.owner)
+	return err
+}
+
+// refresh renews the lock's timestamp, failing with errLockLost when the
+// row no longer belongs to this instance.
+func (l *tableLock) refresh(ctx context.Context) error {
+	res, err := l.db.ExecContext(ctx, db.Rebind(l.dialect.name, "UPDATE "+lockTable+" SET locked_at = ? WHERE id = 1 AND owner = ?"),
+		time.Now().UTC(), l.owner)
+	if err != nil {
+		return fmt.Errorf("refreshing migration lock: %w", err)
+	}
+	// MySQL counts matched rows as affected only when a value changes; the
+	// microsecond timestamp always does.
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return errLockLost
+	}
+	return nil
+}
+
+// keepAlive refreshes the lock every lockRefreshInterval until ctx is done,
+// and calls lost if the lock can no longer be kept.
+func (l *tableLock) keepAlive(ctx context.Context, lost func(error)) {
+	ticker := time.NewTicker(lockRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.refresh(ctx); err != nil && ctx.Err() == nil {
+				lost(err)
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,481 @@
+package migrate
+
+import (
+	"Ledger/pkg/db"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Migration is one versioned schema change with its up and down scripts.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a known migration has been applied.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	dialect    dialect
+	migrations []Migration
+	lock       *tableLock
+}
+
+// New loads the migrations in files (named <version>_<name>.<up|down>.sql)
+// for the given driver.
+func New(db *sql.DB, driver string, files fs.FS) (*Migrator, error) {
+	d, err := dialectFor(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{
+		db:         db,
+		dialect:    d,
+		migrations: migrations,
+		lock:       newTableLock(db, d),
+	}, nil
+}
+
+func load(files fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, fmt.Errorf("reading migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		version, name, direction, err := parseFileName(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := fs.ReadFile(files, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, name)
+		}
+
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		if m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s has no down script", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+func parseFileName(file string) (int64, string, string, error) {
+	base := strings.TrimSuffix(file, ".sql")
+
+	var direction string
+	switch {
+	case strings.HasSuffix(base, ".up"):
+		direction = "up"
+	case strings.HasSuffix(base, ".down"):
+		direction = "down"
+	default:
+		return 0, "", "", fmt.Errorf("migration %s must end in .up.sql or .down.sql", file)
+	}
+	base = strings.TrimSuffix(base, "."+direction)
+
+	versionPart, name, ok := strings.Cut(base, "_")
+	if !ok || name == "" {
+		return 0, "", "", fmt.Errorf("migration %s must be named <version>_<name>", file)
+	}
+
+	version, err := strconv.ParseInt(versionPart, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("migration %s has an invalid version", file)
+	}
+
+	return version, name, direction, nil
+}
+
+// Migrations returns every known migration in version order.
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Latest returns the highest known migration version.
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Down reverts the most recently applied migrations, one per step.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(ctx context.Context) error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if err := m.revert(ctx, mig); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// To migrates up or down until exactly the migrations up to and including
+// version are applied. Version 0 reverts everything.
+func (m *Migrator) To(ctx context.Context, version int64) error {
+	if version != 0 && !m.known(version) {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+
+	return m.withLock(ctx, func(ctx context.Context) error {
+		applied, err := m.applied(ctx)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; ok && mig.Version > version {
+				if err := m.revert(ctx, mig); err != nil {
+					return err
+				}
+			}
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; !ok && mig.Version <= version {
+				if err := m.apply(ctx, mig); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Status lists every known migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.ensureVersionTable(ctx); err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(m.migrations))
+	for i, mig := range m.migrations {
+		appliedAt, ok := applied[mig.Version]
+		statuses[i] = MigrationStatus{
+			Version:   mig.Version,
+			Name:      mig.Name,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		}
+	}
+	return statuses, nil
+}
+
+// Pending returns the number of known migrations that have not been applied.
+func (m *Migrator) Pending(ctx context.Context) (int, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, s := range statuses {
+		if !s.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+func (m *Migrator) known(version int64) bool {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// withLock runs fn while holding the migration lock. fn's context is
+// cancelled if the lock is lost, so no two instances apply DDL at once.
+func (m *Migrator) withLock(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	if err := m.ensureVersionTable(ctx); err != nil {
+		return err
+	}
+
+	lockCtx, cancel := context.WithTimeout(ctx, lockTimeout)
+	defer cancel()
+	if err := m.lock.acquire(lockCtx); err != nil {
+		return err
+	}
+	defer func() {
+		if releaseErr := m.lock.release(context.Background()); releaseErr != nil {
+			err = errors.Join(err, fmt.Errorf("releasing migration lock: %w", releaseErr))
+		}
+	}()
+
+	runCtx, stop := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.lock.keepAlive(runCtx, stop)
+	}()
+	defer func() {
+		stop(nil)
+		<-done
+	}()
+
+	err = fn(runCtx)
+	if cause := context.Cause(runCtx); err != nil && cause != nil && ctx.Err() == nil {
+		// The lock, not the caller, cancelled fn.
+		err = fmt.Errorf("%w: %w", cause, err)
+	}
+	return err
+}
+
+func (m *Migrator) ensureVersionTable(ctx context.Context) error {
+	for _, stmt := range []string{m.dialect.createVersionTable, m.dialect.createLockTable} {
+		if _, err := m.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("creating migration bookkeeping tables: %w", err)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]time.Time, error) {
+	rows, err := m.db.QueryContext(ctx, "SELECT version, applied_at FROM "+versionTable)
+	if err != nil {
+		return nil, fmt.Errorf("reading applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) apply(ctx context.Context, mig Migration) error {
+	return m.run(ctx, mig, mig.Up, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, db.Rebind(m.dialect.name, "INSERT INTO "+versionTable+" (version, name, applied_at) VALUES (?, ?, ?)"),
+			mig.Version, mig.Name, time.Now().UTC())
+		return err
+	})
+}
+
+func (m *Migrator) revert(ctx context.Context, mig Migration) error {
+	return m.run(ctx, mig, mig.Down, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, db.Rebind(m.dialect.name, "DELETE FROM "+versionTable+" WHERE version = ?"), mig.Version)
+		return err
+	})
+}
+
+// run executes script and the bookkeeping update in one transaction. MySQL
+// commits DDL implicitly, so a failing MySQL migration may be left partially
+// applied; its scripts are written to be re-runnable for that reason, with
+// the DDL MySQL has no IF [NOT] EXISTS for guarded through
+// information_schema.
+func (m *Migrator) run(ctx context.Context, mig Migration, script string, record func(tx *sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range splitStatements(script) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", mig.Version, mig.Name, err)
+		}
+	}
+
+	if err := record(tx); err != nil {
+		return fmt.Errorf("migration %d_%s: recording version: %w", mig.Version, mig.Name, err)
+	}
+
+	return tx.Commit()
+}
+
+// splitStatements breaks a script on semicolons that are not inside quotes,
+// Postgres dollar-quoted bodies, MySQL BEGIN ... END blocks or comments,
+// since the MySQL driver runs one statement per Exec.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+	var quote rune
+	var dollarTag string
+	inLineComment := false
+	blockDepth := 0
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		c := runes[i]
+
+		switch {
+		case dollarTag != "":
+			if strings.HasPrefix(string(runes[i:]), dollarTag) {
+				current.WriteString(dollarTag)
+				i += len([]rune(dollarTag)) - 1
+				dollarTag = ""
+				continue
+			}
+		case inLineComment:
+			if c == '\n' {
+				inLineComment = false
+				current.WriteRune(c)
+			}
+			continue
+		case quote != 0:
+			current.WriteRune(c)
+			if c == quote {
+				quote = 0
+			}
+			continue
+		case c == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			inLineComment = true
+			continue
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case isWordStart(runes, i):
+			word := readWord(runes[i:])
+			switch strings.ToUpper(word) {
+			case "BEGIN":
+				blockDepth++
+			case "END":
+				// END IF, END LOOP and the like close other constructs.
+				if blockDepth > 0 && !closesOtherBlock(runes[i+len([]rune(word)):]) {
+					blockDepth--
+				}
+			}
+			current.WriteString(word)
+			i += len([]rune(word)) - 1
+			continue
+		case c == '$' && dollarQuoteTag(runes[i:]) != "":
+			dollarTag = dollarQuoteTag(runes[i:])
+			current.WriteString(dollarTag)
+			i += len([]rune(dollarTag)) - 1
+			continue
+		case c == ';' && blockDepth == 0:
+			if stmt := strings.TrimSpace(current.String()); stmt != "" {
+				statements = append(statements, stmt)
+			}
+			current.Reset()
+			continue
+		}
+		current.WriteRune(c)
+	}
+
+	if stmt := strings.TrimSpace(current.String()); stmt != "" {
+		statements = append(statements, stmt)
+	}
+	return statements
+}
+
+func isWordStart(runes []rune, i int) bool {
+	return unicode.IsLetter(runes[i]) && (i == 0 || !isWordRune(runes[i-1]))
+}
+
+func isWordRune(c rune) bool {
+	return c == '_' || c == '$' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+func readWord(runes []rune) string {
+	j := 0
+	for j < len(runes) && isWordRune(runes[j]) {
+		j++
+	}
+	return string(runes[:j])
+}
+
+func closesOtherBlock(rest []rune) bool {
+	word := strings.ToUpper(readWord([]rune(strings.TrimLeft(string(rest), " \t\r\n"))))
+	switch word {
+	case "IF", "LOOP", "WHILE", "REPEAT", "CASE":
+		return true
+	}
+	return false
+}
+
+// dollarQuoteTag returns the $tag$ or $$ opening a dollar-quoted string at
+// the start of runes, or "" when there is none.
+func dollarQuoteTag(runes []rune) string {
+	for j := 1; j < len(runes); j++ {
+		switch c := runes[j]; {
+		case c == '$':
+			return string(runes[:j+1])
+		case c == '_' || unicode.IsLetter(c) || (j > 1 && unicode.IsDigit(c)):
+		default:
+			return ""
+		}
+	}
+	return ""
+}
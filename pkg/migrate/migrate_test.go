@@ -0,0 +1,107 @@
+package migrate
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"one per semicolon", "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n", []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"}},
+		{"no trailing semicolon", "SELECT 1;\nSELECT 2", []string{"SELECT 1", "SELECT 2"}},
+		{"empty statements", " ;SELECT 1;;\n\t;", []string{"SELECT 1"}},
+		{"empty script", "\n-- nothing to do\n", nil},
+
+		{"single quotes", "INSERT INTO t VALUES ('a;b'); SELECT 1", []string{"INSERT INTO t VALUES ('a;b')", "SELECT 1"}},
+		{"doubled quotes", "SELECT 'it''s; fine'; SELECT 2", []string{"SELECT 'it''s; fine'", "SELECT 2"}},
+		{"quoted identifiers", "SELECT \"a;b\", `c;d` FROM t; SELECT 1", []string{"SELECT \"a;b\", `c;d` FROM t", "SELECT 1"}},
+		{"keywords in quotes", "SELECT 'BEGIN'; SELECT 'END'", []string{"SELECT 'BEGIN'", "SELECT 'END'"}},
+
+		{"line comments", "-- first; not a statement\nSELECT 1; -- trailing; comment\nSELECT 2", []string{"SELECT 1", "SELECT 2"}},
+		{"quotes in comments", "SELECT 1 -- it's\n; SELECT 2", []string{"SELECT 1", "SELECT 2"}},
+		{"dashes in quotes", "SELECT '--;'; SELECT 2", []string{"SELECT '--;'", "SELECT 2"}},
+
+		{
+			"dollar-quoted body",
+			"CREATE FUNCTION f() RETURNS trigger AS $$\nBEGIN\n  RAISE EXCEPTION 'no; way';\nEND;\n$$ LANGUAGE plpgsql;\nDROP FUNCTION f",
+			[]string{"CREATE FUNCTION f() RETURNS trigger AS $$\nBEGIN\n  RAISE EXCEPTION 'no; way';\nEND;\n$$ LANGUAGE plpgsql", "DROP FUNCTION f"},
+		},
+		{
+			"tagged dollar-quoted body",
+			"DO $body$ BEGIN PERFORM '$$;'; END $body$; SELECT 1",
+			[]string{"DO $body$ BEGIN PERFORM '$$;'; END $body$", "SELECT 1"},
+		},
+		{"dollar in an identifier", "SELECT a$b; SELECT $1", []string{"SELECT a$b", "SELECT $1"}},
+
+		{
+			"trigger block",
+			"CREATE TRIGGER t BEFORE UPDATE ON a FOR EACH ROW\nBEGIN\n  SET NEW.x = 1;\n  SET NEW.y = 2;\nEND;\nSELECT 1",
+			[]string{"CREATE TRIGGER t BEFORE UPDATE ON a FOR EACH ROW\nBEGIN\n  SET NEW.x = 1;\n  SET NEW.y = 2;\nEND", "SELECT 1"},
+		},
+		{
+			"END IF inside a block",
+			"CREATE TRIGGER t BEFORE UPDATE ON a FOR EACH ROW\nBEGIN\n  IF NEW.x < 0 THEN\n    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'no; way';\n  END IF;\n  SET NEW.y = 1;\nEND;\nSELECT 1",
+			[]string{"CREATE TRIGGER t BEFORE UPDATE ON a FOR EACH ROW\nBEGIN\n  IF NEW.x < 0 THEN\n    SIGNAL SQLSTATE '45000' SET MESSAGE_TEXT = 'no; way';\n  END IF;\n  SET NEW.y = 1;\nEND", "SELECT 1"},
+		},
+		{"keywords inside words", "SELECT began_at, backend FROM t; SELECT 1", []string{"SELECT began_at, backend FROM t", "SELECT 1"}},
+
+		{
+			"guarded DDL",
+			"SET @ddl = IF(\n    (SELECT COUNT(*) FROM information_schema.columns WHERE column_name = 'description') = 0,\n    'ALTER TABLE t ADD COLUMN description VARCHAR(255) NOT NULL DEFAULT '''' AFTER amount',\n    'DO 0');\nPREPARE ddl FROM @ddl;\nEXECUTE ddl;\nDEALLOCATE PREPARE ddl;\n",
+			[]string{
+				"SET @ddl = IF(\n    (SELECT COUNT(*) FROM information_schema.columns WHERE column_name = 'description') = 0,\n    'ALTER TABLE t ADD COLUMN description VARCHAR(255) NOT NULL DEFAULT '''' AFTER amount',\n    'DO 0')",
+				"PREPARE ddl FROM @ddl",
+				"EXECUTE ddl",
+				"DEALLOCATE PREPARE ddl",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTablesFromModelsTypes(t *testing.T) {
+	type account struct {
+		ID      uint
+		Owner   string
+		Balance float64 `gorm:"type:DECIMAL(10, 2);default:0"`
+	}
+	tables, err := TablesFromModels(&account{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Table{{
+		Name:    "accounts",
+		Columns: []string{"id", "owner", "balance"},
+		Types:   map[string]string{"balance": "decimal(10,2)"},
+	}}
+	if !reflect.DeepEqual(tables, want) {
+		t.Errorf("got %+v, want %+v", tables, want)
+	}
+}
+
+func TestNormaliseType(t *testing.T) {
+	tests := map[string]string{
+		"decimal":           "decimal",
+		"DECIMAL(10, 2)":    "decimal(10,2)",
+		"numeric( 10 ,2 )":  "decimal(10,2)",
+		"NUMERIC":           "decimal",
+		"double":            "double",
+		"Double  Precision": "double precision",
+		"character varying": "character varying",
+	}
+	for typ, want := range tests {
+		if got := normaliseType(typ); got != want {
+			t.Errorf("normaliseType(%q) = %q, want %q", typ, got, want)
+		}
+	}
+}
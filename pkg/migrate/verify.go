@@ -0,0 +1,132 @@
+package migrate
+
+import (
+	"Ledger/pkg/db"
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm/schema"
+)
+
+// Table is the set of columns a model expects to find in the database.
+type Table struct {
+	Name    string
+	Columns []string
+	// Types maps columns to the type they must have, such as
+	// decimal(10,2); other columns may have any type. Types are the
+	// information_schema data types, with numeric spelled decimal and
+	// decimals carrying their precision and scale.
+	Types map[string]string
+}
+
+// TablesFromModels derives the expected tables from GORM models, honouring
+// their column and type tags and the default naming strategy.
+func TablesFromModels(models ...interface{}) ([]Table, error) {
+	cache := &sync.Map{}
+	tables := make([]Table, 0, len(models))
+	for _, model := range models {
+		s, err := schema.Parse(model, cache, schema.NamingStrategy{})
+		if err != nil {
+			return nil, err
+		}
+		table := Table{Name: s.Table}
+		for _, f := range s.Fields {
+			if f.DBName == "" {
+				continue
+			}
+			table.Columns = append(table.Columns, f.DBName)
+			if typ := f.TagSettings["TYPE"]; typ != "" {
+				if table.Types == nil {
+					table.Types = make(map[string]string)
+				}
+				table.Types[f.DBName] = normaliseType(typ)
+			}
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// Verify checks that every expected table and column exists in the current
+// schema, with the type the table declares for it. Extra columns in the
+// database are allowed.
+func (m *Migrator) Verify(ctx context.Context, tables []Table) error {
+	const columns = "SELECT column_name, data_type, numeric_precision, numeric_scale FROM information_schema.columns"
+	query := columns + " WHERE table_schema = DATABASE() AND table_name = ?"
+	if m.dialect.name == "postgres" {
+		query = columns + " WHERE table_schema = current_schema() AND table_name = ?"
+	}
+	query = db.Rebind(m.dialect.name, query)
+
+	var problems []string
+	for _, table := range tables {
+		rows, err := m.db.QueryContext(ctx, query, table.Name)
+		if err != nil {
+			return fmt.Errorf("reading columns of %s: %w", table.Name, err)
+		}
+
+		existing := make(map[string]string)
+		for rows.Next() {
+			var column, dataType string
+			var precision, scale sql.NullInt64
+			if err := rows.Scan(&column, &dataType, &precision, &scale); err != nil {
+				rows.Close()
+				return err
+			}
+			typ := normaliseType(dataType)
+			if typ == "decimal" && precision.Valid && scale.Valid {
+				typ = fmt.Sprintf("decimal(%d,%d)", precision.Int64, scale.Int64)
+			}
+			existing[strings.ToLower(column)] = typ
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		if len(existing) == 0 {
+			problems = append(problems, fmt.Sprintf("table %s is missing", table.Name))
+			continue
+		}
+
+		var missing, mistyped []string
+		for _, column := range table.Columns {
+			typ, ok := existing[strings.ToLower(column)]
+			switch {
+			case !ok:
+				missing = append(missing, column)
+			case table.Types[column] != "" && typ != normaliseType(table.Types[column]):
+				mistyped = append(mistyped, fmt.Sprintf("%s is %s, not %s", column, typ, normaliseType(table.Types[column])))
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			problems = append(problems, fmt.Sprintf("table %s is missing columns %s", table.Name, strings.Join(missing, ", ")))
+		}
+		if len(mistyped) > 0 {
+			sort.Strings(mistyped)
+			problems = append(problems, fmt.Sprintf("table %s has mistyped columns: %s", table.Name, strings.Join(mistyped, "; ")))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("schema does not match models:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// normaliseType spells a column type the way Verify compares it: lowercased,
+// without spaces around its parameters, and with numeric, which MySQL and
+// Postgres both accept, spelled decimal.
+func normaliseType(typ string) string {
+	typ = strings.ToLower(strings.Join(strings.Fields(typ), " "))
+	typ = strings.NewReplacer(" (", "(", "( ", "(", " ,", ",", ", ", ",", " )", ")").Replace(typ)
+	if rest, ok := strings.CutPrefix(typ, "numeric"); ok {
+		typ = "decimal" + rest
+	}
+	return typ
+}
@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery is how often Memory drops the buckets that have refilled.
+const sweepEvery = time.Minute
+
+// Memory keeps the buckets of one process. Behind a load balancer every
+// instance counts separately, so a client gets up to the limit from each.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+type bucket struct {
+	limit  Limit
+	tokens float64
+	at     time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+func (m *Memory) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Requests)}
+		m.buckets[key] = b
+	} else {
+		b.tokens = refill(limit, b.tokens, now.Sub(b.at))
+	}
+	b.limit, b.at = limit, now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	return result(limit, b.tokens, allowed), nil
+}
+
+// sweep drops the buckets that are full again, which a new bucket would
+// equal, so clients seen once do not pile up.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.swept) < sweepEvery {
+		return
+	}
+	m.swept = now
+	for key, b := range m.buckets {
+		if now.Sub(b.at) >= b.limit.Window {
+			delete(m.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+// Package ratelimit throttles requests with token buckets. A bucket holds up
+// to Limit.Requests tokens and refills them evenly over Limit.Window; every
+// request takes one, and a request finding less than one is refused. The
+// buckets live in Redis, so every instance of the server shares them, or in
+// memory when Redis cannot be reached.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit is the size of a bucket and the time it takes to refill.
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// Result is the state of a bucket after a request took from it.
+type Result struct {
+	Allowed bool
+	// Limit is the size of the bucket.
+	Limit int
+	// Remaining is the number of requests the bucket allows right away.
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until a refused request would be allowed.
+	RetryAfter time.Duration
+}
+
+// Limiter takes a token from the bucket of key.
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit Limit) (Result, error)
+}
+
+// refill returns the tokens in a bucket of limit that held tokens elapsed
+// ago.
+func refill(limit Limit, tokens float64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return tokens
+	}
+	tokens += float64(limit.Requests) * float64(elapsed) / float64(limit.Window)
+	return math.Min(tokens, float64(limit.Requests))
+}
+
+// result describes a bucket of limit left holding tokens.
+func result(limit Limit, tokens float64, allowed bool) Result {
+	perToken := float64(limit.Window) / float64(limit.Requests)
+	r := Result{
+		Allowed:   allowed,
+		Limit:     limit.Requests,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     time.Duration((float64(limit.Requests) - tokens) * perToken),
+	}
+	if !allowed {
+		r.RetryAfter = time.Duration((1 - tokens) * perToken)
+	}
+	return r
+}
@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript takes a token from the bucket hash KEYS[1] of ARGV[1] tokens
+// refilled over ARGV[2] milliseconds and returns whether it got one and the
+// tokens left. The time is Redis's own, so the clocks of the servers sharing
+// the bucket do not matter. The bucket expires once it would be full again.
+var takeScript = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'at')
+local tokens = capacity
+if bucket[1] then
+	local elapsed = math.max(0, now - tonumber(bucket[2]))
+	tokens = math.min(capacity, tonumber(bucket[1]) + elapsed * capacity / window)
+end
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'at', tostring(now))
+redis.call('PEXPIRE', KEYS[1], window)
+return {allowed, tostring(tokens)}
+`)
+
+// fallbackWarnEvery is how often a Redis outage is logged while requests
+// are counted by the fallback.
+const fallbackWarnEvery = time.Minute
+
+// Redis keeps the buckets in Redis and counts requests with fallback while
+// Redis fails.
+type Redis struct {
+	client   redis.Scripter
+	prefix   string
+	fallback Limiter
+	warned   atomic.Int64
+}
+
+// NewRedis stores buckets under keys starting with prefix.
+func NewRedis(client redis.Scripter, prefix string, fallback Limiter) *Redis {
+	return &Redis{client: client, prefix: prefix, fallback: fallback}
+}
+
+func (l *Redis) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	reply, err := takeScript.Run(ctx, l.client, []string{l.prefix + key},
+		limit.Requests, limit.Window.Milliseconds()).Slice()
+	if err == nil {
+		var r Result
+		if r, err = parseReply(limit, reply); err == nil {
+			return r, nil
+		}
+	}
+
+	if now := time.Now().UnixNano(); now-l.warned.Load() >= int64(fallbackWarnEvery) {
+		l.warned.Store(now)
+		slog.WarnContext(ctx, "Rate limits counted in memory, Redis failed", "error", err)
+	}
+	return l.fallback.Allow(ctx, key, limit)
+}
+
+func parseReply(limit Limit, reply []interface{}) (Result, error) {
+	if len(reply) == 2 {
+		allowed, ok := reply[0].(int64)
+		raw, isString := reply[1].(string)
+		if ok && isString {
+			tokens, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return Result{}, err
+			}
+			return result(limit, tokens, allowed == 1), nil
+		}
+	}
+	return Result{}, fmt.Errorf("unexpected rate limit script reply %v", reply)
+}
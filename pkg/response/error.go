@@ -1,16 +1,58 @@
 package response
 
 import (
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/logging"
 	"encoding/json"
+	"log/slog"
 	"net/http"
 )
 
-type ErrorResponse struct {
-	Error string `json:"error"`
+// ContentTypeProblem is the media type of RFC 7807 problem details.
+const ContentTypeProblem = "application/problem+json"
+
+// Problem is the body of every error response, an RFC 7807 problem
+// details object with the error code, the request ID and the invalid
+// fields as extensions.
+type Problem struct {
+	Type      string              `json:"type"`
+	Title     string              `json:"title"`
+	Status    int                 `json:"status"`
+	Detail    string              `json:"detail,omitempty"`
+	Instance  string              `json:"instance,omitempty"`
+	Code      apperr.Code         `json:"code"`
+	RequestID string              `json:"request_id,omitempty"`
+	Errors    []apperr.FieldError `json:"errors,omitempty"`
+}
+
+// TypeURI is the problem type of code.
+func TypeURI(code apperr.Code) string {
+	return "urn:ledger:error:" + string(code)
 }
 
-func WriteError(w http.ResponseWriter, statusCode int, message string) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
-} 
\ No newline at end of file
+// Error answers r with err as problem details. Errors that are not
+// *apperr.Error are answered as internal errors without their message;
+// they and every other server error are logged with their cause. r may be
+// nil for requests that could not be built.
+func Error(w http.ResponseWriter, r *http.Request, err error) {
+	e := apperr.From(err)
+	p := Problem{
+		Type:   TypeURI(e.Code),
+		Title:  http.StatusText(e.Status),
+		Status: e.Status,
+		Detail: e.Message,
+		Code:   e.Code,
+		Errors: e.Fields,
+	}
+	if r != nil {
+		p.Instance = r.URL.Path
+		p.RequestID = logging.RequestID(r.Context())
+		if e.Status >= http.StatusInternalServerError {
+			slog.ErrorContext(r.Context(), "Request failed", "code", e.Code, "error", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", ContentTypeProblem)
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(p)
+}
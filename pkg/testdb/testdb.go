@@ -0,0 +1,54 @@
+// Package testdb opens the scratch databases that tests of the storage run
+// against. Each driver's database is named by an environment variable
+// holding its DSN; tests of a driver without one are skipped, so go test
+// passes without any database.
+package testdb
+
+import (
+	"Ledger/pkg/migrate"
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+)
+
+// Drivers are the storage drivers tests run against.
+var Drivers = []string{"mysql", "postgres"}
+
+// dsnVars name the variables holding the DSN of each driver's database.
+var dsnVars = map[string]string{
+	"mysql":    "LEDGER_TEST_MYSQL_DSN",
+	"postgres": "LEDGER_TEST_POSTGRES_DSN",
+}
+
+// Open returns the database of driver with every migration applied, and
+// skips t when its DSN is not set. The MySQL DSN must set parseTime=true.
+// Tests only create rows of their own, so the database may be shared.
+func Open(t testing.TB, driver string) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv(dsnVars[driver])
+	if dsn == "" {
+		t.Skipf("%s is not set", dsnVars[driver])
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		t.Fatalf("open %s: %v", driver, err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Fatalf("connect to %s: %v", driver, err)
+	}
+
+	migrator, err := migrate.ForDriver(db, driver)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("apply migrations: %v", err)
+	}
+	return db
+}
@@ -0,0 +1,210 @@
+// Package audit records who changed what. Events are only ever inserted;
+// the audit_events table rejects updates and deletes.
+package audit
+
+import (
+	"Ledger/pkg/db"
+	"Ledger/pkg/migrate"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Tables are the tables events are recorded in, for migrate verify.
+var Tables = []migrate.Table{
+	{Name: "audit_events", Columns: []string{"id", "occurred_at", "actor_id", "actor", "action", "target", "request_id", "ip", "before_value", "after_value", "reason"}},
+}
+
+// Event is one audited action. Before and After hold the affected state as
+// JSON (or any text) and may be empty.
+type Event struct {
+	ID         int64     `json:"id"`
+	OccurredAt time.Time `json:"occurred_at"`
+	ActorID    *uint     `json:"actor_id,omitempty"`
+	Actor      string    `json:"actor"`
+	Action     string    `json:"action"`
+	Target     string    `json:"target"`
+	RequestID  string    `json:"request_id,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	Before     string    `json:"before,omitempty"`
+	After      string    `json:"after,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+}
+
+// State renders v for Before or After: strings are kept as they are and
+// anything else is encoded as JSON. nil gives an empty state.
+func State(v interface{}) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// Recorder stores audit events.
+type Recorder interface {
+	Record(ctx context.Context, e Event) error
+}
+
+// Execer runs a statement. *sql.DB, *sql.Tx and GORM's connection pool all
+// satisfy it, so an event can be inserted in the transaction of the change
+// it describes.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Insert writes e through exec.
+func Insert(ctx context.Context, exec Execer, driver string, e Event) error {
+	if e.OccurredAt.IsZero() {
+		e.OccurredAt = time.Now().UTC()
+	}
+
+	_, err := exec.ExecContext(ctx, db.Rebind(driver, `INSERT INTO audit_events
+		(occurred_at, actor_id, actor, action, target, request_id, ip, before_value, after_value, reason)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		e.OccurredAt, e.ActorID, e.Actor, e.Action, e.Target, e.RequestID, e.IP,
+		nullable(e.Before), nullable(e.After), e.Reason)
+	return err
+}
+
+// Filter selects events. Zero fields match everything.
+type Filter struct {
+	ActorID   *uint
+	Actor     string
+	Action    string
+	Target    string
+	RequestID string
+	From      time.Time
+	To        time.Time
+	// Newest lists the most recent events first instead of in the order
+	// they were recorded.
+	Newest bool
+	Limit  int
+	Offset int
+}
+
+// Querier reads recorded events.
+type Querier interface {
+	Query(ctx context.Context, f Filter) ([]Event, error)
+}
+
+// SQLStore writes events to the audit_events table and reads them back.
+type SQLStore struct {
+	db     *sql.DB
+	driver string
+}
+
+func NewSQLStore(sqlDB *sql.DB, driver string) *SQLStore {
+	return &SQLStore{db: sqlDB, driver: driver}
+}
+
+func (s *SQLStore) Record(ctx context.Context, e Event) error {
+	return Insert(ctx, s.db, s.driver, e)
+}
+
+// Query returns the events matching f.
+func (s *SQLStore) Query(ctx context.Context, f Filter) ([]Event, error) {
+	events := []Event{}
+	err := s.Each(ctx, f, func(e Event) error {
+		events = append(events, e)
+		return nil
+	})
+	return events, err
+}
+
+// Each calls fn for every event matching f without holding them all in
+// memory, for exports.
+func (s *SQLStore) Each(ctx context.Context, f Filter, fn func(Event) error) error {
+	query, args := f.sql()
+	rows, err := s.db.QueryContext(ctx, db.Rebind(s.driver, query), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e Event
+		var actorID sql.NullInt64
+		var before, after sql.NullString
+		if err := rows.Scan(&e.ID, &e.OccurredAt, &actorID, &e.Actor, &e.Action, &e.Target,
+			&e.RequestID, &e.IP, &before, &after, &e.Reason); err != nil {
+			return err
+		}
+		if actorID.Valid {
+			id := uint(actorID.Int64)
+			e.ActorID = &id
+		}
+		e.Before = before.String
+		e.After = after.String
+		if err := fn(e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (f Filter) sql() (string, []interface{}) {
+	var where []string
+	var args []interface{}
+	add := func(cond string, arg interface{}) {
+		where = append(where, cond)
+		args = append(args, arg)
+	}
+
+	if f.ActorID != nil {
+		add("actor_id = ?", *f.ActorID)
+	}
+	if f.Actor != "" {
+		add("actor = ?", f.Actor)
+	}
+	if f.Action != "" {
+		add("action = ?", f.Action)
+	}
+	if f.Target != "" {
+		add("target = ?", f.Target)
+	}
+	if f.RequestID != "" {
+		add("request_id = ?", f.RequestID)
+	}
+	if !f.From.IsZero() {
+		add("occurred_at >= ?", f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		add("occurred_at < ?", f.To.UTC())
+	}
+
+	query := `SELECT id, occurred_at, actor_id, actor, action, target, request_id, ip,
+		before_value, after_value, reason FROM audit_events`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	if f.Newest {
+		query += " ORDER BY id DESC"
+	} else {
+		query += " ORDER BY id"
+	}
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+		if f.Offset > 0 {
+			query += " OFFSET ?"
+			args = append(args, f.Offset)
+		}
+	}
+	return query, args
+}
+
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,225 @@
+// Package balance answers what an account's balance was at a point in
+// time. Every balance change is logged, so a past balance is the one the
+// entries recorded before that point leave. To keep that replay short a
+// scheduled job stores every account's balance at the end of each UTC day;
+// a query starts from the latest snapshot before the point it asks about
+// and replays only the entries after it.
+package balance
+
+import (
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/db"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrUserNotFound is returned for balances of a user that does not exist.
+var ErrUserNotFound = apperr.ErrUserNotFound
+
+// dayLayout is how days are written in snapshots, requests and responses.
+const dayLayout = "2006-01-02"
+
+// Point is a balance at a point in time.
+type Point struct {
+	UserID  uint      `json:"user_id"`
+	At      time.Time `json:"at"`
+	Balance float64   `json:"balance"`
+}
+
+// Day is a balance at the end of a UTC day.
+type Day struct {
+	Date    string  `json:"date"`
+	Balance float64 `json:"balance"`
+}
+
+type Store struct {
+	db     *sql.DB
+	driver string
+}
+
+func NewStore(sqlDB *sql.DB, driver string) *Store {
+	return &Store{db: sqlDB, driver: driver}
+}
+
+// At returns the balance userID had after every entry recorded before at.
+func (s *Store) At(ctx context.Context, userID uint, at time.Time) (*Point, error) {
+	balances, err := s.history(ctx, userID, []time.Time{at})
+	if err != nil {
+		return nil, err
+	}
+	return &Point{UserID: userID, At: at.UTC(), Balance: balances[0]}, nil
+}
+
+// Daily returns the balance userID had at the end of every UTC day from
+// from to to, both included.
+func (s *Store) Daily(ctx context.Context, userID uint, from, to time.Time) ([]Day, error) {
+	var ends []time.Time
+	for day := StartOfDay(from); !day.After(to); day = day.AddDate(0, 0, 1) {
+		ends = append(ends, day.AddDate(0, 0, 1))
+	}
+	if len(ends) == 0 {
+		return []Day{}, nil
+	}
+
+	balances, err := s.history(ctx, userID, ends)
+	if err != nil {
+		return nil, err
+	}
+	days := make([]Day, len(ends))
+	for i, end := range ends {
+		days[i] = Day{Date: end.AddDate(0, 0, -1).Format(dayLayout), Balance: balances[i]}
+	}
+	return days, nil
+}
+
+// ParseTime reads a point in time for a balance query: an RFC 3339
+// timestamp, or a date standing for the end of that UTC day.
+func ParseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.Parse(dayLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 timestamp nor a YYYY-MM-DD date", value)
+	}
+	return day.AddDate(0, 0, 1), nil
+}
+
+// StartOfDay returns midnight UTC of the day t falls on.
+func StartOfDay(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// date returns midnight UTC of the calendar day t names in its own
+// location, which is how drivers return DATE columns.
+func date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// history returns the balance userID had at every one of bounds, which
+// must be ascending: the balance after the entries recorded before the
+// bound. Users, snapshots and entries are read from one snapshot of the
+// database.
+func (s *Store) history(ctx context.Context, userID uint, bounds []time.Time) ([]float64, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var stored float64
+	err = tx.QueryRowContext(ctx, db.Rebind(s.driver, "SELECT COALESCE(credit, 0) FROM users WHERE id = ?"), userID).Scan(&stored)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The latest snapshot of a day that ended by the first bound.
+	var acc account
+	var since, day time.Time
+	err = tx.QueryRowContext(ctx, db.Rebind(s.driver,
+		"SELECT day, balance FROM balance_snapshots WHERE user_id = ? AND day <= ? ORDER BY day DESC LIMIT 1"),
+		userID, bounds[0].UTC().AddDate(0, 0, -1).Format(dayLayout)).Scan(&day, &acc.balance)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return nil, err
+	default:
+		acc.open = true
+		since = date(day).AddDate(0, 0, 1)
+	}
+
+	rows, err := tx.QueryContext(ctx, db.Rebind(s.driver, `SELECT `+entryColumns+`
+		FROM transaction_logs WHERE (sender_id = ? OR receiver_id = ?) AND transaction_date >= ?
+		ORDER BY id`), userID, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := make([]float64, len(bounds))
+	next := 0
+	for next < len(bounds) && rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		acc.openAt(e, userID)
+		for next < len(bounds) && !e.date.Before(bounds[next]) {
+			balances[next] = acc.balance
+			next++
+		}
+		if next < len(bounds) {
+			acc.apply(e, userID)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// An account without snapshot or history has kept the balance it has.
+	if !acc.open {
+		acc.balance = stored
+	}
+	for ; next < len(bounds); next++ {
+		balances[next] = acc.balance
+	}
+	return balances, nil
+}
+
+// entryColumns are the columns of transaction_logs a replay reads.
+const entryColumns = `COALESCE(sender_id, 0), COALESCE(receiver_id, 0), amount,
+	sender_credit_before, receiver_credit_before, transaction_date`
+
+type entry struct {
+	senderID, receiverID         uint
+	amount                       float64
+	senderBefore, receiverBefore sql.NullFloat64
+	date                         time.Time
+}
+
+func scanEntry(rows *sql.Rows) (entry, error) {
+	var e entry
+	err := rows.Scan(&e.senderID, &e.receiverID, &e.amount, &e.senderBefore, &e.receiverBefore, &e.date)
+	return e, err
+}
+
+// account is a balance being replayed. An account opens with a snapshot or,
+// without one, with the balance before its first entry, which covers
+// balances from before changes were logged.
+type account struct {
+	balance float64
+	open    bool
+}
+
+// openAt opens the account with the balance userID had before e, unless it
+// is open already. Entries written before the balance columns existed open
+// it at zero.
+func (a *account) openAt(e entry, userID uint) {
+	if a.open {
+		return
+	}
+	a.open = true
+	if e.senderID == userID {
+		a.balance = e.senderBefore.Float64
+	} else {
+		a.balance = e.receiverBefore.Float64
+	}
+}
+
+// apply moves the balance by what e changed for userID.
+func (a *account) apply(e entry, userID uint) {
+	if e.senderID == userID {
+		a.balance -= e.amount
+	}
+	if e.receiverID == userID {
+		a.balance += e.amount
+	}
+	a.balance = math.Round(a.balance*100) / 100
+}
@@ -0,0 +1,253 @@
+package balance
+
+import (
+	"Ledger/pkg/db"
+	"Ledger/pkg/migrate"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Tables are the tables snapshots are kept in, for migrate verify.
+var Tables = []migrate.Table{
+	{Name: "balance_snapshots", Columns: []string{"user_id", "day", "balance", "created_at"}, Types: map[string]string{"balance": "decimal(10,2)"}},
+}
+
+// settle is how long after midnight a day is left open, so transfers
+// dated just before midnight have committed before the day is snapshotted.
+const settle = 5 * time.Minute
+
+// insertChunk is the number of snapshot rows written by one statement.
+const insertChunk = 500
+
+// Snapshot stores every user's balance at the end of the UTC day day falls
+// on and returns the number of balances stored. A day that was snapshotted
+// already is left alone, so the call can be repeated.
+//
+// The balances continue from the latest snapshot before the day, which
+// holds every user that existed when it was taken; only the entries
+// recorded since it are replayed. Users it does not hold open with the
+// balance before their first entry after it.
+func (s *Store) Snapshot(ctx context.Context, day time.Time) (int, error) {
+	day = StartOfDay(day)
+	end := day.AddDate(0, 0, 1)
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var taken int
+	if err := tx.QueryRowContext(ctx, db.Rebind(s.driver, "SELECT COUNT(*) FROM balance_snapshots WHERE day = ?"),
+		day.Format(dayLayout)).Scan(&taken); err != nil {
+		return 0, err
+	}
+	if taken > 0 {
+		return 0, nil
+	}
+
+	ids, stored, err := users(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	accounts, since, err := s.previous(ctx, tx, day)
+	if err != nil {
+		return 0, err
+	}
+
+	unopened := 0
+	for _, id := range ids {
+		if !accounts[id].open {
+			unopened++
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, db.Rebind(s.driver, `SELECT `+entryColumns+`
+		FROM transaction_logs WHERE transaction_date >= ? ORDER BY id`), since)
+	if err != nil {
+		return 0, err
+	}
+	// Entries after the day only open the accounts not open yet; the scan
+	// stops once none is left.
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if !e.date.Before(end) && unopened == 0 {
+			break
+		}
+		for _, id := range []uint{e.senderID, e.receiverID} {
+			acc := accounts[id]
+			if _, exists := stored[id]; !exists || (acc.open && !e.date.Before(end)) {
+				continue
+			}
+			if !acc.open {
+				acc.openAt(e, id)
+				unopened--
+			}
+			if e.date.Before(end) {
+				acc.apply(e, id)
+			}
+			accounts[id] = acc
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// Accounts without snapshot or history have kept the balance they have.
+	balances := make([]float64, len(ids))
+	for i, id := range ids {
+		if acc := accounts[id]; acc.open {
+			balances[i] = acc.balance
+		} else {
+			balances[i] = stored[id]
+		}
+	}
+
+	if err := s.insert(ctx, tx, day, ids, balances); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// users returns every user ID in order with its stored balance.
+func users(ctx context.Context, tx *sql.Tx) ([]uint, map[uint]float64, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id, COALESCE(credit, 0) FROM users ORDER BY id")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var ids []uint
+	stored := make(map[uint]float64)
+	for rows.Next() {
+		var id uint
+		var credit float64
+		if err := rows.Scan(&id, &credit); err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+		stored[id] = credit
+	}
+	return ids, stored, rows.Err()
+}
+
+// previous returns the accounts of the latest snapshot before day, opened
+// with its balances, and the time the entries after it start at. Without
+// an earlier snapshot the whole history is replayed.
+func (s *Store) previous(ctx context.Context, tx *sql.Tx, day time.Time) (map[uint]account, time.Time, error) {
+	accounts := make(map[uint]account)
+
+	var latest sql.NullTime
+	if err := tx.QueryRowContext(ctx, db.Rebind(s.driver, "SELECT MAX(day) FROM balance_snapshots WHERE day < ?"),
+		day.Format(dayLayout)).Scan(&latest); err != nil {
+		return nil, time.Time{}, err
+	}
+	if !latest.Valid {
+		return accounts, time.Time{}, nil
+	}
+	previous := date(latest.Time)
+
+	rows, err := tx.QueryContext(ctx, db.Rebind(s.driver, "SELECT user_id, balance FROM balance_snapshots WHERE day = ?"),
+		previous.Format(dayLayout))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id uint
+		acc := account{open: true}
+		if err := rows.Scan(&id, &acc.balance); err != nil {
+			return nil, time.Time{}, err
+		}
+		accounts[id] = acc
+	}
+	return accounts, previous.AddDate(0, 0, 1), rows.Err()
+}
+
+func (s *Store) insert(ctx context.Context, tx *sql.Tx, day time.Time, ids []uint, balances []float64) error {
+	createdAt := time.Now().UTC().Truncate(time.Millisecond)
+	for start := 0; start < len(ids); start += insertChunk {
+		stop := min(start+insertChunk, len(ids))
+		values := make([]string, 0, stop-start)
+		args := make([]interface{}, 0, 4*(stop-start))
+		for i := start; i < stop; i++ {
+			values = append(values, "(?, ?, ?, ?)")
+			args = append(args, ids[i], day.Format(dayLayout), balances[i], createdAt)
+		}
+		query := "INSERT INTO balance_snapshots (user_id, day, balance, created_at) VALUES " + strings.Join(values, ", ")
+		if _, err := tx.ExecContext(ctx, db.Rebind(s.driver, query), args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SnapshotDue snapshots every day that ended at least settle ago and has
+// no snapshot yet, oldest first, starting after the latest snapshot or, on
+// the first run, at the day of the first entry. It returns the number of
+// days snapshotted.
+func (s *Store) SnapshotDue(ctx context.Context, now time.Time) (int, error) {
+	last := StartOfDay(now.Add(-settle)).AddDate(0, 0, -1)
+
+	var latest sql.NullTime
+	if err := s.db.QueryRowContext(ctx, "SELECT MAX(day) FROM balance_snapshots").Scan(&latest); err != nil {
+		return 0, err
+	}
+	var first time.Time
+	if latest.Valid {
+		first = date(latest.Time).AddDate(0, 0, 1)
+	} else {
+		var oldest sql.NullTime
+		if err := s.db.QueryRowContext(ctx, "SELECT MIN(transaction_date) FROM transaction_logs").Scan(&oldest); err != nil {
+			return 0, err
+		}
+		if !oldest.Valid {
+			oldest.Time = last
+		}
+		first = StartOfDay(oldest.Time)
+	}
+
+	days := 0
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		if _, err := s.Snapshot(ctx, day); err != nil {
+			return days, err
+		}
+		days++
+	}
+	return days, nil
+}
+
+// Every snapshots the days that are due every interval until ctx is done,
+// logging failures. It is how the server schedules the job.
+func (s *Store) Every(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			days, err := s.SnapshotDue(ctx, time.Now())
+			if err != nil && !errors.Is(err, context.Canceled) {
+				slog.ErrorContext(ctx, "Balance snapshot failed", "days", days, "error", err)
+				continue
+			}
+			if days > 0 {
+				slog.InfoContext(ctx, "Balance snapshots taken", "days", days)
+			}
+		}
+	}
+}
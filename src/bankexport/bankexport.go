@@ -0,0 +1,107 @@
+// Package bankexport renders account statements in the formats banking
+// software imports: ISO 20022 camt.053 (bank-to-customer statement) and
+// OFX 2.2. Every export is checked against the schema of its format
+// before it is written, so a file that a bank or accounting package would
+// reject never leaves the ledger.
+package bankexport
+
+import (
+	"Ledger/src/statement"
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Formats an export renders to.
+const (
+	FormatCAMT053 = "camt053"
+	FormatOFX     = "ofx"
+)
+
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// ValidCurrency reports whether code is an ISO 4217 alphabetic code.
+func ValidCurrency(code string) bool {
+	return currencyCode.MatchString(code)
+}
+
+// ContentType returns the media type of format, or "" for an unknown one.
+func ContentType(format string) string {
+	switch format {
+	case FormatCAMT053:
+		return "application/xml"
+	case FormatOFX:
+		return "application/x-ofx"
+	}
+	return ""
+}
+
+// FileName is the name an export is downloaded under.
+func FileName(s *statement.Statement, format string) string {
+	ext := "xml"
+	if format == FormatOFX {
+		ext = "ofx"
+	}
+	return fmt.Sprintf("statement-%d-%s-%s.%s", s.UserID, s.From, s.To, ext)
+}
+
+// Write renders s to w as format with amounts in currency. Nothing is
+// written unless the export passes validation.
+func Write(w io.Writer, s *statement.Statement, format, currency string) error {
+	if !ValidCurrency(currency) {
+		return fmt.Errorf("invalid currency %q, want an ISO 4217 code", currency)
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case FormatCAMT053:
+		err = writeCAMT053(&buf, s, currency)
+	case FormatOFX:
+		err = writeOFX(&buf, s, currency)
+	default:
+		return fmt.Errorf("unknown export format %q, want %s or %s", format, FormatCAMT053, FormatOFX)
+	}
+	if err != nil {
+		return err
+	}
+	if err := Validate(bytes.NewReader(buf.Bytes()), format); err != nil {
+		return fmt.Errorf("%s export of user %d is invalid: %w", format, s.UserID, err)
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// Validate checks the export in r against the schema of format.
+func Validate(r io.Reader, format string) error {
+	switch format {
+	case FormatCAMT053:
+		return validate(r, camt053Schema, camt053Namespace)
+	case FormatOFX:
+		return validateOFX(r)
+	}
+	return fmt.Errorf("unknown export format %q, want %s or %s", format, FormatCAMT053, FormatOFX)
+}
+
+// reference identifies the statement s within the ledger; it is the
+// message and statement id of camt.053 and the transaction id of OFX.
+func reference(s *statement.Statement) string {
+	return strconv.FormatUint(uint64(s.UserID), 10) + "-" +
+		strings.ReplaceAll(s.From, "-", "") + "-" + strings.ReplaceAll(s.To, "-", "")
+}
+
+func decimal(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// truncate cuts v to at most n characters.
+func truncate(v string, n int) string {
+	runes := []rune(v)
+	if len(runes) <= n {
+		return v
+	}
+	return string(runes[:n])
+}
@@ -0,0 +1,316 @@
+package bankexport
+
+import (
+	"Ledger/src/statement"
+	"encoding/xml"
+	"io"
+	"math"
+	"strconv"
+)
+
+const camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+
+const isoDateTime = "2006-01-02T15:04:05Z"
+
+type camtDocument struct {
+	XMLName xml.Name      `xml:"Document"`
+	Xmlns   string        `xml:"xmlns,attr"`
+	GrpHdr  camtGroup     `xml:"BkToCstmrStmt>GrpHdr"`
+	Stmt    camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtGroup struct {
+	MsgID   string `xml:"MsgId"`
+	CreDtTm string `xml:"CreDtTm"`
+}
+
+type camtStatement struct {
+	ID        string        `xml:"Id"`
+	CreDtTm   string        `xml:"CreDtTm"`
+	FrDtTm    string        `xml:"FrToDt>FrDtTm"`
+	ToDtTm    string        `xml:"FrToDt>ToDtTm"`
+	Acct      camtAccount   `xml:"Acct"`
+	Bal       []camtBalance `xml:"Bal"`
+	TxsSummry camtSummary   `xml:"TxsSummry"`
+	Ntry      []camtEntry   `xml:"Ntry"`
+}
+
+type camtAccount struct {
+	ID    string     `xml:"Id>Othr>Id"`
+	Ccy   string     `xml:"Ccy"`
+	Owner *camtParty `xml:"Ownr,omitempty"`
+}
+
+type camtParty struct {
+	Name string `xml:"Nm"`
+}
+
+type camtAccountRef struct {
+	ID string `xml:"Id>Othr>Id"`
+}
+
+type camtAmount struct {
+	Ccy   string `xml:"Ccy,attr"`
+	Value string `xml:",chardata"`
+}
+
+type camtBalance struct {
+	Code      string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amt       camtAmount `xml:"Amt"`
+	CdtDbtInd string     `xml:"CdtDbtInd"`
+	Date      string     `xml:"Dt>Dt"`
+}
+
+type camtSummary struct {
+	Total   camtTotal `xml:"TtlNtries"`
+	Credits camtCount `xml:"TtlCdtNtries"`
+	Debits  camtCount `xml:"TtlDbtNtries"`
+}
+
+type camtTotal struct {
+	NbOfNtries    string `xml:"NbOfNtries"`
+	Sum           string `xml:"Sum"`
+	TtlNetNtryAmt string `xml:"TtlNetNtryAmt"`
+	CdtDbtInd     string `xml:"CdtDbtInd"`
+}
+
+type camtCount struct {
+	NbOfNtries string `xml:"NbOfNtries"`
+	Sum        string `xml:"Sum"`
+}
+
+type camtEntry struct {
+	NtryRef     string        `xml:"NtryRef"`
+	Amt         camtAmount    `xml:"Amt"`
+	CdtDbtInd   string        `xml:"CdtDbtInd"`
+	Sts         string        `xml:"Sts"`
+	BookgDtTm   string        `xml:"BookgDt>DtTm"`
+	ValDt       string        `xml:"ValDt>Dt"`
+	AcctSvcrRef string        `xml:"AcctSvcrRef"`
+	BkTxCd      string        `xml:"BkTxCd>Prtry>Cd"`
+	TxDtls      camtTxDetails `xml:"NtryDtls>TxDtls"`
+}
+
+type camtTxDetails struct {
+	AcctSvcrRef string             `xml:"Refs>AcctSvcrRef"`
+	RltdPties   *camtRelatedParty  `xml:"RltdPties,omitempty"`
+	RmtInf      *camtRemittanceInf `xml:"RmtInf,omitempty"`
+}
+
+// camtRelatedParty is the counterparty of a transfer: the debtor of a
+// credit or the creditor of a debit. Nested paths cannot be left out
+// with omitempty, hence the pointers.
+type camtRelatedParty struct {
+	Debtor          *camtParty      `xml:"Dbtr,omitempty"`
+	DebtorAccount   *camtAccountRef `xml:"DbtrAcct,omitempty"`
+	Creditor        *camtParty      `xml:"Cdtr,omitempty"`
+	CreditorAccount *camtAccountRef `xml:"CdtrAcct,omitempty"`
+}
+
+type camtRemittanceInf struct {
+	Ustrd string `xml:"Ustrd"`
+}
+
+// Proprietary bank transaction codes of the entries.
+const (
+	codeTransfer   = "TRANSFER"
+	codeAdjustment = "ADJUSTMENT"
+)
+
+// writeCAMT053 renders s as a camt.053.001.02 message holding one
+// statement, with the opening and closing booked balances.
+func writeCAMT053(w io.Writer, s *statement.Statement, currency string) error {
+	ref := reference(s)
+	created := s.GeneratedAt.UTC().Format(isoDateTime)
+
+	doc := camtDocument{
+		Xmlns:  camt053Namespace,
+		GrpHdr: camtGroup{MsgID: ref, CreDtTm: created},
+		Stmt: camtStatement{
+			ID:      ref,
+			CreDtTm: created,
+			FrDtTm:  s.From + "T00:00:00Z",
+			ToDtTm:  s.To + "T23:59:59Z",
+			Acct: camtAccount{
+				ID:  strconv.FormatUint(uint64(s.UserID), 10),
+				Ccy: currency,
+			},
+			Bal: []camtBalance{
+				camtBalanceOf("OPBD", s.OpeningBalance, s.From, currency),
+				camtBalanceOf("CLBD", s.ClosingBalance, s.To, currency),
+			},
+		},
+	}
+
+	if s.Name != "" {
+		doc.Stmt.Acct.Owner = &camtParty{Name: truncate(s.Name, 140)}
+	}
+
+	credits, debits := 0, 0
+	for _, line := range s.Lines {
+		entryRef := strconv.FormatUint(line.EntryID, 10)
+		entry := camtEntry{
+			NtryRef:     entryRef,
+			Sts:         "BOOK",
+			BookgDtTm:   line.Date.UTC().Format(isoDateTime),
+			ValDt:       line.Date.UTC().Format("2006-01-02"),
+			AcctSvcrRef: entryRef,
+			BkTxCd:      codeAdjustment,
+			TxDtls:      camtTxDetails{AcctSvcrRef: entryRef},
+		}
+		if line.Description != "" {
+			entry.TxDtls.RmtInf = &camtRemittanceInf{Ustrd: truncate(line.Description, 140)}
+		}
+		if line.Credit != 0 {
+			credits++
+			entry.Amt = camtAmount{Ccy: currency, Value: decimal(line.Credit)}
+			entry.CdtDbtInd = "CRDT"
+		} else {
+			debits++
+			entry.Amt = camtAmount{Ccy: currency, Value: decimal(line.Debit)}
+			entry.CdtDbtInd = "DBIT"
+		}
+		if line.CounterpartyID != 0 {
+			entry.BkTxCd = codeTransfer
+			account := &camtAccountRef{ID: strconv.FormatUint(uint64(line.CounterpartyID), 10)}
+			var party *camtParty
+			if line.Counterparty != "" {
+				party = &camtParty{Name: truncate(line.Counterparty, 140)}
+			}
+			if line.Credit != 0 {
+				entry.TxDtls.RltdPties = &camtRelatedParty{Debtor: party, DebtorAccount: account}
+			} else {
+				entry.TxDtls.RltdPties = &camtRelatedParty{Creditor: party, CreditorAccount: account}
+			}
+		}
+		doc.Stmt.Ntry = append(doc.Stmt.Ntry, entry)
+	}
+
+	net := s.TotalCredits - s.TotalDebits
+	doc.Stmt.TxsSummry = camtSummary{
+		Total: camtTotal{
+			NbOfNtries:    strconv.Itoa(len(s.Lines)),
+			Sum:           decimal(s.TotalCredits + s.TotalDebits),
+			TtlNetNtryAmt: decimal(math.Abs(net)),
+			CdtDbtInd:     creditDebit(net),
+		},
+		Credits: camtCount{NbOfNtries: strconv.Itoa(credits), Sum: decimal(s.TotalCredits)},
+		Debits:  camtCount{NbOfNtries: strconv.Itoa(debits), Sum: decimal(s.TotalDebits)},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// camtBalanceOf is a balance of the statement; camt.053 amounts are never
+// negative, the sign is carried by the credit/debit indicator.
+func camtBalanceOf(code string, balance float64, day, currency string) camtBalance {
+	return camtBalance{
+		Code:      code,
+		Amt:       camtAmount{Ccy: currency, Value: decimal(math.Abs(balance))},
+		CdtDbtInd: creditDebit(balance),
+		Date:      day,
+	}
+}
+
+func creditDebit(v float64) string {
+	if v < 0 {
+		return "DBIT"
+	}
+	return "CRDT"
+}
+
+// Types of camt.053.001.02 the exports use.
+var (
+	camtDateTime   = timeType("ISODateTime", `\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})?`, "2006-01-02T15:04:05")
+	camtDate       = timeType("ISODate", `\d{4}-\d{2}-\d{2}`, "2006-01-02")
+	camtCurrency   = pattern("ActiveOrHistoricCurrencyCode", `[A-Z]{3}`)
+	camtAmountType = pattern("ActiveOrHistoricCurrencyAndAmount", `[0-9]{1,13}(\.[0-9]{1,5})?`)
+	camtDecimal    = pattern("DecimalNumber", `[+-]?[0-9]{1,17}(\.[0-9]{1,17})?`)
+	camtNumeric15  = pattern("Max15NumericText", `[0-9]{1,15}`)
+	camtCdtDbt     = enum("CreditDebitCode", "CRDT", "DBIT")
+	camtBalance12  = enum("BalanceType12Code", "ITBD", "CLAV", "CLBD", "FWAV", "INFO", "ITAV", "OPAV", "OPBD", "PRCD", "XPCD")
+	camtStatus     = enum("EntryStatus2Code", "BOOK", "PDNG", "INFO")
+)
+
+func camtAmountElement(name string) element {
+	e := leaf(name, 1, 1, camtAmountType)
+	e.attrs = map[string]*simpleType{"Ccy": camtCurrency}
+	return e
+}
+
+func camtAccountID() element {
+	return group("Id", 1, 1, group("Othr", 1, 1, leaf("Id", 1, 1, maxText(34))))
+}
+
+func camtCount15() []element {
+	return []element{leaf("NbOfNtries", 0, 1, camtNumeric15), leaf("Sum", 0, 1, camtDecimal)}
+}
+
+// camt053Schema is the part of camt.053.001.02 the exports use, with the
+// element order, cardinality and types of the XSD.
+var camt053Schema = group("Document", 1, 1,
+	group("BkToCstmrStmt", 1, 1,
+		group("GrpHdr", 1, 1,
+			leaf("MsgId", 1, 1, maxText(35)),
+			leaf("CreDtTm", 1, 1, camtDateTime),
+		),
+		group("Stmt", 1, 0,
+			leaf("Id", 1, 1, maxText(35)),
+			leaf("CreDtTm", 1, 1, camtDateTime),
+			group("FrToDt", 0, 1,
+				leaf("FrDtTm", 1, 1, camtDateTime),
+				leaf("ToDtTm", 1, 1, camtDateTime),
+			),
+			group("Acct", 1, 1,
+				camtAccountID(),
+				leaf("Ccy", 0, 1, camtCurrency),
+				group("Ownr", 0, 1, leaf("Nm", 0, 1, maxText(140))),
+			),
+			group("Bal", 1, 0,
+				group("Tp", 1, 1, group("CdOrPrtry", 1, 1, leaf("Cd", 1, 1, camtBalance12))),
+				camtAmountElement("Amt"),
+				leaf("CdtDbtInd", 1, 1, camtCdtDbt),
+				group("Dt", 1, 1, leaf("Dt", 1, 1, camtDate)),
+			),
+			group("TxsSummry", 0, 1,
+				group("TtlNtries", 0, 1, append(camtCount15(),
+					leaf("TtlNetNtryAmt", 0, 1, camtDecimal),
+					leaf("CdtDbtInd", 0, 1, camtCdtDbt),
+				)...),
+				group("TtlCdtNtries", 0, 1, camtCount15()...),
+				group("TtlDbtNtries", 0, 1, camtCount15()...),
+			),
+			group("Ntry", 0, 0,
+				leaf("NtryRef", 0, 1, maxText(35)),
+				camtAmountElement("Amt"),
+				leaf("CdtDbtInd", 1, 1, camtCdtDbt),
+				leaf("Sts", 1, 1, camtStatus),
+				group("BookgDt", 0, 1, leaf("DtTm", 1, 1, camtDateTime)),
+				group("ValDt", 0, 1, leaf("Dt", 1, 1, camtDate)),
+				leaf("AcctSvcrRef", 0, 1, maxText(35)),
+				group("BkTxCd", 1, 1, group("Prtry", 1, 1, leaf("Cd", 1, 1, maxText(35)))),
+				group("NtryDtls", 0, 0,
+					group("TxDtls", 0, 0,
+						group("Refs", 0, 1, leaf("AcctSvcrRef", 0, 1, maxText(35))),
+						group("RltdPties", 0, 1,
+							group("Dbtr", 0, 1, leaf("Nm", 0, 1, maxText(140))),
+							group("DbtrAcct", 0, 1, camtAccountID()),
+							group("Cdtr", 0, 1, leaf("Nm", 0, 1, maxText(140))),
+							group("CdtrAcct", 0, 1, camtAccountID()),
+						),
+						group("RmtInf", 0, 1, leaf("Ustrd", 0, 0, maxText(140))),
+					),
+				),
+			),
+		),
+	),
+)
@@ -0,0 +1,125 @@
+package bankexport_test
+
+import (
+	"Ledger/src/bankexport"
+	"Ledger/src/statement"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// update rewrites the golden files from the current output:
+//
+//	go test ./src/bankexport -update
+var update = flag.Bool("update", false, "rewrite the golden files from the current output")
+
+// currency is the currency the golden files are rendered in.
+const currency = "TRY"
+
+// extensions maps each format to the extension of its golden files.
+var extensions = map[string]string{
+	bankexport.FormatCAMT053: ".camt053.xml",
+	bankexport.FormatOFX:     ".ofx",
+}
+
+// TestGolden renders every statement fixture in testdata to each format and
+// compares the result with the golden file next to it.
+func TestGolden(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures in testdata")
+	}
+
+	for _, fixture := range fixtures {
+		for _, format := range []string{bankexport.FormatCAMT053, bankexport.FormatOFX} {
+			golden := strings.TrimSuffix(fixture, ".json") + extensions[format]
+			t.Run(filepath.Base(golden), func(t *testing.T) {
+				got := render(t, fixture, format)
+				if *update {
+					if err := os.WriteFile(golden, got, 0o644); err != nil {
+						t.Fatal(err)
+					}
+					return
+				}
+
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("output differs from the golden file:\n%s", diff(string(want), string(got)))
+				}
+			})
+		}
+	}
+}
+
+// TestRejectsInvalid checks that every file under testdata/invalid fails
+// validation.
+func TestRejectsInvalid(t *testing.T) {
+	invalid, err := filepath.Glob(filepath.Join("testdata", "invalid", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range invalid {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			format := bankexport.FormatCAMT053
+			if strings.HasSuffix(path, ".ofx") {
+				format = bankexport.FormatOFX
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if err := bankexport.Validate(f, format); err == nil {
+				t.Errorf("accepted as a valid %s file", format)
+			}
+		})
+	}
+}
+
+// render renders the statement in fixture as format.
+func render(t *testing.T, fixture, format string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s statement.Statement
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+
+	var got bytes.Buffer
+	if err := bankexport.Write(&got, &s, format, currency); err != nil {
+		t.Fatal(err)
+	}
+	return got.Bytes()
+}
+
+// diff reports the first line where want and got differ.
+func diff(want, got string) string {
+	wantLines, gotLines := strings.Split(want, "\n"), strings.Split(got, "\n")
+	for i := 0; i < len(wantLines) || i < len(gotLines); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			return fmt.Sprintf("line %d:\n  want %q\n  got  %q", i+1, w, g)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,237 @@
+package bankexport
+
+import (
+	"Ledger/src/statement"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ofxHeader opens every OFX 2.2 file.
+const ofxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>` + "\n" +
+	`<?OFX OFXHEADER="200" VERSION="220" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n"
+
+// ofxBankID is the routing number the accounts of the ledger are exported
+// under; OFX needs one, the ledger has none.
+const ofxBankID = "LEDGER"
+
+// ofxNameLength is how much of a name the NAME element holds.
+const ofxNameLength = 32
+
+type ofxDocument struct {
+	XMLName xml.Name         `xml:"OFX"`
+	Signon  ofxSignon        `xml:"SIGNONMSGSRSV1>SONRS"`
+	TrnUID  string           `xml:"BANKMSGSRSV1>STMTTRNRS>TRNUID"`
+	Status  ofxStatus        `xml:"BANKMSGSRSV1>STMTTRNRS>STATUS"`
+	Stmt    ofxStatementResp `xml:"BANKMSGSRSV1>STMTTRNRS>STMTRS"`
+}
+
+type ofxSignon struct {
+	Status   ofxStatus `xml:"STATUS"`
+	DTServer string    `xml:"DTSERVER"`
+	Language string    `xml:"LANGUAGE"`
+}
+
+type ofxStatus struct {
+	Code     string `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxStatementResp struct {
+	CurDef    string       `xml:"CURDEF"`
+	AcctFrom  ofxAccount   `xml:"BANKACCTFROM"`
+	DTStart   string       `xml:"BANKTRANLIST>DTSTART"`
+	DTEnd     string       `xml:"BANKTRANLIST>DTEND"`
+	Trns      []ofxTrn     `xml:"BANKTRANLIST>STMTTRN"`
+	LedgerBal ofxLedgerBal `xml:"LEDGERBAL"`
+}
+
+type ofxAccount struct {
+	BankID   string `xml:"BANKID"`
+	AcctID   string `xml:"ACCTID"`
+	AcctType string `xml:"ACCTTYPE"`
+}
+
+type ofxTrn struct {
+	TrnType  string      `xml:"TRNTYPE"`
+	DTPosted string      `xml:"DTPOSTED"`
+	TrnAmt   string      `xml:"TRNAMT"`
+	FITID    string      `xml:"FITID"`
+	Name     string      `xml:"NAME,omitempty"`
+	AcctTo   *ofxAccount `xml:"BANKACCTTO,omitempty"`
+	Memo     string      `xml:"MEMO,omitempty"`
+}
+
+type ofxLedgerBal struct {
+	BalAmt string `xml:"BALAMT"`
+	DTAsOf string `xml:"DTASOF"`
+}
+
+// ofxTime formats t the way OFX writes dates and times, in UTC.
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405.000") + "[0:GMT]"
+}
+
+// writeOFX renders s as an OFX 2.2 bank statement response. Transfers are
+// XFER transactions, outgoing ones with the receiver's account; credits and
+// debits of the balance itself are CREDIT and DEBIT.
+func writeOFX(w io.Writer, s *statement.Statement, currency string) error {
+	from, err := time.Parse("2006-01-02", s.From)
+	if err != nil {
+		return err
+	}
+	to, err := time.Parse("2006-01-02", s.To)
+	if err != nil {
+		return err
+	}
+	end := to.AddDate(0, 0, 1)
+	ok := ofxStatus{Code: "0", Severity: "INFO"}
+
+	doc := ofxDocument{
+		Signon: ofxSignon{Status: ok, DTServer: ofxTime(s.GeneratedAt), Language: "ENG"},
+		TrnUID: reference(s),
+		Status: ok,
+		Stmt: ofxStatementResp{
+			CurDef:    currency,
+			AcctFrom:  ofxAccount{BankID: ofxBankID, AcctID: strconv.FormatUint(uint64(s.UserID), 10), AcctType: "CHECKING"},
+			DTStart:   ofxTime(from),
+			DTEnd:     ofxTime(end),
+			LedgerBal: ofxLedgerBal{BalAmt: decimal(s.ClosingBalance), DTAsOf: ofxTime(end)},
+		},
+	}
+
+	for _, line := range s.Lines {
+		trn := ofxTrn{
+			DTPosted: ofxTime(line.Date),
+			FITID:    strconv.FormatUint(line.EntryID, 10),
+			Memo:     truncate(line.Description, 255),
+		}
+		if line.Credit != 0 {
+			trn.TrnType, trn.TrnAmt = "CREDIT", decimal(line.Credit)
+		} else {
+			trn.TrnType, trn.TrnAmt = "DEBIT", decimal(-line.Debit)
+		}
+		if line.CounterpartyID != 0 {
+			trn.TrnType = "XFER"
+			trn.Name = truncate(line.Counterparty, ofxNameLength)
+			if line.Debit != 0 {
+				trn.AcctTo = &ofxAccount{BankID: ofxBankID, AcctID: strconv.FormatUint(uint64(line.CounterpartyID), 10), AcctType: "CHECKING"}
+			}
+		} else {
+			trn.Name = truncate(line.Description, ofxNameLength)
+		}
+		doc.Stmt.Trns = append(doc.Stmt.Trns, trn)
+	}
+
+	if _, err := io.WriteString(w, ofxHeader); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// validateOFX checks the OFX header, then the document against ofxSchema.
+func validateOFX(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	for header := false; !header; {
+		tok, err := dec.Token()
+		if err != nil {
+			return fmt.Errorf("not well-formed: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.ProcInst:
+			if t.Target == "OFX" {
+				inst := string(t.Inst)
+				if !strings.Contains(inst, `OFXHEADER="200"`) || !strings.Contains(inst, `VERSION="220"`) {
+					return fmt.Errorf("OFX header %q is not version 2.2", inst)
+				}
+				header = true
+			}
+		case xml.StartElement:
+			return fmt.Errorf("missing OFX header")
+		}
+	}
+	return validate(bytes.NewReader(data), ofxSchema, "")
+}
+
+// Types of OFX 2.2 the exports use.
+var (
+	ofxDateTime = timeType("DateTime", `\d{8}(\d{6}(\.\d{3})?)?(\[[+-]?\d+(\.\d+)?(:[A-Za-z]+)?\])?`, "20060102")
+	ofxAmount   = pattern("Amount", `[+-]?[0-9]+(\.[0-9]+)?`)
+	ofxCurrency = pattern("CurrencyEnum", `[A-Z]{3}`)
+	ofxLanguage = pattern("LanguageEnum", `[A-Z]{3}`)
+	ofxCode     = pattern("StatusCode", `[0-9]{1,6}`)
+	ofxSeverity = enum("SeverityEnum", "INFO", "WARN", "ERROR")
+	ofxAcctType = enum("AccountEnum", "CHECKING", "SAVINGS", "MONEYMRKT", "CREDITLINE", "CD")
+	ofxTrnType  = enum("TransactionEnum", "CREDIT", "DEBIT", "INT", "DIV", "FEE", "SRVCHG", "DEP", "ATM",
+		"POS", "XFER", "CHECK", "PAYMENT", "CASH", "DIRECTDEP", "DIRECTDEBIT", "REPEATPMT", "HOLD", "OTHER")
+)
+
+func ofxStatusElement() element {
+	return group("STATUS", 1, 1,
+		leaf("CODE", 1, 1, ofxCode),
+		leaf("SEVERITY", 1, 1, ofxSeverity),
+		leaf("MESSAGE", 0, 1, maxText(255)),
+	)
+}
+
+func ofxAccountElement(name string, min int) element {
+	return group(name, min, 1,
+		leaf("BANKID", 1, 1, maxText(9)),
+		leaf("BRANCHID", 0, 1, maxText(22)),
+		leaf("ACCTID", 1, 1, maxText(22)),
+		leaf("ACCTTYPE", 1, 1, ofxAcctType),
+	)
+}
+
+// ofxSchema is the part of the OFX 2.2 banking message set the exports
+// use, with the element order, cardinality and types of the OFX schema.
+var ofxSchema = group("OFX", 1, 1,
+	group("SIGNONMSGSRSV1", 1, 1,
+		group("SONRS", 1, 1,
+			ofxStatusElement(),
+			leaf("DTSERVER", 1, 1, ofxDateTime),
+			leaf("LANGUAGE", 1, 1, ofxLanguage),
+		),
+	),
+	group("BANKMSGSRSV1", 0, 1,
+		group("STMTTRNRS", 0, 0,
+			leaf("TRNUID", 1, 1, maxText(36)),
+			ofxStatusElement(),
+			group("STMTRS", 0, 1,
+				leaf("CURDEF", 1, 1, ofxCurrency),
+				ofxAccountElement("BANKACCTFROM", 1),
+				group("BANKTRANLIST", 0, 1,
+					leaf("DTSTART", 1, 1, ofxDateTime),
+					leaf("DTEND", 1, 1, ofxDateTime),
+					group("STMTTRN", 0, 0,
+						leaf("TRNTYPE", 1, 1, ofxTrnType),
+						leaf("DTPOSTED", 1, 1, ofxDateTime),
+						leaf("TRNAMT", 1, 1, ofxAmount),
+						leaf("FITID", 1, 1, maxText(255)),
+						leaf("NAME", 0, 1, maxText(ofxNameLength)),
+						ofxAccountElement("BANKACCTTO", 0),
+						leaf("MEMO", 0, 1, maxText(255)),
+					),
+				),
+				group("LEDGERBAL", 1, 1,
+					leaf("BALAMT", 1, 1, ofxAmount),
+					leaf("DTASOF", 1, 1, ofxDateTime),
+				),
+			),
+		),
+	),
+)
@@ -0,0 +1,216 @@
+package bankexport
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// element is one element of a schema: how often it may occur where it is
+// declared, its children in the order they must appear, or, for a leaf, the
+// type of its text. The schemas in this package cover the part of each
+// standard the exports use; any element they do not declare is rejected.
+type element struct {
+	name     string
+	min, max int // max 0 means unbounded
+	children []element
+	text     *simpleType
+	attrs    map[string]*simpleType
+}
+
+// simpleType constrains the text of a leaf element or an attribute.
+type simpleType struct {
+	name      string
+	pattern   *regexp.Regexp
+	maxLength int
+	enum      []string
+	layout    string // the value starts with a time in this layout
+}
+
+func (t *simpleType) check(v string) error {
+	if t.maxLength > 0 && utf8.RuneCountInString(v) > t.maxLength {
+		return fmt.Errorf("%q is longer than %d characters (%s)", v, t.maxLength, t.name)
+	}
+	if t.maxLength > 0 && v == "" {
+		return fmt.Errorf("empty value (%s)", t.name)
+	}
+	if t.pattern != nil && !t.pattern.MatchString(v) {
+		return fmt.Errorf("%q is not a valid %s", v, t.name)
+	}
+	if t.layout != "" {
+		if len(v) < len(t.layout) {
+			return fmt.Errorf("%q is not a valid %s", v, t.name)
+		}
+		if _, err := time.Parse(t.layout, v[:len(t.layout)]); err != nil {
+			return fmt.Errorf("%q is not a valid %s", v, t.name)
+		}
+	}
+	if len(t.enum) > 0 {
+		for _, e := range t.enum {
+			if v == e {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s (%s)", v, strings.Join(t.enum, ", "), t.name)
+	}
+	return nil
+}
+
+func maxText(n int) *simpleType {
+	return &simpleType{name: fmt.Sprintf("Max%dText", n), maxLength: n}
+}
+
+func pattern(name, expr string) *simpleType {
+	return &simpleType{name: name, pattern: regexp.MustCompile("^(?:" + expr + ")$")}
+}
+
+// timeType is a pattern whose leading time must also exist on the calendar.
+func timeType(name, expr, layout string) *simpleType {
+	t := pattern(name, expr)
+	t.layout = layout
+	return t
+}
+
+func enum(name string, values ...string) *simpleType {
+	return &simpleType{name: name, enum: values}
+}
+
+func leaf(name string, min, max int, t *simpleType) element {
+	return element{name: name, min: min, max: max, text: t}
+}
+
+func group(name string, min, max int, children ...element) element {
+	return element{name: name, min: min, max: max, children: children}
+}
+
+// node is an element read back from a document.
+type node struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	text     string
+	children []*node
+}
+
+// parse reads the document in r into a tree, skipping the prolog,
+// processing instructions and comments.
+func parse(r io.Reader) (*node, error) {
+	dec := xml.NewDecoder(r)
+	var stack []*node
+	var root *node
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name, attrs: t.Attr}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			} else if root != nil {
+				return nil, fmt.Errorf("more than one root element")
+			} else {
+				root = n
+			}
+			stack = append(stack, n)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("no root element")
+	}
+	return root, nil
+}
+
+// validate reads the document in r and checks it against root. namespace
+// is the namespace every element must be in, "" for none.
+func validate(r io.Reader, root element, namespace string) error {
+	doc, err := parse(r)
+	if err != nil {
+		return fmt.Errorf("not well-formed: %w", err)
+	}
+	if doc.name.Local != root.name {
+		return fmt.Errorf("root element is %s, want %s", doc.name.Local, root.name)
+	}
+	return check(doc, root, namespace, "/"+root.name)
+}
+
+func check(n *node, e element, namespace, path string) error {
+	if n.name.Space != namespace {
+		return fmt.Errorf("%s: namespace %q, want %q", path, n.name.Space, namespace)
+	}
+
+	for _, a := range n.attrs {
+		if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" {
+			continue
+		}
+		t, ok := e.attrs[a.Name.Local]
+		if !ok {
+			return fmt.Errorf("%s: unexpected attribute %s", path, a.Name.Local)
+		}
+		if err := t.check(a.Value); err != nil {
+			return fmt.Errorf("%s/@%s: %w", path, a.Name.Local, err)
+		}
+	}
+	for name := range e.attrs {
+		if !hasAttr(n, name) {
+			return fmt.Errorf("%s: missing attribute %s", path, name)
+		}
+	}
+
+	if e.text != nil {
+		if len(n.children) > 0 {
+			return fmt.Errorf("%s: unexpected element %s in a text element", path, n.children[0].name.Local)
+		}
+		if err := e.text.check(n.text); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		return nil
+	}
+	if strings.TrimSpace(n.text) != "" {
+		return fmt.Errorf("%s: unexpected text %q", path, strings.TrimSpace(n.text))
+	}
+
+	// Children are matched against the declarations in order, each taking
+	// as many consecutive elements of its name as it allows.
+	i := 0
+	for _, child := range e.children {
+		count := 0
+		for i < len(n.children) && n.children[i].name.Local == child.name && (child.max == 0 || count < child.max) {
+			if err := check(n.children[i], child, namespace, path+"/"+child.name); err != nil {
+				return err
+			}
+			i++
+			count++
+		}
+		if count < child.min {
+			return fmt.Errorf("%s: missing %s", path, child.name)
+		}
+	}
+	if i < len(n.children) {
+		return fmt.Errorf("%s: unexpected element %s", path, n.children[i].name.Local)
+	}
+	return nil
+}
+
+func hasAttr(n *node, name string) bool {
+	for _, a := range n.attrs {
+		if a.Name.Local == name {
+			return true
+		}
+	}
+	return false
+}
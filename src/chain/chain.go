@@ -0,0 +1,167 @@
+// Package chain makes transaction_logs tamper-evident. Every entry carries a
+// SHA-256 hash over its contents and the hash of the entry before it, so
+// editing, removing or reordering a recorded transfer breaks every later
+// link. Signed checkpoints pin the chain at points in time, so even a
+// rewrite of the whole chain after a checkpoint is detected by Verify.
+package chain
+
+import (
+	"Ledger/pkg/db"
+	"Ledger/pkg/migrate"
+	"Ledger/src/models"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Tables are the tables the chain and its checkpoints are kept in, for
+// migrate verify.
+var Tables = []migrate.Table{
+	{Name: "ledger_chain_head", Columns: []string{"id", "log_id", "length", "hash"}},
+	{Name: "ledger_checkpoints", Columns: []string{"id", "log_id", "length", "hash", "signature", "created_at"}},
+}
+
+// version is part of every hash so the encoding can change without
+// ambiguity.
+const version = "v1"
+
+// Conn runs statements. *sql.DB, *sql.Tx and GORM's connection pool all
+// satisfy it.
+type Conn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// ActionCheckpoint is the audit action recorded when a checkpoint is
+// requested.
+const ActionCheckpoint = "chain.checkpoint"
+
+// Store checks the chain and signs checkpoints of it. key is the
+// checkpoint signing key; without one no checkpoint can be created and
+// Verify does not authenticate the existing ones.
+type Store struct {
+	db     *sql.DB
+	driver string
+	key    []byte
+}
+
+func NewStore(sqlDB *sql.DB, driver string, key []byte) *Store {
+	return &Store{db: sqlDB, driver: driver, key: key}
+}
+
+// Hash returns the hash of log linked onto prev. The entry's own ID is not
+// part of it: the order is carried by the links.
+func Hash(prev string, log models.TransactionLog) string {
+	// A JSON array keeps the encoding unambiguous whatever the description
+	// contains. Amounts are rendered the way the DECIMAL(10, 2) columns
+	// return them.
+	fields, _ := json.Marshal([]interface{}{
+		version,
+		prev,
+		log.SenderID,
+		log.ReceiverID,
+		cents(log.Amount),
+		log.Description,
+		cents(log.SenderCreditBefore),
+		cents(log.ReceiverCreditBefore),
+		cents(log.SenderCreditAfter),
+		cents(log.ReceiverCreditAfter),
+		log.TransactionDate.UnixMilli(),
+	})
+	sum := sha256.Sum256(fields)
+	return hex.EncodeToString(sum[:])
+}
+
+func cents(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// Append inserts log as the next entry of the chain through conn, which
+// must be the transaction that makes the transfer: the head row stays
+// locked until it commits, so concurrent appends queue up behind each
+// other and the chain order is the commit order. log gets its ID, date and
+// hashes filled in.
+func Append(ctx context.Context, conn Conn, driver string, log *models.TransactionLog) error {
+	normalize(log)
+
+	var length uint64
+	err := conn.QueryRowContext(ctx,
+		"SELECT hash, length FROM ledger_chain_head WHERE id = 1 FOR UPDATE").Scan(&log.PrevHash, &length)
+	if err != nil {
+		return err
+	}
+	log.Hash = Hash(log.PrevHash, *log)
+
+	insert := `INSERT INTO transaction_logs
+		(sender_id, receiver_id, amount, description, sender_credit_before, receiver_credit_before,
+		sender_credit_after, receiver_credit_after, transaction_date, prev_hash, hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	// A credit or debit has one party; the other side is stored as NULL
+	// and read back, and hashed, as zero.
+	sender := party(log.SenderID, log.SenderCreditBefore, log.SenderCreditAfter)
+	receiver := party(log.ReceiverID, log.ReceiverCreditBefore, log.ReceiverCreditAfter)
+	args := []interface{}{
+		sender[0], receiver[0], log.Amount, log.Description,
+		sender[1], receiver[1], sender[2], receiver[2],
+		log.TransactionDate, log.PrevHash, log.Hash,
+	}
+	if driver == "postgres" {
+		var id uint
+		if err := conn.QueryRowContext(ctx, db.Rebind(driver, insert+" RETURNING id"), args...).Scan(&id); err != nil {
+			return err
+		}
+		log.ID = id
+	} else {
+		result, err := conn.ExecContext(ctx, insert, args...)
+		if err != nil {
+			return err
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
+		log.ID = uint(id)
+	}
+
+	_, err = conn.ExecContext(ctx, db.Rebind(driver,
+		"UPDATE ledger_chain_head SET log_id = ?, length = ?, hash = ? WHERE id = 1"),
+		log.ID, length+1, log.Hash)
+	return err
+}
+
+// party returns the id and balances of one side of an entry, all nil when
+// the side has no user.
+func party(id uint, before, after float64) [3]interface{} {
+	if id == 0 {
+		return [3]interface{}{}
+	}
+	return [3]interface{}{id, before, after}
+}
+
+// normalize rounds log to what the database stores, cents and
+// milliseconds in UTC, so the hash computed now matches the row read back
+// later. The balances of a missing party are zeroed for the same reason.
+func normalize(log *models.TransactionLog) {
+	if log.SenderID == 0 {
+		log.SenderCreditBefore, log.SenderCreditAfter = 0, 0
+	}
+	if log.ReceiverID == 0 {
+		log.ReceiverCreditBefore, log.ReceiverCreditAfter = 0, 0
+	}
+	for _, v := range []*float64{
+		&log.Amount,
+		&log.SenderCreditBefore, &log.ReceiverCreditBefore,
+		&log.SenderCreditAfter, &log.ReceiverCreditAfter,
+	} {
+		*v = math.Round(*v*100) / 100
+	}
+	if log.TransactionDate.IsZero() {
+		log.TransactionDate = time.Now()
+	}
+	log.TransactionDate = log.TransactionDate.UTC().Truncate(time.Millisecond)
+}
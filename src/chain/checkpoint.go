@@ -0,0 +1,140 @@
+package chain
+
+import (
+	"Ledger/pkg/db"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrNoSigningKey is returned when a checkpoint is requested without a
+// signing key configured.
+var ErrNoSigningKey = errors.New("no checkpoint signing key configured (LEDGER_CHECKPOINT_KEY)")
+
+// ErrEmpty is returned when a checkpoint is requested before anything was
+// chained.
+var ErrEmpty = errors.New("the chain has no entries yet")
+
+// Checkpoint pins the chain head at LogID. The signature is an HMAC over
+// the position and hash made with a key the database does not hold, so it
+// cannot be recomputed by someone who rewrites the chain.
+type Checkpoint struct {
+	ID        uint64    `json:"id"`
+	LogID     uint64    `json:"log_id"`
+	Length    uint64    `json:"length"`
+	Hash      string    `json:"hash"`
+	Signature string    `json:"signature"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Sign returns the signature of a checkpoint at logID with the given
+// length and hash.
+func Sign(key []byte, logID, length uint64, hash string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(version + ":" + strconv.FormatUint(logID, 10) + ":" + strconv.FormatUint(length, 10) + ":" + hash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Valid reports whether c carries the signature key would give it.
+func (c Checkpoint) Valid(key []byte) bool {
+	return hmac.Equal([]byte(c.Signature), []byte(Sign(key, c.LogID, c.Length, c.Hash)))
+}
+
+// Checkpoint signs the current head of the chain. When the newest
+// checkpoint already covers the head it is returned instead of a duplicate,
+// so the call can be scheduled freely.
+func (s *Store) Checkpoint(ctx context.Context) (*Checkpoint, error) {
+	if len(s.key) == 0 {
+		return nil, ErrNoSigningKey
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Holding the head lock keeps appends out until the checkpoint is
+	// written, so it names an entry that really is the head.
+	var c Checkpoint
+	err = tx.QueryRowContext(ctx,
+		"SELECT log_id, length, hash FROM ledger_chain_head WHERE id = 1 FOR UPDATE").Scan(&c.LogID, &c.Length, &c.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if c.Length == 0 {
+		return nil, ErrEmpty
+	}
+
+	latest, err := latestCheckpoint(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil && latest.LogID == c.LogID {
+		return latest, nil
+	}
+
+	c.Signature = Sign(s.key, c.LogID, c.Length, c.Hash)
+	c.CreatedAt = time.Now().UTC().Truncate(time.Millisecond)
+
+	insert := `INSERT INTO ledger_checkpoints (log_id, length, hash, signature, created_at)
+		VALUES (?, ?, ?, ?, ?)`
+	args := []interface{}{c.LogID, c.Length, c.Hash, c.Signature, c.CreatedAt}
+	if s.driver == "postgres" {
+		err = tx.QueryRowContext(ctx, db.Rebind(s.driver, insert+" RETURNING id"), args...).Scan(&c.ID)
+	} else {
+		var result sql.Result
+		if result, err = tx.ExecContext(ctx, insert, args...); err == nil {
+			var id int64
+			id, err = result.LastInsertId()
+			c.ID = uint64(id)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+const checkpointColumns = "id, log_id, length, hash, signature, created_at"
+
+func latestCheckpoint(ctx context.Context, tx *sql.Tx) (*Checkpoint, error) {
+	var c Checkpoint
+	err := tx.QueryRowContext(ctx, "SELECT "+checkpointColumns+" FROM ledger_checkpoints ORDER BY id DESC LIMIT 1").
+		Scan(&c.ID, &c.LogID, &c.Length, &c.Hash, &c.Signature, &c.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Checkpoints lists every checkpoint, oldest first.
+func (s *Store) Checkpoints(ctx context.Context) ([]Checkpoint, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+checkpointColumns+" FROM ledger_checkpoints ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checkpoints := []Checkpoint{}
+	for rows.Next() {
+		var c Checkpoint
+		if err := rows.Scan(&c.ID, &c.LogID, &c.Length, &c.Hash, &c.Signature, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, c)
+	}
+	return checkpoints, rows.Err()
+}
@@ -0,0 +1,141 @@
+package chain
+
+import (
+	"Ledger/src/models"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Report is the outcome of walking the chain. Broken is the first link that
+// failed, or nil when the whole chain holds.
+type Report struct {
+	// Entries is the number of chained entries checked and Unchained the
+	// number of entries recorded before the chain existed.
+	Entries     int    `json:"entries"`
+	Unchained   int    `json:"unchained"`
+	Checkpoints int    `json:"checkpoints"`
+	Head        string `json:"head"`
+	// SignaturesChecked is false when no signing key was given, in which
+	// case checkpoints are matched against the chain but not authenticated.
+	SignaturesChecked bool   `json:"signatures_checked"`
+	Broken            *Break `json:"broken,omitempty"`
+}
+
+// OK reports whether no broken link was found.
+func (r *Report) OK() bool {
+	return r.Broken == nil
+}
+
+// Break is a broken link: the entry or checkpoint that does not fit and why.
+type Break struct {
+	LogID        uint64 `json:"log_id,omitempty"`
+	CheckpointID uint64 `json:"checkpoint_id,omitempty"`
+	Reason       string `json:"reason"`
+}
+
+func (b *Break) String() string {
+	if b.LogID == 0 && b.CheckpointID != 0 {
+		return fmt.Sprintf("checkpoint %d: %s", b.CheckpointID, b.Reason)
+	}
+	return fmt.Sprintf("entry %d: %s", b.LogID, b.Reason)
+}
+
+// Verify walks transaction_logs in insertion order, recomputing every hash
+// and link, and checks the result against the signed checkpoints and the
+// chain head. It stops at the first broken link. Without a signing key
+// the checkpoint signatures are not checked.
+func (s *Store) Verify(ctx context.Context) (*Report, error) {
+	checkpoints, err := s.Checkpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Checkpoints: len(checkpoints), SignaturesChecked: len(s.key) > 0}
+
+	byLog := make(map[uint64][]Checkpoint, len(checkpoints))
+	for _, c := range checkpoints {
+		if report.SignaturesChecked && !c.Valid(s.key) {
+			report.Broken = &Break{CheckpointID: c.ID, Reason: "signature does not match"}
+			return report, nil
+		}
+		byLog[c.LogID] = append(byLog[c.LogID], c)
+	}
+
+	var head struct {
+		logID, length uint64
+		hash          string
+	}
+	err = s.db.QueryRowContext(ctx, "SELECT log_id, length, hash FROM ledger_chain_head WHERE id = 1").
+		Scan(&head.logID, &head.length, &head.hash)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id, COALESCE(sender_id, 0), COALESCE(receiver_id, 0), amount,
+		COALESCE(description, ''), COALESCE(sender_credit_before, 0), COALESCE(receiver_credit_before, 0),
+		COALESCE(sender_credit_after, 0), COALESCE(receiver_credit_after, 0), transaction_date, prev_hash, hash
+		FROM transaction_logs ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lastID uint64
+	for rows.Next() {
+		var log models.TransactionLog
+		var prevHash, hash sql.NullString
+		if err := rows.Scan(&log.ID, &log.SenderID, &log.ReceiverID, &log.Amount, &log.Description,
+			&log.SenderCreditBefore, &log.ReceiverCreditBefore, &log.SenderCreditAfter, &log.ReceiverCreditAfter,
+			&log.TransactionDate, &prevHash, &hash); err != nil {
+			return nil, err
+		}
+		id := uint64(log.ID)
+
+		if !hash.Valid {
+			if report.Entries == 0 {
+				report.Unchained++
+				continue
+			}
+			report.Broken = &Break{LogID: id, Reason: "entry is not chained"}
+			return report, nil
+		}
+
+		if prevHash.String != report.Head {
+			report.Broken = &Break{LogID: id, Reason: fmt.Sprintf("previous hash %q does not match the hash of the entry before it", prevHash.String)}
+			return report, nil
+		}
+		if Hash(report.Head, log) != hash.String {
+			report.Broken = &Break{LogID: id, Reason: "contents do not match the recorded hash"}
+			return report, nil
+		}
+		report.Head = hash.String
+		report.Entries++
+		lastID = id
+
+		for _, c := range byLog[id] {
+			if c.Hash != report.Head || c.Length != uint64(report.Entries) {
+				report.Broken = &Break{LogID: id, CheckpointID: c.ID, Reason: "entry does not match its checkpoint"}
+				return report, nil
+			}
+			delete(byLog, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, c := range checkpoints {
+		if _, missing := byLog[c.LogID]; missing {
+			report.Broken = &Break{CheckpointID: c.ID, Reason: fmt.Sprintf("checkpointed entry %d is missing", c.LogID)}
+			return report, nil
+		}
+	}
+
+	// Entries removed from the end leave a head that points past the last
+	// entry.
+	if head.hash != report.Head || head.length != uint64(report.Entries) || head.logID != lastID {
+		report.Broken = &Break{LogID: head.logID, Reason: fmt.Sprintf(
+			"chain head (%d entries, ending at entry %d) does not match the %d entries found", head.length, head.logID, report.Entries)}
+	}
+	return report, nil
+}
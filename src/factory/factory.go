@@ -1,13 +1,34 @@
 package factory
 
 import (
+	"Ledger/config"
 	"Ledger/pkg/auth"
+	"Ledger/pkg/buildinfo"
 	"Ledger/pkg/cache"
+	"Ledger/pkg/health"
+	"Ledger/pkg/metrics"
 	"Ledger/pkg/middleware"
+	"Ledger/pkg/migrate"
+	"Ledger/pkg/ratelimit"
+	"Ledger/src/audit"
+	"Ledger/src/balance"
+	"Ledger/src/chain"
+	"Ledger/src/grpcserver"
 	"Ledger/src/handlers"
+	"Ledger/src/idempotency"
+	"Ledger/src/lockout"
+	"Ledger/src/openapi"
+	"Ledger/src/reconcile"
 	"Ledger/src/repository"
 	"Ledger/src/services"
+	"Ledger/src/statement"
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"time"
 
+	"google.golang.org/grpc"
 	"gorm.io/gorm"
 )
 
@@ -16,45 +37,243 @@ type Factory interface {
 	NewUserService() services.UserService
 	NewUserRepository() repository.UserRepository
 	NewAuthMiddleware() middleware.AuthMiddleware
+	NewJWTService() auth.JWTService
 	NewRedisCache() *cache.RedisCache
+	NewAuditHandler() *handlers.AuditHandler
+	NewChainHandler() *handlers.ChainHandler
+	NewReconciler() *reconcile.Reconciler
+	NewReconcileHandler() *handlers.ReconcileHandler
+	NewBalanceStore() *balance.Store
+	NewBalanceHandler() *handlers.BalanceHandler
+	NewStatementGenerator() *statement.Generator
+	NewStatementHandler() *handlers.StatementHandler
+	NewExportHandler() *handlers.ExportHandler
+	NewMetricsHandler() http.Handler
+	NewHealthHandler() *handlers.HealthHandler
+	NewRateLimitMiddleware() middleware.RateLimitMiddleware
+	NewClientAddressMiddleware() func(http.Handler) http.Handler
+	NewIdempotencyMiddleware() middleware.IdempotencyMiddleware
+	NewLockoutHandler() *handlers.LockoutHandler
+	NewAPIDocument() *openapi.Document
+	NewGRPCServer() *grpc.Server
 }
 
 type factory struct {
-	db             *gorm.DB
+	cfg            *config.Config
+	started        time.Time
+	db             *sql.DB
+	driver         string
+	newRepository  func() repository.UserRepository
 	jwtService     auth.JWTService
 	authMiddleware middleware.AuthMiddleware
 	redisCache     *cache.RedisCache
+	auditStore     *audit.SQLStore
+	chainStore     *chain.Store
+	reconciler     *reconcile.Reconciler
+	balanceStore   *balance.Store
+	statements     *statement.Generator
+	lockouts       *lockout.Store
+	limiter        ratelimit.Limiter
 }
 
-func NewFactory(db *gorm.DB) Factory {
-	jwtService := auth.NewJWTService()
+// NewFactory wires the application on top of the GORM/MySQL storage adapter
+// with the Redis credit cache.
+func NewFactory(db *gorm.DB, cfg *config.Config) Factory {
+	redisCache := cache.NewRedisCache(cfg.Redis)
+	f := newFactory(cfg, func() repository.UserRepository {
+		return repository.NewUserRepository(db, redisCache)
+	})
+	f.redisCache = redisCache
+	// gorm.Open always hands out a *sql.DB; the error is for custom pools.
+	if sqlDB, err := db.DB(); err == nil {
+		f.db, f.driver = sqlDB, "mysql"
+		metrics.RegisterDB(sqlDB, "ledger")
+		f.auditStore = audit.NewSQLStore(sqlDB, "mysql")
+		f.chainStore = chain.NewStore(sqlDB, "mysql", []byte(cfg.Ledger.CheckpointKey))
+		f.reconciler = reconcile.New(sqlDB, redisCache)
+		f.balanceStore = balance.NewStore(sqlDB, "mysql")
+		f.statements = statement.NewGenerator(sqlDB, "mysql", f.balanceStore)
+		f.lockouts = lockout.NewStore(sqlDB, "mysql", cfg.Lockout)
+	}
+	return f
+}
+
+// NewPostgresFactory wires the application on top of the database/sql
+// Postgres storage adapter used by the lambda. It has no Redis cache.
+func NewPostgresFactory(db *sql.DB, cfg *config.Config) Factory {
+	f := newFactory(cfg, func() repository.UserRepository {
+		return repository.NewPostgresUserRepository(db)
+	})
+	f.db, f.driver = db, "postgres"
+	metrics.RegisterDB(db, "ledger")
+	f.auditStore = audit.NewSQLStore(db, "postgres")
+	f.chainStore = chain.NewStore(db, "postgres", []byte(cfg.Ledger.CheckpointKey))
+	f.reconciler = reconcile.New(db, nil)
+	f.balanceStore = balance.NewStore(db, "postgres")
+	f.statements = statement.NewGenerator(db, "postgres", f.balanceStore)
+	f.lockouts = lockout.NewStore(db, "postgres", cfg.Lockout)
+	return f
+}
+
+func newFactory(cfg *config.Config, newRepository func() repository.UserRepository) *factory {
+	jwtService := auth.NewJWTService(cfg.JWT)
 	authMiddleware := middleware.NewAuthMiddleware(jwtService)
-	redisCache := cache.NewRedisCache("localhost:6379", "", 0)
 
 	return &factory{
-		db:             db,
+		cfg:            cfg,
+		started:        time.Now(),
+		newRepository:  newRepository,
 		jwtService:     jwtService,
 		authMiddleware: authMiddleware,
-		redisCache:     redisCache,
 	}
 }
 
 func (f *factory) NewUserHandler() *handlers.UserHandler {
-	return handlers.NewUserHandler(f.NewUserService(), f.jwtService)
+	return handlers.NewUserHandler(f.NewUserService(), f.jwtService, f.lockouts, f.cfg.Limits)
 }
 
 func (f *factory) NewUserService() services.UserService {
-	return services.NewUserService(f.NewUserRepository())
+	return services.Traced(services.NewUserService(f.NewUserRepository()))
 }
 
 func (f *factory) NewUserRepository() repository.UserRepository {
-	return repository.NewUserRepository(f.db, f.redisCache)
+	return repository.Traced(f.newRepository())
 }
 
 func (f *factory) NewAuthMiddleware() middleware.AuthMiddleware {
 	return f.authMiddleware
 }
 
+func (f *factory) NewJWTService() auth.JWTService {
+	return f.jwtService
+}
+
+// NewRedisCache returns nil when the storage adapter runs without a cache.
 func (f *factory) NewRedisCache() *cache.RedisCache {
 	return f.redisCache
 }
+
+func (f *factory) NewAuditHandler() *handlers.AuditHandler {
+	return handlers.NewAuditHandler(f.auditStore)
+}
+
+func (f *factory) NewChainHandler() *handlers.ChainHandler {
+	return handlers.NewChainHandler(f.chainStore, f.auditStore)
+}
+
+func (f *factory) NewReconciler() *reconcile.Reconciler {
+	return f.reconciler
+}
+
+func (f *factory) NewReconcileHandler() *handlers.ReconcileHandler {
+	return handlers.NewReconcileHandler(f.reconciler, f.auditStore)
+}
+
+func (f *factory) NewBalanceStore() *balance.Store {
+	return f.balanceStore
+}
+
+func (f *factory) NewBalanceHandler() *handlers.BalanceHandler {
+	return handlers.NewBalanceHandler(f.balanceStore)
+}
+
+func (f *factory) NewStatementGenerator() *statement.Generator {
+	return f.statements
+}
+
+func (f *factory) NewStatementHandler() *handlers.StatementHandler {
+	return handlers.NewStatementHandler(f.statements)
+}
+
+func (f *factory) NewExportHandler() *handlers.ExportHandler {
+	return handlers.NewExportHandler(f.statements, f.cfg.Ledger.Currency)
+}
+
+// NewMetricsHandler returns nil when metrics.enabled is off.
+func (f *factory) NewMetricsHandler() http.Handler {
+	if !f.cfg.Metrics.Enabled {
+		return nil
+	}
+	return metrics.Handler()
+}
+
+// NewHealthHandler checks the database, its migrations and, on MySQL, the
+// Redis cache, each within its own timeout.
+func (f *factory) NewHealthHandler() *handlers.HealthHandler {
+	var checks []health.Check
+	if f.db != nil {
+		checks = append(checks,
+			health.Check{Name: "database", Critical: true, Timeout: f.cfg.Health.DBTimeout, Run: f.db.PingContext},
+			health.Check{Name: "migrations", Critical: true, Timeout: f.cfg.Health.MigrationsTimeout, Run: f.pendingMigrations},
+		)
+	}
+	if f.redisCache != nil {
+		checks = append(checks, health.Check{Name: "redis", Critical: f.cfg.Redis.Critical, Timeout: f.cfg.Health.RedisTimeout, Run: f.redisCache.Ping})
+	}
+	return handlers.NewHealthHandler(health.NewChecker(checks...), f.started)
+}
+
+// NewRateLimitMiddleware counts requests in Redis when there is a cache, so
+// every instance shares the limits, and in memory otherwise.
+func (f *factory) NewRateLimitMiddleware() middleware.RateLimitMiddleware {
+	return middleware.NewRateLimitMiddleware(f.rateLimiter(), f.cfg.RateLimit)
+}
+
+// NewClientAddressMiddleware resolves the client address for the audit
+// trail by the same rule as the rate limits.
+func (f *factory) NewClientAddressMiddleware() func(http.Handler) http.Handler {
+	return middleware.ClientAddress(f.cfg.RateLimit.TrustForwardedFor)
+}
+
+// NewIdempotencyMiddleware keeps the responses for retries in the
+// database, so a retry reaching another instance is answered the same.
+func (f *factory) NewIdempotencyMiddleware() middleware.IdempotencyMiddleware {
+	store := idempotency.NewStore(f.db, f.driver, f.cfg.Idempotency)
+	return middleware.NewIdempotencyMiddleware(store, f.cfg.Limits.MaxRequestBodyBytes)
+}
+
+// rateLimiter returns the one limiter of the HTTP and gRPC APIs, so both
+// take from the same buckets even when they are kept in memory.
+func (f *factory) rateLimiter() ratelimit.Limiter {
+	if f.limiter == nil {
+		f.limiter = ratelimit.NewMemory()
+		if f.redisCache != nil {
+			f.limiter = ratelimit.NewRedis(f.redisCache.Client(), "ratelimit:", f.limiter)
+		}
+	}
+	return f.limiter
+}
+
+func (f *factory) NewLockoutHandler() *handlers.LockoutHandler {
+	return handlers.NewLockoutHandler(f.lockouts, f.NewUserService(), f.auditStore)
+}
+
+// NewAPIDocument returns an empty OpenAPI document for the router to
+// describe its routes in.
+func (f *factory) NewAPIDocument() *openapi.Document {
+	return openapi.New("Ledger API", buildinfo.Version, f.cfg.Limits)
+}
+
+// NewGRPCServer returns the gRPC API, served by the same services as the
+// HTTP handlers and rate limited with the same buckets.
+func (f *factory) NewGRPCServer() *grpc.Server {
+	server := grpcserver.NewServer(f.NewUserService(), f.jwtService, f.lockouts, f.cfg.Limits, f.cfg.GRPC.WatchInterval)
+	return grpcserver.New(server, f.rateLimiter(), f.cfg.RateLimit)
+}
+
+// pendingMigrations fails while the schema lags behind the migrations
+// built into the binary.
+func (f *factory) pendingMigrations(ctx context.Context) error {
+	migrator, err := migrate.ForDriver(f.db, f.driver)
+	if err != nil {
+		return err
+	}
+	pending, err := migrator.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d migrations pending", pending)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package grpcserver
+
+import (
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/logging"
+	"context"
+	"log/slog"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+)
+
+// ErrorDomain is the domain of the google.rpc.ErrorInfo of every error.
+const ErrorDomain = "ledger"
+
+// grpcCodes maps the error codes of the API to gRPC codes. Codes that are
+// missing are Unknown.
+var grpcCodes = map[apperr.Code]codes.Code{
+	apperr.CodeInvalidRequest:       codes.InvalidArgument,
+	apperr.CodeValidationFailed:     codes.InvalidArgument,
+	apperr.CodeInvalidAmount:        codes.InvalidArgument,
+	apperr.CodeRequestTooLarge:      codes.ResourceExhausted,
+	apperr.CodeUnauthorized:         codes.Unauthenticated,
+	apperr.CodeInvalidCredentials:   codes.Unauthenticated,
+	apperr.CodeForbidden:            codes.PermissionDenied,
+	apperr.CodeNotFound:             codes.NotFound,
+	apperr.CodeUserNotFound:         codes.NotFound,
+	apperr.CodeMethodNotAllowed:     codes.Unimplemented,
+	apperr.CodeEmailTaken:           codes.AlreadyExists,
+	apperr.CodeConflict:             codes.FailedPrecondition,
+	apperr.CodeInsufficientFunds:    codes.FailedPrecondition,
+	apperr.CodeIdempotencyKeyReused: codes.FailedPrecondition,
+	apperr.CodeRequestInProgress:    codes.Aborted,
+	apperr.CodeRateLimited:          codes.ResourceExhausted,
+	apperr.CodeLoginLocked:          codes.ResourceExhausted,
+	apperr.CodeInternal:             codes.Internal,
+	apperr.CodeUnavailable:          codes.Unavailable,
+}
+
+// toStatus converts err into a gRPC status error the way response.Error
+// writes a problem: the code of the HTTP API goes in an ErrorInfo, invalid
+// fields in a BadRequest, and server errors hide their cause, which is
+// logged instead.
+func toStatus(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	e := apperr.From(err)
+	if e.Status >= 500 {
+		slog.ErrorContext(ctx, "Request failed", "error", err)
+	}
+	code, ok := grpcCodes[e.Code]
+	if !ok {
+		code = codes.Unknown
+	}
+
+	info := &errdetails.ErrorInfo{Reason: string(e.Code), Domain: ErrorDomain}
+	if id := logging.RequestID(ctx); id != "" {
+		info.Metadata = map[string]string{"request_id": id}
+	}
+	details := []protoadapt.MessageV1{info}
+	if len(e.Fields) > 0 {
+		violations := make([]*errdetails.BadRequest_FieldViolation, len(e.Fields))
+		for i, f := range e.Fields {
+			violations[i] = &errdetails.BadRequest_FieldViolation{Field: f.Field, Description: f.Message}
+		}
+		details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+	}
+
+	st := status.New(code, e.Message)
+	if detailed, err := st.WithDetails(details...); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
@@ -0,0 +1,210 @@
+package grpcserver
+
+import (
+	ledgerv1 "Ledger/api/ledger/v1"
+	"Ledger/config"
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/auth"
+	"Ledger/pkg/logging"
+	"Ledger/pkg/metrics"
+	"Ledger/pkg/middleware"
+	"Ledger/pkg/ratelimit"
+	"context"
+	"log/slog"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Metadata keys read from calls, the gRPC forms of the HTTP headers.
+const (
+	requestIDKey   = "x-request-id"
+	auditReasonKey = "x-audit-reason"
+)
+
+// access is who may call a method.
+type access int
+
+const (
+	public access = iota
+	user
+	admin
+)
+
+// methods lists the access of every method. Methods missing from it are
+// refused, so a new RPC cannot be served unprotected by mistake.
+var methods = map[string]access{
+	ledgerv1.Ledger_CreateUser_FullMethodName:         public,
+	ledgerv1.Ledger_Login_FullMethodName:              public,
+	ledgerv1.Ledger_GetUser_FullMethodName:            user,
+	ledgerv1.Ledger_ListUsers_FullMethodName:          admin,
+	ledgerv1.Ledger_GetBalance_FullMethodName:         user,
+	ledgerv1.Ledger_ListBalances_FullMethodName:       admin,
+	ledgerv1.Ledger_WatchBalance_FullMethodName:       user,
+	ledgerv1.Ledger_Transfer_FullMethodName:           user,
+	ledgerv1.Ledger_ListTransactions_FullMethodName:   user,
+	ledgerv1.Ledger_AddCredit_FullMethodName:          admin,
+	ledgerv1.Ledger_BatchUpdateCredits_FullMethodName: admin,
+}
+
+// policies lists the methods that are rate limited. They take from the
+// same buckets as their HTTP routes, so switching APIs gains nothing.
+var policies = map[string]string{
+	ledgerv1.Ledger_CreateUser_FullMethodName: middleware.PolicySignup,
+	ledgerv1.Ledger_Login_FullMethodName:      middleware.PolicyLogin,
+	ledgerv1.Ledger_Transfer_FullMethodName:   middleware.PolicyTransfer,
+}
+
+// interceptors give every call a request ID, authenticate it with the
+// bearer token of its authorization metadata, rate limit it, convert its
+// error into a status and log it, as the HTTP middleware does for requests.
+type interceptors struct {
+	jwt     auth.JWTService
+	limiter ratelimit.Limiter
+	cfg     config.RateLimitConfig
+}
+
+func (i *interceptors) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	ctx = i.begin(ctx)
+
+	ctx, err := i.authenticate(ctx, info.FullMethod)
+	if err == nil {
+		err = i.rateLimit(ctx, info.FullMethod)
+	}
+	var resp interface{}
+	if err == nil {
+		resp, err = handler(ctx, req)
+	}
+	err = toStatus(ctx, err)
+	i.end(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+func (i *interceptors) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx := i.begin(ss.Context())
+
+	ctx, err := i.authenticate(ctx, info.FullMethod)
+	if err == nil {
+		err = handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+	err = toStatus(ctx, err)
+	i.end(ctx, info.FullMethod, start, err)
+	return err
+}
+
+// begin takes the request ID of the call, or makes one up, and sends it
+// back in the response header.
+func (i *interceptors) begin(ctx context.Context) context.Context {
+	id := firstValue(ctx, requestIDKey)
+	if !middleware.ValidRequestID(id) {
+		id = logging.NewRequestID()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDKey, id))
+	return logging.WithRequestID(ctx, id)
+}
+
+func (i *interceptors) end(ctx context.Context, method string, start time.Time, err error) {
+	slog.InfoContext(ctx, "gRPC request", "method", method, "code", status.Code(err).String(), "duration", time.Since(start))
+}
+
+// authenticate puts the claims of the bearer token into ctx, as
+// middleware.Authenticate does.
+func (i *interceptors) authenticate(ctx context.Context, method string) (context.Context, error) {
+	required, ok := methods[method]
+	if !ok {
+		return ctx, apperr.ErrNotFound.WithMessage("Unknown method %s", method)
+	}
+	if required == public {
+		return ctx, nil
+	}
+
+	token, found := strings.CutPrefix(firstValue(ctx, "authorization"), "Bearer ")
+	if !found || token == "" {
+		return ctx, apperr.ErrUnauthorized.WithMessage("A bearer token is required")
+	}
+	claims, err := i.jwt.ValidateToken(token)
+	if err != nil {
+		slog.InfoContext(ctx, "Token rejected", "error", err)
+		return ctx, apperr.ErrUnauthorized.WithMessage("Invalid token: %v", err)
+	}
+	if required == admin && !i.jwt.IsAdmin(claims) {
+		return ctx, apperr.ErrForbidden.WithMessage("Admin privileges required")
+	}
+	return middleware.SetUserInContext(ctx, claims), nil
+}
+
+// rateLimit takes a token from the bucket of the method's policy, by user
+// for transfers and by peer address otherwise, and refuses the call when
+// there is none. A limiter error lets the call through.
+func (i *interceptors) rateLimit(ctx context.Context, method string) error {
+	policy, ok := policies[method]
+	if !ok || !i.cfg.Enabled {
+		return nil
+	}
+
+	var limit ratelimit.Limit
+	key := "ip:" + peerIP(ctx)
+	switch policy {
+	case middleware.PolicyLogin:
+		limit = ratelimit.Limit{Requests: i.cfg.LoginRequests, Window: i.cfg.LoginWindow}
+	case middleware.PolicySignup:
+		limit = ratelimit.Limit{Requests: i.cfg.SignupRequests, Window: i.cfg.SignupWindow}
+	case middleware.PolicyTransfer:
+		limit = ratelimit.Limit{Requests: i.cfg.TransferRequests, Window: i.cfg.TransferWindow}
+		if claims := middleware.GetUserFromContext(ctx); claims != nil {
+			key = "user:" + strconv.FormatUint(uint64(claims.UserID), 10)
+		}
+	}
+
+	result, err := i.limiter.Allow(ctx, policy+":"+key, limit)
+	if err != nil {
+		slog.WarnContext(ctx, "Rate limit not checked", "policy", policy, "error", err)
+		return nil
+	}
+	if !result.Allowed {
+		retry := int64(math.Ceil(result.RetryAfter.Seconds()))
+		grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.FormatInt(retry, 10)))
+		metrics.ObserveRateLimited(policy)
+		slog.InfoContext(ctx, "Request rate limited", "policy", policy, "retry_after", retry)
+		return apperr.ErrRateLimited.WithMessage("Too many requests, retry in %d seconds", retry)
+	}
+	return nil
+}
+
+// peerIP returns the address of the client without its port.
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func firstValue(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// serverStream is a stream with the context of the interceptors.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
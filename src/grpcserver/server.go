@@ -0,0 +1,352 @@
+// Package grpcserver serves the gRPC API of api/ledger/v1 on top of the
+// same services as the HTTP handlers, with the same authentication,
+// validation and error codes.
+package grpcserver
+
+import (
+	ledgerv1 "Ledger/api/ledger/v1"
+	"Ledger/config"
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/auth"
+	"Ledger/pkg/logging"
+	"Ledger/pkg/middleware"
+	"Ledger/pkg/ratelimit"
+	"Ledger/src/audit"
+	"Ledger/src/models"
+	"Ledger/src/services"
+	"Ledger/src/validation"
+	"context"
+	"log/slog"
+	"math"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// LoginGuard locks accounts out of login after repeated failures.
+type LoginGuard interface {
+	LockedUntil(ctx context.Context, email string) (time.Time, error)
+	Fail(ctx context.Context, email string) (time.Time, error)
+	Reset(ctx context.Context, email string) error
+}
+
+// Server implements ledgerv1.LedgerServer.
+type Server struct {
+	ledgerv1.UnimplementedLedgerServer
+	users         services.UserService
+	jwt           auth.JWTService
+	lockouts      LoginGuard
+	limits        config.LimitsConfig
+	validator     *validation.Validator
+	watchInterval time.Duration
+}
+
+func NewServer(users services.UserService, jwtService auth.JWTService, lockouts LoginGuard, limits config.LimitsConfig, watchInterval time.Duration) *Server {
+	return &Server{
+		users:         users,
+		jwt:           jwtService,
+		lockouts:      lockouts,
+		limits:        limits,
+		validator:     validation.New(limits),
+		watchInterval: watchInterval,
+	}
+}
+
+// New returns a gRPC server serving s behind the interceptors, which rate
+// limit calls with limiter.
+func New(s *Server, limiter ratelimit.Limiter, rateLimits config.RateLimitConfig) *grpc.Server {
+	i := &interceptors{jwt: s.jwt, limiter: limiter, cfg: rateLimits}
+	g := grpc.NewServer(grpc.UnaryInterceptor(i.unary), grpc.StreamInterceptor(i.stream))
+	ledgerv1.RegisterLedgerServer(g, s)
+	return g
+}
+
+// Request structs carry the rules of package validation, named as the
+// fields of the protobuf messages.
+
+type transferRequest struct {
+	SenderID   uint    `json:"sender_id" validate:"required"`
+	ReceiverID uint    `json:"receiver_id" validate:"required,nefield=SenderID"`
+	Amount     float64 `json:"amount" validate:"amount"`
+}
+
+type creditRequest struct {
+	UserID uint    `json:"user_id" validate:"required"`
+	Amount float64 `json:"amount" validate:"amount"`
+}
+
+type batchRequest struct {
+	Entries []models.BatchTransaction `json:"entries" validate:"required,batch"`
+}
+
+func (s *Server) CreateUser(ctx context.Context, req *ledgerv1.CreateUserRequest) (*ledgerv1.CreateUserResponse, error) {
+	r := models.RegisterRequest{Name: req.Name, Surname: req.Surname, Age: int(req.Age), Email: req.Email, Password: req.Password}
+	if err := s.validator.Struct(&r); err != nil {
+		return nil, err
+	}
+
+	user := &models.User{Name: r.Name, Surname: r.Surname, Age: r.Age, Email: r.Email, Password: r.Password}
+	if err := s.users.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return &ledgerv1.CreateUserResponse{UserId: uint32(user.ID)}, nil
+}
+
+// Login returns a token. An email that failed too often in a row is refused
+// until its lockout ends, as on POST /login.
+func (s *Server) Login(ctx context.Context, req *ledgerv1.LoginRequest) (*ledgerv1.LoginResponse, error) {
+	r := models.LoginRequest{Email: req.Email, Password: req.Password}
+	if err := s.validator.Struct(&r); err != nil {
+		return nil, err
+	}
+
+	until, err := s.lockouts.LockedUntil(ctx, r.Email)
+	if err != nil {
+		return nil, apperr.ErrInternal.WithMessage("Failed to check the login").Wrap(err)
+	}
+	if !until.IsZero() {
+		retry := int64(math.Ceil(time.Until(until).Seconds()))
+		return nil, apperr.ErrLoginLocked.WithMessage("Too many failed logins, try again in %d seconds", retry)
+	}
+
+	user, err := s.users.GetUserByEmail(ctx, r.Email)
+	if err == nil {
+		err = s.users.ValidatePassword(ctx, user, r.Password)
+	}
+	if err != nil {
+		if until, err := s.lockouts.Fail(ctx, r.Email); err != nil {
+			slog.WarnContext(ctx, "Failed login not counted", "error", err)
+		} else if !until.IsZero() {
+			slog.WarnContext(ctx, "Login locked out", "email", r.Email, "locked_until", until)
+		}
+		return nil, apperr.ErrInvalidCredentials.WithMessage("Invalid credentials")
+	}
+	if err := s.lockouts.Reset(ctx, r.Email); err != nil {
+		slog.WarnContext(ctx, "Failed logins not cleared", "user_id", user.ID, "error", err)
+	}
+
+	token, err := s.jwt.GenerateToken(user.ID, user.Email, user.Role == models.RoleAdmin)
+	if err != nil {
+		return nil, apperr.ErrInternal.WithMessage("Failed to generate token").Wrap(err)
+	}
+	return &ledgerv1.LoginResponse{Token: token}, nil
+}
+
+func (s *Server) GetUser(ctx context.Context, req *ledgerv1.GetUserRequest) (*ledgerv1.User, error) {
+	if err := own(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+	user, err := s.users.WithAudit(auditEvent(ctx)).GetUserByID(ctx, uint(req.UserId))
+	if err != nil {
+		return nil, err
+	}
+	return toUser(*user), nil
+}
+
+func (s *Server) ListUsers(ctx context.Context, req *ledgerv1.ListUsersRequest) (*ledgerv1.ListUsersResponse, error) {
+	users, err := s.users.WithAudit(auditEvent(ctx)).GetAllUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resp := &ledgerv1.ListUsersResponse{Users: make([]*ledgerv1.User, len(users))}
+	for i, u := range users {
+		resp.Users[i] = toUser(u)
+	}
+	return resp, nil
+}
+
+func (s *Server) GetBalance(ctx context.Context, req *ledgerv1.GetBalanceRequest) (*ledgerv1.Balance, error) {
+	if err := own(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+	credit, err := s.users.GetUserCredit(ctx, uint(req.UserId))
+	if err != nil {
+		return nil, err
+	}
+	return &ledgerv1.Balance{UserId: req.UserId, Balance: credit, At: timestamppb.Now()}, nil
+}
+
+func (s *Server) ListBalances(ctx context.Context, req *ledgerv1.ListBalancesRequest) (*ledgerv1.ListBalancesResponse, error) {
+	if len(req.UserIds) > s.limits.MaxBatchSize {
+		return nil, apperr.ErrInvalidRequest.WithMessage("Batch size exceeds the limit of %d", s.limits.MaxBatchSize)
+	}
+
+	users := s.users.WithAudit(auditEvent(ctx))
+	var accounts []models.User
+	var err error
+	if len(req.UserIds) == 0 {
+		accounts, err = users.GetAllCredits(ctx)
+	} else {
+		ids := make([]uint, len(req.UserIds))
+		for i, id := range req.UserIds {
+			ids[i] = uint(id)
+		}
+		accounts, err = users.GetMultipleUserCredits(ctx, ids)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := timestamppb.Now()
+	resp := &ledgerv1.ListBalancesResponse{Balances: make([]*ledgerv1.Balance, len(accounts))}
+	for i, a := range accounts {
+		resp.Balances[i] = &ledgerv1.Balance{UserId: uint32(a.ID), Balance: a.Credit, At: now}
+	}
+	return resp, nil
+}
+
+// WatchBalance sends the balance, then checks it every watch interval and
+// sends it again whenever it changed, until the client cancels the call or
+// the server stops.
+func (s *Server) WatchBalance(req *ledgerv1.WatchBalanceRequest, stream ledgerv1.Ledger_WatchBalanceServer) error {
+	ctx := stream.Context()
+	if err := own(ctx, req.UserId); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(s.watchInterval)
+	defer ticker.Stop()
+	last := math.NaN()
+	for {
+		credit, err := s.users.GetUserCredit(ctx, uint(req.UserId))
+		if err != nil {
+			return err
+		}
+		if credit != last {
+			if err := stream.Send(&ledgerv1.Balance{UserId: req.UserId, Balance: credit, At: timestamppb.Now()}); err != nil {
+				return err
+			}
+			last = credit
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Transfer moves credit. Users may only send from their own account, as on
+// POST /v1/transfers.
+func (s *Server) Transfer(ctx context.Context, req *ledgerv1.TransferRequest) (*ledgerv1.TransferResponse, error) {
+	r := transferRequest{SenderID: uint(req.SenderId), ReceiverID: uint(req.ReceiverId), Amount: req.Amount}
+	if err := s.validator.Struct(&r); err != nil {
+		return nil, err
+	}
+	if err := own(ctx, req.SenderId); err != nil {
+		return nil, apperr.ErrForbidden.WithMessage("Transfers may only be sent from your own account")
+	}
+
+	if err := s.users.SendCredit(ctx, r.SenderID, r.ReceiverID, r.Amount); err != nil {
+		return nil, err
+	}
+	return &ledgerv1.TransferResponse{}, nil
+}
+
+func (s *Server) ListTransactions(ctx context.Context, req *ledgerv1.ListTransactionsRequest) (*ledgerv1.ListTransactionsResponse, error) {
+	if err := own(ctx, req.UserId); err != nil {
+		return nil, err
+	}
+
+	var logs []models.TransactionLog
+	var err error
+	if req.Date != "" {
+		if _, err := time.Parse("2006-01-02", req.Date); err != nil {
+			return nil, apperr.ErrInvalidRequest.WithMessage("Invalid date format. Use YYYY-MM-DD")
+		}
+		logs, err = s.users.GetTransactionLogsBySenderAndDate(ctx, uint(req.UserId), req.Date)
+	} else {
+		logs, err = s.users.GetTransactionLogsByUser(ctx, uint(req.UserId))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &ledgerv1.ListTransactionsResponse{Transactions: make([]*ledgerv1.Transaction, len(logs))}
+	for i, l := range logs {
+		resp.Transactions[i] = &ledgerv1.Transaction{
+			Id:                  uint64(l.ID),
+			SenderId:            uint32(l.SenderID),
+			ReceiverId:          uint32(l.ReceiverID),
+			Amount:              l.Amount,
+			Description:         l.Description,
+			SenderCreditAfter:   l.SenderCreditAfter,
+			ReceiverCreditAfter: l.ReceiverCreditAfter,
+			TransactionDate:     timestamppb.New(l.TransactionDate),
+		}
+	}
+	return resp, nil
+}
+
+func (s *Server) AddCredit(ctx context.Context, req *ledgerv1.AddCreditRequest) (*ledgerv1.AddCreditResponse, error) {
+	r := creditRequest{UserID: uint(req.UserId), Amount: req.Amount}
+	if err := s.validator.Struct(&r); err != nil {
+		return nil, err
+	}
+	if err := s.users.WithAudit(auditEvent(ctx)).AddCredit(ctx, r.UserID, r.Amount); err != nil {
+		return nil, err
+	}
+	return &ledgerv1.AddCreditResponse{}, nil
+}
+
+func (s *Server) BatchUpdateCredits(ctx context.Context, req *ledgerv1.BatchUpdateCreditsRequest) (*ledgerv1.BatchUpdateCreditsResponse, error) {
+	r := batchRequest{Entries: make([]models.BatchTransaction, len(req.Entries))}
+	for i, e := range req.Entries {
+		r.Entries[i] = models.BatchTransaction{UserID: uint(e.UserId), Amount: e.Amount}
+	}
+	if err := s.validator.Struct(&r); err != nil {
+		return nil, err
+	}
+
+	results := s.users.WithAudit(auditEvent(ctx)).ProcessBatchCreditUpdate(ctx, r.Entries)
+	resp := &ledgerv1.BatchUpdateCreditsResponse{Results: make([]*ledgerv1.CreditResult, len(results))}
+	for i, res := range results {
+		resp.Results[i] = &ledgerv1.CreditResult{
+			UserId:  uint32(res.UserID),
+			Amount:  res.Amount,
+			Success: res.Success,
+			Error:   res.Error,
+			Code:    string(res.Code),
+		}
+	}
+	return resp, nil
+}
+
+// own checks that the caller is the user of userID or an admin.
+func own(ctx context.Context, userID uint32) error {
+	claims := middleware.GetUserFromContext(ctx)
+	if claims == nil || (!claims.IsAdmin && claims.UserID != uint(userID)) {
+		return apperr.ErrForbidden
+	}
+	return nil
+}
+
+// auditEvent describes who is behind the call for the audit trail, as
+// handlers do for requests.
+func auditEvent(ctx context.Context) audit.Event {
+	e := audit.Event{
+		RequestID: logging.RequestID(ctx),
+		IP:        peerIP(ctx),
+		Reason:    firstValue(ctx, auditReasonKey),
+	}
+	if claims := middleware.GetUserFromContext(ctx); claims != nil {
+		id := claims.UserID
+		e.ActorID = &id
+		e.Actor = claims.Email
+	}
+	return e
+}
+
+func toUser(u models.User) *ledgerv1.User {
+	return &ledgerv1.User{
+		Id:      uint32(u.ID),
+		Name:    u.Name,
+		Surname: u.Surname,
+		Age:     int32(u.Age),
+		Email:   u.Email,
+		Role:    u.Role,
+		Credit:  u.Credit,
+	}
+}
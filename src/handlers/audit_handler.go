@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/logging"
+	"Ledger/pkg/middleware"
+	"Ledger/pkg/response"
+	"Ledger/src/audit"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AuditReasonHeader carries the operator's justification for a privileged
+// request into the audit trail.
+const AuditReasonHeader = "X-Audit-Reason"
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// AuditStore is what the audit endpoints need from the audit trail.
+type AuditStore interface {
+	audit.Recorder
+	audit.Querier
+	Export(ctx context.Context, f audit.Filter, format string, w io.Writer) error
+}
+
+type AuditHandler struct {
+	store AuditStore
+}
+
+func NewAuditHandler(store AuditStore) *AuditHandler {
+	return &AuditHandler{store: store}
+}
+
+// auditEvent describes who is behind r for the audit trail: the
+// authenticated user, the request ID, the client address and the reason
+// header.
+func auditEvent(r *http.Request) audit.Event {
+	e := audit.Event{
+		RequestID: logging.RequestID(r.Context()),
+		IP:        middleware.ClientIPFromContext(r),
+		Reason:    r.Header.Get(AuditReasonHeader),
+	}
+	if claims := middleware.GetUserFromContext(r.Context()); claims != nil {
+		id := claims.UserID
+		e.ActorID = &id
+		e.Actor = claims.Email
+	}
+	return e
+}
+
+// ListEvents answers GET /admin/audit-events, newest first. It filters on
+// actor_id, actor, action, target, request_id, from and to (RFC 3339) and
+// pages with limit and offset.
+func (h *AuditHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("%v", err))
+		return
+	}
+	filter.Newest = true
+
+	filter.Limit = defaultAuditLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 || limit > maxAuditLimit {
+			response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("limit must be between 1 and %d", maxAuditLimit))
+			return
+		}
+		filter.Limit = limit
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid offset"))
+			return
+		}
+		filter.Offset = offset
+	}
+
+	events, err := h.store.Query(r.Context(), filter)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(events)
+}
+
+// ExportEvents answers GET /admin/audit-events/export with every matching
+// event in recording order, as CSV (the default) or newline-delimited JSON
+// with format=ndjson. The export itself is audited.
+func (h *AuditHandler) ExportEvents(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseAuditFilter(r)
+	if err != nil {
+		response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("%v", err))
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = audit.FormatCSV
+	}
+	if format != audit.FormatCSV && format != audit.FormatNDJSON {
+		response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("format must be csv or ndjson"))
+		return
+	}
+
+	e := auditEvent(r)
+	e.Action = audit.ActionExport
+	e.Target = "audit_events"
+	e.After = r.URL.RawQuery
+	if err := h.store.Record(r.Context(), e); err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	name := "audit-events-" + time.Now().UTC().Format("20060102T150405Z") + "." + format
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+
+	if format == audit.FormatNDJSON {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	} else {
+		w.Header().Set("Content-Type", "text/csv")
+	}
+
+	// Headers go out with the first row, so a failure after that can only
+	// cut the export short.
+	h.store.Export(r.Context(), filter, format, w)
+}
+
+func parseAuditFilter(r *http.Request) (audit.Filter, error) {
+	q := r.URL.Query()
+	f := audit.Filter{
+		Actor:     q.Get("actor"),
+		Action:    q.Get("action"),
+		Target:    q.Get("target"),
+		RequestID: q.Get("request_id"),
+	}
+
+	if v := q.Get("actor_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return f, errors.New("Invalid actor_id")
+		}
+		actorID := uint(id)
+		f.ActorID = &actorID
+	}
+	for name, dst := range map[string]*time.Time{"from": &f.From, "to": &f.To} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, fmt.Errorf("Invalid %s, use RFC 3339 such as 2024-03-01T00:00:00Z", name)
+			}
+			*dst = t
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/middleware"
+	"Ledger/pkg/response"
+	"Ledger/src/balance"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// defaultSeriesDays is the length of a series requested without from.
+	defaultSeriesDays = 30
+	// maxSeriesDays bounds the number of points one series request returns.
+	maxSeriesDays = 366
+)
+
+// BalanceStore is what the balance endpoints need from the balance
+// history.
+type BalanceStore interface {
+	At(ctx context.Context, userID uint, at time.Time) (*balance.Point, error)
+	Daily(ctx context.Context, userID uint, from, to time.Time) ([]balance.Day, error)
+}
+
+type BalanceHandler struct {
+	store BalanceStore
+}
+
+func NewBalanceHandler(store BalanceStore) *BalanceHandler {
+	return &BalanceHandler{store: store}
+}
+
+// GetBalance answers GET /accounts/{id}/balance?at=<timestamp> with the
+// balance the account had at that time: an RFC 3339 timestamp, or a date,
+// which stands for the end of that UTC day. Without at it is the balance
+// now.
+func (h *BalanceHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
+	userID, ok := accountID(w, r)
+	if !ok {
+		return
+	}
+
+	at := time.Now()
+	if value := r.URL.Query().Get("at"); value != "" {
+		var err error
+		if at, err = balance.ParseTime(value); err != nil {
+			response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid at: %v", err))
+			return
+		}
+	}
+
+	point, err := h.store.At(r.Context(), userID, at)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(point)
+}
+
+// GetBalances answers GET /accounts/{id}/balances?from&to&interval=day with
+// the balance at the end of every day from from to to, both dates
+// included. to defaults to today and from to 30 days before it.
+func (h *BalanceHandler) GetBalances(w http.ResponseWriter, r *http.Request) {
+	userID, ok := accountID(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	if interval := query.Get("interval"); interval != "" && interval != "day" {
+		response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Unsupported interval %q, only day is supported", interval))
+		return
+	}
+	to := balance.StartOfDay(time.Now())
+	if value := query.Get("to"); value != "" {
+		var err error
+		if to, err = parseDay(value); err != nil {
+			response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid to: %v", err))
+			return
+		}
+	}
+	from := to.AddDate(0, 0, 1-defaultSeriesDays)
+	if value := query.Get("from"); value != "" {
+		var err error
+		if from, err = parseDay(value); err != nil {
+			response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid from: %v", err))
+			return
+		}
+	}
+	if from.After(to) {
+		response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("from must not be after to"))
+		return
+	}
+	if days := int(to.Sub(from).Hours()/24) + 1; days > maxSeriesDays {
+		response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("At most %d days can be requested at once", maxSeriesDays))
+		return
+	}
+
+	days, err := h.store.Daily(r.Context(), userID, from, to)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BalanceSeries{
+		UserID:   userID,
+		Interval: "day",
+		From:     from.Format("2006-01-02"),
+		To:       to.Format("2006-01-02"),
+		Balances: days,
+	})
+}
+
+// BalanceSeries is the body of the balance series response.
+type BalanceSeries struct {
+	UserID   uint          `json:"user_id"`
+	Interval string        `json:"interval"`
+	From     string        `json:"from"`
+	To       string        `json:"to"`
+	Balances []balance.Day `json:"balances"`
+}
+
+// accountID reads the {id} of the path. Users may only read their own
+// account; admins may read any.
+func accountID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 32)
+	if err != nil {
+		response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid user ID"))
+		return 0, false
+	}
+	claims := middleware.GetUserFromContext(r.Context())
+	if claims == nil || (!claims.IsAdmin && claims.UserID != uint(id)) {
+		response.Error(w, r, apperr.ErrForbidden)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func parseDay(value string) (time.Time, error) {
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a YYYY-MM-DD date", value)
+	}
+	return day, nil
+}
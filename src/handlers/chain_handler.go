@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/response"
+	"Ledger/src/audit"
+	"Ledger/src/chain"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ChainStore is what the chain endpoints need from the transaction chain.
+type ChainStore interface {
+	Verify(ctx context.Context) (*chain.Report, error)
+	Checkpoint(ctx context.Context) (*chain.Checkpoint, error)
+	Checkpoints(ctx context.Context) ([]chain.Checkpoint, error)
+}
+
+type ChainHandler struct {
+	chain ChainStore
+	audit audit.Recorder
+}
+
+func NewChainHandler(store ChainStore, recorder audit.Recorder) *ChainHandler {
+	return &ChainHandler{chain: store, audit: recorder}
+}
+
+// Verify answers GET /admin/ledger/verify with the verification report. A
+// broken chain is answered with 409 so monitoring can alert on the status
+// alone.
+func (h *ChainHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	report, err := h.chain.Verify(r.Context())
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !report.OK() {
+		w.WriteHeader(http.StatusConflict)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// ListCheckpoints answers GET /admin/ledger/checkpoints, oldest first.
+func (h *ChainHandler) ListCheckpoints(w http.ResponseWriter, r *http.Request) {
+	checkpoints, err := h.chain.Checkpoints(r.Context())
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(checkpoints)
+}
+
+// CreateCheckpoint answers POST /admin/ledger/checkpoints by signing the
+// current chain head. The request is audited.
+func (h *ChainHandler) CreateCheckpoint(w http.ResponseWriter, r *http.Request) {
+	checkpoint, err := h.chain.Checkpoint(r.Context())
+	switch {
+	case errors.Is(err, chain.ErrNoSigningKey):
+		response.Error(w, r, apperr.ErrUnavailable.WithMessage("%v", err))
+		return
+	case errors.Is(err, chain.ErrEmpty):
+		response.Error(w, r, apperr.ErrConflict.WithMessage("%v", err))
+		return
+	case err != nil:
+		response.Error(w, r, err)
+		return
+	}
+
+	e := auditEvent(r)
+	e.Action = chain.ActionCheckpoint
+	e.Target = "ledger_checkpoints"
+	e.After = audit.State(checkpoint)
+	if err := h.audit.Record(r.Context(), e); err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(checkpoint)
+}
@@ -1,18 +0,0 @@
-package handlers
-
-import (
-	"encoding/json"
-	"log"
-	"net/http"
-)
-
-type ErrorResponse struct {
-	Message string `json:"message"`
-}
-
-func WriteErrorResponse(w http.ResponseWriter, statusCode int, message string) {
-	w.WriteHeader(statusCode)
-	if err := json.NewEncoder(w).Encode(ErrorResponse{Message: message}); err != nil {
-		log.Fatalf("Failed to write error response: %v", err)
-	}
-}
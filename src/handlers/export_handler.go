@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/response"
+	"Ledger/src/bankexport"
+	"bytes"
+	"net/http"
+)
+
+type ExportHandler struct {
+	statements StatementGenerator
+	currency   string
+}
+
+func NewExportHandler(statements StatementGenerator, currency string) *ExportHandler {
+	return &ExportHandler{statements: statements, currency: currency}
+}
+
+// GetExport answers GET /accounts/{id}/export with the statement of the
+// same periods as GetStatement, as an ISO 20022 camt.053 message or an OFX
+// file (format, camt053 by default).
+func (h *ExportHandler) GetExport(w http.ResponseWriter, r *http.Request) {
+	userID, ok := accountID(w, r)
+	if !ok {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = bankexport.FormatCAMT053
+	}
+	contentType := bankexport.ContentType(format)
+	if contentType == "" {
+		response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid format, want camt053 or ofx"))
+		return
+	}
+
+	from, to, ok := statementPeriod(w, r)
+	if !ok {
+		return
+	}
+
+	s, err := h.statements.Statement(r.Context(), userID, from, to)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	// An export that fails validation is never sent.
+	var body bytes.Buffer
+	if err := bankexport.Write(&body, s, format, h.currency); err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", `attachment; filename="`+bankexport.FileName(s, format)+`"`)
+	w.Write(body.Bytes())
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"Ledger/pkg/buildinfo"
+	"Ledger/pkg/health"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type HealthHandler struct {
+	checker *health.Checker
+	started time.Time
+}
+
+func NewHealthHandler(checker *health.Checker, started time.Time) *HealthHandler {
+	return &HealthHandler{checker: checker, started: started}
+}
+
+// Live answers GET /healthz as long as the process serves requests. It
+// checks no dependency, so an orchestrator does not restart the server
+// over a database outage.
+func (h *HealthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, http.StatusOK, map[string]string{"status": health.StatusOK})
+}
+
+// Ready answers GET /readyz with 200 while every critical dependency
+// answers, degraded or not, and 503 otherwise. Failures are logged rather
+// than answered, as the endpoint is public.
+func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	report := h.checker.Run(r.Context())
+	for i, c := range report.Checks {
+		if c.Status == health.StatusDown {
+			slog.WarnContext(r.Context(), "Dependency check failed", "check", c.Name, "critical", c.Critical, "error", c.Error)
+			report.Checks[i].Error = ""
+		}
+	}
+	writeHealth(w, readiness(report), report)
+}
+
+// statusResponse is the body of GET /status.
+type statusResponse struct {
+	health.Report
+	Build         buildinfo.Info `json:"build"`
+	StartedAt     time.Time      `json:"started_at"`
+	UptimeSeconds int64          `json:"uptime_seconds"`
+}
+
+// Status answers GET /status, for operators, with the readiness report
+// including the errors of failed checks, the build and the uptime.
+func (h *HealthHandler) Status(w http.ResponseWriter, r *http.Request) {
+	report := h.checker.Run(r.Context())
+	writeHealth(w, readiness(report), statusResponse{
+		Report:        report,
+		Build:         buildinfo.Read(),
+		StartedAt:     h.started.UTC(),
+		UptimeSeconds: int64(time.Since(h.started).Seconds()),
+	})
+}
+
+func readiness(report health.Report) int {
+	if report.Ready() {
+		return http.StatusOK
+	}
+	return http.StatusServiceUnavailable
+}
+
+func writeHealth(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
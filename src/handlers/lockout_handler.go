@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"Ledger/pkg/response"
+	"Ledger/src/audit"
+	"Ledger/src/lockout"
+	"Ledger/src/services"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// LockoutStore is what the lockout endpoints need from the login lockouts.
+type LockoutStore interface {
+	Locked(ctx context.Context) ([]lockout.Lockout, error)
+	Unlock(ctx context.Context, email string) (*lockout.Lockout, error)
+}
+
+type LockoutHandler struct {
+	lockouts LockoutStore
+	users    services.UserService
+	audit    audit.Recorder
+}
+
+func NewLockoutHandler(lockouts LockoutStore, users services.UserService, recorder audit.Recorder) *LockoutHandler {
+	return &LockoutHandler{lockouts: lockouts, users: users, audit: recorder}
+}
+
+// ListLocked answers GET /admin/lockouts with the emails locked out of
+// login now, including emails of no account.
+func (h *LockoutHandler) ListLocked(w http.ResponseWriter, r *http.Request) {
+	lockouts, err := h.lockouts.Locked(r.Context())
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lockouts)
+}
+
+// Unlock answers POST /admin/users/{id}/unlock by clearing the failed
+// logins of the user, locked or not. The request is audited with the
+// lockout it cleared.
+func (h *LockoutHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	userID, ok := accountID(w, r)
+	if !ok {
+		return
+	}
+	user, err := h.users.GetUserByID(r.Context(), userID)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	cleared, err := h.lockouts.Unlock(r.Context(), user.Email)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	e := auditEvent(r)
+	e.Action = lockout.ActionUnlock
+	e.Target = "user:" + strconv.FormatUint(uint64(userID), 10)
+	if cleared != nil {
+		e.Before = audit.State(cleared)
+	}
+	if err := h.audit.Record(r.Context(), e); err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Unlocked{UserID: userID, Unlocked: cleared != nil})
+}
+
+// Unlocked is the body of the unlock response.
+type Unlocked struct {
+	UserID   uint `json:"user_id"`
+	Unlocked bool `json:"unlocked"`
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"Ledger/pkg/response"
+	"Ledger/src/audit"
+	"Ledger/src/reconcile"
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// Reconciler is what the reconciliation endpoints need from the
+// reconciliation job.
+type Reconciler interface {
+	Run(ctx context.Context, opts reconcile.Options) (*reconcile.Report, error)
+}
+
+type ReconcileHandler struct {
+	reconciler Reconciler
+	audit      audit.Recorder
+}
+
+func NewReconcileHandler(reconciler Reconciler, recorder audit.Recorder) *ReconcileHandler {
+	return &ReconcileHandler{reconciler: reconciler, audit: recorder}
+}
+
+// Check answers GET /admin/reconciliation with a discrepancy report and
+// changes nothing. Discrepancies are answered with 409, as a broken chain
+// is.
+func (h *ReconcileHandler) Check(w http.ResponseWriter, r *http.Request) {
+	report, err := h.reconciler.Run(r.Context(), reconcile.Options{})
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	writeReconcileReport(w, report)
+}
+
+// Repair answers POST /admin/reconciliation by reconciling and dropping
+// cached balances that differ from the stored ones. The report lists what
+// was wrong before the repair, and the request is audited with it.
+func (h *ReconcileHandler) Repair(w http.ResponseWriter, r *http.Request) {
+	report, err := h.reconciler.Run(r.Context(), reconcile.Options{RepairCache: true})
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	e := auditEvent(r)
+	e.Action = reconcile.ActionRun
+	e.Target = "users"
+	e.After = audit.State(map[string]int{"discrepancies": len(report.Discrepancies), "cache_repaired": report.CacheRepaired})
+	if err := h.audit.Record(r.Context(), e); err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	writeReconcileReport(w, report)
+}
+
+func writeReconcileReport(w http.ResponseWriter, report *reconcile.Report) {
+	w.Header().Set("Content-Type", "application/json")
+	if !report.OK() {
+		w.WriteHeader(http.StatusConflict)
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/response"
+	"Ledger/src/statement"
+	"bytes"
+	"context"
+	"net/http"
+	"time"
+)
+
+// maxStatementDays bounds the period of one statement request.
+const maxStatementDays = 366
+
+// StatementGenerator is what the statement endpoint needs from the
+// statement generator.
+type StatementGenerator interface {
+	Statement(ctx context.Context, userID uint, from, to time.Time) (*statement.Statement, error)
+}
+
+type StatementHandler struct {
+	statements StatementGenerator
+}
+
+func NewStatementHandler(statements StatementGenerator) *StatementHandler {
+	return &StatementHandler{statements: statements}
+}
+
+// GetStatement answers GET /accounts/{id}/statement with the statement of a
+// month (month=YYYY-MM, the last one by default) or of the days from from to
+// to, as csv, json or pdf (format, json by default).
+func (h *StatementHandler) GetStatement(w http.ResponseWriter, r *http.Request) {
+	userID, ok := accountID(w, r)
+	if !ok {
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = statement.FormatJSON
+	}
+	contentType := statement.ContentType(format)
+	if contentType == "" {
+		response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid format, want csv, json or pdf"))
+		return
+	}
+
+	from, to, ok := statementPeriod(w, r)
+	if !ok {
+		return
+	}
+
+	s, err := h.statements.Statement(r.Context(), userID, from, to)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	// Rendered in full first, so a failure can still be answered with 500.
+	var body bytes.Buffer
+	if err := statement.Write(&body, s, format); err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	if format != statement.FormatJSON {
+		w.Header().Set("Content-Disposition", `attachment; filename="`+statement.FileName(s, format)+`"`)
+	}
+	w.Write(body.Bytes())
+}
+
+// statementPeriod reads the period of a statement request: month=YYYY-MM,
+// from and to as days, or the last month when neither is given. It answers
+// 400 itself when the period is invalid.
+func statementPeriod(w http.ResponseWriter, r *http.Request) (time.Time, time.Time, bool) {
+	query := r.URL.Query()
+	from, to := statement.Month(time.Now().UTC().AddDate(0, -1, 0))
+	switch {
+	case query.Get("month") != "":
+		month, err := time.Parse("2006-01", query.Get("month"))
+		if err != nil {
+			response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid month, want YYYY-MM"))
+			return from, to, false
+		}
+		from, to = statement.Month(month)
+	case query.Get("from") != "" || query.Get("to") != "":
+		var err error
+		if from, err = parseDay(query.Get("from")); err != nil {
+			response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid from: %v", err))
+			return from, to, false
+		}
+		if to, err = parseDay(query.Get("to")); err != nil {
+			response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid to: %v", err))
+			return from, to, false
+		}
+		if from.After(to) {
+			response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("from must not be after to"))
+			return from, to, false
+		}
+		if days := int(to.Sub(from).Hours()/24) + 1; days > maxStatementDays {
+			response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("A statement covers at most %d days", maxStatementDays))
+			return from, to, false
+		}
+	}
+	return from, to, true
+}
@@ -1,31 +1,68 @@
 package handlers
 
 import (
+	"Ledger/config"
+	"Ledger/pkg/apperr"
 	"Ledger/pkg/auth"
+	"Ledger/pkg/middleware"
+	"Ledger/pkg/response"
 	"Ledger/src/models"
 	"Ledger/src/services"
+	"Ledger/src/validation"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// LoginGuard locks accounts out of login after repeated failures.
+type LoginGuard interface {
+	LockedUntil(ctx context.Context, email string) (time.Time, error)
+	Fail(ctx context.Context, email string) (time.Time, error)
+	Reset(ctx context.Context, email string) error
+}
+
 type UserHandler struct {
 	service    services.UserService
 	jwtService auth.JWTService
+	lockouts   LoginGuard
+	limits     config.LimitsConfig
+	validator  *validation.Validator
 }
 
-func NewUserHandler(service services.UserService, jwtService auth.JWTService) *UserHandler {
+func NewUserHandler(service services.UserService, jwtService auth.JWTService, lockouts LoginGuard, limits config.LimitsConfig) *UserHandler {
 	return &UserHandler{
 		service:    service,
 		jwtService: jwtService,
+		lockouts:   lockouts,
+		limits:     limits,
+		validator:  validation.New(limits),
+	}
+}
+
+func (h *UserHandler) decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	return json.NewDecoder(http.MaxBytesReader(w, r.Body, h.limits.MaxRequestBodyBytes)).Decode(v)
+}
+
+// decodeRequest decodes the body into the request struct v and validates
+// it.
+func (h *UserHandler) decodeRequest(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	if err := h.decodeBody(w, r, v); err != nil {
+		return invalidBody(err)
 	}
+	return h.validator.Struct(v)
 }
 
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 	var req models.RegisterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := h.decodeRequest(w, r, &req); err != nil {
+		response.Error(w, r, err)
 		return
 	}
 
@@ -37,22 +74,19 @@ func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 		Password: req.Password,
 	}
 
-	if err := h.service.CreateUser(user); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.service.CreateUser(r.Context(), user); err != nil {
+		response.Error(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "User created successfully",
-		"user_id": user.ID,
-	})
+	json.NewEncoder(w).Encode(UserCreated{Message: "User created successfully", UserID: user.ID})
 }
 
 func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.service.GetAllUsers()
+	users, err := h.service.WithAudit(auditEvent(r)).GetAllUsers(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, err)
 		return
 	}
 
@@ -63,94 +97,226 @@ func (h *UserHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 	idStr := r.URL.Query().Get("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid user ID"))
 		return
 	}
 
-	user, err := h.service.GetUserByID(uint(id))
+	user, err := h.service.WithAudit(auditEvent(r)).GetUserByID(r.Context(), uint(id))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		response.Error(w, r, err)
 		return
 	}
 
 	json.NewEncoder(w).Encode(user)
 }
 
+// GetUser answers GET /v1/users/{id}. Users may only read themselves;
+// admins may read anyone.
+func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := accountID(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.service.WithAudit(auditEvent(r)).GetUserByID(r.Context(), userID)
+	if err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(user)
+}
+
+// Login answers POST /login with a token. An email that failed too often
+// in a row is refused with 429 until its lockout ends, whether or not the
+// password is right.
 func (h *UserHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req models.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := h.decodeRequest(w, r, &req); err != nil {
+		response.Error(w, r, err)
+		return
+	}
+
+	until, err := h.lockouts.LockedUntil(r.Context(), req.Email)
+	if err != nil {
+		response.Error(w, r, apperr.ErrInternal.WithMessage("Failed to check the login").Wrap(err))
+		return
+	}
+	if !until.IsZero() {
+		retry := int64(math.Ceil(time.Until(until).Seconds()))
+		w.Header().Set("Retry-After", strconv.FormatInt(retry, 10))
+		response.Error(w, r, apperr.ErrLoginLocked.WithMessage("Too many failed logins, try again in %d seconds", retry))
 		return
 	}
 
-	user, err := h.service.GetUserByEmail(req.Email)
+	user, err := h.service.GetUserByEmail(r.Context(), req.Email)
+	if err == nil {
+		err = h.service.ValidatePassword(r.Context(), user, req.Password)
+	}
 	if err != nil {
-		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+		h.loginFailed(r, req.Email)
+		response.Error(w, r, apperr.ErrInvalidCredentials.WithMessage("Invalid credentials"))
 		return
 	}
+	if err := h.lockouts.Reset(r.Context(), req.Email); err != nil {
+		slog.WarnContext(r.Context(), "Failed logins not cleared", "user_id", user.ID, "error", err)
+	}
 
-	token, err := h.jwtService.GenerateToken(user.ID, user.Email, user.Role == "admin")
+	token, err := h.jwtService.GenerateToken(user.ID, user.Email, user.Role == models.RoleAdmin)
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		response.Error(w, r, apperr.ErrInternal.WithMessage("Failed to generate token").Wrap(err))
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]string{
-		"token": token,
-	})
+	json.NewEncoder(w).Encode(Token{Token: token})
+}
+
+// loginFailed counts a failed login against email. A failure that cannot
+// be counted is logged; the login is refused either way.
+func (h *UserHandler) loginFailed(r *http.Request, email string) {
+	until, err := h.lockouts.Fail(r.Context(), email)
+	switch {
+	case err != nil:
+		slog.WarnContext(r.Context(), "Failed login not counted", "error", err)
+	case !until.IsZero():
+		slog.WarnContext(r.Context(), "Login locked out", "email", email, "locked_until", until)
+	}
 }
 
 func (h *UserHandler) GetCredit(w http.ResponseWriter, r *http.Request) {
 	idStr := r.URL.Query().Get("id")
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid user ID"))
 		return
 	}
 
-	credit, err := h.service.GetUserCredit(uint(id))
+	credit, err := h.service.GetUserCredit(r.Context(), uint(id))
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusNotFound)
+		response.Error(w, r, err)
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]float64{
-		"credit": credit,
-	})
+	json.NewEncoder(w).Encode(Credit{Credit: credit})
 }
 
+// SendCredit accepts the transfer either as senderId/receiverId/amount query
+// parameters or as a JSON body with sender_id, receiver_id and amount. Like
+// CreateTransfer, it only sends from the caller's own account.
 func (h *UserHandler) SendCredit(w http.ResponseWriter, r *http.Request) {
-	senderIDStr := r.URL.Query().Get("senderId")
-	receiverIDStr := r.URL.Query().Get("receiverId")
-	amountStr := r.URL.Query().Get("amount")
-
-	senderID, err := strconv.ParseUint(senderIDStr, 10, 32)
+	var req TransferRequest
+	var err error
+	if r.URL.Query().Has("senderId") {
+		senderID, err := strconv.ParseUint(r.URL.Query().Get("senderId"), 10, 32)
+		if err != nil {
+			response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid sender ID"))
+			return
+		}
+
+		receiverID, err := strconv.ParseUint(r.URL.Query().Get("receiverId"), 10, 32)
+		if err != nil {
+			response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid receiver ID"))
+			return
+		}
+
+		amount, err := strconv.ParseFloat(r.URL.Query().Get("amount"), 64)
+		if err != nil {
+			response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid amount"))
+			return
+		}
+
+		req = TransferRequest{SenderID: flexibleID(senderID), ReceiverID: flexibleID(receiverID), Amount: amount}
+		err = h.validator.Struct(&req)
+	} else {
+		err = h.decodeRequest(w, r, &req)
+	}
 	if err != nil {
-		http.Error(w, "Invalid sender ID", http.StatusBadRequest)
+		response.Error(w, r, err)
 		return
 	}
 
-	receiverID, err := strconv.ParseUint(receiverIDStr, 10, 32)
-	if err != nil {
-		http.Error(w, "Invalid receiver ID", http.StatusBadRequest)
+	h.transfer(w, r, req)
+}
+
+// CreateTransfer answers POST /v1/transfers. Users may only send from their
+// own account; admins may send from any.
+func (h *UserHandler) CreateTransfer(w http.ResponseWriter, r *http.Request) {
+	var req TransferRequest
+	if err := h.decodeRequest(w, r, &req); err != nil {
+		response.Error(w, r, err)
 		return
 	}
+	h.transfer(w, r, req)
+}
 
-	amount, err := strconv.ParseFloat(amountStr, 64)
-	if err != nil {
-		http.Error(w, "Invalid amount", http.StatusBadRequest)
+// transfer sends req for the authenticated user. Users may only send from
+// their own account; admins may send from any.
+func (h *UserHandler) transfer(w http.ResponseWriter, r *http.Request, req TransferRequest) {
+	claims := middleware.GetUserFromContext(r.Context())
+	if claims == nil || (!claims.IsAdmin && claims.UserID != uint(req.SenderID)) {
+		response.Error(w, r, apperr.ErrForbidden.WithMessage("Transfers may only be sent from your own account"))
 		return
 	}
-
-	if err := h.service.SendCredit(uint(senderID), uint(receiverID), amount); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := h.service.SendCredit(r.Context(), uint(req.SenderID), uint(req.ReceiverID), req.Amount); err != nil {
+		response.Error(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Credit transferred successfully",
-	})
+	json.NewEncoder(w).Encode(Message{Message: "Credit transferred successfully"})
+}
+
+type TransferRequest struct {
+	SenderID   flexibleID `json:"sender_id" validate:"required"`
+	ReceiverID flexibleID `json:"receiver_id" validate:"required,nefield=SenderID"`
+	Amount     float64    `json:"amount" validate:"amount"`
+}
+
+// CreditRequest is the body of POST /v1/accounts/{id}/credits.
+type CreditRequest struct {
+	Amount float64 `json:"amount" validate:"amount"`
+}
+
+type addCreditRequest struct {
+	UserID uint    `json:"id" validate:"required"`
+	Amount float64 `json:"amount" validate:"amount"`
+}
+
+type BatchCreditRequest struct {
+	Transactions []models.BatchTransaction `json:"transactions" validate:"required,batch"`
+}
+
+// Message, UserCreated, Token and Credit are response bodies.
+
+type Message struct {
+	Message string `json:"message"`
+}
+
+type UserCreated struct {
+	Message string `json:"message"`
+	UserID  uint   `json:"user_id"`
+}
+
+type Token struct {
+	Token string `json:"token"`
+}
+
+type Credit struct {
+	Credit float64 `json:"credit"`
+}
+
+// flexibleID accepts a user ID as a JSON number or a numeric string, as
+// clients of the lambda deployment send both.
+type flexibleID uint
+
+func (id *flexibleID) UnmarshalJSON(data []byte) error {
+	raw := strings.Trim(string(data), `"`)
+	v, err := strconv.ParseUint(raw, 10, 32)
+	if err != nil {
+		return fmt.Errorf("invalid user ID %s", data)
+	}
+	*id = flexibleID(v)
+	return nil
 }
 
 func (h *UserHandler) GetTransactionLogsBySenderAndDate(w http.ResponseWriter, r *http.Request) {
@@ -159,18 +325,46 @@ func (h *UserHandler) GetTransactionLogsBySenderAndDate(w http.ResponseWriter, r
 
 	senderID, err := strconv.ParseUint(senderIDStr, 10, 32)
 	if err != nil {
-		http.Error(w, "Invalid sender ID", http.StatusBadRequest)
+		response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid sender ID"))
 		return
 	}
 
 	if _, err := time.Parse("2006-01-02", dateStr); err != nil {
-		http.Error(w, "Invalid date format. Use YYYY-MM-DD", http.StatusBadRequest)
+		response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid date format. Use YYYY-MM-DD"))
 		return
 	}
 
-	logs, err := h.service.GetTransactionLogsBySenderAndDate(uint(senderID), dateStr)
+	logs, err := h.service.GetTransactionLogsBySenderAndDate(r.Context(), uint(senderID), dateStr)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, err)
+		return
+	}
+
+	json.NewEncoder(w).Encode(logs)
+}
+
+// ListTransactions answers GET /v1/accounts/{id}/transactions with the
+// transfers the account sent or received, or only those it sent on date
+// (YYYY-MM-DD) when given.
+func (h *UserHandler) ListTransactions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := accountID(w, r)
+	if !ok {
+		return
+	}
+
+	var logs []models.TransactionLog
+	var err error
+	if date := r.URL.Query().Get("date"); date != "" {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid date format. Use YYYY-MM-DD"))
+			return
+		}
+		logs, err = h.service.GetTransactionLogsBySenderAndDate(r.Context(), userID, date)
+	} else {
+		logs, err = h.service.GetTransactionLogsByUser(r.Context(), userID)
+	}
+	if err != nil {
+		response.Error(w, r, err)
 		return
 	}
 
@@ -183,31 +377,54 @@ func (h *UserHandler) AddCredit(w http.ResponseWriter, r *http.Request) {
 
 	id, err := strconv.ParseUint(idStr, 10, 32)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid user ID"))
 		return
 	}
 
 	amount, err := strconv.ParseFloat(amountStr, 64)
 	if err != nil {
-		http.Error(w, "Invalid amount", http.StatusBadRequest)
+		response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid amount"))
+		return
+	}
+
+	req := addCreditRequest{UserID: uint(id), Amount: amount}
+	if err := h.validator.Struct(&req); err != nil {
+		response.Error(w, r, err)
 		return
 	}
 
-	if err := h.service.AddCredit(uint(id), amount); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	h.addCredit(w, r, req.UserID, req.Amount)
+}
+
+// CreditAccount answers POST /v1/accounts/{id}/credits by adding the amount
+// of the body to the account.
+func (h *UserHandler) CreditAccount(w http.ResponseWriter, r *http.Request) {
+	userID, ok := accountID(w, r)
+	if !ok {
+		return
+	}
+	var req CreditRequest
+	if err := h.decodeRequest(w, r, &req); err != nil {
+		response.Error(w, r, err)
+		return
+	}
+	h.addCredit(w, r, userID, req.Amount)
+}
+
+func (h *UserHandler) addCredit(w http.ResponseWriter, r *http.Request, userID uint, amount float64) {
+	if err := h.service.WithAudit(auditEvent(r)).AddCredit(r.Context(), userID, amount); err != nil {
+		response.Error(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Credit added successfully",
-	})
+	json.NewEncoder(w).Encode(Message{Message: "Credit added successfully"})
 }
 
 func (h *UserHandler) GetAllCredits(w http.ResponseWriter, r *http.Request) {
-	credits, err := h.service.GetAllCredits()
+	credits, err := h.service.WithAudit(auditEvent(r)).GetAllCredits(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, err)
 		return
 	}
 
@@ -216,14 +433,43 @@ func (h *UserHandler) GetAllCredits(w http.ResponseWriter, r *http.Request) {
 
 func (h *UserHandler) GetMultipleUserCredits(w http.ResponseWriter, r *http.Request) {
 	var userIDs []uint
-	if err := json.NewDecoder(r.Body).Decode(&userIDs); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := h.decodeBody(w, r, &userIDs); err != nil {
+		response.Error(w, r, invalidBody(err))
+		return
+	}
+	h.multipleUserCredits(w, r, userIDs)
+}
+
+// ListAccounts answers GET /v1/accounts with the balance of every account,
+// or of the accounts in ids, a comma separated list, when given.
+func (h *UserHandler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	ids := r.URL.Query().Get("ids")
+	if ids == "" {
+		h.GetAllCredits(w, r)
 		return
 	}
 
-	credits, err := h.service.GetMultipleUserCredits(userIDs)
+	var userIDs []uint
+	for _, s := range strings.Split(ids, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(s), 10, 32)
+		if err != nil {
+			response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Invalid user ID %q in ids", s))
+			return
+		}
+		userIDs = append(userIDs, uint(id))
+	}
+	h.multipleUserCredits(w, r, userIDs)
+}
+
+func (h *UserHandler) multipleUserCredits(w http.ResponseWriter, r *http.Request, userIDs []uint) {
+	if len(userIDs) > h.limits.MaxBatchSize {
+		response.Error(w, r, apperr.ErrInvalidRequest.WithMessage("Batch size exceeds the limit of %d", h.limits.MaxBatchSize))
+		return
+	}
+
+	credits, err := h.service.WithAudit(auditEvent(r)).GetMultipleUserCredits(r.Context(), userIDs)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		response.Error(w, r, err)
 		return
 	}
 
@@ -231,15 +477,21 @@ func (h *UserHandler) GetMultipleUserCredits(w http.ResponseWriter, r *http.Requ
 }
 
 func (h *UserHandler) ProcessBatchCreditUpdate(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		Transactions []models.BatchTransaction `json:"transactions"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req BatchCreditRequest
+	if err := h.decodeRequest(w, r, &req); err != nil {
+		response.Error(w, r, err)
 		return
 	}
 
-	results := h.service.ProcessBatchCreditUpdate(req.Transactions)
+	results := h.service.WithAudit(auditEvent(r)).ProcessBatchCreditUpdate(r.Context(), req.Transactions)
 	json.NewEncoder(w).Encode(results)
 }
+
+// invalidBody is the error for a request body that could not be decoded.
+func invalidBody(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return apperr.ErrRequestTooLarge.WithMessage("Request body exceeds %d bytes", tooLarge.Limit)
+	}
+	return apperr.ErrInvalidRequest.WithMessage("Invalid request body")
+}
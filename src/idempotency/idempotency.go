@@ -0,0 +1,133 @@
+// Package idempotency keeps the responses to requests sent with an
+// Idempotency-Key, so a client that lost a response can retry the request
+// without running it twice. A key is claimed before its request runs; a
+// claim that outlives the lease is taken to belong to a crashed request and
+// is given to the next retry. Keys are forgotten after the TTL.
+package idempotency
+
+import (
+	"Ledger/config"
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/db"
+	"Ledger/pkg/middleware"
+	"Ledger/pkg/migrate"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Tables are the tables responses are kept in, for migrate verify.
+var Tables = []migrate.Table{
+	{Name: "idempotency_keys", Columns: []string{"key_hash", "fingerprint", "status_code", "content_type", "body", "created_at"}},
+}
+
+// purgeInterval is how often expired keys are deleted.
+const purgeInterval = time.Minute
+
+// Store keeps keys in the idempotency_keys table.
+type Store struct {
+	db     *sql.DB
+	driver string
+	cfg    config.IdempotencyConfig
+	now    func() time.Time
+
+	mu       sync.Mutex
+	purgedAt time.Time
+}
+
+func NewStore(sqlDB *sql.DB, driver string, cfg config.IdempotencyConfig) *Store {
+	return &Store{db: sqlDB, driver: driver, cfg: cfg, now: time.Now}
+}
+
+func (s *Store) Begin(ctx context.Context, key, fingerprint string) (*middleware.StoredResponse, error) {
+	now := s.now().UTC()
+	s.purge(ctx, now)
+
+	insert := "INSERT IGNORE INTO idempotency_keys (key_hash, fingerprint, created_at) VALUES (?, ?, ?)"
+	if s.driver == "postgres" {
+		insert = "INSERT INTO idempotency_keys (key_hash, fingerprint, created_at) VALUES ($1, $2, $3) ON CONFLICT (key_hash) DO NOTHING"
+	}
+	res, err := s.db.ExecContext(ctx, insert, key, fingerprint, now)
+	if err != nil {
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 1 {
+		return nil, err
+	}
+
+	var stored string
+	var status sql.NullInt64
+	var contentType sql.NullString
+	var body []byte
+	var createdAt time.Time
+	err = s.db.QueryRowContext(ctx, s.rebind("SELECT fingerprint, status_code, content_type, body, created_at FROM idempotency_keys WHERE key_hash = ?"), key).
+		Scan(&stored, &status, &contentType, &body, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Released or purged since the insert failed; a retry claims it.
+		return nil, apperr.ErrRequestInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	expired := now.Sub(createdAt) >= s.cfg.TTL
+	abandoned := !status.Valid && now.Sub(createdAt) >= s.cfg.Lease
+	switch {
+	case expired || abandoned:
+		return nil, s.takeOver(ctx, key, fingerprint, createdAt, now)
+	case stored != fingerprint:
+		return nil, apperr.ErrIdempotencyKeyReused
+	case !status.Valid:
+		return nil, apperr.ErrRequestInProgress
+	}
+	return &middleware.StoredResponse{Status: int(status.Int64), ContentType: contentType.String, Body: body}, nil
+}
+
+// takeOver claims a key whose claim or response has run out, unless another
+// request got there first.
+func (s *Store) takeOver(ctx context.Context, key, fingerprint string, createdAt, now time.Time) error {
+	res, err := s.db.ExecContext(ctx, s.rebind(`UPDATE idempotency_keys
+		SET fingerprint = ?, status_code = NULL, content_type = NULL, body = NULL, created_at = ?
+		WHERE key_hash = ? AND created_at = ?`), fingerprint, now, key, createdAt)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return apperr.ErrRequestInProgress
+	}
+	return nil
+}
+
+func (s *Store) Finish(ctx context.Context, key string, resp middleware.StoredResponse) error {
+	_, err := s.db.ExecContext(ctx, s.rebind("UPDATE idempotency_keys SET status_code = ?, content_type = ?, body = ? WHERE key_hash = ?"),
+		resp.Status, resp.ContentType, resp.Body, key)
+	return err
+}
+
+func (s *Store) Release(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, s.rebind("DELETE FROM idempotency_keys WHERE key_hash = ? AND status_code IS NULL"), key)
+	return err
+}
+
+// purge deletes the keys past the TTL, at most once per purgeInterval. A
+// failure is only logged, as an expired key is taken over anyway.
+func (s *Store) purge(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.purgedAt) < purgeInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.purgedAt = now
+	s.mu.Unlock()
+
+	if _, err := s.db.ExecContext(ctx, s.rebind("DELETE FROM idempotency_keys WHERE created_at < ?"), now.Add(-s.cfg.TTL)); err != nil {
+		slog.WarnContext(ctx, "Expired idempotency keys not deleted", "error", err)
+	}
+}
+
+func (s *Store) rebind(query string) string {
+	return db.Rebind(s.driver, query)
+}
@@ -0,0 +1,115 @@
+// Package integrity checks the ledger tables for states the application
+// should never produce: negative balances, transfers that do not add up and
+// transfers that point at missing users.
+package integrity
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Finding is one row that failed a check.
+type Finding struct {
+	Check  string `json:"check"`
+	Table  string `json:"table"`
+	ID     uint64 `json:"id"`
+	Detail string `json:"detail"`
+}
+
+type check struct {
+	name  string
+	table string
+	// query selects the offending row id and a human readable detail.
+	query string
+}
+
+// Legacy rows written before the transfer columns existed have NULL
+// balances or parties and are skipped rather than reported.
+var checks = []check{
+	{
+		name:  "negative_balance",
+		table: "users",
+		query: `SELECT id, CONCAT('credit ', CAST(credit AS CHAR(32)))
+			FROM users WHERE credit < 0`,
+	},
+	{
+		name:  "non_positive_amount",
+		table: "transaction_logs",
+		query: `SELECT id, CONCAT('amount ', CAST(amount AS CHAR(32)))
+			FROM transaction_logs WHERE amount <= 0`,
+	},
+	{
+		name:  "unknown_sender",
+		table: "transaction_logs",
+		query: `SELECT t.id, CONCAT('sender ', CAST(t.sender_id AS CHAR(32)))
+			FROM transaction_logs t LEFT JOIN users u ON u.id = t.sender_id
+			WHERE t.sender_id IS NOT NULL AND u.id IS NULL`,
+	},
+	{
+		name:  "unknown_receiver",
+		table: "transaction_logs",
+		query: `SELECT t.id, CONCAT('receiver ', CAST(t.receiver_id AS CHAR(32)))
+			FROM transaction_logs t LEFT JOIN users u ON u.id = t.receiver_id
+			WHERE t.receiver_id IS NOT NULL AND u.id IS NULL`,
+	},
+	{
+		name:  "sender_balance_mismatch",
+		table: "transaction_logs",
+		query: `SELECT id, CONCAT(CAST(sender_credit_before AS CHAR(32)), ' - ', CAST(amount AS CHAR(32)),
+				' != ', CAST(sender_credit_after AS CHAR(32)))
+			FROM transaction_logs
+			WHERE sender_credit_before IS NOT NULL AND sender_credit_after IS NOT NULL
+			AND ABS(sender_credit_before - amount - sender_credit_after) >= 0.005`,
+	},
+	{
+		name:  "receiver_balance_mismatch",
+		table: "transaction_logs",
+		query: `SELECT id, CONCAT(CAST(receiver_credit_before AS CHAR(32)), ' + ', CAST(amount AS CHAR(32)),
+				' != ', CAST(receiver_credit_after AS CHAR(32)))
+			FROM transaction_logs
+			WHERE receiver_credit_before IS NOT NULL AND receiver_credit_after IS NOT NULL
+			AND ABS(receiver_credit_before + amount - receiver_credit_after) >= 0.005`,
+	},
+}
+
+// Checks returns the names of the checks Check runs, in order.
+func Checks() []string {
+	names := make([]string, len(checks))
+	for i, c := range checks {
+		names[i] = c.name
+	}
+	return names
+}
+
+// Check runs every check against db and returns what it found. An empty
+// result means the ledger is consistent.
+func Check(ctx context.Context, db *sql.DB) ([]Finding, error) {
+	var findings []Finding
+	for _, c := range checks {
+		found, err := run(ctx, db, c)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.name, err)
+		}
+		findings = append(findings, found...)
+	}
+	return findings, nil
+}
+
+func run(ctx context.Context, db *sql.DB, c check) ([]Finding, error) {
+	rows, err := db.QueryContext(ctx, c.query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var findings []Finding
+	for rows.Next() {
+		f := Finding{Check: c.name, Table: c.table}
+		if err := rows.Scan(&f.ID, &f.Detail); err != nil {
+			return nil, err
+		}
+		findings = append(findings, f)
+	}
+	return findings, rows.Err()
+}
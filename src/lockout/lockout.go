@@ -0,0 +1,221 @@
+// Package lockout locks accounts out of login after repeated failures.
+// Failures are counted by normalised email, whether or not an account has
+// it, so a lockout does not tell an attacker which emails are registered.
+// Every failure past the threshold doubles the lockout, up to a maximum; a
+// successful login or an administrator's unlock clears the count.
+package lockout
+
+import (
+	"Ledger/config"
+	"Ledger/pkg/db"
+	"Ledger/pkg/metrics"
+	"Ledger/pkg/migrate"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Tables are the tables failed logins are counted in, for migrate verify.
+var Tables = []migrate.Table{
+	{Name: "login_failures", Columns: []string{"email", "failures", "last_failure_at", "locked_until"}},
+}
+
+// ActionUnlock is the audit action recorded when an administrator unlocks
+// an account.
+const ActionUnlock = "user.unlock"
+
+// Lockout is the failed logins recorded for an email.
+type Lockout struct {
+	Email         string     `json:"email"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+}
+
+type Store struct {
+	db     *sql.DB
+	driver string
+	cfg    config.LockoutConfig
+	now    func() time.Time
+}
+
+// NewStore returns a store that locks nothing when cfg.Threshold is 0.
+func NewStore(sqlDB *sql.DB, driver string, cfg config.LockoutConfig) *Store {
+	return &Store{db: sqlDB, driver: driver, cfg: cfg, now: time.Now}
+}
+
+// Normalize returns the form emails are counted under.
+func Normalize(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func (s *Store) enabled() bool {
+	return s.cfg.Threshold > 0
+}
+
+// LockedUntil returns the end of the lockout of email, or the zero time
+// when it is not locked.
+func (s *Store) LockedUntil(ctx context.Context, email string) (time.Time, error) {
+	if !s.enabled() {
+		return time.Time{}, nil
+	}
+
+	var until sql.NullTime
+	err := s.db.QueryRowContext(ctx, db.Rebind(s.driver,
+		"SELECT locked_until FROM login_failures WHERE email = ?"), Normalize(email)).Scan(&until)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !until.Valid) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !until.Time.After(s.now()) {
+		return time.Time{}, nil
+	}
+	return until.Time, nil
+}
+
+// Fail records a failed login for email and returns the end of the lockout
+// it caused, or the zero time when it caused none. Failures older than the
+// window are forgotten first.
+func (s *Store) Fail(ctx context.Context, email string) (time.Time, error) {
+	if !s.enabled() {
+		return time.Time{}, nil
+	}
+	email = Normalize(email)
+	now := s.now().UTC()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	// Create the row first, so concurrent failures queue on its lock
+	// instead of racing to insert it.
+	insert := "INSERT IGNORE INTO login_failures (email, failures, last_failure_at) VALUES (?, 0, ?)"
+	if s.driver == "postgres" {
+		insert = "INSERT INTO login_failures (email, failures, last_failure_at) VALUES ($1, 0, $2) ON CONFLICT (email) DO NOTHING"
+	}
+	if _, err := tx.ExecContext(ctx, insert, email, now); err != nil {
+		return time.Time{}, err
+	}
+
+	var failures int
+	var last time.Time
+	if err := tx.QueryRowContext(ctx, db.Rebind(s.driver,
+		"SELECT failures, last_failure_at FROM login_failures WHERE email = ? FOR UPDATE"), email).Scan(&failures, &last); err != nil {
+		return time.Time{}, err
+	}
+	if now.Sub(last) > s.cfg.Window {
+		failures = 0
+	}
+	failures++
+
+	var until sql.NullTime
+	if failures >= s.cfg.Threshold {
+		until = sql.NullTime{Time: now.Add(s.duration(failures)), Valid: true}
+	}
+	if _, err := tx.ExecContext(ctx, db.Rebind(s.driver,
+		"UPDATE login_failures SET failures = ?, last_failure_at = ?, locked_until = ? WHERE email = ?"),
+		failures, now, until, email); err != nil {
+		return time.Time{}, err
+	}
+
+	// Emails that stopped failing would otherwise stay forever.
+	if _, err := tx.ExecContext(ctx, db.Rebind(s.driver,
+		"DELETE FROM login_failures WHERE last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)"),
+		now.Add(-s.cfg.Window), now); err != nil {
+		return time.Time{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return time.Time{}, err
+	}
+	if !until.Valid {
+		return time.Time{}, nil
+	}
+	metrics.ObserveLockout()
+	return until.Time, nil
+}
+
+// duration is the lockout caused by the failures-th failure in a row.
+func (s *Store) duration(failures int) time.Duration {
+	d := s.cfg.Duration
+	for i := s.cfg.Threshold; i < failures && d < s.cfg.MaxDuration; i++ {
+		d *= 2
+	}
+	return min(d, s.cfg.MaxDuration)
+}
+
+// Reset forgets the failed logins of email after a successful one.
+func (s *Store) Reset(ctx context.Context, email string) error {
+	if !s.enabled() {
+		return nil
+	}
+	_, err := s.db.ExecContext(ctx, db.Rebind(s.driver, "DELETE FROM login_failures WHERE email = ?"), Normalize(email))
+	return err
+}
+
+// Unlock forgets the failed logins of email and returns what was recorded,
+// or nil when there was nothing.
+func (s *Store) Unlock(ctx context.Context, email string) (*Lockout, error) {
+	email = Normalize(email)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, db.Rebind(s.driver, `SELECT `+columns+`
+		FROM login_failures WHERE email = ? FOR UPDATE`), email)
+	if err != nil {
+		return nil, err
+	}
+	lockouts, err := scan(rows)
+	if err != nil || len(lockouts) == 0 {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, db.Rebind(s.driver, "DELETE FROM login_failures WHERE email = ?"), email); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &lockouts[0], nil
+}
+
+// Locked returns the emails locked out now, the longest locked first.
+func (s *Store) Locked(ctx context.Context) ([]Lockout, error) {
+	rows, err := s.db.QueryContext(ctx, db.Rebind(s.driver, `SELECT `+columns+`
+		FROM login_failures WHERE locked_until > ? ORDER BY locked_until DESC, email`), s.now().UTC())
+	if err != nil {
+		return nil, err
+	}
+	return scan(rows)
+}
+
+const columns = "email, failures, last_failure_at, locked_until"
+
+func scan(rows *sql.Rows) ([]Lockout, error) {
+	defer rows.Close()
+
+	lockouts := []Lockout{}
+	for rows.Next() {
+		var l Lockout
+		var until sql.NullTime
+		if err := rows.Scan(&l.Email, &l.Failures, &l.LastFailureAt, &until); err != nil {
+			return nil, err
+		}
+		l.LastFailureAt = l.LastFailureAt.UTC()
+		if until.Valid {
+			t := until.Time.UTC()
+			l.LockedUntil = &t
+		}
+		lockouts = append(lockouts, l)
+	}
+	return lockouts, rows.Err()
+}
@@ -2,12 +2,22 @@ package models
 
 import "time"
 
+// TransactionLog records one change of balances. A transfer has both
+// parties; a credit has no sender and a debit no receiver, shown as a zero
+// ID with zero balances.
 type TransactionLog struct {
 	ID                   uint      `json:"id" gorm:"primaryKey"`
 	SenderID             uint      `json:"sender_id"`
 	ReceiverID           uint      `json:"receiver_id"`
-	Amount               float64   `json:"amount"`
-	SenderCreditBefore   float64   `json:"sender_credit_before"`
-	ReceiverCreditBefore float64   `json:"receiver_credit_before"`
+	Amount               float64   `json:"amount" gorm:"type:decimal(10,2)"`
+	Description          string    `json:"description,omitempty"`
+	SenderCreditBefore   float64   `json:"sender_credit_before" gorm:"type:decimal(10,2)"`
+	ReceiverCreditBefore float64   `json:"receiver_credit_before" gorm:"type:decimal(10,2)"`
+	SenderCreditAfter    float64   `json:"sender_credit_after" gorm:"type:decimal(10,2)"`
+	ReceiverCreditAfter  float64   `json:"receiver_credit_after" gorm:"type:decimal(10,2)"`
 	TransactionDate      time.Time `json:"transaction_date"`
-} 
\ No newline at end of file
+	// PrevHash and Hash link the entry into the tamper-evident chain; both
+	// are empty for entries recorded before the chain existed.
+	PrevHash string `json:"prev_hash,omitempty"`
+	Hash     string `json:"hash,omitempty"`
+}
@@ -1,5 +1,13 @@
 package models
 
+import "Ledger/pkg/apperr"
+
+// Roles a user can hold.
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	ID       uint    `json:"id" gorm:"primaryKey"`
 	Name     string  `json:"name"`
@@ -8,30 +16,34 @@ type User struct {
 	Email    string  `json:"email" gorm:"unique"`
 	Password string  `json:"-" gorm:"column:Password_Hash"`
 	Role     string  `json:"role" gorm:"default:user"`
-	Credit   float64 `json:"credit" gorm:"default:0"`
+	Credit   float64 `json:"credit" gorm:"type:decimal(10,2);default:0"`
 }
 
+// Request structs carry the rules of package validation in their validate
+// tags.
+
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
 }
 
 type RegisterRequest struct {
-	Name     string `json:"name"`
-	Surname  string `json:"surname"`
-	Age      int    `json:"age"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Name     string `json:"name" validate:"trim,required,max=100"`
+	Surname  string `json:"surname" validate:"trim,required,max=100"`
+	Age      int    `json:"age" validate:"min=1,max=150"`
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,min=8,max=72"`
 }
 
 type BatchTransaction struct {
-	UserID uint    `json:"user_id"`
-	Amount float64 `json:"amount"`
+	UserID uint    `json:"user_id" validate:"required"`
+	Amount float64 `json:"amount" validate:"delta"`
 }
 
 type BatchTransactionResult struct {
-	Success bool    `json:"success"`
-	UserID  uint    `json:"user_id"`
-	Amount  float64 `json:"amount"`
-	Error   string  `json:"error,omitempty"`
+	Success bool        `json:"success"`
+	UserID  uint        `json:"user_id"`
+	Amount  float64     `json:"amount"`
+	Error   string      `json:"error,omitempty"`
+	Code    apperr.Code `json:"code,omitempty"`
 }
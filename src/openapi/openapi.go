@@ -0,0 +1,332 @@
+// Package openapi builds the OpenAPI 3.1 document of the API from the routes
+// as they are registered, so the document cannot drift from the handlers.
+// Schemas are reflected from the request and response types; the rules in
+// validate tags become schema constraints.
+package openapi
+
+import (
+	"Ledger/config"
+	"Ledger/pkg/response"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Version is the OpenAPI version of the document.
+const Version = "3.1.0"
+
+// Auth is who may call an operation.
+type Auth int
+
+const (
+	Public Auth = iota
+	// User operations take a bearer token.
+	User
+	// Admin operations take the bearer token of an admin.
+	Admin
+)
+
+// Param is a path or query parameter of an operation.
+type Param struct {
+	Name        string
+	In          string // "path", "query" or "header"
+	Description string
+	Required    bool
+	// Type is the JSON schema type, string when empty.
+	Type   string
+	Format string
+}
+
+// Path returns the parameter {name} of the path.
+func Path(name, description string) Param {
+	return Param{Name: name, In: "path", Description: description, Required: true, Type: "integer"}
+}
+
+// Query returns an optional query parameter.
+func Query(name, description string) Param {
+	return Param{Name: name, In: "query", Description: description}
+}
+
+// Operation describes one route. Body and Response are values of the
+// request and response types, nil for none.
+type Operation struct {
+	Method  string
+	Path    string
+	ID      string
+	Summary string
+	Tag     string
+	Auth    Auth
+	Params  []Param
+	Body    interface{}
+	// Status is the status of a successful response, 200 when zero.
+	Status   int
+	Response interface{}
+	// Produces lists the media types of a response other than JSON, such
+	// as the csv of a statement.
+	Produces []string
+	// Idempotent operations take an Idempotency-Key header, under which
+	// retries are answered with the response of the first request.
+	Idempotent bool
+}
+
+// Document is an OpenAPI document. It is safe to add operations while it is
+// served.
+type Document struct {
+	mu         sync.Mutex
+	limits     config.LimitsConfig
+	title      string
+	version    string
+	operations []Operation
+}
+
+func New(title, version string, limits config.LimitsConfig) *Document {
+	return &Document{title: title, version: version, limits: limits}
+}
+
+// Add records op.
+func (d *Document) Add(op Operation) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.operations = append(d.operations, op)
+}
+
+// ServeHTTP answers with the document as JSON.
+func (d *Document) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d.Build())
+}
+
+// Build returns the document as a JSON object.
+func (d *Document) Build() map[string]interface{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s := &schemas{limits: d.limits, components: map[string]interface{}{}}
+	problem := s.of(reflect.TypeOf(response.Problem{}))
+
+	paths := map[string]map[string]interface{}{}
+	for _, op := range d.operations {
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]interface{}{}
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = s.operation(op, problem)
+	}
+
+	return map[string]interface{}{
+		"openapi": Version,
+		"info":    map[string]interface{}{"title": d.title, "version": d.version},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": s.components,
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+// schemas reflects types into schemas, collecting named struct types as
+// components.
+type schemas struct {
+	limits     config.LimitsConfig
+	components map[string]interface{}
+}
+
+func (s *schemas) operation(op Operation, problem map[string]interface{}) map[string]interface{} {
+	o := map[string]interface{}{
+		"operationId": op.ID,
+		"summary":     op.Summary,
+	}
+	if op.Tag != "" {
+		o["tags"] = []string{op.Tag}
+	}
+	switch op.Auth {
+	case User:
+		o["security"] = []map[string][]string{{"bearer": {}}}
+	case Admin:
+		o["security"] = []map[string][]string{{"bearer": {}}}
+		o["description"] = "Admins only."
+	}
+
+	var params []map[string]interface{}
+	allParams := op.Params
+	if op.Idempotent {
+		allParams = append(allParams[:len(allParams):len(allParams)], Param{
+			Name:        "Idempotency-Key",
+			In:          "header",
+			Description: "Key of the request, sent again with its retries. Up to 128 letters, digits or ._:-",
+		})
+	}
+	for _, p := range allParams {
+		schema := map[string]interface{}{"type": "string"}
+		if p.Type != "" {
+			schema["type"] = p.Type
+		}
+		if p.Format != "" {
+			schema["format"] = p.Format
+		}
+		param := map[string]interface{}{"name": p.Name, "in": p.In, "required": p.Required, "schema": schema}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		params = append(params, param)
+	}
+	if params != nil {
+		o["parameters"] = params
+	}
+
+	if op.Body != nil {
+		o["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{"application/json": map[string]interface{}{"schema": s.of(reflect.TypeOf(op.Body))}},
+		}
+	}
+
+	status := op.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	content := map[string]interface{}{}
+	if op.Response != nil {
+		content["application/json"] = map[string]interface{}{"schema": s.of(reflect.TypeOf(op.Response))}
+	}
+	for _, mediaType := range op.Produces {
+		content[mediaType] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+	}
+	if len(content) > 0 {
+		success["content"] = content
+	}
+	o["responses"] = map[string]interface{}{
+		strconv.Itoa(status): success,
+		"default": map[string]interface{}{
+			"description": "A problem details object",
+			"content":     map[string]interface{}{response.ContentTypeProblem: map[string]interface{}{"schema": problem}},
+		},
+	}
+	return o
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// of returns the schema of t; named structs are referenced as components.
+func (s *schemas) of(t reflect.Type) map[string]interface{} {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct && t.Name() != "":
+		name := t.String()
+		if _, ok := s.components[name]; !ok {
+			// Reserved first, as the struct may refer to itself.
+			s.components[name] = nil
+			s.components[name] = s.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return s.object(t)
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	}
+	return map[string]interface{}{}
+}
+
+func (s *schemas) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+
+		schema := s.of(f.Type)
+		if rules := f.Tag.Get("validate"); rules != "" {
+			if s.constrain(schema, f.Type, rules) {
+				required = append(required, name)
+			}
+		} else if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Ptr && f.Type.Kind() != reflect.Interface {
+			// Responses always carry these fields.
+			required = append(required, name)
+		}
+		properties[name] = schema
+	}
+
+	o := map[string]interface{}{"type": "object", "properties": properties}
+	if required != nil {
+		sort.Strings(required)
+		o["required"] = required
+	}
+	return o
+}
+
+// constrain adds the validation rules to schema and reports whether the
+// field is required.
+func (s *schemas) constrain(schema map[string]interface{}, t reflect.Type, rules string) bool {
+	required := false
+	limit := s.limits.MaxAmount
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		n, _ := strconv.ParseFloat(arg, 64)
+		switch name {
+		case "required":
+			required = true
+			if t.Kind() == reflect.Slice {
+				schema["minItems"] = 1
+			}
+		case "email":
+			schema["format"] = "email"
+		case "min", "max":
+			schema[sizeKeyword(t.Kind(), name)] = n
+		case "amount":
+			schema["exclusiveMinimum"] = 0
+			schema["maximum"] = limit
+			schema["multipleOf"] = 0.01
+		case "delta":
+			schema["minimum"] = -limit
+			schema["maximum"] = limit
+			schema["multipleOf"] = 0.01
+			schema["not"] = map[string]interface{}{"const": 0}
+		case "batch":
+			schema["maxItems"] = s.limits.MaxBatchSize
+		}
+	}
+	return required
+}
+
+func sizeKeyword(kind reflect.Kind, rule string) string {
+	switch kind {
+	case reflect.String:
+		return rule + "Length"
+	case reflect.Slice:
+		return rule + "Items"
+	}
+	return map[string]string{"min": "minimum", "max": "maximum"}[rule]
+}
@@ -0,0 +1,117 @@
+package queue
+
+import (
+	"Ledger/config"
+	"Ledger/pkg/apperr"
+	"Ledger/src/audit"
+	"Ledger/src/models"
+	"Ledger/src/services"
+	"Ledger/src/validation"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Command names understood by RegisterUserCommands.
+const (
+	CreateUser  = "create_user"
+	SendCredit  = "send_credit"
+	AddCredit   = "add_credit"
+	BatchUpdate = "batch_update_credits"
+)
+
+type SendCreditPayload struct {
+	SenderID   uint    `json:"sender_id" validate:"required"`
+	ReceiverID uint    `json:"receiver_id" validate:"required,nefield=SenderID"`
+	Amount     float64 `json:"amount" validate:"amount"`
+}
+
+type AddCreditPayload struct {
+	UserID uint    `json:"user_id" validate:"required"`
+	Amount float64 `json:"amount" validate:"amount"`
+}
+
+type BatchUpdatePayload struct {
+	Transactions []models.BatchTransaction `json:"transactions" validate:"required,batch"`
+}
+
+// RegisterUserCommands registers the user and credit commands on p. Payloads
+// are validated like the HTTP requests they mirror.
+func RegisterUserCommands(p *Processor, service services.UserService, limits config.LimitsConfig) {
+	v := validation.New(limits)
+	decode := func(payload json.RawMessage, req interface{}) error {
+		if err := decodePayload(payload, req); err != nil {
+			return err
+		}
+		return v.Struct(req)
+	}
+
+	p.Register(CreateUser, func(ctx context.Context, payload json.RawMessage) error {
+		var req models.RegisterRequest
+		if err := decode(payload, &req); err != nil {
+			return err
+		}
+		return service.CreateUser(ctx, &models.User{
+			Name:     req.Name,
+			Surname:  req.Surname,
+			Age:      req.Age,
+			Email:    req.Email,
+			Password: req.Password,
+		})
+	})
+
+	p.Register(SendCredit, func(ctx context.Context, payload json.RawMessage) error {
+		var req SendCreditPayload
+		if err := decode(payload, &req); err != nil {
+			return err
+		}
+		return service.SendCredit(ctx, req.SenderID, req.ReceiverID, req.Amount)
+	})
+
+	p.Register(AddCredit, func(ctx context.Context, payload json.RawMessage) error {
+		var req AddCreditPayload
+		if err := decode(payload, &req); err != nil {
+			return err
+		}
+		return service.WithAudit(commandActor(ctx)).AddCredit(ctx, req.UserID, req.Amount)
+	})
+
+	p.Register(BatchUpdate, func(ctx context.Context, payload json.RawMessage) error {
+		var req BatchUpdatePayload
+		if err := decode(payload, &req); err != nil {
+			return err
+		}
+
+		// An internal error fails every entry of a batch that was rolled
+		// back, which is retried as a whole. Otherwise the batch committed,
+		// completing the message key with it, and entries that failed on
+		// their data fail again on every delivery.
+		var failed []string
+		for _, r := range service.WithAudit(commandActor(ctx)).ProcessBatchCreditUpdate(ctx, req.Transactions) {
+			if r.Code == apperr.CodeInternal {
+				return fmt.Errorf("batch of %d entries rolled back: %s", len(req.Transactions), r.Error)
+			}
+			if !r.Success {
+				failed = append(failed, fmt.Sprintf("user %d: %s", r.UserID, r.Error))
+			}
+		}
+		if len(failed) > 0 {
+			return Permanent(fmt.Errorf("%d of %d entries failed: %s", len(failed), len(req.Transactions), strings.Join(failed, "; ")))
+		}
+		return nil
+	})
+}
+
+// commandActor attributes privileged commands to the queue; the message key
+// stands in for the request ID.
+func commandActor(ctx context.Context) audit.Event {
+	return audit.Event{Actor: "queue", RequestID: MessageKey(ctx)}
+}
+
+func decodePayload(payload json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(payload, v); err != nil {
+		return Permanent(fmt.Errorf("%w: invalid payload: %v", ErrMalformed, err))
+	}
+	return nil
+}
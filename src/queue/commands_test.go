@@ -0,0 +1,125 @@
+package queue
+
+import (
+	"Ledger/config"
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/testdb"
+	"Ledger/src/audit"
+	"Ledger/src/models"
+	"Ledger/src/repository"
+	"Ledger/src/services"
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// batchService answers batches with results and counts them; the other
+// methods of UserService are not used by the batch command.
+type batchService struct {
+	services.UserService
+	results []models.BatchTransactionResult
+	runs    int
+}
+
+func (s *batchService) WithAudit(e audit.Event) services.UserService { return s }
+
+func (s *batchService) ProcessBatchCreditUpdate(ctx context.Context, transactions []models.BatchTransaction) []models.BatchTransactionResult {
+	s.runs++
+	return s.results
+}
+
+var batch = BatchUpdatePayload{Transactions: []models.BatchTransaction{{UserID: 1, Amount: 5}, {UserID: 2, Amount: 5}}}
+
+func TestBatchRetriesRolledBackBatch(t *testing.T) {
+	service := &batchService{results: []models.BatchTransactionResult{
+		{UserID: 1, Amount: 5, Error: "Update failed", Code: apperr.CodeInternal},
+		{UserID: 2, Amount: 5, Error: "Update failed", Code: apperr.CodeInternal},
+	}}
+	dlq := &recordingDeadLetter{}
+	p := NewProcessor(NewMemoryStore(time.Minute), dlq, 3)
+	RegisterUserCommands(p, service, config.Default().Limits)
+
+	for i := 1; i <= 2; i++ {
+		resp := p.Process(context.Background(), events.SQSEvent{Records: []events.SQSMessage{commandRecord(t, "b1", BatchUpdate, batch, i)}})
+		if len(resp.BatchItemFailures) != 1 {
+			t.Fatalf("delivery %d: got %d failures, want the record back for a retry", i, len(resp.BatchItemFailures))
+		}
+	}
+	if service.runs != 2 || len(dlq.sent) != 0 {
+		t.Errorf("ran %d times and dead-lettered %d, want 2 runs and none", service.runs, len(dlq.sent))
+	}
+}
+
+func TestBatchDeadLettersFailedEntries(t *testing.T) {
+	service := &batchService{results: []models.BatchTransactionResult{
+		{UserID: 1, Amount: 5, Success: true},
+		{UserID: 2, Amount: 5, Error: "User not found", Code: apperr.CodeUserNotFound},
+	}}
+	dlq := &recordingDeadLetter{}
+	p := NewProcessor(NewMemoryStore(time.Minute), dlq, 3)
+	RegisterUserCommands(p, service, config.Default().Limits)
+
+	resp := p.Process(context.Background(), events.SQSEvent{Records: []events.SQSMessage{commandRecord(t, "b1", BatchUpdate, batch, 1)}})
+	if len(resp.BatchItemFailures) != 0 || len(dlq.sent) != 1 {
+		t.Errorf("got %d failures and %d dead letters, want the message dead-lettered at once", len(resp.BatchItemFailures), len(dlq.sent))
+	}
+}
+
+// TestBatchKeepsCommittedKey runs a batch with an entry of an unknown user
+// against each storage adapter. The batch commits its other entries with
+// the message key, so a replay of the dead letter applies nothing again.
+func TestBatchKeepsCommittedKey(t *testing.T) {
+	for _, driver := range testdb.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			sqlDB := testdb.Open(t, driver)
+			var repo repository.UserRepository
+			switch driver {
+			case "mysql":
+				gormDB, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB}), &gorm.Config{})
+				if err != nil {
+					t.Fatalf("open GORM: %v", err)
+				}
+				repo = repository.NewUserRepository(gormDB, nil)
+			case "postgres":
+				repo = repository.NewPostgresUserRepository(sqlDB)
+			}
+			ctx := context.Background()
+			prefix := fmt.Sprintf("queue-%d", time.Now().UnixNano())
+			user := &models.User{Name: prefix, Surname: "Queue", Age: 30, Email: prefix + "@example.com", Password: "$2a$10$queuequeuequeuequeuequeuequeuequeuequeuequeuequeueque"}
+			if err := repo.Create(ctx, user); err != nil {
+				t.Fatal(err)
+			}
+
+			dlq := &recordingDeadLetter{}
+			p := NewProcessor(NewSQLStore(sqlDB, driver, time.Minute), dlq, 3)
+			RegisterUserCommands(p, services.NewUserService(repo), config.Default().Limits)
+			payload := BatchUpdatePayload{Transactions: []models.BatchTransaction{
+				{UserID: user.ID, Amount: 5},
+				{UserID: math.MaxUint32, Amount: 5},
+			}}
+
+			for _, delivery := range []string{"first delivery", "replay"} {
+				resp := p.Process(ctx, events.SQSEvent{Records: []events.SQSMessage{commandRecord(t, prefix, BatchUpdate, payload, 1)}})
+				if len(resp.BatchItemFailures) != 0 {
+					t.Fatalf("%s: got %d failures, want none", delivery, len(resp.BatchItemFailures))
+				}
+			}
+			if len(dlq.sent) != 1 {
+				t.Errorf("dead-lettered %d messages, want 1", len(dlq.sent))
+			}
+			credit, err := repo.GetUserCredit(ctx, user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if credit != 5 {
+				t.Errorf("credit = %v, want 5 applied once", credit)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package queue
+
+import (
+	"Ledger/pkg/tracing"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// ErrMessageNotFound is returned when a message ID is not in the queue.
+var ErrMessageNotFound = errors.New("message not found")
+
+// DeadLetterEntry is a dead-lettered message with what is known about its
+// failure. Envelope is nil when the body could not be parsed; ParseError
+// then says why.
+type DeadLetterEntry struct {
+	Message
+	Envelope   *Envelope
+	ParseError error
+}
+
+func (e DeadLetterEntry) Command() string {
+	if e.Envelope != nil {
+		return e.Envelope.Command
+	}
+	return e.Attributes[AttrCommand]
+}
+
+func (e DeadLetterEntry) FailureReason() string {
+	if reason := e.Attributes[AttrFailureReason]; reason != "" {
+		return reason
+	}
+	if e.ParseError != nil {
+		return e.ParseError.Error()
+	}
+	// Moved by the SQS redrive policy rather than by the processor.
+	return "unknown (redriven by SQS)"
+}
+
+// Inspect parses a dead-lettered message the way the processor does.
+func Inspect(msg Message) DeadLetterEntry {
+	env, err := ParseEnvelope(msg.Body)
+	return DeadLetterEntry{Message: msg, Envelope: env, ParseError: err}
+}
+
+// Scan receives up to max messages from q, paging until the queue returns
+// nothing new. Scanned messages stay hidden for hold.
+func Scan(ctx context.Context, q Queue, max int, hold time.Duration) ([]DeadLetterEntry, error) {
+	seen := make(map[string]bool)
+	var entries []DeadLetterEntry
+	for len(entries) < max {
+		batch, err := q.Receive(ctx, max-len(entries), hold)
+		if err != nil {
+			return nil, err
+		}
+
+		added := 0
+		for _, msg := range batch {
+			if seen[msg.ID] {
+				continue
+			}
+			seen[msg.ID] = true
+			entries = append(entries, Inspect(msg))
+			added++
+		}
+		if added == 0 {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// Find scans q for the message with the given ID.
+func Find(ctx context.Context, q Queue, id string, hold time.Duration) (*DeadLetterEntry, error) {
+	seen := make(map[string]bool)
+	for {
+		batch, err := q.Receive(ctx, 10, hold)
+		if err != nil {
+			return nil, err
+		}
+
+		added := 0
+		for _, msg := range batch {
+			if msg.ID == id {
+				entry := Inspect(msg)
+				return &entry, nil
+			}
+			if !seen[msg.ID] {
+				seen[msg.ID] = true
+				added++
+			}
+		}
+		if added == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrMessageNotFound, id)
+		}
+	}
+}
+
+// SetPayloadField sets a dotted path inside the envelope payload. value is
+// used as JSON when it parses as JSON and as a string otherwise.
+func SetPayloadField(env *Envelope, path, value string) error {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(env.Payload, &payload); err != nil {
+		return fmt.Errorf("payload is not a JSON object: %w", err)
+	}
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		v = value
+	}
+
+	keys := strings.Split(path, ".")
+	node := payload
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := node[key].(map[string]interface{})
+		if !ok {
+			child = make(map[string]interface{})
+			node[key] = child
+		}
+		node = child
+	}
+	node[keys[len(keys)-1]] = v
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	env.Payload = raw
+	return nil
+}
+
+// Replay sends body to target and then deletes entry from its dead-letter
+// queue. The body must parse as an envelope so a replay cannot put another
+// poison message back on the queue.
+func Replay(ctx context.Context, dlq, target Queue, entry DeadLetterEntry, body string) error {
+	if _, err := ParseEnvelope(body); err != nil {
+		return err
+	}
+	// The replay continues the trace of the message that failed.
+	attrs := make(map[string]string)
+	tracing.Inject(tracing.Extract(ctx, propagation.MapCarrier(entry.Attributes)), attrs)
+	if err := target.Send(ctx, Message{Body: body, Attributes: attrs}); err != nil {
+		return fmt.Errorf("sending to target queue: %w", err)
+	}
+	if err := dlq.Delete(ctx, entry.Message); err != nil {
+		return fmt.Errorf("message was replayed but not removed from the dead-letter queue: %w", err)
+	}
+	return nil
+}
+
+// ReplayEnvelope returns the envelope to send when replaying entry. Legacy
+// messages are upgraded to the current version and keep the SQS message ID
+// as their idempotency key.
+func ReplayEnvelope(entry DeadLetterEntry) (*Envelope, error) {
+	if entry.Envelope == nil {
+		return nil, entry.ParseError
+	}
+	env := *entry.Envelope
+	env.Version = EnvelopeVersion
+	if env.ID == "" {
+		env.ID = entry.Attributes[AttrSourceMessageID]
+	}
+	if env.ID == "" {
+		env.ID = entry.ID
+	}
+	return &env, nil
+}
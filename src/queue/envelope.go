@@ -0,0 +1,115 @@
+// Package queue runs commands delivered through SQS. Every message carries
+// an Envelope naming the command and its payload; the Processor dispatches
+// it to the registered handler at most once per envelope ID.
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// EnvelopeVersion is the envelope format written by this code.
+const EnvelopeVersion = 1
+
+// ErrMalformed marks messages that can never be processed as sent.
+var ErrMalformed = errors.New("malformed message")
+
+// Envelope wraps one queued command. ID is the idempotency key; messages
+// with the same ID are applied once.
+type Envelope struct {
+	Version int             `json:"version"`
+	ID      string          `json:"id"`
+	Command string          `json:"command"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// NewEnvelope encodes payload as a current-version envelope for command.
+func NewEnvelope(id, command string, payload interface{}) (*Envelope, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{Version: EnvelopeVersion, ID: id, Command: command, Payload: raw}, nil
+}
+
+// Encode returns the message body for e.
+func (e *Envelope) Encode() (string, error) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// legacyMessage is the format the API Gateway SQS integration wrote before
+// envelopes existed: the proxied request itself.
+type legacyMessage struct {
+	Path       string          `json:"path"`
+	HTTPMethod string          `json:"httpMethod"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// legacyCommands maps the routes legacy messages were sent for.
+var legacyCommands = map[string]string{
+	"POST /users/add-user":             CreateUser,
+	"POST /register":                   CreateUser,
+	"POST /users/send-credit":          SendCredit,
+	"POST /users/add-credit":           AddCredit,
+	"POST /users/batch/update-credits": BatchUpdate,
+}
+
+// ParseEnvelope decodes a message body. Legacy request-shaped messages are
+// accepted as version 0 envelopes without an ID; callers fall back to the
+// SQS message ID for them.
+func ParseEnvelope(body string) (*Envelope, error) {
+	var probe struct {
+		Version    *int   `json:"version"`
+		HTTPMethod string `json:"httpMethod"`
+	}
+	if err := json.Unmarshal([]byte(body), &probe); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	if probe.Version == nil && probe.HTTPMethod != "" {
+		return parseLegacy(body)
+	}
+
+	var env Envelope
+	if err := json.Unmarshal([]byte(body), &env); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	switch {
+	case env.Version < 1 || env.Version > EnvelopeVersion:
+		return nil, fmt.Errorf("%w: unsupported envelope version %d", ErrMalformed, env.Version)
+	case env.Command == "":
+		return nil, fmt.Errorf("%w: command is required", ErrMalformed)
+	case len(env.Payload) == 0:
+		return nil, fmt.Errorf("%w: payload is required", ErrMalformed)
+	}
+	return &env, nil
+}
+
+func parseLegacy(body string) (*Envelope, error) {
+	var msg legacyMessage
+	if err := json.Unmarshal([]byte(body), &msg); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	command, ok := legacyCommands[msg.HTTPMethod+" "+msg.Path]
+	if !ok {
+		return nil, fmt.Errorf("%w: no command for %s %s", ErrMalformed, msg.HTTPMethod, msg.Path)
+	}
+	if len(msg.Body) == 0 {
+		return nil, fmt.Errorf("%w: body is required", ErrMalformed)
+	}
+
+	payload := msg.Body
+	// Some producers sent the body as a JSON string rather than an object.
+	var inner string
+	if err := json.Unmarshal(msg.Body, &inner); err == nil {
+		payload = json.RawMessage(inner)
+	}
+
+	return &Envelope{Version: 0, Command: command, Payload: payload}, nil
+}
@@ -0,0 +1,193 @@
+package queue
+
+import (
+	"Ledger/pkg/db"
+	"Ledger/pkg/migrate"
+	"Ledger/src/audit"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Tables are the tables the store keeps claims in, for migrate verify.
+var Tables = []migrate.Table{
+	{Name: "processed_messages", Columns: []string{"message_key", "command", "status", "claimed_at", "completed_at"}},
+}
+
+var (
+	// ErrInProgress is returned by Claim while another delivery of the same
+	// message still holds an unexpired claim.
+	ErrInProgress = errors.New("message is being processed by another delivery")
+	// ErrAlreadyApplied is returned by CompleteTx when another delivery,
+	// which took over an expired claim, applied the message first.
+	ErrAlreadyApplied = errors.New("message was applied by another delivery")
+)
+
+// IdempotencyStore records which messages have been applied. Claim marks a
+// key as in progress and reports false for keys that already completed; a
+// claim older than the store's lease is taken over, so a crashed delivery
+// does not block its retries forever.
+//
+// CompleteTx marks a key completed through exec, the transaction of the
+// command, so the mark commits with what the command did and a delivery
+// that crashes after the commit cannot be applied again. Complete does the
+// same on its own, for commands that do not run in one transaction.
+type IdempotencyStore interface {
+	Claim(ctx context.Context, key, command string) (bool, error)
+	CompleteTx(ctx context.Context, exec audit.Execer, key string) error
+	Complete(ctx context.Context, key string) error
+	Release(ctx context.Context, key string) error
+}
+
+const (
+	statusProcessing = "processing"
+	statusDone       = "done"
+)
+
+// SQLStore keeps idempotency keys in the processed_messages table.
+type SQLStore struct {
+	db     *sql.DB
+	driver string
+	lease  time.Duration
+}
+
+func NewSQLStore(db *sql.DB, driver string, lease time.Duration) *SQLStore {
+	return &SQLStore{db: db, driver: driver, lease: lease}
+}
+
+func (s *SQLStore) Claim(ctx context.Context, key, command string) (bool, error) {
+	now := time.Now().UTC()
+	_, insertErr := s.db.ExecContext(ctx, s.rebind("INSERT INTO processed_messages (message_key, command, status, claimed_at) VALUES (?, ?, ?, ?)"),
+		key, command, statusProcessing, now)
+	if insertErr == nil {
+		return true, nil
+	}
+
+	var status string
+	var claimedAt time.Time
+	err := s.db.QueryRowContext(ctx, s.rebind("SELECT status, claimed_at FROM processed_messages WHERE message_key = ?"), key).
+		Scan(&status, &claimedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("claiming %s: %w", key, insertErr)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if status == statusDone {
+		return false, nil
+	}
+	if now.Sub(claimedAt) < s.lease {
+		return false, ErrInProgress
+	}
+
+	// Take over the expired claim unless another delivery got there first.
+	res, err := s.db.ExecContext(ctx, s.rebind("UPDATE processed_messages SET claimed_at = ? WHERE message_key = ? AND status = ? AND claimed_at = ?"),
+		now, key, statusProcessing, claimedAt)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, ErrInProgress
+	}
+	return true, nil
+}
+
+// CompleteTx only completes a key still in progress. A delivery that took
+// over the claim meanwhile and completed it first holds the row until it
+// commits, so of two deliveries running at once only one applies.
+func (s *SQLStore) CompleteTx(ctx context.Context, exec audit.Execer, key string) error {
+	res, err := exec.ExecContext(ctx, s.rebind("UPDATE processed_messages SET status = ?, completed_at = ? WHERE message_key = ? AND status = ?"),
+		statusDone, time.Now().UTC(), key, statusProcessing)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAlreadyApplied
+	}
+	return nil
+}
+
+func (s *SQLStore) Complete(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, s.rebind("UPDATE processed_messages SET status = ?, completed_at = ? WHERE message_key = ?"),
+		statusDone, time.Now().UTC(), key)
+	return err
+}
+
+func (s *SQLStore) Release(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, s.rebind("DELETE FROM processed_messages WHERE message_key = ? AND status = ?"),
+		key, statusProcessing)
+	return err
+}
+
+func (s *SQLStore) rebind(query string) string {
+	return db.Rebind(s.driver, query)
+}
+
+// MemoryStore is an in-process IdempotencyStore for local runs.
+type MemoryStore struct {
+	mu    sync.Mutex
+	lease time.Duration
+	keys  map[string]memoryClaim
+}
+
+type memoryClaim struct {
+	status    string
+	claimedAt time.Time
+}
+
+func NewMemoryStore(lease time.Duration) *MemoryStore {
+	return &MemoryStore{lease: lease, keys: make(map[string]memoryClaim)}
+}
+
+func (s *MemoryStore) Claim(ctx context.Context, key, command string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if c, ok := s.keys[key]; ok {
+		if c.status == statusDone {
+			return false, nil
+		}
+		if now.Sub(c.claimedAt) < s.lease {
+			return false, ErrInProgress
+		}
+	}
+	s.keys[key] = memoryClaim{status: statusProcessing, claimedAt: now}
+	return true, nil
+}
+
+// CompleteTx completes key at once, as there is no transaction to join.
+func (s *MemoryStore) CompleteTx(ctx context.Context, exec audit.Execer, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.keys[key]
+	if !ok || c.status != statusProcessing {
+		return ErrAlreadyApplied
+	}
+	s.keys[key] = memoryClaim{status: statusDone, claimedAt: c.claimedAt}
+	return nil
+}
+
+func (s *MemoryStore) Complete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key] = memoryClaim{status: statusDone, claimedAt: s.keys[key].claimedAt}
+	return nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.keys[key]; ok && c.status == statusProcessing {
+		delete(s.keys, key)
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+package queue
+
+import (
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/logging"
+	"Ledger/pkg/tracing"
+	"Ledger/src/audit"
+	"Ledger/src/repository"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HandlerFunc runs one command with its raw payload.
+type HandlerFunc func(ctx context.Context, payload json.RawMessage) error
+
+type messageKeyContextKey struct{}
+
+func withMessageKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, messageKeyContextKey{}, key)
+}
+
+// MessageKey returns the idempotency key of the message a handler is
+// running for, to correlate what the command does with the message.
+func MessageKey(ctx context.Context) string {
+	key, _ := ctx.Value(messageKeyContextKey{}).(string)
+	return key
+}
+
+// DeadLetter receives messages the processor gives up on.
+type DeadLetter interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Attributes set on dead-lettered messages.
+const (
+	AttrFailureReason   = "failure_reason"
+	AttrFailureCode     = "failure_code"
+	AttrCommand         = "command"
+	AttrAttempts        = "attempts"
+	AttrSourceMessageID = "source_message_id"
+)
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as one that retrying cannot fix; the message is
+// dead-lettered on the first failure.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent or is an
+// apperr error of the request, such as an unknown user or an insufficient
+// balance, which a retry would only repeat.
+func IsPermanent(err error) bool {
+	var p permanentError
+	if errors.As(err, &p) {
+		return true
+	}
+	var e *apperr.Error
+	return errors.As(err, &e) && e.Status < http.StatusInternalServerError
+}
+
+// Processor dispatches queued commands. Failed records are reported back to
+// SQS individually so only they are retried; after maxAttempts deliveries,
+// or at once for permanent failures, they are moved to the dead-letter
+// queue with the failure reason attached. Without a dead-letter queue the
+// SQS redrive policy takes over.
+type Processor struct {
+	handlers    map[string]HandlerFunc
+	store       IdempotencyStore
+	deadLetter  DeadLetter
+	maxAttempts int
+}
+
+func NewProcessor(store IdempotencyStore, deadLetter DeadLetter, maxAttempts int) *Processor {
+	return &Processor{
+		handlers:    make(map[string]HandlerFunc),
+		store:       store,
+		deadLetter:  deadLetter,
+		maxAttempts: maxAttempts,
+	}
+}
+
+// Register sets the handler for command, replacing any earlier one.
+func (p *Processor) Register(command string, h HandlerFunc) {
+	p.handlers[command] = h
+}
+
+// Process handles an SQS batch and returns the records that should be
+// retried.
+func (p *Processor) Process(ctx context.Context, event events.SQSEvent) events.SQSEventResponse {
+	var resp events.SQSEventResponse
+	for _, record := range event.Records {
+		if err := p.processTraced(ctx, record); err != nil {
+			slog.WarnContext(ctx, "Queue message failed", "message_id", record.MessageId, "error", err)
+			resp.BatchItemFailures = append(resp.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: record.MessageId})
+		}
+	}
+	return resp
+}
+
+// processRecord returns an error only when the record should be retried.
+func (p *Processor) processRecord(ctx context.Context, record events.SQSMessage) error {
+	attempts, _ := strconv.Atoi(record.Attributes["ApproximateReceiveCount"])
+
+	env, err := ParseEnvelope(record.Body)
+	if err != nil {
+		return p.fail(ctx, record, "", attempts, Permanent(err))
+	}
+
+	trace.SpanFromContext(ctx).SetName("queue.process " + env.Command)
+
+	handler, ok := p.handlers[env.Command]
+	if !ok {
+		return p.fail(ctx, record, env.Command, attempts, Permanent(fmt.Errorf("%w: unknown command %q", ErrMalformed, env.Command)))
+	}
+
+	key := env.ID
+	if key == "" {
+		key = record.MessageId
+	}
+	// The key correlates the logs of the command with the request that
+	// queued it.
+	ctx = logging.WithRequestID(ctx, key)
+
+	claimed, err := p.store.Claim(ctx, key, env.Command)
+	if errors.Is(err, ErrInProgress) {
+		// Another delivery is running the message, which is no failure of
+		// the message; it is retried without counting toward dead-lettering.
+		return err
+	}
+	if err != nil {
+		return p.fail(ctx, record, env.Command, attempts, err)
+	}
+	if !claimed {
+		slog.InfoContext(ctx, "Queue message already processed, skipping", "message_id", record.MessageId, "command", env.Command)
+		return nil
+	}
+
+	// Commands that move money complete the key in their own transaction.
+	// A batch reports a failed hook only as failed entries, so its error is
+	// kept here.
+	completed := false
+	var hookErr error
+	ctx = repository.WithTxHook(withMessageKey(ctx, key), func(ctx context.Context, exec audit.Execer) error {
+		if err := p.store.CompleteTx(ctx, exec, key); err != nil {
+			hookErr = err
+			return err
+		}
+		completed = true
+		return nil
+	})
+
+	if err := handler(ctx, env.Payload); err != nil {
+		if errors.Is(err, ErrAlreadyApplied) || errors.Is(hookErr, ErrAlreadyApplied) {
+			slog.InfoContext(ctx, "Queue message applied by another delivery, skipping", "message_id", record.MessageId, "command", env.Command)
+			return nil
+		}
+		// Release only frees a key still processing. A command that
+		// committed before it failed, like a batch with entries of unknown
+		// users, keeps its key completed, so a replay of the dead letter
+		// skips it instead of applying the committed entries again.
+		if releaseErr := p.store.Release(ctx, key); releaseErr != nil {
+			slog.ErrorContext(ctx, "Releasing idempotency key failed", "key", key, "error", releaseErr)
+		}
+		return p.fail(ctx, record, env.Command, attempts, err)
+	}
+	if completed {
+		return nil
+	}
+
+	if err := p.store.Complete(ctx, key); err != nil {
+		// The command ran and the message is deleted; only a duplicate
+		// delivery after the claim lease could apply it again.
+		slog.ErrorContext(ctx, "Completing idempotency key failed", "key", key, "error", err)
+	}
+	return nil
+}
+
+func (p *Processor) fail(ctx context.Context, record events.SQSMessage, command string, attempts int, cause error) error {
+	if !IsPermanent(cause) && attempts < p.maxAttempts {
+		return cause
+	}
+	if p.deadLetter == nil {
+		return cause
+	}
+
+	msg := Message{
+		ID:   record.MessageId,
+		Body: record.Body,
+		Attributes: map[string]string{
+			AttrFailureReason:   cause.Error(),
+			AttrFailureCode:     string(apperr.From(cause).Code),
+			AttrAttempts:        strconv.Itoa(attempts),
+			AttrSourceMessageID: record.MessageId,
+		},
+	}
+	if command != "" {
+		msg.Attributes[AttrCommand] = command
+	}
+	tracing.Inject(ctx, msg.Attributes)
+	if err := p.deadLetter.Send(ctx, msg); err != nil {
+		return fmt.Errorf("%v (dead-lettering failed: %w)", cause, err)
+	}
+	slog.WarnContext(ctx, "Queue message dead-lettered", "message_id", record.MessageId, "command", command, "error", cause)
+	return nil
+}
@@ -0,0 +1,117 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+type recordingDeadLetter struct{ sent []Message }
+
+func (d *recordingDeadLetter) Send(ctx context.Context, msg Message) error {
+	d.sent = append(d.sent, msg)
+	return nil
+}
+
+func record(t *testing.T, id string, receiveCount int) events.SQSMessage {
+	t.Helper()
+	return commandRecord(t, id, "test", map[string]int{"n": 1}, receiveCount)
+}
+
+func commandRecord(t *testing.T, id, command string, payload interface{}, receiveCount int) events.SQSMessage {
+	t.Helper()
+	env, err := NewEnvelope(id, command, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := env.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events.SQSMessage{
+		MessageId:  "sqs-" + id,
+		Body:       body,
+		Attributes: map[string]string{"ApproximateReceiveCount": strconv.Itoa(receiveCount)},
+	}
+}
+
+func TestProcessorSkipsAppliedMessages(t *testing.T) {
+	store := NewMemoryStore(time.Minute)
+	p := NewProcessor(store, nil, 3)
+	runs := 0
+	p.Register("test", func(ctx context.Context, payload json.RawMessage) error {
+		runs++
+		return nil
+	})
+
+	for i := 1; i <= 2; i++ {
+		resp := p.Process(context.Background(), events.SQSEvent{Records: []events.SQSMessage{record(t, "m1", i)}})
+		if len(resp.BatchItemFailures) != 0 {
+			t.Fatalf("delivery %d failed: %+v", i, resp.BatchItemFailures)
+		}
+	}
+	if runs != 1 {
+		t.Errorf("ran %d times, want once", runs)
+	}
+}
+
+func TestProcessorRetriesInProgressWithoutDeadLettering(t *testing.T) {
+	store := NewMemoryStore(time.Minute)
+	if _, err := store.Claim(context.Background(), "m1", "test"); err != nil {
+		t.Fatal(err)
+	}
+	dlq := &recordingDeadLetter{}
+	p := NewProcessor(store, dlq, 3)
+	p.Register("test", func(ctx context.Context, payload json.RawMessage) error {
+		t.Error("ran a message another delivery holds")
+		return nil
+	})
+
+	// The last attempt would be dead-lettered if it failed.
+	resp := p.Process(context.Background(), events.SQSEvent{Records: []events.SQSMessage{record(t, "m1", 3)}})
+	if len(resp.BatchItemFailures) != 1 {
+		t.Errorf("got %d failures, want the record back for a retry", len(resp.BatchItemFailures))
+	}
+	if len(dlq.sent) != 0 {
+		t.Errorf("dead-lettered %d messages, want none", len(dlq.sent))
+	}
+}
+
+func TestProcessorReleasesFailedMessages(t *testing.T) {
+	store := NewMemoryStore(time.Minute)
+	p := NewProcessor(store, nil, 3)
+	runs := 0
+	p.Register("test", func(ctx context.Context, payload json.RawMessage) error {
+		runs++
+		if runs == 1 {
+			return errors.New("database unavailable")
+		}
+		return nil
+	})
+
+	for i := 1; i <= 2; i++ {
+		p.Process(context.Background(), events.SQSEvent{Records: []events.SQSMessage{record(t, "m1", i)}})
+	}
+	if runs != 2 {
+		t.Errorf("ran %d times, want a retry after the failure", runs)
+	}
+}
+
+func TestMemoryStoreCompleteTxOnce(t *testing.T) {
+	store := NewMemoryStore(time.Minute)
+	ctx := context.Background()
+	if _, err := store.Claim(ctx, "m1", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CompleteTx(ctx, nil, "m1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CompleteTx(ctx, nil, "m1"); !errors.Is(err, ErrAlreadyApplied) {
+		t.Errorf("got %v, want %v", err, ErrAlreadyApplied)
+	}
+}
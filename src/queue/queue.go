@@ -0,0 +1,100 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Message is a queue message with its string attributes.
+type Message struct {
+	ID            string
+	Body          string
+	Attributes    map[string]string
+	ReceiveCount  int
+	ReceiptHandle string
+}
+
+// Queue is the subset of SQS the tooling needs. Messages returned by
+// Receive stay hidden from other consumers for hold and must be deleted
+// through Delete once handled.
+type Queue interface {
+	DeadLetter
+	Receive(ctx context.Context, max int, hold time.Duration) ([]Message, error)
+	Delete(ctx context.Context, msg Message) error
+}
+
+// MemoryQueue is an in-process Queue for local runs and tooling checks.
+type MemoryQueue struct {
+	mu       sync.Mutex
+	nextID   int
+	messages []*memoryMessage
+}
+
+type memoryMessage struct {
+	Message
+	hiddenUntil time.Time
+}
+
+func NewMemoryQueue() *MemoryQueue {
+	return &MemoryQueue{}
+}
+
+func (q *MemoryQueue) Send(ctx context.Context, msg Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.nextID++
+	msg.ID = "mem-" + strconv.Itoa(q.nextID)
+	msg.ReceiveCount = 0
+	msg.ReceiptHandle = ""
+	attrs := make(map[string]string, len(msg.Attributes))
+	for k, v := range msg.Attributes {
+		attrs[k] = v
+	}
+	msg.Attributes = attrs
+	q.messages = append(q.messages, &memoryMessage{Message: msg})
+	return nil
+}
+
+func (q *MemoryQueue) Receive(ctx context.Context, max int, hold time.Duration) ([]Message, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	var out []Message
+	for _, m := range q.messages {
+		if len(out) == max {
+			break
+		}
+		if now.Before(m.hiddenUntil) {
+			continue
+		}
+		m.ReceiveCount++
+		m.ReceiptHandle = m.ID + "#" + strconv.Itoa(m.ReceiveCount)
+		m.hiddenUntil = now.Add(hold)
+		out = append(out, m.Message)
+	}
+	return out, nil
+}
+
+func (q *MemoryQueue) Delete(ctx context.Context, msg Message) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, m := range q.messages {
+		if m.ReceiptHandle == msg.ReceiptHandle {
+			q.messages = append(q.messages[:i], q.messages[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+// Len returns the number of messages in the queue, hidden or not.
+func (q *MemoryQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.messages)
+}
@@ -0,0 +1,90 @@
+package queue
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+// SQSQueue is a Queue backed by one SQS queue.
+type SQSQueue struct {
+	client *sqs.Client
+	url    string
+}
+
+func NewSQSQueue(client *sqs.Client, url string) *SQSQueue {
+	return &SQSQueue{client: client, url: url}
+}
+
+// NewSQSQueueFromEnv builds a queue client from the default AWS credential
+// chain.
+func NewSQSQueueFromEnv(ctx context.Context, url string) (*SQSQueue, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewSQSQueue(sqs.NewFromConfig(cfg), url), nil
+}
+
+func (q *SQSQueue) Send(ctx context.Context, msg Message) error {
+	attrs := make(map[string]types.MessageAttributeValue, len(msg.Attributes))
+	for k, v := range msg.Attributes {
+		attrs[k] = types.MessageAttributeValue{DataType: aws.String("String"), StringValue: aws.String(v)}
+	}
+
+	_, err := q.client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:          aws.String(q.url),
+		MessageBody:       aws.String(msg.Body),
+		MessageAttributes: attrs,
+	})
+	return err
+}
+
+// Receive returns up to max messages, hiding them from other consumers for
+// hold. SQS returns at most 10 messages per call.
+func (q *SQSQueue) Receive(ctx context.Context, max int, hold time.Duration) ([]Message, error) {
+	if max > 10 {
+		max = 10
+	}
+	out, err := q.client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:                    aws.String(q.url),
+		MaxNumberOfMessages:         int32(max),
+		VisibilityTimeout:           int32(hold / time.Second),
+		WaitTimeSeconds:             1,
+		MessageAttributeNames:       []string{"All"},
+		MessageSystemAttributeNames: []types.MessageSystemAttributeName{types.MessageSystemAttributeNameApproximateReceiveCount},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, len(out.Messages))
+	for i, m := range out.Messages {
+		attrs := make(map[string]string, len(m.MessageAttributes))
+		for k, v := range m.MessageAttributes {
+			attrs[k] = aws.ToString(v.StringValue)
+		}
+		count, _ := strconv.Atoi(m.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+		messages[i] = Message{
+			ID:            aws.ToString(m.MessageId),
+			Body:          aws.ToString(m.Body),
+			Attributes:    attrs,
+			ReceiveCount:  count,
+			ReceiptHandle: aws.ToString(m.ReceiptHandle),
+		}
+	}
+	return messages, nil
+}
+
+func (q *SQSQueue) Delete(ctx context.Context, msg Message) error {
+	_, err := q.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(q.url),
+		ReceiptHandle: aws.String(msg.ReceiptHandle),
+	})
+	return err
+}
@@ -0,0 +1,48 @@
+package queue
+
+import (
+	"Ledger/pkg/tracing"
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("queue")
+
+// messageAttributes reads and writes W3C trace context in the message
+// attributes of an SQS record.
+type messageAttributes map[string]events.SQSMessageAttribute
+
+func (a messageAttributes) Get(key string) string {
+	if v, ok := a[key]; ok && v.StringValue != nil {
+		return *v.StringValue
+	}
+	return ""
+}
+
+func (a messageAttributes) Set(key, value string) {
+	a[key] = events.SQSMessageAttribute{DataType: "String", StringValue: &value}
+}
+
+func (a messageAttributes) Keys() []string {
+	keys := make([]string, 0, len(a))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// processTraced runs processRecord in a span that continues the trace of
+// whoever queued record, carried in its message attributes.
+func (p *Processor) processTraced(ctx context.Context, record events.SQSMessage) error {
+	ctx = tracing.Extract(ctx, messageAttributes(record.MessageAttributes))
+	ctx, span := tracer.Start(ctx, "queue.process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(semconv.MessagingSystemAWSSqs, semconv.MessagingMessageID(record.MessageId)),
+	)
+	err := p.processRecord(ctx, record)
+	tracing.End(span, err)
+	return err
+}
@@ -0,0 +1,67 @@
+package reconcile
+
+import (
+	"Ledger/pkg/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The outcome of the latest run is published with the other metrics of the
+// ledger, so monitoring can alert on discrepancies without parsing reports.
+var (
+	runs = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "ledger_reconcile_runs_total",
+		Help: "Reconciliation runs.",
+	})
+
+	accounts = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ledger_reconcile_accounts",
+		Help: "Accounts checked by the latest reconciliation.",
+	})
+
+	entries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ledger_reconcile_entries",
+		Help: "Transaction log entries replayed by the latest reconciliation.",
+	})
+
+	discrepancies = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ledger_reconcile_discrepancies",
+		Help: "Discrepancies found by the latest reconciliation, by kind.",
+	}, []string{"kind"})
+
+	cacheRepaired = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ledger_reconcile_cache_repaired",
+		Help: "Stale cached balances dropped by the latest reconciliation.",
+	})
+
+	lastRun = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ledger_reconcile_last_run_timestamp_seconds",
+		Help: "Start of the latest reconciliation as a Unix time.",
+	})
+
+	lastDuration = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "ledger_reconcile_duration_seconds",
+		Help: "Time taken by the latest reconciliation.",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(runs, accounts, entries, discrepancies, cacheRepaired, lastRun, lastDuration)
+}
+
+func observe(report *Report) {
+	runs.Inc()
+	accounts.Set(float64(report.Accounts))
+	entries.Set(float64(report.Entries))
+	cacheRepaired.Set(float64(report.CacheRepaired))
+	lastRun.Set(float64(report.StartedAt.Unix()))
+	lastDuration.Set(report.Duration.Seconds())
+
+	byKind := map[string]int{KindHistoryGap: 0, KindBalance: 0, KindCache: 0}
+	for _, d := range report.Discrepancies {
+		byKind[d.Kind]++
+	}
+	for kind, n := range byKind {
+		discrepancies.WithLabelValues(kind).Set(float64(n))
+	}
+}
@@ -0,0 +1,306 @@
+// Package reconcile recomputes every account's balance from its transaction
+// history and compares it with the stored balance and the credit cache.
+// Every balance change is logged, so any difference means a write bypassed
+// the ledger, a log entry went missing or the cache went stale.
+package reconcile
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// Kinds of discrepancy.
+const (
+	// KindHistoryGap is an entry whose balance before does not continue
+	// from the entry before it: a change happened that was not logged.
+	KindHistoryGap = "history_gap"
+	// KindBalance is a stored balance that differs from its history.
+	KindBalance = "balance"
+	// KindCache is a cached balance that differs from the stored one.
+	KindCache = "cache"
+)
+
+// ActionRun is the audit action recorded when a reconciliation that may
+// repair the cache is requested.
+const ActionRun = "ledger.reconcile"
+
+// tolerance is half a cent: balances are stored as DECIMAL(10, 2).
+const tolerance = 0.005
+
+// cacheChunk is the number of cached balances read in one round trip.
+const cacheChunk = 500
+
+// Cache is the credit cache checked against the stored balances.
+// *cache.RedisCache satisfies it.
+type Cache interface {
+	GetMultipleUserCredits(ctx context.Context, userIDs []uint) (map[uint]float64, error)
+	InvalidateMultipleUserCredits(ctx context.Context, userIDs []uint) error
+}
+
+// Options tune a run.
+type Options struct {
+	// RepairCache drops cached balances that differ from the stored ones,
+	// so the next read loads them from the database.
+	RepairCache bool
+}
+
+// Discrepancy is one difference found. Expected is what the history (or,
+// for the cache, the database) says and Actual what was found instead.
+type Discrepancy struct {
+	Kind     string  `json:"kind"`
+	UserID   uint    `json:"user_id"`
+	LogID    uint64  `json:"log_id,omitempty"`
+	Expected float64 `json:"expected"`
+	Actual   float64 `json:"actual"`
+	Detail   string  `json:"detail"`
+	Repaired bool    `json:"repaired,omitempty"`
+}
+
+// Report is the outcome of a run.
+type Report struct {
+	StartedAt time.Time     `json:"started_at"`
+	Duration  time.Duration `json:"duration_ns"`
+	Accounts  int           `json:"accounts"`
+	Entries   int           `json:"entries"`
+	// CacheChecked is false when there is no cache to compare against or
+	// it could not be read, which CacheError then says.
+	CacheChecked  bool          `json:"cache_checked"`
+	CacheError    string        `json:"cache_error,omitempty"`
+	CacheRepaired int           `json:"cache_repaired"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+}
+
+// OK reports whether nothing differed. Repaired cache entries still count:
+// they were wrong when the run started.
+func (r *Report) OK() bool {
+	return len(r.Discrepancies) == 0
+}
+
+type Reconciler struct {
+	db    *sql.DB
+	cache Cache
+}
+
+// New returns a Reconciler over db. cache may be nil when the deployment
+// runs without one.
+func New(db *sql.DB, cache Cache) *Reconciler {
+	return &Reconciler{db: db, cache: cache}
+}
+
+// Run reconciles every account. Users and history are read from one
+// snapshot, so transfers committed meanwhile cannot show up as differences;
+// the cache is read afterwards and compared with that snapshot.
+func (r *Reconciler) Run(ctx context.Context, opts Options) (*Report, error) {
+	report := &Report{StartedAt: time.Now().UTC(), Discrepancies: []Discrepancy{}}
+
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	ids, stored, err := balances(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	report.Accounts = len(ids)
+
+	computed, err := replay(ctx, tx, report)
+	if err != nil {
+		return nil, err
+	}
+	tx.Rollback()
+
+	for _, id := range ids {
+		expected, ok := computed[id]
+		if !ok {
+			// Without history the balance can only be what a new account
+			// starts with.
+			if math.Abs(stored[id]) >= tolerance {
+				report.Discrepancies = append(report.Discrepancies, Discrepancy{
+					Kind: KindBalance, UserID: id, Actual: stored[id],
+					Detail: "balance has no transaction history",
+				})
+			}
+			continue
+		}
+		if math.Abs(expected-stored[id]) >= tolerance {
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{
+				Kind: KindBalance, UserID: id, Expected: expected, Actual: stored[id],
+				Detail: "stored balance differs from the transaction history",
+			})
+		}
+	}
+
+	// The cache is only a copy: when it cannot be read the balances are
+	// still reconciled.
+	if r.cache != nil {
+		if err := r.checkCache(ctx, ids, stored, opts, report); err != nil {
+			report.CacheChecked = false
+			report.CacheError = err.Error()
+		}
+	}
+
+	report.Duration = time.Since(report.StartedAt)
+	observe(report)
+	return report, nil
+}
+
+// balances returns every user ID in order with its stored balance.
+func balances(ctx context.Context, tx *sql.Tx) ([]uint, map[uint]float64, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id, COALESCE(credit, 0) FROM users ORDER BY id")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var ids []uint
+	stored := make(map[uint]float64)
+	for rows.Next() {
+		var id uint
+		var credit float64
+		if err := rows.Scan(&id, &credit); err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+		stored[id] = credit
+	}
+	return ids, stored, rows.Err()
+}
+
+// replay walks the history in the order it was written and returns the
+// balance it leaves every account with. An account opens with the balance
+// before its first entry, which covers balances from before changes were
+// logged. Entries written before the balance columns existed only move the
+// amount.
+func replay(ctx context.Context, tx *sql.Tx, report *Report) (map[uint]float64, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT id, COALESCE(sender_id, 0), COALESCE(receiver_id, 0), amount,
+		sender_credit_before, receiver_credit_before
+		FROM transaction_logs ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	computed := make(map[uint]float64)
+	apply := func(logID uint64, userID uint, before sql.NullFloat64, delta float64) {
+		if userID == 0 {
+			return
+		}
+		balance, seen := computed[userID]
+		switch {
+		case !seen:
+			balance = before.Float64
+		case before.Valid && math.Abs(before.Float64-balance) >= tolerance:
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{
+				Kind: KindHistoryGap, UserID: userID, LogID: logID, Expected: balance, Actual: before.Float64,
+				Detail: fmt.Sprintf("entry %d starts from a balance the entries before it do not leave", logID),
+			})
+			// Continue from what the entry says so one gap is reported
+			// once, not again at every later entry.
+			balance = before.Float64
+		}
+		computed[userID] = math.Round((balance+delta)*100) / 100
+	}
+
+	for rows.Next() {
+		var logID uint64
+		var senderID, receiverID uint
+		var amount float64
+		var senderBefore, receiverBefore sql.NullFloat64
+		if err := rows.Scan(&logID, &senderID, &receiverID, &amount, &senderBefore, &receiverBefore); err != nil {
+			return nil, err
+		}
+		report.Entries++
+		apply(logID, senderID, senderBefore, -amount)
+		apply(logID, receiverID, receiverBefore, amount)
+	}
+	return computed, rows.Err()
+}
+
+// checkCache compares the cached balances with the stored ones. Accounts
+// without a cached balance are fine: the next read loads them.
+func (r *Reconciler) checkCache(ctx context.Context, ids []uint, stored map[uint]float64, opts Options, report *Report) error {
+	var stale []uint
+	for start := 0; start < len(ids); start += cacheChunk {
+		chunk := ids[start:min(start+cacheChunk, len(ids))]
+		cached, err := r.cache.GetMultipleUserCredits(ctx, chunk)
+		if err != nil {
+			return fmt.Errorf("reading the credit cache: %w", err)
+		}
+		for _, id := range chunk {
+			credit, ok := cached[id]
+			if !ok || math.Abs(credit-stored[id]) < tolerance {
+				continue
+			}
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{
+				Kind: KindCache, UserID: id, Expected: stored[id], Actual: credit,
+				Detail: "cached balance differs from the stored balance", Repaired: opts.RepairCache,
+			})
+			stale = append(stale, id)
+		}
+	}
+
+	if opts.RepairCache && len(stale) > 0 {
+		if err := r.cache.InvalidateMultipleUserCredits(ctx, stale); err != nil {
+			return fmt.Errorf("repairing the credit cache: %w", err)
+		}
+		report.CacheRepaired = len(stale)
+	}
+	report.CacheChecked = true
+	return nil
+}
+
+// Every runs a reconciliation every interval until ctx is done, logging
+// each outcome. It is how the server schedules the job.
+func (r *Reconciler) Every(ctx context.Context, interval time.Duration, opts Options) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			report, err := r.Run(ctx, opts)
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "Reconciliation failed", "error", err)
+				continue
+			}
+			Log(ctx, report)
+		}
+	}
+}
+
+// maxLogged is the number of discrepancies Log writes out one by one.
+const maxLogged = 20
+
+// Log writes a summary of report and its first discrepancies to the
+// default logger, the summary as a warning when anything differs.
+func Log(ctx context.Context, report *Report) {
+	level := slog.LevelInfo
+	if len(report.Discrepancies) > 0 {
+		level = slog.LevelWarn
+	}
+	slog.Log(ctx, level, "Reconciliation finished", "accounts", report.Accounts, "entries", report.Entries,
+		"discrepancies", len(report.Discrepancies), "cache_repaired", report.CacheRepaired)
+	if report.CacheError != "" {
+		slog.WarnContext(ctx, "Reconciliation did not check the cache", "error", report.CacheError)
+	}
+	for i, d := range report.Discrepancies {
+		if i == maxLogged {
+			slog.WarnContext(ctx, "More discrepancies in the report", "count", len(report.Discrepancies)-i)
+			break
+		}
+		slog.WarnContext(ctx, "Balance discrepancy", "kind", d.Kind, "user_id", d.UserID, "detail", d.Detail,
+			"expected", d.Expected, "actual", d.Actual)
+	}
+}
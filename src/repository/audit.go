@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"Ledger/src/audit"
+	"context"
+	"strconv"
+)
+
+// Actions recorded by audited repositories.
+const (
+	ActionUserCreate        = "user.create"
+	ActionUserRead          = "user.read"
+	ActionUserList          = "user.list"
+	ActionUserRole          = "user.role"
+	ActionUserPasswordReset = "user.password_reset"
+	ActionCreditAdd         = "credit.add"
+	ActionCreditAdjust      = "credit.adjust"
+	ActionCreditBatch       = "credit.batch_update"
+	ActionCreditList        = "credit.list"
+	ActionCreditRead        = "credit.read"
+)
+
+// recordAudit writes the pending event of an audited repository, completed
+// with the action, target and state of the change, through exec. Callers
+// inside a transaction pass the transaction so the event commits or rolls
+// back with the change. A repository without a pending event records
+// nothing.
+func recordAudit(ctx context.Context, exec audit.Execer, driver string, pending *audit.Event, action, target string, before, after interface{}) error {
+	if pending == nil {
+		return nil
+	}
+
+	e := *pending
+	e.Action = action
+	if e.Target == "" {
+		e.Target = target
+	}
+	e.Before = audit.State(before)
+	e.After = audit.State(after)
+	return audit.Insert(ctx, exec, driver, e)
+}
+
+func userTarget(id uint) string {
+	return "user:" + strconv.FormatUint(uint64(id), 10)
+}
+
+// usersTarget names a set of users; the IDs go in the event state.
+const usersTarget = "users"
+
+type creditState struct {
+	Credit float64 `json:"credit"`
+}
+
+type roleState struct {
+	Role string `json:"role"`
+}
@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"sync"
+)
+
+// pending tracks the cache invalidations still running after the call that
+// started them has returned.
+var pending sync.WaitGroup
+
+// background runs fn after the caller returns, under ctx without its
+// cancellation: the change it follows is committed, so a client hanging up
+// must not leave a stale balance cached.
+func background(ctx context.Context, fn func(ctx context.Context)) {
+	ctx = context.WithoutCancel(ctx)
+	pending.Add(1)
+	go func() {
+		defer pending.Done()
+		fn(ctx)
+	}()
+}
+
+// Drain waits until the cache invalidations repositories started in the
+// background have finished, or until ctx is done. A server calls it on
+// shutdown, after it stopped taking requests.
+func Drain(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,692 @@
+// Package contract is the behavioural contract every repository.UserRepository
+// adapter must satisfy. It runs against the scratch database of each driver
+// given to package testdb and only creates rows with unique emails, so it is
+// safe to point at a shared scratch schema.
+package contract
+
+import (
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/testdb"
+	"Ledger/src/audit"
+	"Ledger/src/chain"
+	"Ledger/src/models"
+	"Ledger/src/repository"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+type testCase struct {
+	name string
+	run  func(s *suite) error
+}
+
+var cases = []testCase{
+	{"create assigns id and defaults", testCreate},
+	{"get by id and email", testLookups},
+	{"unknown user lookups fail", testUnknownUser},
+	{"create rejects a taken email", testDuplicateEmail},
+	{"add credit", testAddCredit},
+	{"send credit moves funds and logs", testSendCredit},
+	{"send credit rejects insufficient balance", testInsufficientBalance},
+	{"send credit rejects unknown receiver", testUnknownReceiver},
+	{"send credit rejects a self-transfer", testSelfTransfer},
+	{"failing transaction hook rolls back", testTxHookRollback},
+	{"multiple user credits", testMultipleCredits},
+	{"batch credit update", testBatchUpdate},
+	{"get all includes created users", testGetAll},
+	{"update role and password", testUpdateRoleAndPassword},
+	{"adjust credit reports balances", testAdjustCredit},
+	{"adjust credit rejects a negative balance", testAdjustCreditOverdraft},
+	{"transaction logs by user cover both sides", testLogsByUser},
+	{"transfers are chained", testTransfersChained},
+	{"balance changes are logged", testCreditChangesLogged},
+	{"audited mutation records its event", testAuditedMutation},
+	{"failed audited mutation records nothing", testAuditedFailure},
+	{"audited read records its event", testAuditedRead},
+}
+
+// TestContract runs every case against each storage adapter. The cases run
+// in order on one suite.
+func TestContract(t *testing.T) {
+	for _, driver := range testdb.Drivers {
+		t.Run(driver, func(t *testing.T) {
+			sqlDB := testdb.Open(t, driver)
+			var repo repository.UserRepository
+			switch driver {
+			case "mysql":
+				gormDB, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB}), &gorm.Config{})
+				if err != nil {
+					t.Fatalf("open GORM: %v", err)
+				}
+				// The cache is left out so the contract sees the storage adapter only.
+				repo = repository.NewUserRepository(gormDB, nil)
+			case "postgres":
+				repo = repository.NewPostgresUserRepository(sqlDB)
+			}
+
+			s := &suite{ctx: context.Background(), repo: repo, events: audit.NewSQLStore(sqlDB, driver), prefix: fmt.Sprintf("contract-%d", time.Now().UnixNano())}
+			for _, c := range cases {
+				t.Run(c.name, func(t *testing.T) {
+					if err := c.run(s); err != nil {
+						t.Fatal(err)
+					}
+				})
+			}
+		})
+	}
+}
+
+type suite struct {
+	ctx    context.Context
+	repo   repository.UserRepository
+	events audit.Querier
+	prefix string
+	seq    int
+}
+
+func (s *suite) newUser(credit float64) (*models.User, error) {
+	s.seq++
+	user := &models.User{
+		Name:     fmt.Sprintf("%s-%d", s.prefix, s.seq),
+		Surname:  "Contract",
+		Age:      30,
+		Email:    fmt.Sprintf("%s-%d@example.com", s.prefix, s.seq),
+		Password: "$2a$10$contractcontractcontractcontractcontractcontractcon",
+	}
+	if err := s.repo.Create(s.ctx, user); err != nil {
+		return nil, fmt.Errorf("create: %w", err)
+	}
+	if credit > 0 {
+		if err := s.repo.AddCredit(s.ctx, user.ID, credit); err != nil {
+			return nil, fmt.Errorf("add credit: %w", err)
+		}
+		user.Credit = credit
+	}
+	return user, nil
+}
+
+func (s *suite) expectCredit(userID uint, want float64) error {
+	got, err := s.repo.GetUserCredit(s.ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get credit of %d: %w", userID, err)
+	}
+	if !equal(got, want) {
+		return fmt.Errorf("credit of %d = %v, want %v", userID, got, want)
+	}
+	return nil
+}
+
+// audited returns repo recording events under a request ID unique to the
+// case, and a function reading those events back.
+func (s *suite) audited(name string) (repository.UserRepository, func() ([]audit.Event, error)) {
+	requestID := s.prefix + "-" + name
+	repo := s.repo.WithAudit(audit.Event{Actor: s.prefix, RequestID: requestID, Reason: "contract"})
+	return repo, func() ([]audit.Event, error) {
+		return s.events.Query(s.ctx, audit.Filter{RequestID: requestID})
+	}
+}
+
+func equal(a, b float64) bool {
+	return math.Abs(a-b) < 0.005
+}
+
+func testCreate(s *suite) error {
+	user, err := s.newUser(0)
+	if err != nil {
+		return err
+	}
+	if user.ID == 0 {
+		return errors.New("create did not assign an id")
+	}
+
+	stored, err := s.repo.GetByID(s.ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if stored.Role != "user" {
+		return fmt.Errorf("role = %q, want user", stored.Role)
+	}
+	if stored.Credit != 0 {
+		return fmt.Errorf("credit = %v, want 0", stored.Credit)
+	}
+	return nil
+}
+
+func testLookups(s *suite) error {
+	user, err := s.newUser(0)
+	if err != nil {
+		return err
+	}
+
+	byID, err := s.repo.GetByID(s.ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if byID.Email != user.Email || byID.Name != user.Name || byID.Surname != user.Surname || byID.Age != user.Age {
+		return fmt.Errorf("GetByID returned %+v, want %+v", byID, user)
+	}
+
+	byEmail, err := s.repo.GetByEmail(s.ctx, user.Email)
+	if err != nil {
+		return err
+	}
+	if byEmail.ID != user.ID {
+		return fmt.Errorf("GetByEmail id = %d, want %d", byEmail.ID, user.ID)
+	}
+	if byEmail.Password != user.Password {
+		return errors.New("GetByEmail did not return the stored password hash")
+	}
+	return nil
+}
+
+func testUnknownUser(s *suite) error {
+	if _, err := s.repo.GetByEmail(s.ctx, s.prefix+"-missing@example.com"); !errors.Is(err, apperr.ErrUserNotFound) {
+		return fmt.Errorf("GetByEmail of an unknown email: got %v, want %v", err, apperr.ErrUserNotFound)
+	}
+	if _, err := s.repo.GetByID(s.ctx, math.MaxUint32); !errors.Is(err, apperr.ErrUserNotFound) {
+		return fmt.Errorf("GetByID of an unknown id: got %v, want %v", err, apperr.ErrUserNotFound)
+	}
+	if _, err := s.repo.GetUserCredit(s.ctx, math.MaxUint32); !errors.Is(err, apperr.ErrUserNotFound) {
+		return fmt.Errorf("GetUserCredit of an unknown id: got %v, want %v", err, apperr.ErrUserNotFound)
+	}
+	if err := s.repo.AddCredit(s.ctx, math.MaxUint32, 10); !errors.Is(err, apperr.ErrUserNotFound) {
+		return fmt.Errorf("AddCredit of an unknown id: got %v, want %v", err, apperr.ErrUserNotFound)
+	}
+	return nil
+}
+
+func testDuplicateEmail(s *suite) error {
+	user, err := s.newUser(0)
+	if err != nil {
+		return err
+	}
+	dup := &models.User{Name: user.Name, Surname: user.Surname, Email: user.Email, Password: user.Password}
+	if err := s.repo.Create(s.ctx, dup); !errors.Is(err, apperr.ErrEmailTaken) {
+		return fmt.Errorf("create with a taken email: got %v, want %v", err, apperr.ErrEmailTaken)
+	}
+	return nil
+}
+
+func testAddCredit(s *suite) error {
+	user, err := s.newUser(100)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.AddCredit(s.ctx, user.ID, 25.5); err != nil {
+		return err
+	}
+	return s.expectCredit(user.ID, 125.5)
+}
+
+func testSendCredit(s *suite) error {
+	sender, err := s.newUser(100)
+	if err != nil {
+		return err
+	}
+	receiver, err := s.newUser(10)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.SendCredit(s.ctx, sender.ID, receiver.ID, 40); err != nil {
+		return err
+	}
+	if err := s.expectCredit(sender.ID, 60); err != nil {
+		return err
+	}
+	if err := s.expectCredit(receiver.ID, 50); err != nil {
+		return err
+	}
+
+	logs, err := s.repo.GetTransactionLogsBySenderAndDate(s.ctx, sender.ID, time.Now().Format("2006-01-02"))
+	if err != nil {
+		return err
+	}
+	if len(logs) != 1 {
+		return fmt.Errorf("got %d transaction logs, want 1", len(logs))
+	}
+	log := logs[0]
+	if log.ReceiverID != receiver.ID || !equal(log.Amount, 40) {
+		return fmt.Errorf("unexpected log %+v", log)
+	}
+	if !equal(log.SenderCreditBefore, 100) || !equal(log.SenderCreditAfter, 60) ||
+		!equal(log.ReceiverCreditBefore, 10) || !equal(log.ReceiverCreditAfter, 50) {
+		return fmt.Errorf("unexpected balances in log %+v", log)
+	}
+	if log.Description != repository.TransferDescription {
+		return fmt.Errorf("description = %q, want %q", log.Description, repository.TransferDescription)
+	}
+	return nil
+}
+
+func testInsufficientBalance(s *suite) error {
+	sender, err := s.newUser(10)
+	if err != nil {
+		return err
+	}
+	receiver, err := s.newUser(0)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.SendCredit(s.ctx, sender.ID, receiver.ID, 50); !errors.Is(err, apperr.ErrInsufficientFunds) {
+		return fmt.Errorf("transfer above the balance: got %v, want %v", err, apperr.ErrInsufficientFunds)
+	}
+	if err := s.expectCredit(sender.ID, 10); err != nil {
+		return err
+	}
+	return s.expectCredit(receiver.ID, 0)
+}
+
+func testUnknownReceiver(s *suite) error {
+	sender, err := s.newUser(10)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.SendCredit(s.ctx, sender.ID, math.MaxUint32, 5); !errors.Is(err, apperr.ErrUserNotFound) {
+		return fmt.Errorf("transfer to an unknown receiver: got %v, want %v", err, apperr.ErrUserNotFound)
+	}
+	return s.expectCredit(sender.ID, 10)
+}
+
+func testSelfTransfer(s *suite) error {
+	user, err := s.newUser(10)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.SendCredit(s.ctx, user.ID, user.ID, 5); !errors.Is(err, apperr.ErrInvalidRequest) {
+		return fmt.Errorf("transfer to oneself: got %v, want %v", err, apperr.ErrInvalidRequest)
+	}
+	return s.expectCredit(user.ID, 10)
+}
+
+func testTxHookRollback(s *suite) error {
+	sender, err := s.newUser(10)
+	if err != nil {
+		return err
+	}
+	receiver, err := s.newUser(0)
+	if err != nil {
+		return err
+	}
+
+	errHook := errors.New("hook failed")
+	ran := 0
+	ctx := repository.WithTxHook(s.ctx, func(ctx context.Context, exec audit.Execer) error {
+		ran++
+		return errHook
+	})
+	if err := s.repo.SendCredit(ctx, sender.ID, receiver.ID, 5); !errors.Is(err, errHook) {
+		return fmt.Errorf("send credit: got %v, want the hook error", err)
+	}
+	if err := s.repo.AddCredit(ctx, receiver.ID, 5); !errors.Is(err, errHook) {
+		return fmt.Errorf("add credit: got %v, want the hook error", err)
+	}
+	results := s.repo.ProcessBatchCreditUpdate(ctx, []models.BatchTransaction{{UserID: receiver.ID, Amount: 5}})
+	if results[0].Success || results[0].Code != apperr.CodeInternal {
+		return fmt.Errorf("batch: got %+v, want the entry failed", results[0])
+	}
+	if ran != 3 {
+		return fmt.Errorf("hook ran %d times, want 3", ran)
+	}
+	if err := s.expectCredit(sender.ID, 10); err != nil {
+		return err
+	}
+	return s.expectCredit(receiver.ID, 0)
+}
+
+func testMultipleCredits(s *suite) error {
+	a, err := s.newUser(1)
+	if err != nil {
+		return err
+	}
+	b, err := s.newUser(2)
+	if err != nil {
+		return err
+	}
+
+	users, err := s.repo.GetMultipleUserCredits(s.ctx, []uint{a.ID, b.ID})
+	if err != nil {
+		return err
+	}
+	if len(users) != 2 {
+		return fmt.Errorf("got %d users, want 2", len(users))
+	}
+	for _, u := range users {
+		want := map[uint]float64{a.ID: 1, b.ID: 2}[u.ID]
+		if !equal(u.Credit, want) {
+			return fmt.Errorf("credit of %d = %v, want %v", u.ID, u.Credit, want)
+		}
+	}
+	return nil
+}
+
+func testBatchUpdate(s *suite) error {
+	a, err := s.newUser(10)
+	if err != nil {
+		return err
+	}
+	b, err := s.newUser(10)
+	if err != nil {
+		return err
+	}
+
+	results := s.repo.ProcessBatchCreditUpdate(s.ctx, []models.BatchTransaction{
+		{UserID: a.ID, Amount: 5},
+		{UserID: math.MaxUint32, Amount: 5},
+		{UserID: b.ID, Amount: -3},
+	})
+	if len(results) != 3 {
+		return fmt.Errorf("got %d results, want 3", len(results))
+	}
+	if !results[0].Success || results[1].Success || !results[2].Success {
+		return fmt.Errorf("unexpected results %+v", results)
+	}
+	if err := s.expectCredit(a.ID, 15); err != nil {
+		return err
+	}
+	return s.expectCredit(b.ID, 7)
+}
+
+func testGetAll(s *suite) error {
+	user, err := s.newUser(0)
+	if err != nil {
+		return err
+	}
+
+	users, err := s.repo.GetAll(s.ctx)
+	if err != nil {
+		return err
+	}
+	for _, u := range users {
+		if u.ID == user.ID {
+			return nil
+		}
+	}
+	return fmt.Errorf("GetAll did not include user %d", user.ID)
+}
+
+func testUpdateRoleAndPassword(s *suite) error {
+	user, err := s.newUser(0)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.UpdateRole(s.ctx, user.ID, models.RoleAdmin); err != nil {
+		return err
+	}
+	const hash = "$2a$10$resetresetresetresetresetresetresetresetresetresetres"
+	if err := s.repo.UpdatePassword(s.ctx, user.ID, hash); err != nil {
+		return err
+	}
+
+	stored, err := s.repo.GetByID(s.ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	if stored.Role != models.RoleAdmin {
+		return fmt.Errorf("role = %q, want %q", stored.Role, models.RoleAdmin)
+	}
+	if stored.Password != hash {
+		return errors.New("UpdatePassword did not store the new hash")
+	}
+
+	if err := s.repo.UpdateRole(s.ctx, math.MaxUint32, models.RoleAdmin); err == nil {
+		return errors.New("UpdateRole succeeded for an unknown id")
+	}
+	return nil
+}
+
+func testAdjustCredit(s *suite) error {
+	user, err := s.newUser(50)
+	if err != nil {
+		return err
+	}
+
+	before, after, err := s.repo.AdjustCredit(s.ctx, user.ID, -20)
+	if err != nil {
+		return err
+	}
+	if !equal(before, 50) || !equal(after, 30) {
+		return fmt.Errorf("AdjustCredit returned %v -> %v, want 50 -> 30", before, after)
+	}
+	if _, _, err := s.repo.AdjustCredit(s.ctx, math.MaxUint32, 1); err == nil {
+		return errors.New("AdjustCredit succeeded for an unknown id")
+	}
+	return s.expectCredit(user.ID, 30)
+}
+
+func testAdjustCreditOverdraft(s *suite) error {
+	user, err := s.newUser(10)
+	if err != nil {
+		return err
+	}
+	if _, _, err := s.repo.AdjustCredit(s.ctx, user.ID, -10.01); !errors.Is(err, apperr.ErrInsufficientFunds) {
+		return fmt.Errorf("adjustment below zero: got %v, want %v", err, apperr.ErrInsufficientFunds)
+	}
+	return s.expectCredit(user.ID, 10)
+}
+
+func testLogsByUser(s *suite) error {
+	a, err := s.newUser(100)
+	if err != nil {
+		return err
+	}
+	b, err := s.newUser(100)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.SendCredit(s.ctx, a.ID, b.ID, 10); err != nil {
+		return err
+	}
+	if err := s.repo.SendCredit(s.ctx, b.ID, a.ID, 5); err != nil {
+		return err
+	}
+
+	// The opening credit newUser adds is logged as well.
+	logs, err := s.repo.GetTransactionLogsByUser(s.ctx, a.ID)
+	if err != nil {
+		return err
+	}
+	if len(logs) != 3 {
+		return fmt.Errorf("got %d transaction logs, want 3", len(logs))
+	}
+	if logs[0].SenderID != b.ID || logs[1].SenderID != a.ID || logs[2].ReceiverID != a.ID || logs[2].SenderID != 0 {
+		return fmt.Errorf("logs are not newest first: %+v", logs)
+	}
+
+	all, err := s.repo.GetAllTransactionLogs(s.ctx)
+	if err != nil {
+		return err
+	}
+	found := 0
+	for _, log := range all {
+		if log.ID == logs[0].ID || log.ID == logs[1].ID {
+			found++
+		}
+	}
+	if found != 2 {
+		return fmt.Errorf("GetAllTransactionLogs included %d of the 2 new logs", found)
+	}
+	return nil
+}
+
+// testTransfersChained reads the entries back, so it also catches values
+// the database stores differently from how they were hashed.
+func testTransfersChained(s *suite) error {
+	a, err := s.newUser(100)
+	if err != nil {
+		return err
+	}
+	b, err := s.newUser(100)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.SendCredit(s.ctx, a.ID, b.ID, 12.345); err != nil {
+		return err
+	}
+	if err := s.repo.SendCredit(s.ctx, b.ID, a.ID, 3); err != nil {
+		return err
+	}
+
+	logs, err := s.repo.GetTransactionLogsByUser(s.ctx, a.ID)
+	if err != nil {
+		return err
+	}
+	if len(logs) != 3 {
+		return fmt.Errorf("got %d transaction logs, want 3", len(logs))
+	}
+	for _, log := range logs {
+		if log.Hash == "" || log.Hash != chain.Hash(log.PrevHash, log) {
+			return fmt.Errorf("log %d hash %q does not match its contents", log.ID, log.Hash)
+		}
+	}
+	// Newest first; nothing else transfers between the two calls.
+	if logs[0].PrevHash != logs[1].Hash {
+		return fmt.Errorf("log %d links to %q, want the hash of log %d", logs[0].ID, logs[0].PrevHash, logs[1].ID)
+	}
+	return nil
+}
+
+// testCreditChangesLogged checks that every way of changing a balance other
+// than a transfer leaves an entry whose balances explain the change.
+func testCreditChangesLogged(s *suite) error {
+	user, err := s.newUser(50)
+	if err != nil {
+		return err
+	}
+	if _, _, err := s.repo.AdjustCredit(s.ctx, user.ID, 30); err != nil {
+		return err
+	}
+	if _, _, err := s.repo.AdjustCredit(s.ctx, user.ID, -20); err != nil {
+		return err
+	}
+	if results := s.repo.ProcessBatchCreditUpdate(s.ctx, []models.BatchTransaction{{UserID: user.ID, Amount: 5}}); !results[0].Success {
+		return fmt.Errorf("batch update failed: %s", results[0].Error)
+	}
+
+	logs, err := s.repo.GetTransactionLogsByUser(s.ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	// Newest first. A credit has the user as receiver and a debit as
+	// sender, with the other side empty.
+	want := []struct {
+		change, after float64
+	}{{5, 65}, {-20, 60}, {30, 80}, {50, 50}}
+	if len(logs) != len(want) {
+		return fmt.Errorf("got %d transaction logs, want %d", len(logs), len(want))
+	}
+	for i, w := range want {
+		log := logs[i]
+		id, other, before, after := log.ReceiverID, log.SenderID, log.ReceiverCreditBefore, log.ReceiverCreditAfter
+		if w.change < 0 {
+			id, other, before, after = log.SenderID, log.ReceiverID, log.SenderCreditBefore, log.SenderCreditAfter
+		}
+		if id != user.ID || other != 0 {
+			return fmt.Errorf("log %d has sender %d and receiver %d, want only user %d", log.ID, log.SenderID, log.ReceiverID, user.ID)
+		}
+		if !equal(log.Amount, math.Abs(w.change)) || !equal(after-before, w.change) || !equal(after, w.after) {
+			return fmt.Errorf("log %d moves %v from %v to %v, want %v to %v", log.ID, log.Amount, before, after, w.change, w.after)
+		}
+		if log.Hash != chain.Hash(log.PrevHash, log) {
+			return fmt.Errorf("log %d hash %q does not match its contents", log.ID, log.Hash)
+		}
+	}
+	return nil
+}
+
+func testAuditedMutation(s *suite) error {
+	user, err := s.newUser(20)
+	if err != nil {
+		return err
+	}
+
+	repo, events := s.audited("add")
+	if err := repo.AddCredit(s.ctx, user.ID, 5); err != nil {
+		return err
+	}
+
+	recorded, err := events()
+	if err != nil {
+		return err
+	}
+	if len(recorded) != 1 {
+		return fmt.Errorf("got %d audit events, want 1", len(recorded))
+	}
+	e := recorded[0]
+	if e.Action != repository.ActionCreditAdd || e.Target != fmt.Sprintf("user:%d", user.ID) ||
+		e.Actor != s.prefix || e.Reason != "contract" {
+		return fmt.Errorf("unexpected audit event %+v", e)
+	}
+
+	var before, after struct{ Credit float64 }
+	if err := json.Unmarshal([]byte(e.Before), &before); err != nil {
+		return fmt.Errorf("before %q: %w", e.Before, err)
+	}
+	if err := json.Unmarshal([]byte(e.After), &after); err != nil {
+		return fmt.Errorf("after %q: %w", e.After, err)
+	}
+	if !equal(before.Credit, 20) || !equal(after.Credit, 25) {
+		return fmt.Errorf("audited credit %v -> %v, want 20 -> 25", before.Credit, after.Credit)
+	}
+	return nil
+}
+
+func testAuditedFailure(s *suite) error {
+	user, err := s.newUser(5)
+	if err != nil {
+		return err
+	}
+
+	repo, events := s.audited("overdraft")
+	if _, _, err := repo.AdjustCredit(s.ctx, user.ID, -50); err == nil {
+		return errors.New("adjustment below zero succeeded")
+	}
+	if err := repo.AddCredit(s.ctx, math.MaxUint32, 1); err == nil {
+		return errors.New("AddCredit succeeded for an unknown id")
+	}
+
+	recorded, err := events()
+	if err != nil {
+		return err
+	}
+	if len(recorded) != 0 {
+		return fmt.Errorf("failed mutations recorded %d audit events", len(recorded))
+	}
+	return nil
+}
+
+func testAuditedRead(s *suite) error {
+	user, err := s.newUser(1)
+	if err != nil {
+		return err
+	}
+
+	repo, events := s.audited("read")
+	if _, err := repo.GetMultipleUserCredits(s.ctx, []uint{user.ID}); err != nil {
+		return err
+	}
+	if _, err := s.repo.GetAll(s.ctx); err != nil {
+		return err
+	}
+
+	recorded, err := events()
+	if err != nil {
+		return err
+	}
+	if len(recorded) != 1 || recorded[0].Action != repository.ActionCreditRead {
+		return fmt.Errorf("got audit events %+v, want one %s", recorded, repository.ActionCreditRead)
+	}
+	return nil
+}
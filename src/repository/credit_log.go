@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"Ledger/src/chain"
+	"Ledger/src/models"
+	"context"
+	"math"
+	"time"
+)
+
+// appendCreditLog chains a transaction log for a balance change that is not
+// a transfer, through conn, the transaction that made the change. A
+// credit has the user as receiver and a debit has the user as sender, so the
+// amount is always positive. Nothing is logged when the balance did not
+// change.
+func appendCreditLog(ctx context.Context, conn chain.Conn, driver string, userID uint, before, after float64, description string) error {
+	amount := after - before
+	if math.Abs(amount) < 0.005 {
+		return nil
+	}
+
+	log := models.TransactionLog{
+		Amount:          math.Abs(amount),
+		Description:     description,
+		TransactionDate: time.Now(),
+	}
+	if amount > 0 {
+		log.ReceiverID = userID
+		log.ReceiverCreditBefore, log.ReceiverCreditAfter = before, after
+	} else {
+		log.SenderID = userID
+		log.SenderCreditBefore, log.SenderCreditAfter = before, after
+	}
+	return chain.Append(ctx, conn, driver, &log)
+}
@@ -0,0 +1,522 @@
+package repository
+
+import (
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/db"
+	"Ledger/src/audit"
+	"Ledger/src/chain"
+	"Ledger/src/models"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type postgresUserRepository struct {
+	db    *sql.DB
+	audit *audit.Event
+}
+
+// NewPostgresUserRepository returns the database/sql repository used by the
+// lambda deployment.
+func NewPostgresUserRepository(db *sql.DB) UserRepository {
+	return &postgresUserRepository{db: db}
+}
+
+func (r *postgresUserRepository) WithAudit(e audit.Event) UserRepository {
+	audited := *r
+	audited.audit = &e
+	return &audited
+}
+
+// record writes the pending audit event through exec, which is r.db for
+// reads and the open transaction for mutations.
+func (r *postgresUserRepository) record(ctx context.Context, exec audit.Execer, action, target string, before, after interface{}) error {
+	return recordAudit(ctx, exec, "postgres", r.audit, action, target, before, after)
+}
+
+// inTx runs fn in a transaction that commits when fn succeeds.
+func (r *postgresUserRepository) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+const userColumns = "id, name, surname, age, email, password_hash, role, COALESCE(credit, 0)"
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*models.User, error) {
+	var user models.User
+	var age sql.NullInt64
+	if err := row.Scan(
+		&user.ID,
+		&user.Name,
+		&user.Surname,
+		&age,
+		&user.Email,
+		&user.Password,
+		&user.Role,
+		&user.Credit,
+	); err != nil {
+		return nil, err
+	}
+	user.Age = int(age.Int64)
+	return &user, nil
+}
+
+func (r *postgresUserRepository) Create(ctx context.Context, user *models.User) error {
+	if user.Role == "" {
+		user.Role = "user"
+	}
+
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO users (name, surname, age, email, password_hash, role, credit)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id`,
+			user.Name,
+			user.Surname,
+			user.Age,
+			user.Email,
+			user.Password,
+			user.Role,
+			user.Credit,
+		).Scan(&user.ID)
+		if db.IsDuplicate(err) {
+			return apperr.ErrEmailTaken.Wrap(err)
+		}
+		if err != nil {
+			return fmt.Errorf("creating user: %w", err)
+		}
+		if err := appendCreditLog(ctx, tx, "postgres", user.ID, 0, user.Credit, CreditDescription); err != nil {
+			return err
+		}
+		if err := r.record(ctx, tx, ActionUserCreate, userTarget(user.ID), nil, user); err != nil {
+			return err
+		}
+		return runTxHook(ctx, tx)
+	})
+}
+
+func (r *postgresUserRepository) GetAll(ctx context.Context) ([]models.User, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+userColumns+" FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.record(ctx, r.db, ActionUserList, usersTarget, nil, map[string]int{"count": len(users)}); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *postgresUserRepository) GetByID(ctx context.Context, id uint) (*models.User, error) {
+	user, err := scanUser(r.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = $1", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperr.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := r.record(ctx, r.db, ActionUserRead, userTarget(id), nil, nil); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (r *postgresUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	user, err := scanUser(r.db.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE lower(email) = lower($1)", email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, apperr.ErrUserNotFound
+	}
+	return user, err
+}
+
+func (r *postgresUserRepository) GetUserCredit(ctx context.Context, userID uint) (float64, error) {
+	var credit float64
+	err := r.db.QueryRowContext(ctx, "SELECT COALESCE(credit, 0) FROM users WHERE id = $1", userID).Scan(&credit)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, apperr.ErrUserNotFound
+	}
+	return credit, err
+}
+
+func (r *postgresUserRepository) SendCredit(ctx context.Context, senderID, receiverID uint, amount float64) error {
+	if senderID == receiverID {
+		// Both sides would be the same row, and the debit would be undone
+		// by the credit written over it.
+		return apperr.ErrInvalidRequest.WithMessage("sender and receiver must differ")
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock both rows in id order so concurrent transfers in opposite
+	// directions cannot deadlock.
+	credits := make(map[uint]float64, 2)
+	for _, id := range lockOrder(senderID, receiverID) {
+		var credit float64
+		err := tx.QueryRowContext(ctx, "SELECT COALESCE(credit, 0) FROM users WHERE id = $1 FOR UPDATE", id).Scan(&credit)
+		if errors.Is(err, sql.ErrNoRows) {
+			if id == senderID {
+				return apperr.ErrUserNotFound.WithMessage("sender not found")
+			}
+			return apperr.ErrUserNotFound.WithMessage("receiver not found")
+		}
+		if err != nil {
+			return err
+		}
+		credits[id] = credit
+	}
+
+	senderCreditBefore := credits[senderID]
+	receiverCreditBefore := credits[receiverID]
+	if senderCreditBefore < amount {
+		return apperr.ErrInsufficientFunds
+	}
+
+	senderCreditAfter := senderCreditBefore - amount
+	receiverCreditAfter := receiverCreditBefore + amount
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET credit = $1 WHERE id = $2", senderCreditAfter, senderID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET credit = $1 WHERE id = $2", receiverCreditAfter, receiverID); err != nil {
+		return err
+	}
+
+	err = chain.Append(ctx, tx, "postgres", &models.TransactionLog{
+		SenderID:             senderID,
+		ReceiverID:           receiverID,
+		Amount:               amount,
+		Description:          TransferDescription,
+		SenderCreditBefore:   senderCreditBefore,
+		ReceiverCreditBefore: receiverCreditBefore,
+		SenderCreditAfter:    senderCreditAfter,
+		ReceiverCreditAfter:  receiverCreditAfter,
+		TransactionDate:      time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := runTxHook(ctx, tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func lockOrder(a, b uint) []uint {
+	if a < b {
+		return []uint{a, b}
+	}
+	return []uint{b, a}
+}
+
+func (r *postgresUserRepository) GetTransactionLogsBySenderAndDate(ctx context.Context, senderID uint, date string) ([]models.TransactionLog, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+transactionLogColumns+`
+		FROM transaction_logs
+		WHERE sender_id = $1 AND DATE(transaction_date) = $2
+		ORDER BY transaction_date DESC`,
+		senderID, date,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTransactionLogs(rows)
+}
+
+const transactionLogColumns = `id, COALESCE(sender_id, 0), COALESCE(receiver_id, 0), amount, COALESCE(description, ''),
+		COALESCE(sender_credit_before, 0), COALESCE(receiver_credit_before, 0),
+		COALESCE(sender_credit_after, 0), COALESCE(receiver_credit_after, 0), transaction_date,
+		COALESCE(prev_hash, ''), COALESCE(hash, '')`
+
+func scanTransactionLogs(rows *sql.Rows) ([]models.TransactionLog, error) {
+	defer rows.Close()
+
+	var logs []models.TransactionLog
+	for rows.Next() {
+		var log models.TransactionLog
+		if err := rows.Scan(
+			&log.ID,
+			&log.SenderID,
+			&log.ReceiverID,
+			&log.Amount,
+			&log.Description,
+			&log.SenderCreditBefore,
+			&log.ReceiverCreditBefore,
+			&log.SenderCreditAfter,
+			&log.ReceiverCreditAfter,
+			&log.TransactionDate,
+			&log.PrevHash,
+			&log.Hash,
+		); err != nil {
+			return nil, err
+		}
+		logs = append(logs, log)
+	}
+
+	return logs, rows.Err()
+}
+
+func (r *postgresUserRepository) GetTransactionLogsByUser(ctx context.Context, userID uint) ([]models.TransactionLog, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+transactionLogColumns+`
+		FROM transaction_logs
+		WHERE sender_id = $1 OR receiver_id = $1
+		ORDER BY transaction_date DESC, id DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanTransactionLogs(rows)
+}
+
+func (r *postgresUserRepository) GetAllTransactionLogs(ctx context.Context) ([]models.TransactionLog, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+transactionLogColumns+" FROM transaction_logs ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	return scanTransactionLogs(rows)
+}
+
+func (r *postgresUserRepository) AddCredit(ctx context.Context, userID uint, amount float64) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		after, err := addCredit(ctx, tx, userID, amount)
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperr.ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := appendCreditLog(ctx, tx, "postgres", userID, after-amount, after, CreditDescription); err != nil {
+			return err
+		}
+		if err := r.record(ctx, tx, ActionCreditAdd, userTarget(userID), creditState{after - amount}, creditState{after}); err != nil {
+			return err
+		}
+		return runTxHook(ctx, tx)
+	})
+}
+
+// addCredit adds amount to a balance and returns the new balance, or
+// sql.ErrNoRows for an unknown user.
+func addCredit(ctx context.Context, tx *sql.Tx, userID uint, amount float64) (float64, error) {
+	var after float64
+	err := tx.QueryRowContext(ctx, "UPDATE users SET credit = COALESCE(credit, 0) + $1 WHERE id = $2 RETURNING credit", amount, userID).Scan(&after)
+	return after, err
+}
+
+func (r *postgresUserRepository) GetAllCredits(ctx context.Context) ([]models.User, error) {
+	users, err := r.queryCredits(ctx, "SELECT id, COALESCE(credit, 0) FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	if err := r.record(ctx, r.db, ActionCreditList, usersTarget, nil, map[string]int{"count": len(users)}); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *postgresUserRepository) GetMultipleUserCredits(ctx context.Context, userIDs []uint) ([]models.User, error) {
+	if len(userIDs) == 0 {
+		return []models.User{}, nil
+	}
+
+	placeholders := make([]string, len(userIDs))
+	args := make([]interface{}, len(userIDs))
+	for i, id := range userIDs {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+
+	users, err := r.queryCredits(ctx, "SELECT id, COALESCE(credit, 0) FROM users WHERE id IN ("+strings.Join(placeholders, ",")+") ORDER BY id", args...)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.record(ctx, r.db, ActionCreditRead, usersTarget, nil, map[string][]uint{"user_ids": userIDs}); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *postgresUserRepository) queryCredits(ctx context.Context, query string, args ...interface{}) ([]models.User, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.Credit); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (r *postgresUserRepository) ProcessBatchCreditUpdate(ctx context.Context, transactions []models.BatchTransaction) []models.BatchTransactionResult {
+	results := make([]models.BatchTransactionResult, len(transactions))
+	for i, txn := range transactions {
+		results[i] = models.BatchTransactionResult{UserID: txn.UserID, Amount: txn.Amount}
+	}
+	if len(transactions) == 0 {
+		return results
+	}
+
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		// Lock every user before the first entry takes the chain head, the
+		// order SendCredit locks in as well.
+		placeholders := make([]string, len(transactions))
+		args := make([]interface{}, len(transactions))
+		for i, txn := range transactions {
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+			args[i] = txn.UserID
+		}
+		rows, err := tx.QueryContext(ctx, "SELECT id FROM users WHERE id IN ("+strings.Join(placeholders, ",")+") ORDER BY id FOR UPDATE", args...)
+		if err != nil {
+			return err
+		}
+		found := make(map[uint]bool, len(transactions))
+		for rows.Next() {
+			var id uint
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return err
+			}
+			found[id] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for i, txn := range transactions {
+			if !found[txn.UserID] {
+				results[i].Error = "User not found"
+				results[i].Code = apperr.CodeUserNotFound
+				continue
+			}
+			after, err := addCredit(ctx, tx, txn.UserID, txn.Amount)
+			if err != nil {
+				return err
+			}
+			if err := r.record(ctx, tx, ActionCreditBatch, userTarget(txn.UserID),
+				creditState{after - txn.Amount}, creditState{after}); err != nil {
+				return err
+			}
+			if err := appendCreditLog(ctx, tx, "postgres", txn.UserID, after-txn.Amount, after, BatchDescription); err != nil {
+				return err
+			}
+			results[i].Success = true
+		}
+		return runTxHook(ctx, tx)
+	})
+	if err != nil {
+		// Nothing was committed, including entries reported as applied.
+		for i := range results {
+			results[i].Success = false
+			results[i].Error = "Update failed"
+			results[i].Code = apperr.CodeInternal
+		}
+	}
+
+	return results
+}
+
+func (r *postgresUserRepository) UpdateRole(ctx context.Context, userID uint, role string) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		var before string
+		err := tx.QueryRowContext(ctx, "SELECT role FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&before)
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperr.ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET role = $1 WHERE id = $2", role, userID); err != nil {
+			return err
+		}
+		return r.record(ctx, tx, ActionUserRole, userTarget(userID), roleState{before}, roleState{role})
+	})
+}
+
+// UpdatePassword records the reset without either hash.
+func (r *postgresUserRepository) UpdatePassword(ctx context.Context, userID uint, passwordHash string) error {
+	return r.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, "UPDATE users SET password_hash = $1 WHERE id = $2", passwordHash, userID)
+		if err != nil {
+			return err
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if affected == 0 {
+			return apperr.ErrUserNotFound
+		}
+		return r.record(ctx, tx, ActionUserPasswordReset, userTarget(userID), nil, nil)
+	})
+}
+
+func (r *postgresUserRepository) AdjustCredit(ctx context.Context, userID uint, amount float64) (float64, float64, error) {
+	var before, after float64
+	err := r.inTx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, "SELECT COALESCE(credit, 0) FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&before)
+		if errors.Is(err, sql.ErrNoRows) {
+			return apperr.ErrUserNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		after = before + amount
+		if after < 0 {
+			return apperr.ErrInsufficientFunds
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET credit = $1 WHERE id = $2", after, userID); err != nil {
+			return err
+		}
+		if err := appendCreditLog(ctx, tx, "postgres", userID, before, after, AdjustmentDescription); err != nil {
+			return err
+		}
+		return r.record(ctx, tx, ActionCreditAdjust, userTarget(userID), creditState{before}, creditState{after})
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return before, after, nil
+}
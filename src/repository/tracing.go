@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"Ledger/pkg/tracing"
+	"Ledger/src/audit"
+	"Ledger/src/models"
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("repository")
+
+// tracedUserRepository starts a span for every call and hands its context
+// to the repository it wraps, so GORM and Redis spans nest under it.
+type tracedUserRepository struct {
+	next UserRepository
+}
+
+// Traced returns next with every call traced.
+func Traced(next UserRepository) UserRepository {
+	return &tracedUserRepository{next: next}
+}
+
+func start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "UserRepository."+name, trace.WithAttributes(attrs...))
+}
+
+func (r *tracedUserRepository) WithAudit(e audit.Event) UserRepository {
+	return &tracedUserRepository{next: r.next.WithAudit(e)}
+}
+
+func (r *tracedUserRepository) Create(ctx context.Context, user *models.User) error {
+	ctx, span := start(ctx, "Create")
+	err := r.next.Create(ctx, user)
+	tracing.End(span, err)
+	return err
+}
+
+func (r *tracedUserRepository) GetAll(ctx context.Context) ([]models.User, error) {
+	ctx, span := start(ctx, "GetAll")
+	users, err := r.next.GetAll(ctx)
+	tracing.End(span, err)
+	return users, err
+}
+
+func (r *tracedUserRepository) GetByID(ctx context.Context, id uint) (*models.User, error) {
+	ctx, span := start(ctx, "GetByID", tracing.UserID(id))
+	user, err := r.next.GetByID(ctx, id)
+	tracing.End(span, err)
+	return user, err
+}
+
+func (r *tracedUserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, span := start(ctx, "GetByEmail")
+	user, err := r.next.GetByEmail(ctx, email)
+	tracing.End(span, err)
+	return user, err
+}
+
+func (r *tracedUserRepository) GetUserCredit(ctx context.Context, userID uint) (float64, error) {
+	ctx, span := start(ctx, "GetUserCredit", tracing.UserID(userID))
+	credit, err := r.next.GetUserCredit(ctx, userID)
+	tracing.End(span, err)
+	return credit, err
+}
+
+func (r *tracedUserRepository) SendCredit(ctx context.Context, senderID, receiverID uint, amount float64) error {
+	ctx, span := start(ctx, "SendCredit",
+		attribute.Int64("ledger.sender_id", int64(senderID)),
+		attribute.Int64("ledger.receiver_id", int64(receiverID)),
+	)
+	err := r.next.SendCredit(ctx, senderID, receiverID, amount)
+	tracing.End(span, err)
+	return err
+}
+
+func (r *tracedUserRepository) GetTransactionLogsBySenderAndDate(ctx context.Context, senderID uint, date string) ([]models.TransactionLog, error) {
+	ctx, span := start(ctx, "GetTransactionLogsBySenderAndDate", tracing.UserID(senderID))
+	logs, err := r.next.GetTransactionLogsBySenderAndDate(ctx, senderID, date)
+	tracing.End(span, err)
+	return logs, err
+}
+
+func (r *tracedUserRepository) AddCredit(ctx context.Context, userID uint, amount float64) error {
+	ctx, span := start(ctx, "AddCredit", tracing.UserID(userID))
+	err := r.next.AddCredit(ctx, userID, amount)
+	tracing.End(span, err)
+	return err
+}
+
+func (r *tracedUserRepository) GetAllCredits(ctx context.Context) ([]models.User, error) {
+	ctx, span := start(ctx, "GetAllCredits")
+	users, err := r.next.GetAllCredits(ctx)
+	tracing.End(span, err)
+	return users, err
+}
+
+func (r *tracedUserRepository) GetMultipleUserCredits(ctx context.Context, userIDs []uint) ([]models.User, error) {
+	ctx, span := start(ctx, "GetMultipleUserCredits", attribute.Int("ledger.users", len(userIDs)))
+	users, err := r.next.GetMultipleUserCredits(ctx, userIDs)
+	tracing.End(span, err)
+	return users, err
+}
+
+func (r *tracedUserRepository) ProcessBatchCreditUpdate(ctx context.Context, transactions []models.BatchTransaction) []models.BatchTransactionResult {
+	ctx, span := start(ctx, "ProcessBatchCreditUpdate", attribute.Int("ledger.batch_size", len(transactions)))
+	results := r.next.ProcessBatchCreditUpdate(ctx, transactions)
+	tracing.End(span, nil)
+	return results
+}
+
+func (r *tracedUserRepository) UpdateRole(ctx context.Context, userID uint, role string) error {
+	ctx, span := start(ctx, "UpdateRole", tracing.UserID(userID))
+	err := r.next.UpdateRole(ctx, userID, role)
+	tracing.End(span, err)
+	return err
+}
+
+func (r *tracedUserRepository) UpdatePassword(ctx context.Context, userID uint, passwordHash string) error {
+	ctx, span := start(ctx, "UpdatePassword", tracing.UserID(userID))
+	err := r.next.UpdatePassword(ctx, userID, passwordHash)
+	tracing.End(span, err)
+	return err
+}
+
+func (r *tracedUserRepository) AdjustCredit(ctx context.Context, userID uint, amount float64) (float64, float64, error) {
+	ctx, span := start(ctx, "AdjustCredit", tracing.UserID(userID))
+	before, after, err := r.next.AdjustCredit(ctx, userID, amount)
+	tracing.End(span, err)
+	return before, after, err
+}
+
+func (r *tracedUserRepository) GetTransactionLogsByUser(ctx context.Context, userID uint) ([]models.TransactionLog, error) {
+	ctx, span := start(ctx, "GetTransactionLogsByUser", tracing.UserID(userID))
+	logs, err := r.next.GetTransactionLogsByUser(ctx, userID)
+	tracing.End(span, err)
+	return logs, err
+}
+
+func (r *tracedUserRepository) GetAllTransactionLogs(ctx context.Context) ([]models.TransactionLog, error) {
+	ctx, span := start(ctx, "GetAllTransactionLogs")
+	logs, err := r.next.GetAllTransactionLogs(ctx)
+	tracing.End(span, err)
+	return logs, err
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"Ledger/src/audit"
+	"context"
+)
+
+// TxHook writes through exec, the transaction of a mutation, so that what it
+// writes commits or rolls back with the mutation. The queue marks a message
+// applied with it, in the same transaction as the money the message moves.
+type TxHook func(ctx context.Context, exec audit.Execer) error
+
+type txHookKey struct{}
+
+// WithTxHook returns ctx carrying hook. Create, SendCredit, AddCredit and
+// ProcessBatchCreditUpdate run it last in their transaction; a hook that
+// fails rolls the mutation back and its error is returned, or, for a batch,
+// every entry fails.
+func WithTxHook(ctx context.Context, hook TxHook) context.Context {
+	return context.WithValue(ctx, txHookKey{}, hook)
+}
+
+func runTxHook(ctx context.Context, exec audit.Execer) error {
+	hook, _ := ctx.Value(txHookKey{}).(TxHook)
+	if hook == nil {
+		return nil
+	}
+	return hook(ctx, exec)
+}
@@ -1,19 +1,52 @@
 package repository
 
 import (
+	"Ledger/src/audit"
 	"Ledger/src/models"
+	"context"
 )
 
+// TransferDescription is recorded on transaction logs written by SendCredit.
+const TransferDescription = "Credit transfer"
+
+// Descriptions recorded on transaction logs of balance changes that are not
+// transfers.
+const (
+	CreditDescription     = "Credit added"
+	AdjustmentDescription = "Credit adjustment"
+	BatchDescription      = "Batch credit update"
+)
+
+// UserRepository is implemented by every storage adapter. Behaviour that is
+// observable through this interface must not differ between adapters; the
+// contract suite in src/repository/contract checks that. Every method runs
+// its queries under ctx, so they stop when the caller gives up, and logs
+// with it.
 type UserRepository interface {
-	Create(user *models.User) error
-	GetAll() ([]models.User, error)
-	GetByID(id uint) (*models.User, error)
-	GetByEmail(email string) (*models.User, error)
-	GetUserCredit(userID uint) (float64, error)
-	SendCredit(senderID, receiverID uint, amount float64) error
-	GetTransactionLogsBySenderAndDate(senderID uint, date string) ([]models.TransactionLog, error)
-	AddCredit(userID uint, amount float64) error
-	GetAllCredits() ([]models.User, error)
-	GetMultipleUserCredits(userIDs []uint) ([]models.User, error)
-	ProcessBatchCreditUpdate(transactions []models.BatchTransaction) []models.BatchTransactionResult
+	Create(ctx context.Context, user *models.User) error
+	GetAll(ctx context.Context) ([]models.User, error)
+	GetByID(ctx context.Context, id uint) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetUserCredit(ctx context.Context, userID uint) (float64, error)
+	SendCredit(ctx context.Context, senderID, receiverID uint, amount float64) error
+	GetTransactionLogsBySenderAndDate(ctx context.Context, senderID uint, date string) ([]models.TransactionLog, error)
+	AddCredit(ctx context.Context, userID uint, amount float64) error
+	GetAllCredits(ctx context.Context) ([]models.User, error)
+	GetMultipleUserCredits(ctx context.Context, userIDs []uint) ([]models.User, error)
+	ProcessBatchCreditUpdate(ctx context.Context, transactions []models.BatchTransaction) []models.BatchTransactionResult
+	UpdateRole(ctx context.Context, userID uint, role string) error
+	UpdatePassword(ctx context.Context, userID uint, passwordHash string) error
+	// AdjustCredit adds amount, which may be negative, to the balance and
+	// returns the balance before and after. It fails instead of leaving a
+	// negative balance.
+	AdjustCredit(ctx context.Context, userID uint, amount float64) (float64, float64, error)
+	// GetTransactionLogsByUser returns the transfers a user sent or
+	// received and the changes to their own balance, newest first.
+	GetTransactionLogsByUser(ctx context.Context, userID uint) ([]models.TransactionLog, error)
+	GetAllTransactionLogs(ctx context.Context) ([]models.TransactionLog, error)
+	// WithAudit returns a repository whose privileged operations record e,
+	// completed with the action, target and before/after state. Mutations
+	// write the event in their own transaction, so neither exists without
+	// the other; privileged reads fail if the event cannot be written.
+	WithAudit(e audit.Event) UserRepository
 }
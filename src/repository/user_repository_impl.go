@@ -1,21 +1,29 @@
 package repository
 
 import (
+	"Ledger/pkg/apperr"
 	"Ledger/pkg/cache"
+	"Ledger/pkg/db"
+	"Ledger/src/audit"
+	"Ledger/src/chain"
 	"Ledger/src/models"
 	"context"
 	"errors"
-	"fmt"
+	"log/slog"
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type userRepository struct {
 	db    *gorm.DB
 	cache *cache.RedisCache
+	audit *audit.Event
 }
 
+// NewUserRepository returns the GORM/MySQL repository. cache may be nil, in
+// which case every read goes to the database.
 func NewUserRepository(db *gorm.DB, cache *cache.RedisCache) UserRepository {
 	return &userRepository{
 		db:    db,
@@ -23,132 +31,130 @@ func NewUserRepository(db *gorm.DB, cache *cache.RedisCache) UserRepository {
 	}
 }
 
-func (r *userRepository) Create(user *models.User) error {
-	return r.db.Create(user).Error
+func (r *userRepository) WithAudit(e audit.Event) UserRepository {
+	audited := *r
+	audited.audit = &e
+	return &audited
 }
 
-func (r *userRepository) GetAll() ([]models.User, error) {
+// record writes the pending audit event through tx, which is the scoped
+// r.db for reads and the open transaction for mutations.
+func (r *userRepository) record(ctx context.Context, tx *gorm.DB, action, target string, before, after interface{}) error {
+	return recordAudit(ctx, tx.Statement.ConnPool, "mysql", r.audit, action, target, before, after)
+}
+
+// logCredit chains the transaction log of a balance change made in tx.
+func (r *userRepository) logCredit(ctx context.Context, tx *gorm.DB, userID uint, before, after float64, description string) error {
+	return appendCreditLog(ctx, tx.Statement.ConnPool, "mysql", userID, before, after, description)
+}
+
+func (r *userRepository) Create(ctx context.Context, user *models.User) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			if db.IsDuplicate(err) {
+				return apperr.ErrEmailTaken.Wrap(err)
+			}
+			return err
+		}
+		if err := r.logCredit(ctx, tx, user.ID, 0, user.Credit, CreditDescription); err != nil {
+			return err
+		}
+		if err := r.record(ctx, tx, ActionUserCreate, userTarget(user.ID), nil, user); err != nil {
+			return err
+		}
+		return runTxHook(ctx, tx.Statement.ConnPool)
+	})
+}
+
+func (r *userRepository) GetAll(ctx context.Context) ([]models.User, error) {
+	db := r.db.WithContext(ctx)
 	var users []models.User
-	err := r.db.Find(&users).Error
-	return users, err
+	if err := db.Find(&users).Error; err != nil {
+		return nil, err
+	}
+	if err := r.record(ctx, db, ActionUserList, usersTarget, nil, map[string]int{"count": len(users)}); err != nil {
+		return nil, err
+	}
+	return users, nil
 }
 
-func (r *userRepository) GetByID(id uint) (*models.User, error) {
+func (r *userRepository) GetByID(ctx context.Context, id uint) (*models.User, error) {
+	db := r.db.WithContext(ctx)
 	var user models.User
-	err := r.db.First(&user, id).Error
-	return &user, err
+	if err := db.First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperr.ErrUserNotFound
+		}
+		return nil, err
+	}
+	if err := r.record(ctx, db, ActionUserRead, userTarget(id), nil, nil); err != nil {
+		return nil, err
+	}
+	return &user, nil
 }
 
-func (r *userRepository) GetByEmail(email string) (*models.User, error) {
+func (r *userRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("email = ?", email).First(&user).Error
-	return &user, err
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperr.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
 }
 
-func (r *userRepository) GetUserCredit(userID uint) (float64, error) {
-	ctx := context.Background()
-
-	credit, err := r.cache.GetUserCredit(ctx, userID)
-	if err == nil {
-		fmt.Printf("Cache HIT for user %d: %f\n", userID, credit)
-		return credit, nil
+func (r *userRepository) GetUserCredit(ctx context.Context, userID uint) (float64, error) {
+	if r.cache != nil {
+		credit, err := r.cache.GetUserCredit(ctx, userID)
+		if err == nil {
+			slog.DebugContext(ctx, "Credit cache hit", "user_id", userID)
+			return credit, nil
+		}
+		slog.DebugContext(ctx, "Credit cache miss", "user_id", userID)
 	}
-	fmt.Printf("Cache MISS for user %d\n", userID)
 
+	db := r.db.WithContext(ctx)
 	var user models.User
-	if err := r.db.First(&user, userID).Error; err != nil {
+	if err := db.First(&user, userID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return 0, errors.New("user not found")
+			return 0, apperr.ErrUserNotFound
 		}
 		return 0, err
 	}
 
 	var dbCredit float64
-	err = r.db.Model(&models.User{}).Where("id = ?", userID).Select("credit").Scan(&dbCredit).Error
+	err := db.Model(&models.User{}).Where("id = ?", userID).Select("credit").Scan(&dbCredit).Error
 	if err != nil {
 		return 0, err
 	}
 
-	err = r.cache.SetUserCredit(ctx, userID, dbCredit)
-	if err != nil {
-		fmt.Printf("Failed to set cache for user %d: %v\n", userID, err)
-	} else {
-		fmt.Printf("Cache SET for user %d: %f\n", userID, dbCredit)
+	if r.cache != nil {
+		if err := r.cache.SetUserCredit(ctx, userID, dbCredit); err != nil {
+			slog.WarnContext(ctx, "Credit cache update failed", "user_id", userID, "error", err)
+		} else {
+			slog.DebugContext(ctx, "Credit cached", "user_id", userID)
+		}
 	}
 
 	return dbCredit, nil
 }
 
-func (r *userRepository) UpdateCredit(userID uint, newAmount float64) error {
-	ctx := context.Background()
-
-	err := r.db.Model(&models.User{}).Where("id = ?", userID).Update("credit", newAmount).Error
-	if err != nil {
-		return err
-	}
-
-	_ = r.cache.InvalidateUserCredit(ctx, userID)
-
-	return nil
-}
-
-func (r *userRepository) GetAllCredits() ([]models.User, error) {
+func (r *userRepository) GetAllCredits(ctx context.Context) ([]models.User, error) {
+	db := r.db.WithContext(ctx)
 	var users []models.User
-	err := r.db.Select("id, credit").Find(&users).Error
-	return users, err
-}
-
-func (r *userRepository) SendCreditToUser(senderID, receiverID uint, amount float64) error {
-	var receiver models.User
-	if err := r.db.First(&receiver, receiverID).Error; err != nil {
-		return errors.New("receiver not found")
-	}
-
-	var sender models.User
-	if err := r.db.First(&sender, senderID).Error; err != nil {
-		return errors.New("sender not found")
+	if err := db.Select("id, credit").Find(&users).Error; err != nil {
+		return nil, err
 	}
-
-	if sender.Credit < amount {
-		return errors.New("insufficient balance")
+	if err := r.record(ctx, db, ActionCreditList, usersTarget, nil, map[string]int{"count": len(users)}); err != nil {
+		return nil, err
 	}
-
-	return r.db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Model(&models.User{}).
-			Where("id = ?", senderID).
-			Update("credit", gorm.Expr("credit - ?", amount)).Error; err != nil {
-			return err
-		}
-
-		if err := tx.Model(&models.User{}).
-			Where("id = ?", receiverID).
-			Update("credit", gorm.Expr("credit + ?", amount)).Error; err != nil {
-			return err
-		}
-
-		logEntry := models.TransactionLog{
-			SenderID:             senderID,
-			ReceiverID:           receiverID,
-			Amount:               amount,
-			SenderCreditBefore:   sender.Credit,
-			ReceiverCreditBefore: receiver.Credit,
-			TransactionDate:      time.Now(),
-		}
-		if err := tx.Create(&logEntry).Error; err != nil {
-			return err
-		}
-
-		return nil
-	})
-}
-
-func (r *userRepository) LogTransaction(senderID, receiverID uint, amount, senderCreditBefore, receiverCreditBefore float64) error {
-	return nil
+	return users, nil
 }
 
-func (r *userRepository) GetTransactionLogsBySenderAndDate(senderID uint, date string) ([]models.TransactionLog, error) {
+func (r *userRepository) GetTransactionLogsBySenderAndDate(ctx context.Context, senderID uint, date string) ([]models.TransactionLog, error) {
 	var logs []models.TransactionLog
-	query := r.db.Model(&models.TransactionLog{})
+	query := r.db.WithContext(ctx).Model(&models.TransactionLog{})
 
 	err := query.
 		Where("sender_id = ?", senderID).
@@ -159,104 +165,156 @@ func (r *userRepository) GetTransactionLogsBySenderAndDate(senderID uint, date s
 	return logs, err
 }
 
-func (r *userRepository) GetMultipleUserCredits(userIDs []uint) ([]models.User, error) {
+func (r *userRepository) GetMultipleUserCredits(ctx context.Context, userIDs []uint) ([]models.User, error) {
+	db := r.db.WithContext(ctx)
 	var users []models.User
-	err := r.db.Select("id, credit").Where("id IN ?", userIDs).Find(&users).Error
-	return users, err
+	if err := db.Select("id, credit").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	if err := r.record(ctx, db, ActionCreditRead, usersTarget, nil, map[string][]uint{"user_ids": userIDs}); err != nil {
+		return nil, err
+	}
+	return users, nil
 }
 
-func (r *userRepository) ProcessBatchCreditUpdate(transactions []models.BatchTransaction) []models.BatchTransactionResult {
+func (r *userRepository) ProcessBatchCreditUpdate(ctx context.Context, transactions []models.BatchTransaction) []models.BatchTransactionResult {
 	results := make([]models.BatchTransactionResult, len(transactions))
 	updatedUserIDs := make([]uint, 0)
 
-	err := r.db.Transaction(func(tx *gorm.DB) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Lock every user before the first entry takes the chain head, the
+		// order SendCredit locks in as well.
+		userIDs := make([]uint, len(transactions))
 		for i, txn := range transactions {
-			var user models.User
-			if err := tx.First(&user, txn.UserID).Error; err != nil {
+			userIDs[i] = txn.UserID
+		}
+		var locked []models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id IN ?", userIDs).Order("id").Find(&locked).Error; err != nil {
+			return err
+		}
+		users := make(map[uint]*models.User, len(locked))
+		for i := range locked {
+			users[locked[i].ID] = &locked[i]
+		}
+
+		for i, txn := range transactions {
+			user, ok := users[txn.UserID]
+			if !ok {
 				results[i] = models.BatchTransactionResult{
 					Success: false,
 					UserID:  txn.UserID,
 					Amount:  txn.Amount,
 					Error:   "User not found",
+					Code:    apperr.CodeUserNotFound,
 				}
 				continue
 			}
 
-			if err := tx.Model(&user).Update("credit", user.Credit+txn.Amount).Error; err != nil {
-				results[i] = models.BatchTransactionResult{
-					Success: false,
-					UserID:  txn.UserID,
-					Amount:  txn.Amount,
-					Error:   "Update failed",
-				}
-			} else {
-				results[i] = models.BatchTransactionResult{
-					Success: true,
-					UserID:  txn.UserID,
-					Amount:  txn.Amount,
-				}
-				updatedUserIDs = append(updatedUserIDs, txn.UserID)
+			before := user.Credit
+			after := before + txn.Amount
+			// A failed update fails the whole batch, as it does on Postgres,
+			// so an error of the database is never reported as one entry's.
+			if err := tx.Model(user).Update("credit", after).Error; err != nil {
+				return err
 			}
+			if err := r.record(ctx, tx, ActionCreditBatch, userTarget(txn.UserID),
+				creditState{before}, creditState{after}); err != nil {
+				return err
+			}
+			if err := r.logCredit(ctx, tx, txn.UserID, before, after, BatchDescription); err != nil {
+				return err
+			}
+			results[i] = models.BatchTransactionResult{
+				Success: true,
+				UserID:  txn.UserID,
+				Amount:  txn.Amount,
+			}
+			updatedUserIDs = append(updatedUserIDs, txn.UserID)
 		}
 
-		if len(updatedUserIDs) > 0 {
-			ctx := context.Background()
-			go func() {
-				_ = r.cache.InvalidateMultipleUserCredits(ctx, updatedUserIDs)
-			}()
-		}
-
-		return nil
+		return runTxHook(ctx, tx.Statement.ConnPool)
 	})
 
 	if err != nil {
+		// Nothing was committed, including entries reported as applied.
+		for i := range results {
+			results[i].Success = false
+			results[i].Error = "Update failed"
+			results[i].Code = apperr.CodeInternal
+		}
 		return results
 	}
 
+	if len(updatedUserIDs) > 0 && r.cache != nil {
+		background(ctx, func(ctx context.Context) {
+			_ = r.cache.InvalidateMultipleUserCredits(ctx, updatedUserIDs)
+		})
+	}
+
 	return results
 }
 
-func (r *userRepository) SendCredit(senderID, receiverID uint, amount float64) error {
-	var sender, receiver models.User
-	if err := r.db.First(&sender, senderID).Error; err != nil {
-		return errors.New("sender not found")
-	}
-	if err := r.db.First(&receiver, receiverID).Error; err != nil {
-		return errors.New("receiver not found")
+func (r *userRepository) SendCredit(ctx context.Context, senderID, receiverID uint, amount float64) error {
+	if senderID == receiverID {
+		// Both sides would be the same row, and the debit would be undone
+		// by the credit written over it.
+		return apperr.ErrInvalidRequest.WithMessage("sender and receiver must differ")
 	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Lock both rows in id order so concurrent transfers in opposite
+		// directions cannot deadlock.
+		users := make(map[uint]*models.User, 2)
+		for _, id := range lockOrder(senderID, receiverID) {
+			var user models.User
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, id).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				if id == senderID {
+					return apperr.ErrUserNotFound.WithMessage("sender not found")
+				}
+				return apperr.ErrUserNotFound.WithMessage("receiver not found")
+			}
+			if err != nil {
+				return err
+			}
+			users[id] = &user
+		}
+		sender, receiver := users[senderID], users[receiverID]
 
-	if sender.Credit < amount {
-		return errors.New("insufficient balance")
-	}
+		if sender.Credit < amount {
+			return apperr.ErrInsufficientFunds
+		}
 
-	err := r.db.Transaction(func(tx *gorm.DB) error {
 		senderCreditBefore := sender.Credit
 		receiverCreditBefore := receiver.Credit
+		senderCreditAfter := senderCreditBefore - amount
+		receiverCreditAfter := receiverCreditBefore + amount
 
 		// Update sender's credit
-		if err := tx.Model(&sender).Update("credit", sender.Credit-amount).Error; err != nil {
+		if err := tx.Model(sender).Update("credit", senderCreditAfter).Error; err != nil {
 			return err
 		}
 
 		// Update receiver's credit
-		if err := tx.Model(&receiver).Update("credit", receiver.Credit+amount).Error; err != nil {
+		if err := tx.Model(receiver).Update("credit", receiverCreditAfter).Error; err != nil {
 			return err
 		}
 
-		// Log the transaction
+		// Log the transaction as the next link of the chain
 		transactionLog := models.TransactionLog{
 			SenderID:             senderID,
 			ReceiverID:           receiverID,
 			Amount:               amount,
+			Description:          TransferDescription,
 			SenderCreditBefore:   senderCreditBefore,
 			ReceiverCreditBefore: receiverCreditBefore,
+			SenderCreditAfter:    senderCreditAfter,
+			ReceiverCreditAfter:  receiverCreditAfter,
 			TransactionDate:      time.Now(),
 		}
-		if err := tx.Create(&transactionLog).Error; err != nil {
+		if err := chain.Append(ctx, tx.Statement.ConnPool, "mysql", &transactionLog); err != nil {
 			return err
 		}
-
-		return nil
+		return runTxHook(ctx, tx.Statement.ConnPool)
 	})
 
 	if err != nil {
@@ -264,29 +322,135 @@ func (r *userRepository) SendCredit(senderID, receiverID uint, amount float64) e
 	}
 
 	// Invalidate cache for both users
-	ctx := context.Background()
-	go func() {
-		_ = r.cache.InvalidateUserCredit(ctx, senderID)
-		_ = r.cache.InvalidateUserCredit(ctx, receiverID)
-	}()
+	background(ctx, func(ctx context.Context) {
+		r.invalidate(ctx, senderID)
+		r.invalidate(ctx, receiverID)
+	})
 
 	return nil
 }
 
-func (r *userRepository) AddCredit(userID uint, amount float64) error {
-	var user models.User
-	if err := r.db.First(&user, userID).Error; err != nil {
-		return errors.New("user not found")
-	}
+func (r *userRepository) AddCredit(ctx context.Context, userID uint, amount float64) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user, err := lockUser(tx, userID)
+		if err != nil {
+			return err
+		}
 
-	if err := r.db.Model(&user).Update("credit", user.Credit+amount).Error; err != nil {
+		// Update writes the new value back into user, so keep the old one.
+		before := user.Credit
+		after := before + amount
+		if err := tx.Model(user).Update("credit", after).Error; err != nil {
+			return err
+		}
+		if err := r.logCredit(ctx, tx, userID, before, after, CreditDescription); err != nil {
+			return err
+		}
+		if err := r.record(ctx, tx, ActionCreditAdd, userTarget(userID), creditState{before}, creditState{after}); err != nil {
+			return err
+		}
+		return runTxHook(ctx, tx.Statement.ConnPool)
+	})
+	if err != nil {
 		return err
 	}
 
-	ctx := context.Background()
-	go func() {
-		_ = r.cache.InvalidateUserCredit(ctx, userID)
-	}()
+	background(ctx, func(ctx context.Context) {
+		r.invalidate(ctx, userID)
+	})
 
 	return nil
 }
+
+func (r *userRepository) UpdateRole(ctx context.Context, userID uint, role string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user, err := lockUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		before := user.Role
+		if err := tx.Model(user).Update("role", role).Error; err != nil {
+			return err
+		}
+		return r.record(ctx, tx, ActionUserRole, userTarget(userID), roleState{before}, roleState{role})
+	})
+}
+
+// UpdatePassword records the reset without either hash.
+func (r *userRepository) UpdatePassword(ctx context.Context, userID uint, passwordHash string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user, err := lockUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(user).Update("Password_Hash", passwordHash).Error; err != nil {
+			return err
+		}
+		return r.record(ctx, tx, ActionUserPasswordReset, userTarget(userID), nil, nil)
+	})
+}
+
+// lockUser reads a user inside tx and holds its row lock until tx ends.
+func lockUser(tx *gorm.DB, userID uint) (*models.User, error) {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperr.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (r *userRepository) AdjustCredit(ctx context.Context, userID uint, amount float64) (float64, float64, error) {
+	var before, after float64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user, err := lockUser(tx, userID)
+		if err != nil {
+			return err
+		}
+
+		before = user.Credit
+		after = before + amount
+		if after < 0 {
+			return apperr.ErrInsufficientFunds
+		}
+		if err := tx.Model(user).Update("credit", after).Error; err != nil {
+			return err
+		}
+		if err := r.logCredit(ctx, tx, userID, before, after, AdjustmentDescription); err != nil {
+			return err
+		}
+		return r.record(ctx, tx, ActionCreditAdjust, userTarget(userID), creditState{before}, creditState{after})
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	r.invalidate(context.WithoutCancel(ctx), userID)
+	return before, after, nil
+}
+
+func (r *userRepository) GetTransactionLogsByUser(ctx context.Context, userID uint) ([]models.TransactionLog, error) {
+	var logs []models.TransactionLog
+	err := r.db.WithContext(ctx).
+		Where("sender_id = ? OR receiver_id = ?", userID, userID).
+		Order("transaction_date DESC").
+		Order("id DESC").
+		Find(&logs).Error
+	return logs, err
+}
+
+func (r *userRepository) GetAllTransactionLogs(ctx context.Context) ([]models.TransactionLog, error) {
+	var logs []models.TransactionLog
+	err := r.db.WithContext(ctx).Order("id").Find(&logs).Error
+	return logs, err
+}
+
+func (r *userRepository) invalidate(ctx context.Context, userID uint) {
+	if r.cache != nil {
+		if err := r.cache.InvalidateUserCredit(ctx, userID); err != nil {
+			slog.WarnContext(ctx, "Credit cache invalidation failed", "user_id", userID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package router
+
+import (
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/middleware"
+	"Ledger/pkg/response"
+	"Ledger/src/audit"
+	"Ledger/src/balance"
+	"Ledger/src/chain"
+	"Ledger/src/factory"
+	"Ledger/src/handlers"
+	"Ledger/src/lockout"
+	"Ledger/src/models"
+	"Ledger/src/openapi"
+	"Ledger/src/reconcile"
+	"Ledger/src/statement"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// New builds the HTTP routes shared by the server and the lambda.
+func New(f factory.Factory) *mux.Router {
+	userHandler := f.NewUserHandler()
+	auditHandler := f.NewAuditHandler()
+	chainHandler := f.NewChainHandler()
+	reconcileHandler := f.NewReconcileHandler()
+	balanceHandler := f.NewBalanceHandler()
+	statementHandler := f.NewStatementHandler()
+	exportHandler := f.NewExportHandler()
+	healthHandler := f.NewHealthHandler()
+	lockoutHandler := f.NewLockoutHandler()
+	authMiddleware := f.NewAuthMiddleware()
+	rateLimits := f.NewRateLimitMiddleware()
+	idempotency := f.NewIdempotencyMiddleware()
+	clientAddress := f.NewClientAddressMiddleware()
+
+	router := mux.NewRouter()
+	router.Use(middleware.RequestLogger, middleware.Tracing, middleware.Metrics, clientAddress)
+	router.NotFoundHandler = middleware.RequestLogger(middleware.Tracing(middleware.Metrics(http.HandlerFunc(notFound))))
+	router.MethodNotAllowedHandler = middleware.RequestLogger(middleware.Tracing(middleware.Metrics(http.HandlerFunc(methodNotAllowed))))
+
+	router.HandleFunc("/", root).Methods("GET")
+	router.HandleFunc("/healthz", healthHandler.Live).Methods("GET")
+	router.HandleFunc("/readyz", healthHandler.Ready).Methods("GET")
+	if metricsHandler := f.NewMetricsHandler(); metricsHandler != nil {
+		router.Handle("/metrics", metricsHandler).Methods("GET")
+	}
+
+	// The unversioned routes are the API before /v1, kept for old clients.
+	// Their responses point to the /v1 route that replaces them.
+	deprecated := middleware.Deprecated
+	router.HandleFunc("/users/add-user", deprecated("/v1/users", rateLimits.Signup(userHandler.CreateUser))).Methods("POST")
+	router.HandleFunc("/register", deprecated("/v1/users", rateLimits.Signup(userHandler.CreateUser))).Methods("POST")
+	router.HandleFunc("/login", deprecated("/v1/sessions", rateLimits.Login(userHandler.Login))).Methods("POST")
+
+	router.HandleFunc("/users/get-credit", deprecated("/v1/accounts/{id}/balance", authMiddleware.Authenticate(userHandler.GetCredit))).Methods("GET")
+	router.HandleFunc("/users/send-credit", deprecated("/v1/transfers", authMiddleware.Authenticate(rateLimits.Transfer(userHandler.SendCredit)))).Methods("POST")
+	router.HandleFunc("/users/transaction-logs/sender", deprecated("/v1/accounts/{id}/transactions", authMiddleware.Authenticate(userHandler.GetTransactionLogsBySenderAndDate))).Methods("GET")
+	router.HandleFunc("/accounts/{id}/balance", deprecated("/v1/accounts/{id}/balance", authMiddleware.Authenticate(balanceHandler.GetBalance))).Methods("GET")
+	router.HandleFunc("/accounts/{id}/balances", deprecated("/v1/accounts/{id}/balances", authMiddleware.Authenticate(balanceHandler.GetBalances))).Methods("GET")
+	router.HandleFunc("/accounts/{id}/statement", deprecated("/v1/accounts/{id}/statement", authMiddleware.Authenticate(statementHandler.GetStatement))).Methods("GET")
+	router.HandleFunc("/accounts/{id}/export", deprecated("/v1/accounts/{id}/export", authMiddleware.Authenticate(exportHandler.GetExport))).Methods("GET")
+
+	router.HandleFunc("/users", deprecated("/v1/users", authMiddleware.Authenticate(authMiddleware.AdminOnly(userHandler.GetAllUsers)))).Methods("GET")
+	router.HandleFunc("/users/get-user", deprecated("/v1/users/{id}", authMiddleware.Authenticate(authMiddleware.AdminOnly(userHandler.GetUserByID)))).Methods("GET")
+	router.HandleFunc("/users/add-credit", deprecated("/v1/accounts/{id}/credits", authMiddleware.Authenticate(authMiddleware.AdminOnly(userHandler.AddCredit)))).Methods("POST")
+	router.HandleFunc("/users/credits", deprecated("/v1/accounts", authMiddleware.Authenticate(authMiddleware.AdminOnly(userHandler.GetAllCredits)))).Methods("GET")
+	router.HandleFunc("/users/batch/credits", deprecated("/v1/accounts", authMiddleware.Authenticate(authMiddleware.AdminOnly(userHandler.GetMultipleUserCredits)))).Methods("POST")
+	router.HandleFunc("/users/batch/update-credits", deprecated("/v1/credit-batches", authMiddleware.Authenticate(authMiddleware.AdminOnly(userHandler.ProcessBatchCreditUpdate)))).Methods("POST")
+	router.HandleFunc("/admin/audit-events", deprecated("/v1/admin/audit-events", authMiddleware.Authenticate(authMiddleware.AdminOnly(auditHandler.ListEvents)))).Methods("GET")
+	router.HandleFunc("/admin/audit-events/export", deprecated("/v1/admin/audit-events/export", authMiddleware.Authenticate(authMiddleware.AdminOnly(auditHandler.ExportEvents)))).Methods("GET")
+	router.HandleFunc("/admin/ledger/verify", deprecated("/v1/admin/ledger/verify", authMiddleware.Authenticate(authMiddleware.AdminOnly(chainHandler.Verify)))).Methods("GET")
+	router.HandleFunc("/admin/ledger/checkpoints", deprecated("/v1/admin/ledger/checkpoints", authMiddleware.Authenticate(authMiddleware.AdminOnly(chainHandler.ListCheckpoints)))).Methods("GET")
+	router.HandleFunc("/admin/ledger/checkpoints", deprecated("/v1/admin/ledger/checkpoints", authMiddleware.Authenticate(authMiddleware.AdminOnly(chainHandler.CreateCheckpoint)))).Methods("POST")
+	router.HandleFunc("/admin/reconciliation", deprecated("/v1/admin/reconciliation", authMiddleware.Authenticate(authMiddleware.AdminOnly(reconcileHandler.Check)))).Methods("GET")
+	router.HandleFunc("/admin/reconciliation", deprecated("/v1/admin/reconciliation", authMiddleware.Authenticate(authMiddleware.AdminOnly(reconcileHandler.Repair)))).Methods("POST")
+	router.HandleFunc("/admin/lockouts", deprecated("/v1/admin/lockouts", authMiddleware.Authenticate(authMiddleware.AdminOnly(lockoutHandler.ListLocked)))).Methods("GET")
+	router.HandleFunc("/admin/users/{id}/unlock", deprecated("/v1/admin/users/{id}/unlock", authMiddleware.Authenticate(authMiddleware.AdminOnly(lockoutHandler.Unlock)))).Methods("POST")
+
+	// Operational endpoints stay unversioned.
+	router.HandleFunc("/status", authMiddleware.Authenticate(authMiddleware.AdminOnly(healthHandler.Status))).Methods("GET")
+
+	doc := f.NewAPIDocument()
+	router.Handle("/openapi.json", doc).Methods("GET")
+	v1 := &api{router: router, prefix: "/v1", doc: doc, auth: authMiddleware, idempotency: idempotency}
+
+	id := openapi.Path("id", "ID of the user")
+	period := func(format openapi.Param) []openapi.Param {
+		return []openapi.Param{
+			id,
+			openapi.Query("month", "Month of the statement, YYYY-MM; the last month by default"),
+			openapi.Query("from", "First day, YYYY-MM-DD, instead of month"),
+			openapi.Query("to", "Last day, YYYY-MM-DD, instead of month"),
+			format,
+		}
+	}
+
+	v1.handle(openapi.Operation{Method: "POST", Path: "/users", ID: "createUser", Summary: "Register a user", Tag: "users",
+		Body: models.RegisterRequest{}, Status: http.StatusCreated, Response: handlers.UserCreated{}, Idempotent: true},
+		rateLimits.Signup(userHandler.CreateUser))
+	v1.handle(openapi.Operation{Method: "POST", Path: "/sessions", ID: "createSession", Summary: "Log in and get a bearer token", Tag: "users",
+		Body: models.LoginRequest{}, Response: handlers.Token{}},
+		rateLimits.Login(userHandler.Login))
+	v1.handle(openapi.Operation{Method: "GET", Path: "/users", ID: "listUsers", Summary: "List the users", Tag: "users", Auth: openapi.Admin,
+		Response: []models.User{}},
+		userHandler.GetAllUsers)
+	v1.handle(openapi.Operation{Method: "GET", Path: "/users/{id}", ID: "getUser", Summary: "Get a user", Tag: "users", Auth: openapi.User,
+		Params: []openapi.Param{id}, Response: models.User{}},
+		userHandler.GetUser)
+
+	v1.handle(openapi.Operation{Method: "GET", Path: "/accounts", ID: "listAccounts", Summary: "List the balances of the accounts", Tag: "accounts", Auth: openapi.Admin,
+		Params: []openapi.Param{openapi.Query("ids", "Comma separated user IDs; every account when omitted")}, Response: []models.User{}},
+		userHandler.ListAccounts)
+	v1.handle(openapi.Operation{Method: "GET", Path: "/accounts/{id}/balance", ID: "getBalance", Summary: "Get the balance of an account, now or at a time", Tag: "accounts", Auth: openapi.User,
+		Params: []openapi.Param{id, openapi.Query("at", "RFC 3339 timestamp, or a date for the end of that UTC day")}, Response: balance.Point{}},
+		balanceHandler.GetBalance)
+	v1.handle(openapi.Operation{Method: "GET", Path: "/accounts/{id}/balances", ID: "listBalances", Summary: "Get the daily balances of an account", Tag: "accounts", Auth: openapi.User,
+		Params:   []openapi.Param{id, openapi.Query("from", "First day, YYYY-MM-DD"), openapi.Query("to", "Last day, YYYY-MM-DD; today by default"), openapi.Query("interval", "Only day is supported")},
+		Response: handlers.BalanceSeries{}},
+		balanceHandler.GetBalances)
+	v1.handle(openapi.Operation{Method: "GET", Path: "/accounts/{id}/transactions", ID: "listTransactions", Summary: "List the transfers of an account", Tag: "accounts", Auth: openapi.User,
+		Params: []openapi.Param{id, openapi.Query("date", "Only the transfers the account sent on this day, YYYY-MM-DD")}, Response: []models.TransactionLog{}},
+		userHandler.ListTransactions)
+	v1.handle(openapi.Operation{Method: "GET", Path: "/accounts/{id}/statement", ID: "getStatement", Summary: "Get the statement of an account", Tag: "accounts", Auth: openapi.User,
+		Params: period(openapi.Query("format", "csv, json or pdf; json by default")), Response: statement.Statement{}, Produces: []string{"text/csv", "application/pdf"}},
+		statementHandler.GetStatement)
+	v1.handle(openapi.Operation{Method: "GET", Path: "/accounts/{id}/export", ID: "getExport", Summary: "Export the statement of an account for a bank", Tag: "accounts", Auth: openapi.User,
+		Params: period(openapi.Query("format", "camt053 or ofx; camt053 by default")), Produces: []string{"application/xml", "application/x-ofx"}},
+		exportHandler.GetExport)
+	v1.handle(openapi.Operation{Method: "POST", Path: "/accounts/{id}/credits", ID: "creditAccount", Summary: "Add credit to an account", Tag: "accounts", Auth: openapi.Admin,
+		Params: []openapi.Param{id}, Body: handlers.CreditRequest{}, Response: handlers.Message{}, Idempotent: true},
+		userHandler.CreditAccount)
+	v1.handle(openapi.Operation{Method: "POST", Path: "/transfers", ID: "createTransfer", Summary: "Transfer credit between accounts", Tag: "transfers", Auth: openapi.User,
+		Body: handlers.TransferRequest{}, Response: handlers.Message{}, Idempotent: true},
+		rateLimits.Transfer(userHandler.CreateTransfer))
+	v1.handle(openapi.Operation{Method: "POST", Path: "/credit-batches", ID: "createCreditBatch", Summary: "Add or remove credit of many accounts at once", Tag: "transfers", Auth: openapi.Admin,
+		Body: handlers.BatchCreditRequest{}, Response: []models.BatchTransactionResult{}, Idempotent: true},
+		userHandler.ProcessBatchCreditUpdate)
+
+	v1.handle(openapi.Operation{Method: "GET", Path: "/admin/audit-events", ID: "listAuditEvents", Summary: "Search the audit trail", Tag: "admin", Auth: openapi.Admin,
+		Params: auditFilter(openapi.Query("limit", "At most this many events, 100 by default"), openapi.Query("offset", "Events to skip")), Response: []audit.Event{}},
+		auditHandler.ListEvents)
+	v1.handle(openapi.Operation{Method: "GET", Path: "/admin/audit-events/export", ID: "exportAuditEvents", Summary: "Export the audit trail", Tag: "admin", Auth: openapi.Admin,
+		Params: auditFilter(openapi.Query("format", "csv or ndjson; csv by default")), Produces: []string{"text/csv", "application/x-ndjson"}},
+		auditHandler.ExportEvents)
+	v1.handle(openapi.Operation{Method: "GET", Path: "/admin/ledger/verify", ID: "verifyLedger", Summary: "Verify the transaction chain; 409 when broken", Tag: "admin", Auth: openapi.Admin,
+		Response: chain.Report{}},
+		chainHandler.Verify)
+	v1.handle(openapi.Operation{Method: "GET", Path: "/admin/ledger/checkpoints", ID: "listCheckpoints", Summary: "List the chain checkpoints", Tag: "admin", Auth: openapi.Admin,
+		Response: []chain.Checkpoint{}},
+		chainHandler.ListCheckpoints)
+	v1.handle(openapi.Operation{Method: "POST", Path: "/admin/ledger/checkpoints", ID: "createCheckpoint", Summary: "Sign the chain head", Tag: "admin", Auth: openapi.Admin,
+		Response: chain.Checkpoint{}, Idempotent: true},
+		chainHandler.CreateCheckpoint)
+	v1.handle(openapi.Operation{Method: "GET", Path: "/admin/reconciliation", ID: "checkReconciliation", Summary: "Reconcile balances with the log; 409 on discrepancies", Tag: "admin", Auth: openapi.Admin,
+		Response: reconcile.Report{}},
+		reconcileHandler.Check)
+	v1.handle(openapi.Operation{Method: "POST", Path: "/admin/reconciliation", ID: "repairReconciliation", Summary: "Reconcile and drop cached balances that differ", Tag: "admin", Auth: openapi.Admin,
+		Response: reconcile.Report{}},
+		reconcileHandler.Repair)
+	v1.handle(openapi.Operation{Method: "GET", Path: "/admin/lockouts", ID: "listLockouts", Summary: "List the emails locked out of login", Tag: "admin", Auth: openapi.Admin,
+		Response: []lockout.Lockout{}},
+		lockoutHandler.ListLocked)
+	v1.handle(openapi.Operation{Method: "POST", Path: "/admin/users/{id}/unlock", ID: "unlockUser", Summary: "Clear the failed logins of a user", Tag: "admin", Auth: openapi.Admin,
+		Params: []openapi.Param{id}, Response: handlers.Unlocked{}},
+		lockoutHandler.Unlock)
+
+	return router
+}
+
+// api registers the routes of a version of the API and describes them in
+// the OpenAPI document. The authentication an operation declares is the one
+// its route gets, and so is its idempotency.
+type api struct {
+	router      *mux.Router
+	prefix      string
+	doc         *openapi.Document
+	auth        middleware.AuthMiddleware
+	idempotency middleware.IdempotencyMiddleware
+}
+
+func (a *api) handle(op openapi.Operation, handler http.HandlerFunc) {
+	if op.Idempotent {
+		handler = a.idempotency.Idempotent(handler)
+	}
+	switch op.Auth {
+	case openapi.User:
+		handler = a.auth.Authenticate(handler)
+	case openapi.Admin:
+		handler = a.auth.Authenticate(a.auth.AdminOnly(handler))
+	}
+	op.Path = a.prefix + op.Path
+	a.router.HandleFunc(op.Path, handler).Methods(op.Method)
+	a.doc.Add(op)
+}
+
+// auditFilter returns the query parameters of the audit endpoints.
+func auditFilter(extra ...openapi.Param) []openapi.Param {
+	return append([]openapi.Param{
+		openapi.Query("actor", "Email of the actor"),
+		openapi.Query("actor_id", "User ID of the actor"),
+		openapi.Query("action", "Action, such as credit.add"),
+		openapi.Query("target", "Target, such as user:42"),
+		openapi.Query("request_id", "Request ID"),
+		openapi.Query("from", "RFC 3339 timestamp"),
+		openapi.Query("to", "RFC 3339 timestamp"),
+	}, extra...)
+}
+
+func root(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"status":"ok","message":"Ledger API is running"}`))
+}
+
+func notFound(w http.ResponseWriter, r *http.Request) {
+	response.Error(w, r, apperr.ErrNotFound.WithMessage("Endpoint not found: %s", r.URL.Path))
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	response.Error(w, r, apperr.ErrMethodNotAllowed.WithMessage("Method %s not allowed on %s", r.Method, r.URL.Path))
+}
@@ -0,0 +1,244 @@
+package serverless
+
+import (
+	"Ledger/pkg/apigateway"
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/logging"
+	"Ledger/pkg/metrics"
+	"Ledger/pkg/response"
+	"Ledger/pkg/tracing"
+	"Ledger/src/balance"
+	"Ledger/src/factory"
+	"Ledger/src/queue"
+	"Ledger/src/reconcile"
+	"Ledger/src/router"
+	"Ledger/src/statement"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+)
+
+var tracer = tracing.Tracer("serverless")
+
+// Handler adapts lambda events to the shared service layer. HTTP events run
+// through the same router as the server and SQS batches through the command
+// processor. factory and commands may be nil when the database is
+// unavailable; API requests then fail with 503 and queued messages are
+// retried.
+type Handler struct {
+	commands   *queue.Processor
+	http       *apigateway.Adapter
+	reconciler *reconcile.Reconciler
+	snapshots  *balance.Store
+	statements *statement.Generator
+	metrics    *metrics.EMF
+	traces     *tracing.Provider
+}
+
+func NewHandler(f factory.Factory, commands *queue.Processor) *Handler {
+	var routes http.Handler = http.HandlerFunc(unavailable)
+	h := &Handler{commands: commands}
+	if f != nil {
+		routes = router.New(f)
+		h.reconciler = f.NewReconciler()
+		h.snapshots = f.NewBalanceStore()
+		h.statements = f.NewStatementGenerator()
+	}
+	h.http = apigateway.New(routes)
+	return h
+}
+
+func unavailable(w http.ResponseWriter, r *http.Request) {
+	response.Error(w, r, apperr.ErrUnavailable.WithMessage("Factory başlatılamadı"))
+}
+
+// sqsProbe tells SQS batches apart from HTTP events.
+type sqsProbe struct {
+	Records []struct {
+		EventSource string `json:"eventSource"`
+	} `json:"Records"`
+}
+
+// Jobs a scheduled event can run, named by "job" in its detail.
+const (
+	JobReconcile  = "reconcile"
+	JobSnapshot   = "snapshot"
+	JobStatements = "statements"
+)
+
+// scheduleProbe tells EventBridge scheduled events apart from HTTP events.
+// The event detail names the job, reconciliation when it names none; a
+// reconciliation rule can also set "repair_cache": true.
+type scheduleProbe struct {
+	Source string `json:"source"`
+	Detail struct {
+		Job         string `json:"job"`
+		RepairCache bool   `json:"repair_cache"`
+	} `json:"detail"`
+}
+
+// HandleRequest is the lambda entry point. It dispatches SQS batches,
+// EventBridge schedules and API Gateway (REST and HTTP API) and ALB
+// requests. A panic fails the invocation, so SQS retries the whole batch
+// rather than deleting it as processed.
+func (h *Handler) HandleRequest(ctx context.Context, payload json.RawMessage) (result interface{}, err error) {
+	// Records of the invocation carry its request ID until an HTTP request
+	// or a queued command brings its own.
+	if lc, ok := lambdacontext.FromContext(ctx); ok {
+		ctx = logging.WithRequestID(ctx, lc.AwsRequestID)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "Invocation panicked", "panic", fmt.Sprint(r), "stack", string(debug.Stack()))
+			result, err = nil, fmt.Errorf("invocation panicked: %v", r)
+		}
+	}()
+	defer h.flush(ctx)
+
+	var probe sqsProbe
+	if err := json.Unmarshal(payload, &probe); err == nil && len(probe.Records) > 0 && probe.Records[0].EventSource == "aws:sqs" {
+		var sqsEvent events.SQSEvent
+		if err := json.Unmarshal(payload, &sqsEvent); err != nil {
+			return nil, err
+		}
+		slog.InfoContext(ctx, "SQS olayı alındı", "records", len(sqsEvent.Records))
+		return h.HandleSQSEvent(ctx, sqsEvent), nil
+	}
+
+	var schedule scheduleProbe
+	if err := json.Unmarshal(payload, &schedule); err == nil && schedule.Source == "aws.events" {
+		return h.handleSchedule(ctx, schedule)
+	}
+
+	kind := apigateway.Detect(payload)
+	if kind == apigateway.UnknownEvent {
+		slog.WarnContext(ctx, "Desteklenmeyen olay türü")
+		return events.APIGatewayProxyResponse{
+			StatusCode: http.StatusBadRequest,
+			Body:       "Desteklenmeyen olay türü",
+		}, nil
+	}
+
+	slog.DebugContext(ctx, "HTTP olayı alındı", "kind", kind)
+	return h.http.Proxy(ctx, payload)
+}
+
+// flush exports the spans of the invocation and writes what it changed in
+// the metrics as EMF lines before the lambda is frozen.
+func (h *Handler) flush(ctx context.Context) {
+	if err := h.traces.Flush(ctx); err != nil {
+		slog.WarnContext(ctx, "İzler gönderilemedi", "error", err)
+	}
+	if h.metrics == nil {
+		return
+	}
+	if err := h.metrics.Flush(); err != nil {
+		slog.WarnContext(ctx, "Metrikler yazılamadı", "error", err)
+	}
+}
+
+// handleSchedule runs the job a scheduled event names in a span of its own.
+func (h *Handler) handleSchedule(ctx context.Context, schedule scheduleProbe) (result interface{}, err error) {
+	job := schedule.Detail.Job
+	if job == "" {
+		job = JobReconcile
+	}
+	ctx, span := tracer.Start(ctx, "schedule "+job)
+	defer func() { tracing.End(span, err) }()
+
+	switch job {
+	case JobSnapshot:
+		slog.InfoContext(ctx, "Zamanlanmış olay alındı, bakiye anlık görüntüleri alınıyor")
+		return h.HandleSnapshot(ctx)
+	case JobStatements:
+		slog.InfoContext(ctx, "Zamanlanmış olay alındı, geçen ayın hesap özetleri hazırlanıyor")
+		return h.HandleStatements(ctx)
+	case JobReconcile:
+		slog.InfoContext(ctx, "Zamanlanmış olay alındı, mutabakat başlıyor")
+		return h.HandleSchedule(ctx, reconcile.Options{RepairCache: schedule.Detail.RepairCache})
+	default:
+		slog.ErrorContext(ctx, "Bilinmeyen zamanlanmış iş", "job", schedule.Detail.Job)
+		return nil, errors.New("bilinmeyen zamanlanmış iş: " + schedule.Detail.Job)
+	}
+}
+
+// HandleSQSEvent runs queued commands and reports the records SQS should
+// retry.
+func (h *Handler) HandleSQSEvent(ctx context.Context, event events.SQSEvent) events.SQSEventResponse {
+	if h.commands == nil {
+		slog.ErrorContext(ctx, "Komut işlemcisi yok, kayıtlar yeniden denenecek", "records", len(event.Records))
+		var resp events.SQSEventResponse
+		for _, record := range event.Records {
+			resp.BatchItemFailures = append(resp.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: record.MessageId})
+		}
+		return resp
+	}
+	return h.commands.Process(ctx, event)
+}
+
+// HandleSchedule runs a reconciliation and returns its report. It fails
+// when the database is unavailable, so the schedule records the miss.
+func (h *Handler) HandleSchedule(ctx context.Context, opts reconcile.Options) (*reconcile.Report, error) {
+	if h.reconciler == nil {
+		slog.ErrorContext(ctx, "Mutabakat yapılamadı: veritabanı bağlantısı yok")
+		return nil, errors.New("mutabakat için veritabanı bağlantısı yok")
+	}
+	report, err := h.reconciler.Run(ctx, opts)
+	if err != nil {
+		slog.ErrorContext(ctx, "Mutabakat başarısız", "error", err)
+		return nil, err
+	}
+	reconcile.Log(ctx, report)
+	return report, nil
+}
+
+// SnapshotResult is what a snapshot schedule answers with.
+type SnapshotResult struct {
+	Days int `json:"days"`
+}
+
+// HandleSnapshot takes the end-of-day balance snapshots that are due. It
+// fails when the database is unavailable, so the schedule records the miss.
+func (h *Handler) HandleSnapshot(ctx context.Context) (*SnapshotResult, error) {
+	if h.snapshots == nil {
+		slog.ErrorContext(ctx, "Bakiye anlık görüntüsü alınamadı: veritabanı bağlantısı yok")
+		return nil, errors.New("bakiye anlık görüntüsü için veritabanı bağlantısı yok")
+	}
+	days, err := h.snapshots.SnapshotDue(ctx, time.Now())
+	if err != nil {
+		slog.ErrorContext(ctx, "Bakiye anlık görüntüsü başarısız", "days", days, "error", err)
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Bakiye anlık görüntüsü alındı", "days", days)
+	return &SnapshotResult{Days: days}, nil
+}
+
+// StatementsResult is what a statement schedule answers with.
+type StatementsResult struct {
+	Statements int `json:"statements"`
+}
+
+// HandleStatements pre-generates last month's statements that are missing.
+// It fails when the database is unavailable, so the schedule records the
+// miss.
+func (h *Handler) HandleStatements(ctx context.Context) (*StatementsResult, error) {
+	if h.statements == nil {
+		slog.ErrorContext(ctx, "Hesap özetleri hazırlanamadı: veritabanı bağlantısı yok")
+		return nil, errors.New("hesap özetleri için veritabanı bağlantısı yok")
+	}
+	stored, err := h.statements.PregenerateDue(ctx, time.Now())
+	if err != nil {
+		slog.ErrorContext(ctx, "Hesap özetleri hazırlanamadı", "stored", stored, "error", err)
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Hesap özetleri hazırlandı", "stored", stored)
+	return &StatementsResult{Statements: stored}, nil
+}
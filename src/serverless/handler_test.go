@@ -0,0 +1,35 @@
+package serverless
+
+import (
+	"Ledger/src/queue"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestHandleRequestFailsOnPanic(t *testing.T) {
+	commands := queue.NewProcessor(queue.NewMemoryStore(time.Minute), nil, 3)
+	commands.Register("test", func(ctx context.Context, payload json.RawMessage) error {
+		panic("boom")
+	})
+	env, err := queue.NewEnvelope("m1", "test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := env.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(events.SQSEvent{Records: []events.SQSMessage{{MessageId: "m1", EventSource: "aws:sqs", Body: body}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := NewHandler(nil, commands).HandleRequest(context.Background(), payload)
+	if err == nil {
+		t.Fatalf("got %+v and no error, want the invocation failed", result)
+	}
+}
@@ -0,0 +1,113 @@
+package serverless
+
+import (
+	"Ledger/config"
+	database "Ledger/pkg/db"
+	"Ledger/pkg/metrics"
+	"Ledger/pkg/migrate"
+	"Ledger/pkg/tracing"
+	"Ledger/src/factory"
+	"Ledger/src/queue"
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// SkipDBHost disables the database connection when used as DB_HOST.
+const SkipDBHost = "skip-db-connection"
+
+// Defaults returns the configuration defaults of the lambda deployment.
+func Defaults() config.Config {
+	defaults := config.Default()
+	defaults.DB.Driver = "postgres"
+	defaults.DB.SSLMode = "require"
+	defaults.DB.MaxOpenConns = 5
+	defaults.DB.MaxIdleConns = 2
+	// CloudWatch Logs Insights parses JSON records into fields.
+	defaults.Log.Format = "json"
+	// Nothing can scrape a lambda; its metrics go to CloudWatch as EMF log
+	// lines instead.
+	defaults.Metrics.Enabled = false
+	defaults.Metrics.EMF = true
+	defaults.Tracing.ServiceName = "ledger-lambda"
+	// A lambda is only reached through its events.
+	defaults.GRPC.Addr = ""
+	return defaults
+}
+
+// Setup connects to the database and wires a Handler the way the lambda
+// runs. deadLetter overrides the SQS dead-letter queue named in settings,
+// for local runs. A failed connection is logged and leaves the handler
+// without a factory, as the lambda must still answer.
+func Setup(ctx context.Context, settings *config.Config, deadLetter queue.DeadLetter) (*Handler, error) {
+	traces, err := tracing.Setup(ctx, settings.Tracing)
+	if err != nil {
+		return nil, err
+	}
+
+	var db *sql.DB
+	if dbHost := settings.DB.Host; dbHost != SkipDBHost {
+		db, err = database.OpenSQL(settings.DB)
+		if err != nil {
+			slog.ErrorContext(ctx, "Veritabanı bağlantısı oluşturulamadı", "error", err)
+		} else {
+			slog.InfoContext(ctx, "Veritabanı bağlantısı başarılı", "host", dbHost)
+		}
+
+		if db != nil && settings.DB.AutoMigrate {
+			if err := applyMigrations(ctx, db, settings.DB.Driver); err != nil {
+				return nil, fmt.Errorf("applying migrations: %w", err)
+			}
+		}
+	} else {
+		slog.WarnContext(ctx, "DB_HOST bağlantısız çalışma için ayarlandı", "host", SkipDBHost)
+	}
+
+	if db == nil {
+		slog.ErrorContext(ctx, "Factory oluşturulamadı: veritabanı bağlantısı yok")
+		return instrument(NewHandler(nil, nil), settings, traces), nil
+	}
+
+	appFactory := factory.NewPostgresFactory(db, settings)
+	slog.InfoContext(ctx, "Factory başarıyla oluşturuldu")
+
+	if deadLetter == nil && settings.Queue.DeadLetterURL != "" {
+		dlq, err := queue.NewSQSQueueFromEnv(ctx, settings.Queue.DeadLetterURL)
+		if err != nil {
+			return nil, err
+		}
+		deadLetter = dlq
+	}
+
+	return instrument(NewHandler(appFactory, newCommandProcessor(db, appFactory, settings, deadLetter)), settings, traces), nil
+}
+
+// instrument makes h export its spans through traces and, when settings
+// ask for it, write its metrics as EMF to stdout after every invocation.
+func instrument(h *Handler, settings *config.Config, traces *tracing.Provider) *Handler {
+	h.traces = traces
+	if settings.Metrics.EMF {
+		h.metrics = metrics.NewEMF(os.Stdout, settings.Metrics.Namespace)
+	}
+	return h
+}
+
+func applyMigrations(ctx context.Context, db *sql.DB, driver string) error {
+	migrator, err := migrate.ForDriver(db, driver)
+	if err != nil {
+		return err
+	}
+	return migrator.Up(ctx)
+}
+
+// newCommandProcessor wires the SQS command processor. Claims outlive the
+// visibility timeout of the queue and the timeout of the lambda, so a
+// redelivery never takes over a delivery that may still be running.
+func newCommandProcessor(db *sql.DB, appFactory factory.Factory, settings *config.Config, deadLetter queue.DeadLetter) *queue.Processor {
+	store := queue.NewSQLStore(db, settings.DB.Driver, settings.Queue.ClaimLease)
+	processor := queue.NewProcessor(store, deadLetter, settings.Queue.MaxAttempts)
+	queue.RegisterUserCommands(processor, appFactory.NewUserService(), settings.Limits)
+	return processor
+}
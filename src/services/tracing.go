@@ -0,0 +1,156 @@
+package services
+
+import (
+	"Ledger/pkg/tracing"
+	"Ledger/src/audit"
+	"Ledger/src/models"
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = tracing.Tracer("services")
+
+// tracedUserService starts a span for every call and hands its context to
+// the service it wraps, so the repository, GORM and Redis spans of the call
+// nest under it.
+type tracedUserService struct {
+	next UserService
+}
+
+// Traced returns next with every call traced.
+func Traced(next UserService) UserService {
+	return &tracedUserService{next: next}
+}
+
+func start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "UserService."+name, trace.WithAttributes(attrs...))
+}
+
+func (s *tracedUserService) WithAudit(e audit.Event) UserService {
+	return &tracedUserService{next: s.next.WithAudit(e)}
+}
+
+func (s *tracedUserService) CreateUser(ctx context.Context, user *models.User) error {
+	ctx, span := start(ctx, "CreateUser")
+	err := s.next.CreateUser(ctx, user)
+	tracing.End(span, err)
+	return err
+}
+
+func (s *tracedUserService) GetAllUsers(ctx context.Context) ([]models.User, error) {
+	ctx, span := start(ctx, "GetAllUsers")
+	users, err := s.next.GetAllUsers(ctx)
+	tracing.End(span, err)
+	return users, err
+}
+
+func (s *tracedUserService) GetUserByID(ctx context.Context, id uint) (*models.User, error) {
+	ctx, span := start(ctx, "GetUserByID", tracing.UserID(id))
+	user, err := s.next.GetUserByID(ctx, id)
+	tracing.End(span, err)
+	return user, err
+}
+
+func (s *tracedUserService) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, span := start(ctx, "GetUserByEmail")
+	user, err := s.next.GetUserByEmail(ctx, email)
+	tracing.End(span, err)
+	return user, err
+}
+
+func (s *tracedUserService) GetUserCredit(ctx context.Context, userID uint) (float64, error) {
+	ctx, span := start(ctx, "GetUserCredit", tracing.UserID(userID))
+	credit, err := s.next.GetUserCredit(ctx, userID)
+	tracing.End(span, err)
+	return credit, err
+}
+
+func (s *tracedUserService) SendCredit(ctx context.Context, senderID, receiverID uint, amount float64) error {
+	ctx, span := start(ctx, "SendCredit",
+		attribute.Int64("ledger.sender_id", int64(senderID)),
+		attribute.Int64("ledger.receiver_id", int64(receiverID)),
+		attribute.Float64("ledger.amount", amount),
+	)
+	err := s.next.SendCredit(ctx, senderID, receiverID, amount)
+	tracing.End(span, err)
+	return err
+}
+
+func (s *tracedUserService) GetTransactionLogsBySenderAndDate(ctx context.Context, senderID uint, date string) ([]models.TransactionLog, error) {
+	ctx, span := start(ctx, "GetTransactionLogsBySenderAndDate", tracing.UserID(senderID))
+	logs, err := s.next.GetTransactionLogsBySenderAndDate(ctx, senderID, date)
+	tracing.End(span, err)
+	return logs, err
+}
+
+func (s *tracedUserService) AddCredit(ctx context.Context, userID uint, amount float64) error {
+	ctx, span := start(ctx, "AddCredit", tracing.UserID(userID), attribute.Float64("ledger.amount", amount))
+	err := s.next.AddCredit(ctx, userID, amount)
+	tracing.End(span, err)
+	return err
+}
+
+func (s *tracedUserService) GetAllCredits(ctx context.Context) ([]models.User, error) {
+	ctx, span := start(ctx, "GetAllCredits")
+	users, err := s.next.GetAllCredits(ctx)
+	tracing.End(span, err)
+	return users, err
+}
+
+func (s *tracedUserService) GetMultipleUserCredits(ctx context.Context, userIDs []uint) ([]models.User, error) {
+	ctx, span := start(ctx, "GetMultipleUserCredits", attribute.Int("ledger.users", len(userIDs)))
+	users, err := s.next.GetMultipleUserCredits(ctx, userIDs)
+	tracing.End(span, err)
+	return users, err
+}
+
+func (s *tracedUserService) ProcessBatchCreditUpdate(ctx context.Context, transactions []models.BatchTransaction) []models.BatchTransactionResult {
+	ctx, span := start(ctx, "ProcessBatchCreditUpdate", attribute.Int("ledger.batch_size", len(transactions)))
+	results := s.next.ProcessBatchCreditUpdate(ctx, transactions)
+	tracing.End(span, nil)
+	return results
+}
+
+func (s *tracedUserService) ValidatePassword(ctx context.Context, user *models.User, password string) error {
+	ctx, span := start(ctx, "ValidatePassword", tracing.UserID(user.ID))
+	err := s.next.ValidatePassword(ctx, user, password)
+	tracing.End(span, err)
+	return err
+}
+
+func (s *tracedUserService) SetRole(ctx context.Context, userID uint, role string) error {
+	ctx, span := start(ctx, "SetRole", tracing.UserID(userID))
+	err := s.next.SetRole(ctx, userID, role)
+	tracing.End(span, err)
+	return err
+}
+
+func (s *tracedUserService) ResetPassword(ctx context.Context, userID uint, password string) error {
+	ctx, span := start(ctx, "ResetPassword", tracing.UserID(userID))
+	err := s.next.ResetPassword(ctx, userID, password)
+	tracing.End(span, err)
+	return err
+}
+
+func (s *tracedUserService) AdjustCredit(ctx context.Context, userID uint, amount float64) (float64, float64, error) {
+	ctx, span := start(ctx, "AdjustCredit", tracing.UserID(userID), attribute.Float64("ledger.amount", amount))
+	before, after, err := s.next.AdjustCredit(ctx, userID, amount)
+	tracing.End(span, err)
+	return before, after, err
+}
+
+func (s *tracedUserService) GetTransactionLogsByUser(ctx context.Context, userID uint) ([]models.TransactionLog, error) {
+	ctx, span := start(ctx, "GetTransactionLogsByUser", tracing.UserID(userID))
+	logs, err := s.next.GetTransactionLogsByUser(ctx, userID)
+	tracing.End(span, err)
+	return logs, err
+}
+
+func (s *tracedUserService) GetAllTransactionLogs(ctx context.Context) ([]models.TransactionLog, error) {
+	ctx, span := start(ctx, "GetAllTransactionLogs")
+	logs, err := s.next.GetAllTransactionLogs(ctx)
+	tracing.End(span, err)
+	return logs, err
+}
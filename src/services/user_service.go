@@ -1,19 +1,33 @@
 package services
 
 import (
+	"Ledger/src/audit"
 	"Ledger/src/models"
+	"context"
 )
 
+// UserService is the business logic over a UserRepository. Every method
+// passes ctx down to the repository and logs with it, so a request that is
+// cancelled stops its queries and its records carry the caller's request ID.
 type UserService interface {
-	CreateUser(user *models.User) error
-	GetAllUsers() ([]models.User, error)
-	GetUserByID(id uint) (*models.User, error)
-	GetUserByEmail(email string) (*models.User, error)
-	GetUserCredit(userID uint) (float64, error)
-	SendCredit(senderID, receiverID uint, amount float64) error
-	GetTransactionLogsBySenderAndDate(senderID uint, date string) ([]models.TransactionLog, error)
-	AddCredit(userID uint, amount float64) error
-	GetAllCredits() ([]models.User, error)
-	GetMultipleUserCredits(userIDs []uint) ([]models.User, error)
-	ProcessBatchCreditUpdate(transactions []models.BatchTransaction) []models.BatchTransactionResult
+	CreateUser(ctx context.Context, user *models.User) error
+	GetAllUsers(ctx context.Context) ([]models.User, error)
+	GetUserByID(ctx context.Context, id uint) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	GetUserCredit(ctx context.Context, userID uint) (float64, error)
+	SendCredit(ctx context.Context, senderID, receiverID uint, amount float64) error
+	GetTransactionLogsBySenderAndDate(ctx context.Context, senderID uint, date string) ([]models.TransactionLog, error)
+	AddCredit(ctx context.Context, userID uint, amount float64) error
+	GetAllCredits(ctx context.Context) ([]models.User, error)
+	GetMultipleUserCredits(ctx context.Context, userIDs []uint) ([]models.User, error)
+	ProcessBatchCreditUpdate(ctx context.Context, transactions []models.BatchTransaction) []models.BatchTransactionResult
+	ValidatePassword(ctx context.Context, user *models.User, password string) error
+	SetRole(ctx context.Context, userID uint, role string) error
+	ResetPassword(ctx context.Context, userID uint, password string) error
+	AdjustCredit(ctx context.Context, userID uint, amount float64) (float64, float64, error)
+	GetTransactionLogsByUser(ctx context.Context, userID uint) ([]models.TransactionLog, error)
+	GetAllTransactionLogs(ctx context.Context) ([]models.TransactionLog, error)
+	// WithAudit returns a service whose privileged operations are recorded
+	// in the audit trail as performed by e's actor.
+	WithAudit(e audit.Event) UserService
 }
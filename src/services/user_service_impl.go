@@ -1,8 +1,18 @@
 package services
 
 import (
+	"Ledger/pkg/apperr"
+	"Ledger/pkg/metrics"
+	"Ledger/src/audit"
 	"Ledger/src/models"
 	"Ledger/src/repository"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 type userService struct {
@@ -15,46 +25,148 @@ func NewUserService(repo repository.UserRepository) UserService {
 	}
 }
 
-func (s *userService) CreateUser(user *models.User) error {
-	return s.repo.Create(user)
+func (s *userService) WithAudit(e audit.Event) UserService {
+	return &userService{repo: s.repo.WithAudit(e)}
+}
+
+// CreateUser stores user with its plain text Password replaced by a bcrypt
+// hash.
+func (s *userService) CreateUser(ctx context.Context, user *models.User) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	user.Password = string(hash)
+
+	return s.repo.Create(ctx, user)
+}
+
+func (s *userService) GetAllUsers(ctx context.Context) ([]models.User, error) {
+	return s.repo.GetAll(ctx)
+}
+
+func (s *userService) GetUserByID(ctx context.Context, id uint) (*models.User, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *userService) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.repo.GetByEmail(ctx, email)
+}
+
+func (s *userService) GetUserCredit(ctx context.Context, userID uint) (float64, error) {
+	return s.repo.GetUserCredit(ctx, userID)
+}
+
+func (s *userService) SendCredit(ctx context.Context, senderID, receiverID uint, amount float64) error {
+	err := s.repo.SendCredit(ctx, senderID, receiverID, amount)
+	metrics.ObserveTransfer(transferOutcome(err), amount)
+	if err != nil {
+		slog.InfoContext(ctx, "Credit transfer failed", "sender_id", senderID, "receiver_id", receiverID, "amount", amount, "error", err)
+		return err
+	}
+	slog.InfoContext(ctx, "Credit transferred", "sender_id", senderID, "receiver_id", receiverID, "amount", amount)
+	return nil
+}
+
+// transferOutcome classifies the result of a transfer for the metrics.
+func transferOutcome(err error) string {
+	switch {
+	case err == nil:
+		return metrics.OutcomeSuccess
+	case errors.Is(err, apperr.ErrInsufficientFunds):
+		return metrics.OutcomeInsufficientBalance
+	case errors.Is(err, apperr.ErrUserNotFound):
+		return metrics.OutcomeNotFound
+	}
+	return metrics.OutcomeError
+}
+
+func (s *userService) GetTransactionLogsBySenderAndDate(ctx context.Context, senderID uint, date string) ([]models.TransactionLog, error) {
+	return s.repo.GetTransactionLogsBySenderAndDate(ctx, senderID, date)
 }
 
-func (s *userService) GetAllUsers() ([]models.User, error) {
-	return s.repo.GetAll()
+func (s *userService) AddCredit(ctx context.Context, userID uint, amount float64) error {
+	if amount <= 0 {
+		return apperr.ErrInvalidAmount
+	}
+	return s.repo.AddCredit(ctx, userID, amount)
 }
 
-func (s *userService) GetUserByID(id uint) (*models.User, error) {
-	return s.repo.GetByID(id)
+func (s *userService) GetAllCredits(ctx context.Context) ([]models.User, error) {
+	return s.repo.GetAllCredits(ctx)
 }
 
-func (s *userService) GetUserByEmail(email string) (*models.User, error) {
-	return s.repo.GetByEmail(email)
+func (s *userService) GetMultipleUserCredits(ctx context.Context, userIDs []uint) ([]models.User, error) {
+	return s.repo.GetMultipleUserCredits(ctx, userIDs)
 }
 
-func (s *userService) GetUserCredit(userID uint) (float64, error) {
-	return s.repo.GetUserCredit(userID)
+func (s *userService) ProcessBatchCreditUpdate(ctx context.Context, transactions []models.BatchTransaction) []models.BatchTransactionResult {
+	metrics.ObserveBatch(len(transactions))
+	results := s.repo.ProcessBatchCreditUpdate(ctx, transactions)
+	failed := 0
+	for _, result := range results {
+		if !result.Success {
+			failed++
+		}
+	}
+	slog.InfoContext(ctx, "Batch credit update processed", "entries", len(results), "failed", failed)
+	return results
 }
 
-func (s *userService) SendCredit(senderID, receiverID uint, amount float64) error {
-	return s.repo.SendCredit(senderID, receiverID, amount)
+func (s *userService) SetRole(ctx context.Context, userID uint, role string) error {
+	if role != models.RoleUser && role != models.RoleAdmin {
+		return apperr.ErrInvalidRequest.WithMessage("unknown role %q", role)
+	}
+	return s.repo.UpdateRole(ctx, userID, role)
 }
 
-func (s *userService) GetTransactionLogsBySenderAndDate(senderID uint, date string) ([]models.TransactionLog, error) {
-	return s.repo.GetTransactionLogsBySenderAndDate(senderID, date)
+// ResetPassword replaces the password of a user with a bcrypt hash of
+// password.
+func (s *userService) ResetPassword(ctx context.Context, userID uint, password string) error {
+	if password == "" {
+		return apperr.ErrInvalidRequest.WithMessage("password is empty")
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return s.repo.UpdatePassword(ctx, userID, string(hash))
 }
 
-func (s *userService) AddCredit(userID uint, amount float64) error {
-	return s.repo.AddCredit(userID, amount)
+// AdjustCredit is an operator correction: unlike AddCredit the amount may
+// be negative, but it may not take the balance below zero.
+func (s *userService) AdjustCredit(ctx context.Context, userID uint, amount float64) (float64, float64, error) {
+	if amount == 0 {
+		return 0, 0, apperr.ErrInvalidAmount.WithMessage("amount must not be zero")
+	}
+	return s.repo.AdjustCredit(ctx, userID, amount)
 }
 
-func (s *userService) GetAllCredits() ([]models.User, error) {
-	return s.repo.GetAllCredits()
+func (s *userService) GetTransactionLogsByUser(ctx context.Context, userID uint) ([]models.TransactionLog, error) {
+	return s.repo.GetTransactionLogsByUser(ctx, userID)
 }
 
-func (s *userService) GetMultipleUserCredits(userIDs []uint) ([]models.User, error) {
-	return s.repo.GetMultipleUserCredits(userIDs)
+func (s *userService) GetAllTransactionLogs(ctx context.Context) ([]models.TransactionLog, error) {
+	return s.repo.GetAllTransactionLogs(ctx)
 }
 
-func (s *userService) ProcessBatchCreditUpdate(transactions []models.BatchTransaction) []models.BatchTransactionResult {
-	return s.repo.ProcessBatchCreditUpdate(transactions)
+// ValidatePassword checks password against the stored hash. Rows written by
+// the lambda before passwords were hashed hold plain text and are compared
+// in constant time instead.
+func (s *userService) ValidatePassword(ctx context.Context, user *models.User, password string) error {
+	if user.Password == "" {
+		return apperr.ErrInvalidCredentials
+	}
+
+	if strings.HasPrefix(user.Password, "$2") {
+		if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+			return apperr.ErrInvalidCredentials
+		}
+		return nil
+	}
+
+	if subtle.ConstantTimeCompare([]byte(user.Password), []byte(password)) != 1 {
+		return apperr.ErrInvalidCredentials
+	}
+	return nil
 }
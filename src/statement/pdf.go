@@ -0,0 +1,129 @@
+package statement
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// pdfColumn is one column of the PDF table.
+type pdfColumn struct {
+	title string
+	width float64
+	align string
+}
+
+// The table fills an A4 page between 15 mm margins.
+var pdfColumns = []pdfColumn{
+	{"Date", 26, "L"},
+	{"Description", 42, "L"},
+	{"Counterparty", 46, "L"},
+	{"Credit", 22, "R"},
+	{"Debit", 22, "R"},
+	{"Balance", 22, "R"},
+}
+
+const pdfRowHeight = 6
+
+// transliterate replaces the Turkish letters the PDF core fonts cannot
+// show; the rest of Latin-1 is translated to the fonts' encoding.
+var transliterate = strings.NewReplacer("ş", "s", "Ş", "S", "ğ", "g", "Ğ", "G", "ı", "i", "İ", "I")
+
+// writePDF renders s as a one-table PDF using the core Helvetica font, so
+// no font files are needed.
+func writePDF(w io.Writer, s *Statement) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.SetTitle("Account statement "+s.From+" - "+s.To, true)
+	pdf.SetCreationDate(s.GeneratedAt)
+	pdf.AliasNbPages("")
+	encode := pdf.UnicodeTranslatorFromDescriptor("")
+	text := func(v string) string { return encode(transliterate.Replace(v)) }
+
+	header := func() {
+		pdf.SetFont("Helvetica", "B", 9)
+		pdf.SetFillColor(230, 230, 230)
+		for _, c := range pdfColumns {
+			pdf.CellFormat(c.width, pdfRowHeight, c.title, "B", 0, c.align, true, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont("Helvetica", "", 9)
+	}
+	pdf.SetHeaderFunc(func() {
+		if pdf.PageNo() > 1 {
+			header()
+		}
+	})
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.CellFormat(0, 5, fmt.Sprintf("Page %d/{nb}", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	pdf.AddPage()
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 10, "Account statement", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	for _, row := range [][2]string{
+		{"Account", fmt.Sprintf("%d - %s <%s>", s.UserID, s.Name, s.Email)},
+		{"Period", s.From + " to " + s.To},
+		{"Generated", s.GeneratedAt.Format("2006-01-02 15:04 MST")},
+	} {
+		pdf.CellFormat(30, pdfRowHeight, row[0], "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, pdfRowHeight, text(row[1]), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	header()
+	summary := func(day, label, credit, debit string, balance float64) {
+		pdf.SetFont("Helvetica", "B", 9)
+		cells := []string{day, label, "", credit, debit, amount(balance)}
+		for i, c := range pdfColumns {
+			pdf.CellFormat(c.width, pdfRowHeight, cells[i], "", 0, c.align, false, 0, "")
+		}
+		pdf.Ln(-1)
+		pdf.SetFont("Helvetica", "", 9)
+	}
+	summary(s.From, "Opening balance", "", "", s.OpeningBalance)
+	for _, line := range s.Lines {
+		counterparty := "-"
+		if line.CounterpartyID != 0 {
+			counterparty = strconv.FormatUint(uint64(line.CounterpartyID), 10)
+			if line.Counterparty != "" {
+				counterparty += " " + line.Counterparty
+			}
+		}
+		cells := []string{
+			line.Date.Format("2006-01-02 15:04"),
+			line.Description,
+			counterparty,
+			optionalAmount(line.Credit),
+			optionalAmount(line.Debit),
+			amount(line.Balance),
+		}
+		for i, c := range pdfColumns {
+			pdf.CellFormat(c.width, pdfRowHeight, fit(pdf, text(cells[i]), c.width-2), "", 0, c.align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+	pdf.SetDrawColor(0, 0, 0)
+	pdf.Line(15, pdf.GetY(), 195, pdf.GetY())
+	summary(s.To, "Closing balance", amount(s.TotalCredits), amount(s.TotalDebits), s.ClosingBalance)
+
+	return pdf.Output(w)
+}
+
+// fit shortens v until it fits width, marking the cut with "...".
+func fit(pdf *fpdf.Fpdf, v string, width float64) string {
+	if pdf.GetStringWidth(v) <= width {
+		return v
+	}
+	for len(v) > 0 && pdf.GetStringWidth(v+"...") > width {
+		v = v[:len(v)-1]
+	}
+	return v + "..."
+}
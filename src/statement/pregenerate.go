@@ -0,0 +1,100 @@
+package statement
+
+import (
+	"Ledger/pkg/db"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+// settle is how long after the month ends its statements wait, so
+// transfers dated just before midnight have committed.
+const settle = 5 * time.Minute
+
+// Pregenerate stores the statement of every user for the month month falls
+// on and returns the number stored. Statements stored already are kept, so
+// the call can be repeated.
+func (g *Generator) Pregenerate(ctx context.Context, month time.Time) (int, error) {
+	from, to := Month(month)
+
+	rows, err := g.db.QueryContext(ctx, db.Rebind(g.driver, `SELECT id FROM users WHERE id NOT IN
+		(SELECT user_id FROM account_statements WHERE period_start = ? AND period_end = ?) ORDER BY id`),
+		from.Format(dayLayout), to.Format(dayLayout))
+	if err != nil {
+		return 0, err
+	}
+	var ids []uint
+	for rows.Next() {
+		var id uint
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// Another instance may store the same statement meanwhile.
+	insert := `INSERT IGNORE INTO account_statements (user_id, period_start, period_end, data, created_at)
+		VALUES (?, ?, ?, ?, ?)`
+	if g.driver == "postgres" {
+		insert = `INSERT INTO account_statements (user_id, period_start, period_end, data, created_at)
+		VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`
+	}
+
+	stored := 0
+	for _, id := range ids {
+		s, err := g.Generate(ctx, id, from, to)
+		if errors.Is(err, ErrUserNotFound) {
+			continue
+		}
+		if err != nil {
+			return stored, err
+		}
+		data, err := json.Marshal(s)
+		if err != nil {
+			return stored, err
+		}
+		if _, err := g.db.ExecContext(ctx, db.Rebind(g.driver, insert),
+			id, s.From, s.To, string(data), s.GeneratedAt); err != nil {
+			return stored, err
+		}
+		stored++
+	}
+	return stored, nil
+}
+
+// PregenerateDue stores last month's statements of every user that has
+// none yet.
+func (g *Generator) PregenerateDue(ctx context.Context, now time.Time) (int, error) {
+	first, _ := Month(now.Add(-settle))
+	return g.Pregenerate(ctx, first.AddDate(0, -1, 0))
+}
+
+// Every pre-generates the statements that are due every interval until ctx
+// is done, logging failures. It is how the server schedules the job.
+func (g *Generator) Every(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stored, err := g.PregenerateDue(ctx, time.Now())
+			if err != nil && !errors.Is(err, context.Canceled) {
+				slog.ErrorContext(ctx, "Statement pre-generation failed", "stored", stored, "error", err)
+				continue
+			}
+			if stored > 0 {
+				slog.InfoContext(ctx, "Statements pre-generated", "stored", stored)
+			}
+		}
+	}
+}
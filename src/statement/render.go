@@ -0,0 +1,91 @@
+package statement
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Formats a statement renders to.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+	FormatPDF  = "pdf"
+)
+
+// ContentType returns the media type of format, or "" for an unknown one.
+func ContentType(format string) string {
+	switch format {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatJSON:
+		return "application/json"
+	case FormatPDF:
+		return "application/pdf"
+	}
+	return ""
+}
+
+// FileName is the name a statement is downloaded under.
+func FileName(s *Statement, format string) string {
+	return fmt.Sprintf("statement-%d-%s-%s.%s", s.UserID, s.From, s.To, format)
+}
+
+// Write renders s to w as format.
+func Write(w io.Writer, s *Statement, format string) error {
+	switch format {
+	case FormatCSV:
+		return writeCSV(w, s)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	case FormatPDF:
+		return writePDF(w, s)
+	}
+	return fmt.Errorf("unknown statement format %q, want %s, %s or %s", format, FormatCSV, FormatJSON, FormatPDF)
+}
+
+var csvHeader = []string{"date", "entry_id", "description", "counterparty_id", "counterparty", "credit", "debit", "balance"}
+
+// writeCSV writes one row per line between an opening and a closing
+// balance row, so the file adds up on its own.
+func writeCSV(w io.Writer, s *Statement) error {
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	cw.Write([]string{s.From, "", "Opening balance", "", "", "", "", amount(s.OpeningBalance)})
+	for _, line := range s.Lines {
+		counterpartyID := ""
+		if line.CounterpartyID != 0 {
+			counterpartyID = strconv.FormatUint(uint64(line.CounterpartyID), 10)
+		}
+		cw.Write([]string{
+			line.Date.Format(time.RFC3339),
+			strconv.FormatUint(line.EntryID, 10),
+			line.Description,
+			counterpartyID,
+			line.Counterparty,
+			optionalAmount(line.Credit),
+			optionalAmount(line.Debit),
+			amount(line.Balance),
+		})
+	}
+	cw.Write([]string{s.To, "", "Closing balance", "", "", amount(s.TotalCredits), amount(s.TotalDebits), amount(s.ClosingBalance)})
+	cw.Flush()
+	return cw.Error()
+}
+
+func amount(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// optionalAmount leaves the column of the side a line is not on empty.
+func optionalAmount(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return amount(v)
+}
@@ -0,0 +1,192 @@
+// Package statement builds account statements: the opening balance of a
+// period, every credit and debit in it with its counterparty and running
+// balance, and the closing balance. Statements render to CSV, JSON and PDF.
+// Statements of closed months are pre-generated by a scheduled job and
+// kept in account_statements, since the history they are built from never
+// changes.
+package statement
+
+import (
+	"Ledger/pkg/db"
+	"Ledger/pkg/migrate"
+	"Ledger/src/balance"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"time"
+)
+
+// Tables are the tables pre-generated statements are kept in, for migrate
+// verify.
+var Tables = []migrate.Table{
+	{Name: "account_statements", Columns: []string{"user_id", "period_start", "period_end", "data", "created_at"}},
+}
+
+// ErrUserNotFound is returned for statements of a user that does not exist.
+var ErrUserNotFound = balance.ErrUserNotFound
+
+// dayLayout is how the days of a period are written.
+const dayLayout = "2006-01-02"
+
+// Statement is one account over one period. From and To are UTC days, both
+// included.
+type Statement struct {
+	UserID         uint      `json:"user_id"`
+	Name           string    `json:"name"`
+	Email          string    `json:"email"`
+	From           string    `json:"from"`
+	To             string    `json:"to"`
+	OpeningBalance float64   `json:"opening_balance"`
+	TotalCredits   float64   `json:"total_credits"`
+	TotalDebits    float64   `json:"total_debits"`
+	ClosingBalance float64   `json:"closing_balance"`
+	Lines          []Line    `json:"lines"`
+	GeneratedAt    time.Time `json:"generated_at"`
+}
+
+// Line is one entry of a statement. Exactly one of Credit and Debit is
+// set. Credits and debits of the balance itself have no counterparty.
+type Line struct {
+	EntryID        uint64    `json:"entry_id"`
+	Date           time.Time `json:"date"`
+	Description    string    `json:"description"`
+	CounterpartyID uint      `json:"counterparty_id,omitempty"`
+	Counterparty   string    `json:"counterparty,omitempty"`
+	Credit         float64   `json:"credit"`
+	Debit          float64   `json:"debit"`
+	Balance        float64   `json:"balance"`
+}
+
+// Generator builds statements and keeps the pre-generated ones.
+type Generator struct {
+	db       *sql.DB
+	driver   string
+	balances *balance.Store
+}
+
+func NewGenerator(sqlDB *sql.DB, driver string, balances *balance.Store) *Generator {
+	return &Generator{db: sqlDB, driver: driver, balances: balances}
+}
+
+// Month returns the first and last day of the month t falls on, in UTC.
+func Month(t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	first := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return first, first.AddDate(0, 1, -1)
+}
+
+// Statement returns the statement of userID from the day from to the day
+// to, both included. A pre-generated statement of the period is returned
+// when there is one.
+func (g *Generator) Statement(ctx context.Context, userID uint, from, to time.Time) (*Statement, error) {
+	from, to = balance.StartOfDay(from), balance.StartOfDay(to)
+
+	stored, err := g.stored(ctx, userID, from, to)
+	if err != nil || stored != nil {
+		return stored, err
+	}
+	return g.Generate(ctx, userID, from, to)
+}
+
+// Generate builds the statement of userID from the day from to the day to,
+// both included, from the transaction history.
+func (g *Generator) Generate(ctx context.Context, userID uint, from, to time.Time) (*Statement, error) {
+	from, to = balance.StartOfDay(from), balance.StartOfDay(to)
+	end := to.AddDate(0, 0, 1)
+
+	s := &Statement{
+		UserID:      userID,
+		From:        from.Format(dayLayout),
+		To:          to.Format(dayLayout),
+		Lines:       []Line{},
+		GeneratedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	var name, surname string
+	err := g.db.QueryRowContext(ctx, db.Rebind(g.driver, "SELECT name, surname, email FROM users WHERE id = ?"), userID).
+		Scan(&name, &surname, &s.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.Name = strings.TrimSpace(name + " " + surname)
+
+	opening, err := g.balances.At(ctx, userID, from)
+	if err != nil {
+		return nil, err
+	}
+	s.OpeningBalance = opening.Balance
+
+	rows, err := g.db.QueryContext(ctx, db.Rebind(g.driver, `SELECT t.id, t.transaction_date, COALESCE(t.description, ''),
+		COALESCE(t.sender_id, 0), COALESCE(t.receiver_id, 0), t.amount,
+		COALESCE(u.name, ''), COALESCE(u.surname, ''), COALESCE(u.email, '')
+		FROM transaction_logs t
+		LEFT JOIN users u ON u.id = CASE WHEN t.sender_id = ? THEN t.receiver_id ELSE t.sender_id END
+		WHERE (t.sender_id = ? OR t.receiver_id = ?) AND t.transaction_date >= ? AND t.transaction_date < ?
+		ORDER BY t.id`), userID, userID, userID, from, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	running := s.OpeningBalance
+	for rows.Next() {
+		var line Line
+		var senderID, receiverID uint
+		var amount float64
+		var partyName, partySurname, partyEmail string
+		if err := rows.Scan(&line.EntryID, &line.Date, &line.Description, &senderID, &receiverID, &amount,
+			&partyName, &partySurname, &partyEmail); err != nil {
+			return nil, err
+		}
+		line.Date = line.Date.UTC()
+		if senderID == userID {
+			line.Debit, line.CounterpartyID = amount, receiverID
+		} else {
+			line.Credit, line.CounterpartyID = amount, senderID
+		}
+		if line.CounterpartyID != 0 {
+			line.Counterparty = strings.TrimSpace(partyName + " " + partySurname)
+			if line.Counterparty == "" {
+				line.Counterparty = partyEmail
+			}
+		}
+		running = round(running + line.Credit - line.Debit)
+		line.Balance = running
+		s.TotalCredits = round(s.TotalCredits + line.Credit)
+		s.TotalDebits = round(s.TotalDebits + line.Debit)
+		s.Lines = append(s.Lines, line)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	s.ClosingBalance = running
+	return s, nil
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// stored returns the pre-generated statement of the period, or nil.
+func (g *Generator) stored(ctx context.Context, userID uint, from, to time.Time) (*Statement, error) {
+	var data string
+	err := g.db.QueryRowContext(ctx, db.Rebind(g.driver,
+		"SELECT data FROM account_statements WHERE user_id = ? AND period_start = ? AND period_end = ?"),
+		userID, from.Format(dayLayout), to.Format(dayLayout)).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s Statement
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
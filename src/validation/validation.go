@@ -0,0 +1,222 @@
+// Package validation checks request structs against the rules in their
+// validate tags, so the HTTP handlers, the lambda and the queue commands
+// accept the same requests.
+//
+// A tag is a comma separated list of rules, applied in order; a field stops
+// at its first failing rule:
+//
+//	trim       trims surrounding spaces from a string, in place
+//	required   the field is not empty or zero
+//	email      a plain address, not blank; trimmed and lowercased in place
+//	min=N      at least N characters, entries or N itself
+//	max=N      at most N characters, entries or N itself
+//	amount     a finite amount above zero with at most two decimals, up
+//	           to limits.max_amount
+//	delta      like amount, but either sign and not zero
+//	batch      at most limits.max_batch_size entries
+//	nefield=F  differs from the field F of the same struct
+//
+// Slices of structs are checked entry by entry. Fields are reported by
+// their JSON names, such as transactions[2].amount.
+package validation
+
+import (
+	"Ledger/config"
+	"Ledger/pkg/apperr"
+	"fmt"
+	"math"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Decimals is the precision of amounts.
+const Decimals = 2
+
+type Validator struct {
+	limits config.LimitsConfig
+}
+
+func New(limits config.LimitsConfig) *Validator {
+	return &Validator{limits: limits}
+}
+
+// Struct checks the struct ptr points to, normalising fields in place. A
+// request with invalid fields is an apperr.ErrValidationFailed listing them.
+func (v *Validator) Struct(ptr interface{}) error {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validation: %T is not a pointer to a struct", ptr))
+	}
+	var fields []apperr.FieldError
+	v.walk(rv.Elem(), "", &fields)
+	if len(fields) > 0 {
+		return apperr.ErrValidationFailed.WithFields(fields)
+	}
+	return nil
+}
+
+func (v *Validator) walk(s reflect.Value, prefix string, fields *[]apperr.FieldError) {
+	t := s.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		name := prefix + jsonName(sf)
+		f := s.Field(i)
+
+		if tag := sf.Tag.Get("validate"); tag != "" {
+			if msg := v.check(s, f, tag); msg != "" {
+				*fields = append(*fields, apperr.FieldError{Field: name, Message: msg})
+				continue
+			}
+		}
+
+		switch {
+		case f.Kind() == reflect.Struct:
+			v.walk(f, name+".", fields)
+		case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.Struct:
+			for j := 0; j < f.Len(); j++ {
+				v.walk(f.Index(j), fmt.Sprintf("%s[%d].", name, j), fields)
+			}
+		}
+	}
+}
+
+// check applies the rules of tag to f, a field of s, and returns why the
+// first failing rule failed.
+func (v *Validator) check(s, f reflect.Value, tag string) string {
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+		var msg string
+		switch name {
+		case "trim":
+			f.SetString(strings.TrimSpace(f.String()))
+		case "required":
+			if f.IsZero() || (f.Kind() == reflect.Slice && f.Len() == 0) {
+				msg = "is required"
+			}
+		case "email":
+			msg = normaliseEmail(f)
+		case "min":
+			msg = checkSize(f, mustFloat(arg), false)
+		case "max":
+			msg = checkSize(f, mustFloat(arg), true)
+		case "amount":
+			msg = v.checkAmount(f.Float(), false)
+		case "delta":
+			msg = v.checkAmount(f.Float(), true)
+		case "batch":
+			if f.Len() > v.limits.MaxBatchSize {
+				msg = fmt.Sprintf("must have at most %d entries", v.limits.MaxBatchSize)
+			}
+		case "nefield":
+			other, ok := s.Type().FieldByName(arg)
+			if !ok {
+				panic("validation: no field " + arg + " in " + s.Type().String())
+			}
+			if f.Interface() == s.FieldByIndex(other.Index).Interface() {
+				msg = "must differ from " + jsonName(other)
+			}
+		default:
+			panic("validation: unknown rule " + name)
+		}
+		if msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+func normaliseEmail(f reflect.Value) string {
+	email := strings.ToLower(strings.TrimSpace(f.String()))
+	f.SetString(email)
+	if email == "" {
+		// required saw the untrimmed value, so blanks are refused here.
+		return "is required"
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || !strings.Contains(email[strings.LastIndexByte(email, '@'):], ".") {
+		return "must be a valid email address"
+	}
+	return ""
+}
+
+// checkSize bounds the length of a string or slice, or the value of a
+// number, by n.
+func checkSize(f reflect.Value, n float64, upper bool) string {
+	var size float64
+	var unit string
+	switch f.Kind() {
+	case reflect.String:
+		size, unit = float64(utf8.RuneCountInString(f.String())), " characters"
+	case reflect.Slice:
+		size, unit = float64(f.Len()), " entries"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		size = float64(f.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		size = float64(f.Uint())
+	case reflect.Float32, reflect.Float64:
+		size = f.Float()
+	default:
+		panic("validation: min and max do not apply to " + f.Type().String())
+	}
+
+	bound := strconv.FormatFloat(n, 'f', -1, 64)
+	switch {
+	case upper && size > n:
+		if unit == "" {
+			return "must be at most " + bound
+		}
+		return "must have at most " + bound + unit
+	case !upper && size < n:
+		if unit == "" {
+			return "must be at least " + bound
+		}
+		return "must have at least " + bound + unit
+	}
+	return ""
+}
+
+// checkAmount checks an amount of money. A delta may be negative; an amount
+// may not.
+func (v *Validator) checkAmount(amount float64, delta bool) string {
+	switch {
+	case math.IsNaN(amount) || math.IsInf(amount, 0):
+		return "must be a finite number"
+	case delta && amount == 0:
+		return "must not be zero"
+	case !delta && amount <= 0:
+		return "must be greater than zero"
+	case math.Abs(amount) > v.limits.MaxAmount:
+		limit := strconv.FormatFloat(v.limits.MaxAmount, 'f', -1, 64)
+		if delta {
+			return "must be between -" + limit + " and " + limit
+		}
+		return "must not exceed " + limit
+	}
+	digits := strconv.FormatFloat(amount, 'f', -1, 64)
+	if i := strings.IndexByte(digits, '.'); i >= 0 && len(digits)-i-1 > Decimals {
+		return fmt.Sprintf("must have at most %d decimal places", Decimals)
+	}
+	return ""
+}
+
+func mustFloat(s string) float64 {
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		panic("validation: bad bound " + s)
+	}
+	return n
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return f.Name
+	}
+	return name
+}
@@ -0,0 +1,104 @@
+package validation_test
+
+import (
+	"Ledger/config"
+	"Ledger/pkg/apperr"
+	"Ledger/src/models"
+	"Ledger/src/validation"
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// transfer mirrors the transfer requests of the handlers and the queue.
+type transfer struct {
+	SenderID   uint    `json:"sender_id" validate:"required"`
+	ReceiverID uint    `json:"receiver_id" validate:"required,nefield=SenderID"`
+	Amount     float64 `json:"amount" validate:"amount"`
+}
+
+type batch struct {
+	Transactions []models.BatchTransaction `json:"transactions" validate:"required,batch"`
+}
+
+func register(email string) *models.RegisterRequest {
+	return &models.RegisterRequest{Name: "Ada", Surname: "Lovelace", Age: 36, Email: email, Password: "analytical"}
+}
+
+func TestStruct(t *testing.T) {
+	limits := config.Default().Limits
+	limits.MaxAmount = 1000
+	limits.MaxBatchSize = 2
+	v := validation.New(limits)
+
+	tests := []struct {
+		name string
+		req  interface{}
+		// want maps each invalid field to its message; nil for a valid
+		// request.
+		want map[string]string
+	}{
+		{"valid transfer", &transfer{SenderID: 1, ReceiverID: 2, Amount: 10.5}, nil},
+		{"self-transfer", &transfer{SenderID: 1, ReceiverID: 1, Amount: 10}, map[string]string{"receiver_id": "must differ from sender_id"}},
+		{"missing sender", &transfer{ReceiverID: 2, Amount: 10}, map[string]string{"sender_id": "is required"}},
+		{"zero amount", &transfer{SenderID: 1, ReceiverID: 2}, map[string]string{"amount": "must be greater than zero"}},
+		{"negative amount", &transfer{SenderID: 1, ReceiverID: 2, Amount: -5}, map[string]string{"amount": "must be greater than zero"}},
+		{"NaN amount", &transfer{SenderID: 1, ReceiverID: 2, Amount: math.NaN()}, map[string]string{"amount": "must be a finite number"}},
+		{"infinite amount", &transfer{SenderID: 1, ReceiverID: 2, Amount: math.Inf(1)}, map[string]string{"amount": "must be a finite number"}},
+		{"negative infinite amount", &transfer{SenderID: 1, ReceiverID: 2, Amount: math.Inf(-1)}, map[string]string{"amount": "must be a finite number"}},
+		{"amount above the limit", &transfer{SenderID: 1, ReceiverID: 2, Amount: 1000.01}, map[string]string{"amount": "must not exceed 1000"}},
+		{"amount with three decimals", &transfer{SenderID: 1, ReceiverID: 2, Amount: 1.005}, map[string]string{"amount": "must have at most 2 decimal places"}},
+		{"every bad field", &transfer{SenderID: 0, ReceiverID: 0, Amount: 0}, map[string]string{"sender_id": "is required", "receiver_id": "is required", "amount": "must be greater than zero"}},
+
+		{"valid registration", register("ada@example.com"), nil},
+		{"empty email", register(""), map[string]string{"email": "is required"}},
+		{"blank email", register("   "), map[string]string{"email": "is required"}},
+		{"malformed email", register("ada@example"), map[string]string{"email": "must be a valid email address"}},
+		{"named email", register("Ada <ada@example.com>"), map[string]string{"email": "must be a valid email address"}},
+		{"blank name", &models.RegisterRequest{Name: "  ", Surname: "Lovelace", Age: 36, Email: "ada@example.com", Password: "analytical"}, map[string]string{"name": "is required"}},
+		{"short password", &models.RegisterRequest{Name: "Ada", Surname: "Lovelace", Age: 36, Email: "ada@example.com", Password: "short"}, map[string]string{"password": "must have at least 8 characters"}},
+		{"age out of range", &models.RegisterRequest{Name: "Ada", Surname: "Lovelace", Age: 151, Email: "ada@example.com", Password: "analytical"}, map[string]string{"age": "must be at most 150"}},
+
+		{"valid batch", &batch{Transactions: []models.BatchTransaction{{UserID: 1, Amount: -5}, {UserID: 2, Amount: 5}}}, nil},
+		{"empty batch", &batch{}, map[string]string{"transactions": "is required"}},
+		{"batch above the limit", &batch{Transactions: make([]models.BatchTransaction, 3)}, map[string]string{"transactions": "must have at most 2 entries"}},
+		{"zero delta", &batch{Transactions: []models.BatchTransaction{{UserID: 1, Amount: 5}, {UserID: 2}}}, map[string]string{"transactions[1].amount": "must not be zero"}},
+		{"NaN delta", &batch{Transactions: []models.BatchTransaction{{UserID: 1, Amount: math.NaN()}}}, map[string]string{"transactions[0].amount": "must be a finite number"}},
+		{"delta above the limit", &batch{Transactions: []models.BatchTransaction{{UserID: 1, Amount: -1001}}}, map[string]string{"transactions[0].amount": "must be between -1000 and 1000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := v.Struct(tt.req)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("got %v, want valid", err)
+				}
+				return
+			}
+
+			var appErr *apperr.Error
+			if !errors.As(err, &appErr) || !errors.Is(err, apperr.ErrValidationFailed) {
+				t.Fatalf("got %v, want %v", err, apperr.ErrValidationFailed)
+			}
+			got := make(map[string]string, len(appErr.Fields))
+			for _, f := range appErr.Fields {
+				got[f.Field] = f.Message
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got fields %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructNormalises(t *testing.T) {
+	req := register("  Ada@Example.COM ")
+	req.Name = "  Ada "
+	if err := validation.New(config.Default().Limits).Struct(req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Email != "ada@example.com" || req.Name != "Ada" {
+		t.Errorf("got email %q and name %q, want them trimmed and the email lowercased", req.Email, req.Name)
+	}
+}
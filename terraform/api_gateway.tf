@@ -68,10 +68,17 @@ resource "aws_api_gateway_integration" "add_user_sqs_integration" {
     "integration.request.header.Content-Type" = "'application/x-www-form-urlencoded'"
   }
 
+  # İstekteki W3C traceparent başlığı, izin lambdada sürmesi için mesaj
+  # özniteliği olarak taşınır. SQS boş öznitelik kabul etmediğinden yalnızca
+  # başlık varsa eklenir.
   request_templates = {
     "application/json" = <<EOF
+#set($traceparent = $input.params().header.get('traceparent'))
 Action=SendMessage
-&MessageBody=$util.urlEncode("{\"path\": \"/users/add-user\", \"httpMethod\": \"POST\", \"body\": $input.json('$'), \"headers\": {#foreach($header in $input.params().header.keySet())\"$header\": \"$util.escapeJavaScript($input.params().header.get($header))\"#if($foreach.hasNext),#end#end}}")
+&MessageBody=$util.urlEncode("{\"version\": 1, \"id\": \"$context.requestId\", \"command\": \"create_user\", \"payload\": $input.json('$')}")
+#if($traceparent && $traceparent != "")
+&MessageAttribute.1.Name=traceparent&MessageAttribute.1.Value.DataType=String&MessageAttribute.1.Value.StringValue=$util.urlEncode($traceparent)
+#end
 EOF
   }
 }
@@ -209,6 +216,61 @@ resource "aws_api_gateway_integration" "get_credit_lambda_integration" {
   uri                     = aws_lambda_function.ledger_processor.invoke_arn
 }
 
+# /v1 altındaki tüm yollar lambdaya olduğu gibi iletilir; yönlendirme
+# uygulamanın kendi router'ında yapılır.
+resource "aws_api_gateway_resource" "v1" {
+  rest_api_id = aws_api_gateway_rest_api.ledger_api.id
+  parent_id   = aws_api_gateway_rest_api.ledger_api.root_resource_id
+  path_part   = "v1"
+}
+
+resource "aws_api_gateway_resource" "v1_proxy" {
+  rest_api_id = aws_api_gateway_rest_api.ledger_api.id
+  parent_id   = aws_api_gateway_resource.v1.id
+  path_part   = "{proxy+}"
+}
+
+resource "aws_api_gateway_method" "v1_proxy_any" {
+  rest_api_id   = aws_api_gateway_rest_api.ledger_api.id
+  resource_id   = aws_api_gateway_resource.v1_proxy.id
+  http_method   = "ANY"
+  authorization = "NONE"
+  api_key_required = true
+}
+
+resource "aws_api_gateway_integration" "v1_proxy_lambda_integration" {
+  rest_api_id             = aws_api_gateway_rest_api.ledger_api.id
+  resource_id             = aws_api_gateway_resource.v1_proxy.id
+  http_method             = aws_api_gateway_method.v1_proxy_any.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = aws_lambda_function.ledger_processor.invoke_arn
+}
+
+# OpenAPI belgesi
+resource "aws_api_gateway_resource" "openapi" {
+  rest_api_id = aws_api_gateway_rest_api.ledger_api.id
+  parent_id   = aws_api_gateway_rest_api.ledger_api.root_resource_id
+  path_part   = "openapi.json"
+}
+
+resource "aws_api_gateway_method" "openapi_get" {
+  rest_api_id   = aws_api_gateway_rest_api.ledger_api.id
+  resource_id   = aws_api_gateway_resource.openapi.id
+  http_method   = "GET"
+  authorization = "NONE"
+  api_key_required = true
+}
+
+resource "aws_api_gateway_integration" "openapi_lambda_integration" {
+  rest_api_id             = aws_api_gateway_rest_api.ledger_api.id
+  resource_id             = aws_api_gateway_resource.openapi.id
+  http_method             = aws_api_gateway_method.openapi_get.http_method
+  integration_http_method = "POST"
+  type                    = "AWS_PROXY"
+  uri                     = aws_lambda_function.ledger_processor.invoke_arn
+}
+
 # API Key tanımı
 resource "aws_api_gateway_api_key" "api_key" {
   name        = "${var.app_name}-api-key-${var.environment}"
@@ -249,6 +311,34 @@ resource "aws_api_gateway_usage_plan_key" "usage_plan_key" {
   usage_plan_id = aws_api_gateway_usage_plan.usage_plan.id
 }
 
+# API Gateway'in kendi hataları (geçersiz API anahtarı, kısıtlama, 5xx)
+# da lambdanın döndürdüğü RFC 7807 biçiminde ve aynı hata kodlarıyla döner.
+locals {
+  gateway_problems = {
+    DEFAULT_4XX     = "invalid_request"
+    DEFAULT_5XX     = "unavailable"
+    INVALID_API_KEY = "forbidden"
+    THROTTLED       = "rate_limited"
+    QUOTA_EXCEEDED  = "rate_limited"
+  }
+}
+
+resource "aws_api_gateway_gateway_response" "problem" {
+  for_each      = local.gateway_problems
+  rest_api_id   = aws_api_gateway_rest_api.ledger_api.id
+  response_type = each.key
+
+  response_parameters = {
+    "gatewayresponse.header.Content-Type" = "'application/problem+json'"
+  }
+
+  response_templates = {
+    "application/json" = <<EOF
+{"type": "urn:ledger:error:${each.value}", "title": $context.error.messageString, "code": "${each.value}", "request_id": "$context.requestId"}
+EOF
+  }
+}
+
 # API Gateway dağıtımı
 resource "aws_api_gateway_deployment" "api_deployment" {
   rest_api_id = aws_api_gateway_rest_api.ledger_api.id
@@ -261,18 +351,25 @@ resource "aws_api_gateway_deployment" "api_deployment" {
       aws_api_gateway_resource.register.id,
       aws_api_gateway_resource.get_credit.id,
       aws_api_gateway_resource.send_credit.id,
+      aws_api_gateway_resource.v1_proxy.id,
+      aws_api_gateway_resource.openapi.id,
       aws_api_gateway_method.root_method.id,
       aws_api_gateway_method.add_user_post.id,
       aws_api_gateway_method.login_post.id,
       aws_api_gateway_method.register_post.id,
       aws_api_gateway_method.get_credit_get.id,
       aws_api_gateway_method.send_credit_post.id,
+      aws_api_gateway_method.v1_proxy_any.id,
+      aws_api_gateway_method.openapi_get.id,
       aws_api_gateway_integration.root_integration.id,
       aws_api_gateway_integration.add_user_sqs_integration.id,
       aws_api_gateway_integration.login_lambda_integration.id,
       aws_api_gateway_integration.register_lambda_integration.id,
       aws_api_gateway_integration.get_credit_lambda_integration.id,
-      aws_api_gateway_integration.send_credit_lambda_integration.id
+      aws_api_gateway_integration.send_credit_lambda_integration.id,
+      aws_api_gateway_integration.v1_proxy_lambda_integration.id,
+      aws_api_gateway_integration.openapi_lambda_integration.id,
+      aws_api_gateway_gateway_response.problem
     ]))
   }
   
@@ -292,7 +389,11 @@ resource "aws_api_gateway_deployment" "api_deployment" {
     aws_api_gateway_method.get_credit_get,
     aws_api_gateway_integration.get_credit_lambda_integration,
     aws_api_gateway_method.send_credit_post,
-    aws_api_gateway_integration.send_credit_lambda_integration
+    aws_api_gateway_integration.send_credit_lambda_integration,
+    aws_api_gateway_method.v1_proxy_any,
+    aws_api_gateway_integration.v1_proxy_lambda_integration,
+    aws_api_gateway_method.openapi_get,
+    aws_api_gateway_integration.openapi_lambda_integration
   ]
 }
 
@@ -101,18 +101,28 @@ resource "aws_sqs_queue" "dlq" {
   }
 }
 
+# The lambda holds a claim on a message for QUEUE_CLAIM_LEASE, which must
+# outlive both timeouts; the lambda refuses to start otherwise.
+locals {
+  queue_visibility_timeout_seconds = 60
+  lambda_timeout_seconds           = 30
+  queue_claim_lease                = "2m"
+}
+
 # SQS kuyruğu
 resource "aws_sqs_queue" "ledger_queue" {
   name                       = "${var.app_name}-queue-${var.environment}"
   delay_seconds              = 0
   max_message_size           = 262144
   message_retention_seconds  = 345600 # 4 days
-  visibility_timeout_seconds = 60
+  visibility_timeout_seconds = local.queue_visibility_timeout_seconds
   receive_wait_time_seconds  = 10
   
   redrive_policy = jsonencode({
     deadLetterTargetArn = aws_sqs_queue.dlq.arn
-    maxReceiveCount     = 3
+    # The lambda dead-letters failing messages itself after
+    # QUEUE_MAX_ATTEMPTS deliveries; this is only a backstop.
+    maxReceiveCount     = 5
   })
 
   tags = {
@@ -140,7 +150,7 @@ resource "aws_lambda_function" "ledger_processor" {
   handler           = "bootstrap"
   source_code_hash  = filebase64sha256(var.lambda_zip_path)
   runtime           = "provided.al2"
-  timeout           = 30
+  timeout           = local.lambda_timeout_seconds
   memory_size       = 128
 
   vpc_config {
@@ -157,6 +167,16 @@ resource "aws_lambda_function" "ledger_processor" {
       DB_NAME        = var.db_name
       DB_USER        = var.db_username
       DB_PASSWORD    = var.db_password
+      JWT_SECRET_KEY = var.jwt_secret_key
+      LEDGER_CHECKPOINT_KEY = var.ledger_checkpoint_key
+      DB_AUTO_MIGRATE = "true"
+      SQS_DLQ_URL    = aws_sqs_queue.dlq.url
+      QUEUE_MAX_ATTEMPTS = "3"
+      QUEUE_CLAIM_LEASE      = local.queue_claim_lease
+      SQS_VISIBILITY_TIMEOUT = "${local.queue_visibility_timeout_seconds}s"
+      LAMBDA_TIMEOUT         = "${local.lambda_timeout_seconds}s"
+      TRACING_EXPORTER      = var.tracing_otlp_endpoint == "" ? "none" : "otlp"
+      TRACING_OTLP_ENDPOINT = var.tracing_otlp_endpoint
     }
   }
 
@@ -173,6 +193,83 @@ resource "aws_lambda_event_source_mapping" "sqs_trigger" {
   function_name    = aws_lambda_function.ledger_processor.arn
   batch_size       = 5
   enabled          = true
+
+  function_response_types = ["ReportBatchItemFailures"]
+}
+
+# Mutabakat zamanlaması: bakiyeler işlem geçmişiyle düzenli olarak karşılaştırılır
+resource "aws_cloudwatch_event_rule" "reconcile_schedule" {
+  name                = "${var.app_name}-reconcile-${var.environment}"
+  schedule_expression = var.reconcile_schedule
+
+  tags = {
+    Environment = var.environment
+    Name        = "${var.app_name}-reconcile-${var.environment}"
+  }
+}
+
+resource "aws_cloudwatch_event_target" "reconcile_lambda" {
+  rule  = aws_cloudwatch_event_rule.reconcile_schedule.name
+  arn   = aws_lambda_function.ledger_processor.arn
+  input = jsonencode({ source = "aws.events", "detail-type" = "Scheduled Event", detail = { job = "reconcile", repair_cache = false } })
+}
+
+resource "aws_lambda_permission" "reconcile_schedule" {
+  statement_id  = "AllowReconcileSchedule"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.ledger_processor.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.reconcile_schedule.arn
+}
+
+# Gün sonu bakiye anlık görüntüleri: geçmiş bakiye sorguları buradan başlar
+resource "aws_cloudwatch_event_rule" "snapshot_schedule" {
+  name                = "${var.app_name}-balance-snapshot-${var.environment}"
+  schedule_expression = var.snapshot_schedule
+
+  tags = {
+    Environment = var.environment
+    Name        = "${var.app_name}-balance-snapshot-${var.environment}"
+  }
+}
+
+resource "aws_cloudwatch_event_target" "snapshot_lambda" {
+  rule  = aws_cloudwatch_event_rule.snapshot_schedule.name
+  arn   = aws_lambda_function.ledger_processor.arn
+  input = jsonencode({ source = "aws.events", "detail-type" = "Scheduled Event", detail = { job = "snapshot" } })
+}
+
+resource "aws_lambda_permission" "snapshot_schedule" {
+  statement_id  = "AllowSnapshotSchedule"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.ledger_processor.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.snapshot_schedule.arn
+}
+
+# Aylık hesap özetleri: geçen ayın özetleri önceden hazırlanır
+resource "aws_cloudwatch_event_rule" "statement_schedule" {
+  name                = "${var.app_name}-statements-${var.environment}"
+  schedule_expression = var.statement_schedule
+
+  tags = {
+    Environment = var.environment
+    Name        = "${var.app_name}-statements-${var.environment}"
+  }
+}
+
+resource "aws_cloudwatch_event_target" "statement_lambda" {
+  rule  = aws_cloudwatch_event_rule.statement_schedule.name
+  arn   = aws_lambda_function.ledger_processor.arn
+  input = jsonencode({ source = "aws.events", "detail-type" = "Scheduled Event", detail = { job = "statements" } })
+}
+
+resource "aws_lambda_permission" "statement_schedule" {
+  statement_id  = "AllowStatementSchedule"
+  action        = "lambda:InvokeFunction"
+  function_name = aws_lambda_function.ledger_processor.function_name
+  principal     = "events.amazonaws.com"
+  source_arn    = aws_cloudwatch_event_rule.statement_schedule.arn
 }
 
 # API Gateway için IAM rolü (SQS'e mesaj gönderebilmesi için)
@@ -53,6 +53,39 @@ resource "aws_route_table_association" "private" {
   route_table_id = aws_route_table.private.id
 }
 
+# VPC içindeki lambda'nın DLQ'ya mesaj gönderebilmesi için SQS endpoint'i
+resource "aws_security_group" "sqs_endpoint_sg" {
+  name        = "${var.app_name}-sqs-endpoint-sg-${var.environment}"
+  description = "HTTPS from the VPC to the SQS endpoint"
+  vpc_id      = aws_vpc.main.id
+
+  ingress {
+    from_port   = 443
+    to_port     = 443
+    protocol    = "tcp"
+    cidr_blocks = [var.vpc_cidr]
+  }
+
+  tags = {
+    Name        = "${var.app_name}-sqs-endpoint-sg-${var.environment}"
+    Environment = var.environment
+  }
+}
+
+resource "aws_vpc_endpoint" "sqs" {
+  vpc_id              = aws_vpc.main.id
+  service_name        = "com.amazonaws.${var.region}.sqs"
+  vpc_endpoint_type   = "Interface"
+  subnet_ids          = aws_subnet.private[*].id
+  security_group_ids  = [aws_security_group.sqs_endpoint_sg.id]
+  private_dns_enabled = true
+
+  tags = {
+    Name        = "${var.app_name}-sqs-endpoint-${var.environment}"
+    Environment = var.environment
+  }
+}
+
 # Security Group
 resource "aws_security_group" "db_sg" {
   name        = "${var.app_name}-db-sg-${var.environment}"
@@ -169,7 +202,10 @@ resource "null_resource" "db_setup" {
   depends_on = [aws_db_instance.ledger_db]
 
   provisioner "local-exec" {
-    command = "PGPASSWORD=${var.db_password} psql -h ${aws_db_instance.ledger_db.address} -U ${var.db_username} -d ${var.db_name} -a -f ${path.module}/init_db.sql"
+    command = <<EOT
+cd ${path.module}/.. && go run ./cmd migrate up -db-driver postgres -db-ssl-mode require -db-host ${aws_db_instance.ledger_db.address} -db-user ${var.db_username} -db-password ${var.db_password} -db-name ${var.db_name}
+PGPASSWORD=${var.db_password} psql -h ${aws_db_instance.ledger_db.address} -U ${var.db_username} -d ${var.db_name} -a -f ${path.module}/init_db.sql
+EOT
   }
 
   # RDS yapılandırması değişirse yeniden çalıştır
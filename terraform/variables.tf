@@ -40,8 +40,43 @@ variable "db_password" {
   sensitive   = true
 }
 
+variable "jwt_secret_key" {
+  description = "JWT imzalama anahtarı"
+  type        = string
+  sensitive   = true
+}
+
+variable "ledger_checkpoint_key" {
+  description = "Zincir kontrol noktalarını imzalayan HMAC anahtarı"
+  type        = string
+  sensitive   = true
+}
+
 variable "vpc_cidr" {
   description = "VPC için CIDR bloğu"
   type        = string
   default     = "10.0.0.0/16"
-} 
\ No newline at end of file
+} 
+variable "reconcile_schedule" {
+  description = "Mutabakat işinin EventBridge zamanlama ifadesi"
+  type        = string
+  default     = "rate(1 hour)"
+}
+
+variable "snapshot_schedule" {
+  description = "Gün sonu bakiye anlık görüntüsü işinin EventBridge zamanlama ifadesi"
+  type        = string
+  default     = "cron(10 0 * * ? *)"
+}
+
+variable "statement_schedule" {
+  description = "Geçen ayın hesap özetlerini hazırlayan işin EventBridge zamanlama ifadesi; eksik kalan özetler sonraki çalışmada tamamlanır"
+  type        = string
+  default     = "cron(30 * 1 * ? *)"
+}
+
+variable "tracing_otlp_endpoint" {
+  description = "İzlerin gönderileceği OTLP/HTTP toplayıcısının host:port adresi; boş bırakılırsa izleme kapalıdır"
+  type        = string
+  default     = ""
+}